@@ -0,0 +1,200 @@
+package a11y
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for value, expected := range map[string]Color{
+		"rgb(1, 2, 3)":            {R: 1, G: 2, B: 3, A: 1},
+		"rgba(1, 2, 3, 0.5)":      {R: 1, G: 2, B: 3, A: 0.5},
+		"rgb(100% 0% 0% / 25%)":   {R: 255, G: 0, B: 0, A: 0.25},
+		"#fff":                    {R: 255, G: 255, B: 255, A: 1},
+		"#00ff0080":               {R: 0, G: 255, B: 0, A: 128.0 / 255},
+		" Transparent ":           {},
+		"RGBA(10,20,30,1)":        {R: 10, G: 20, B: 30, A: 1},
+		"rgb(255.5, 12.25, 0.75)": {R: 255.5, G: 12.25, B: 0.75, A: 1},
+	} {
+		color, err := ParseColor(value)
+		if nil != err {
+			t.Errorf("Could not parse %q: %s", value, err)
+		} else if expected != color {
+			t.Errorf("Expected %v for %q, received %v", expected, value, color)
+		}
+	}
+	for _, value := range []string{"red", "#12345", "rgb(1, 2)", "rgb(a, b, c)", "hsl(0, 0%, 0%)"} {
+		if _, err := ParseColor(value); nil == err {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	for _, test := range []struct {
+		foreground, background string
+		ratio                  float64
+	}{
+		{"#000", "#fff", 21},
+		{"#fff", "#fff", 1},
+		{"#777", "#fff", 4.48},
+		{"rgb(118, 118, 118)", "white", 0},
+		{"#000", "transparent", 21},
+		{"rgba(0, 0, 0, 0.5)", "#fff", 3.98},
+	} {
+		ratio, err := ContrastRatio(test.foreground, test.background)
+		if 0 == test.ratio {
+			if nil == err {
+				t.Errorf("Expected an error for %s on %s", test.foreground, test.background)
+			}
+			continue
+		}
+		if nil != err || math.Abs(ratio-test.ratio) > 0.01 {
+			t.Errorf("Expected %.2f for %s on %s, received %.2f (%v)", test.ratio, test.foreground, test.background, ratio, err)
+		}
+	}
+}
+
+func testTree() *Tree {
+	tree := &Tree{URL: "https://example.com/"}
+	root := &Node{ID: "1", Role: "RootWebArea", Name: "Test"}
+	tree.Roots = []*Node{root}
+	add := func(role, name string, element *Element) *Node {
+		node := &Node{ID: element.Path, Role: role, Name: name, Element: element, Parent: root, Properties: map[string]interface{}{}}
+		element.Node = node
+		root.Children = append(root.Children, node)
+		return node
+	}
+	element := func(tag, path string, attributes ...string) *Element {
+		element := &Element{BackendNodeID: len(tree.Elements) + 1, Tag: tag, Path: path, Attributes: map[string]string{}}
+		for a := 0; a+1 < len(attributes); a += 2 {
+			element.Attributes[attributes[a]] = attributes[a+1]
+		}
+		tree.Elements = append(tree.Elements, element)
+		return element
+	}
+
+	add("img", "", element("img", "img:nth-of-type(1)"))
+	add("img", "", element("img", "img:nth-of-type(2)", "alt", ""))
+	add("img", "Logo", element("img", "img:nth-of-type(3)", "aria-label", "Logo"))
+	add("button", "", element("button", "button:nth-of-type(1)"))
+	add("button", "", element("button", "button:nth-of-type(2)", "aria-hidden", "true")).Ignored = true
+	add("textbox", "", element("input", "input#q:nth-of-type(1)", "id", "q"))
+	add("textbox", "Query", element("input", "input#q:nth-of-type(2)", "id", "q", "aria-labelledby", "missing"))
+	add("generic", "", element("div", "div", "role", "buton widget", "aria-foo", "1", "aria-hidden", "yes"))
+	add("generic", "", element("span", "div > ::shadow-root > span#q", "id", "q", "aria-level", "2x"))
+	low := element("p", "p:nth-of-type(1)")
+	low.Text = true
+	low.Style = &Style{Color: "rgb(170, 170, 170)", Backgrounds: []string{"rgb(255, 255, 255)"}, FontSize: "16px", FontWeight: "400"}
+	add("paragraph", "", low)
+	large := element("h1", "h1")
+	large.Text = true
+	large.Style = &Style{Color: "rgb(148, 148, 148)", Backgrounds: []string{"#fff"}, FontSize: "32px", FontWeight: "700"}
+	add("heading", "Big", large)
+	disabled := element("button", "button:nth-of-type(3)")
+	disabled.Text = true
+	disabled.Style = &Style{Color: "#ccc", Backgrounds: []string{"#fff"}, FontSize: "16px", FontWeight: "400"}
+	add("button", "Off", disabled).Properties["disabled"] = true
+	gradient := element("p", "p:nth-of-type(2)")
+	gradient.Text = true
+	gradient.Style = &Style{Color: "#000", Backgrounds: []string{"#fff", "#333"}, FontSize: "16px", FontWeight: "400"}
+	add("paragraph", "", gradient)
+	return tree
+}
+
+func TestAudit(t *testing.T) {
+	report := Audit(testTree())
+	if len(DefaultRules) != len(report.Rules) {
+		t.Errorf("Expected the default rules, received %d rules", len(report.Rules))
+	}
+	expected := map[string][]string{
+		"image-alt":       {"img:nth-of-type(1): <img> has no alt attribute"},
+		"accessible-name": {"button:nth-of-type(1): button has no accessible name"},
+		"label":           {"input#q:nth-of-type(1): textbox has no label"},
+		"duplicate-id":    {`input#q:nth-of-type(2): id "q" is already used by input#q:nth-of-type(1)`},
+		"aria-role": {
+			`div: role "buton" is not an ARIA role`,
+			`div: role "widget" is abstract`,
+		},
+		"aria-attribute": {
+			`input#q:nth-of-type(2): aria-labelledby references the missing id "missing"`,
+			"div: aria-foo is not an ARIA attribute",
+			`div: aria-hidden="yes" is not one of true, false, undefined`,
+			`div > ::shadow-root > span#q: aria-level="2x" is not an integer`,
+		},
+		"color-contrast": {
+			"p:nth-of-type(1): contrast ratio 2.32:1 of rgb(170, 170, 170) on rgb(255, 255, 255) is below 4.5:1",
+			"p:nth-of-type(2): contrast ratio 1.66:1 of #000 on #333 is below 4.5:1",
+		},
+	}
+	for _, rule := range report.Rules {
+		issues := []string{}
+		for _, issue := range report.RuleIssues(rule.ID) {
+			if rule.WCAG != issue.WCAG {
+				t.Errorf("Expected WCAG %s, received %s", rule.WCAG, issue.WCAG)
+			}
+			issues = append(issues, issue.Path+": "+issue.Message)
+		}
+		if strings.Join(expected[rule.ID], "\n") != strings.Join(issues, "\n") {
+			t.Errorf("Unexpected %s issues:\n%s", rule.ID, strings.Join(issues, "\n"))
+		}
+	}
+
+	report = Audit(testTree(), ImageAltRule)
+	if 1 != len(report.Rules) || 1 != len(report.Issues) || 1 != report.Issues[0].BackendNodeID {
+		t.Errorf("Unexpected report %v", report.Issues)
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	report := Audit(testTree(), ImageAltRule, LabelRule, AriaRoleRule)
+
+	buf := &bytes.Buffer{}
+	if err := report.WriteJSON(buf); nil != err {
+		t.Fatalf("WriteJSON failed: %s", err)
+	}
+	decoded := struct {
+		URL   string
+		Rules []struct {
+			ID     string
+			Issues int
+		}
+		Issues []*Issue
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); nil != err {
+		t.Fatalf("Invalid JSON %s: %s", buf.String(), err)
+	}
+	if "https://example.com/" != decoded.URL || 3 != len(decoded.Rules) || "aria-role" != decoded.Rules[2].ID || 2 != decoded.Rules[2].Issues || 4 != len(decoded.Issues) {
+		t.Errorf("Unexpected JSON %s", buf.String())
+	}
+	if issue := decoded.Issues[1]; "label" != issue.Rule || "3.3.2" != issue.WCAG || "textbox" != issue.Role || 6 != issue.BackendNodeID {
+		t.Errorf("Unexpected issue %v", issue)
+	}
+
+	buf.Reset()
+	if err := report.WriteJUnit(buf); nil != err {
+		t.Fatalf("WriteJUnit failed: %s", err)
+	}
+	suites := &junitSuites{}
+	if err := xml.Unmarshal(buf.Bytes(), suites); nil != err {
+		t.Fatalf("Invalid XML %s: %s", buf.String(), err)
+	}
+	suite := suites.Suites[0]
+	if "accessibility https://example.com/" != suite.Name || 3 != suite.Tests || 3 != suite.Failures {
+		t.Errorf("Unexpected suite %s", buf.String())
+	}
+	if failure := suite.Cases[2].Failure; "a11y.aria-role" != suite.Cases[2].ClassName || "2 issue(s)" != failure.Message ||
+		"div: role \"buton\" is not an ARIA role\ndiv: role \"widget\" is abstract" != failure.Text {
+		t.Errorf("Unexpected test case %s", buf.String())
+	}
+
+	buf.Reset()
+	Audit(&Tree{}, ImageAltRule).WriteJUnit(buf)
+	if !strings.Contains(buf.String(), `<testcase classname="a11y.image-alt" name="Images must have a text alternative (WCAG 1.1.1)"></testcase>`) {
+		t.Errorf("Unexpected passing test case %s", buf.String())
+	}
+}
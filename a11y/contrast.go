@@ -0,0 +1,141 @@
+package a11y
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+Color is an RGBA color with channels from 0 to 255 and alpha from 0 to 1.
+*/
+type Color struct {
+	R, G, B float64
+	A       float64
+}
+
+/*
+ParseColor parses a CSS color in the formats of computed styles: rgb(),
+rgba(), hex colors and "transparent".
+*/
+func ParseColor(value string) (Color, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case "transparent" == value:
+		return Color{A: 0}, nil
+	case strings.HasPrefix(value, "#"):
+		return parseHexColor(value)
+	case strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba("):
+		open := strings.Index(value, "(")
+		if !strings.HasSuffix(value, ")") {
+			break
+		}
+		fields := strings.FieldsFunc(value[open+1:len(value)-1], func(r rune) bool {
+			return ',' == r || '/' == r || ' ' == r
+		})
+		if len(fields) < 3 || len(fields) > 4 {
+			break
+		}
+		channels := []float64{0, 0, 0, 1}
+		for a, field := range fields {
+			percent := strings.HasSuffix(field, "%")
+			number, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+			if nil != err {
+				return Color{}, fmt.Errorf("a11y: invalid color %q", value)
+			}
+			switch {
+			case percent && a < 3:
+				number = number * 255 / 100
+			case percent:
+				number = number / 100
+			}
+			channels[a] = number
+		}
+		return Color{R: channels[0], G: channels[1], B: channels[2], A: channels[3]}, nil
+	}
+	return Color{}, fmt.Errorf("a11y: unsupported color %q", value)
+}
+
+func parseHexColor(value string) (Color, error) {
+	hex := value[1:]
+	if 3 == len(hex) || 4 == len(hex) {
+		expanded := ""
+		for _, digit := range hex {
+			expanded += string(digit) + string(digit)
+		}
+		hex = expanded
+	}
+	if 6 == len(hex) {
+		hex += "ff"
+	}
+	if 8 != len(hex) {
+		return Color{}, fmt.Errorf("a11y: invalid color %q", value)
+	}
+	number, err := strconv.ParseUint(hex, 16, 32)
+	if nil != err {
+		return Color{}, fmt.Errorf("a11y: invalid color %q", value)
+	}
+	return Color{
+		R: float64(number >> 24 & 0xff),
+		G: float64(number >> 16 & 0xff),
+		B: float64(number >> 8 & 0xff),
+		A: float64(number&0xff) / 255,
+	}, nil
+}
+
+/*
+Over returns the color composited over a background.
+*/
+func (color Color) Over(background Color) Color {
+	alpha := color.A + background.A*(1-color.A)
+	if 0 == alpha {
+		return Color{}
+	}
+	mix := func(foreground, back float64) float64 {
+		return (foreground*color.A + back*background.A*(1-color.A)) / alpha
+	}
+	return Color{
+		R: mix(color.R, background.R),
+		G: mix(color.G, background.G),
+		B: mix(color.B, background.B),
+		A: alpha,
+	}
+}
+
+/*
+Luminance returns the relative luminance of the color as defined by WCAG.
+*/
+func (color Color) Luminance() float64 {
+	channel := func(value float64) float64 {
+		value /= 255
+		if value <= 0.03928 {
+			return value / 12.92
+		}
+		return math.Pow((value+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(color.R) + 0.7152*channel(color.G) + 0.0722*channel(color.B)
+}
+
+/*
+ContrastRatio returns the WCAG contrast ratio of a text color on a background
+color. Translucent backgrounds are composited over white, translucent text
+over the background.
+*/
+func ContrastRatio(foreground, background string) (float64, error) {
+	text, err := ParseColor(foreground)
+	if nil != err {
+		return 0, err
+	}
+	back, err := ParseColor(background)
+	if nil != err {
+		return 0, err
+	}
+	back = back.Over(Color{R: 255, G: 255, B: 255, A: 1})
+	text = text.Over(back)
+	lighter, darker := text.Luminance(), back.Luminance()
+	if darker > lighter {
+		lighter, darker = darker, lighter
+	}
+	return (lighter + 0.05) / (darker + 0.05), nil
+}
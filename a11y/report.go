@@ -0,0 +1,181 @@
+package a11y
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+Issue is an accessibility issue of an element.
+*/
+type Issue struct {
+	// Rule is the ID of the rule that found the issue.
+	Rule string `json:"rule"`
+
+	// WCAG is the WCAG 2 success criterion of the rule.
+	WCAG string `json:"wcag"`
+
+	// Path is the path of the element.
+	Path string `json:"path"`
+
+	// Role is the computed role of the element.
+	Role string `json:"role,omitempty"`
+
+	// Name is the accessible name of the element.
+	Name string `json:"name,omitempty"`
+
+	// Message describes the issue.
+	Message string `json:"message"`
+
+	// BackendNodeID identifies the element in the DOM domain.
+	BackendNodeID int `json:"backendNodeId,omitempty"`
+}
+
+/*
+Report is the result of an audit.
+*/
+type Report struct {
+	// URL is the URL of the page.
+	URL string `json:"url"`
+
+	// Rules are the checked rules.
+	Rules []*Rule `json:"-"`
+
+	// Issues are the issues found, by rule and in document order.
+	Issues []*Issue `json:"issues"`
+}
+
+/*
+Audit checks a tree with the specified rules, or with DefaultRules if no rules
+are specified.
+*/
+func Audit(tree *Tree, rules ...*Rule) *Report {
+	if 0 == len(rules) {
+		rules = DefaultRules
+	}
+	report := &Report{URL: tree.URL, Rules: rules, Issues: []*Issue{}}
+	for _, rule := range rules {
+		for _, issue := range rule.Check(tree) {
+			issue.Rule = rule.ID
+			issue.WCAG = rule.WCAG
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	return report
+}
+
+/*
+RuleIssues returns the issues found by a rule.
+*/
+func (report *Report) RuleIssues(id string) []*Issue {
+	issues := []*Issue{}
+	for _, issue := range report.Issues {
+		if id == issue.Rule {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+/*
+WriteJSON writes the report as indented JSON.
+*/
+func (report *Report) WriteJSON(w io.Writer) error {
+	type jsonRule struct {
+		ID          string `json:"id"`
+		Description string `json:"description"`
+		WCAG        string `json:"wcag"`
+		Issues      int    `json:"issues"`
+	}
+	rules := []*jsonRule{}
+	for _, rule := range report.Rules {
+		rules = append(rules, &jsonRule{
+			ID:          rule.ID,
+			Description: rule.Description,
+			WCAG:        rule.WCAG,
+			Issues:      len(report.RuleIssues(rule.ID)),
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		URL    string      `json:"url"`
+		Rules  []*jsonRule `json:"rules"`
+		Issues []*Issue    `json:"issues"`
+	}{report.URL, rules, report.Issues})
+}
+
+type junitSuites struct {
+	XMLName xml.Name      `xml:"testsuites"`
+	Suites  []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+/*
+WriteJUnit writes the report as JUnit XML with a test case per rule. The
+failure of a rule lists its issues.
+*/
+func (report *Report) WriteJUnit(w io.Writer) error {
+	suite := &junitSuite{Name: "accessibility " + report.URL}
+	for _, rule := range report.Rules {
+		testCase := &junitCase{
+			ClassName: "a11y." + rule.ID,
+			Name:      fmt.Sprintf("%s (WCAG %s)", rule.Description, rule.WCAG),
+		}
+		if issues := report.RuleIssues(rule.ID); len(issues) > 0 {
+			lines := []string{}
+			for _, issue := range issues {
+				lines = append(lines, issue.Path+": "+issue.Message)
+			}
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d issue(s)", len(issues)),
+				Type:    rule.ID,
+				Text:    strings.Join(lines, "\n"),
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+	}
+	if _, err := io.WriteString(w, xml.Header); nil != err {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(&junitSuites{Suites: []*junitSuite{suite}}); nil != err {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,443 @@
+package a11y
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Rule is an accessibility check.
+*/
+type Rule struct {
+	// ID identifies the rule, e.g. "image-alt".
+	ID string
+
+	// Description describes the requirement checked by the rule.
+	Description string
+
+	// WCAG is the WCAG 2 success criterion of the rule, e.g. "1.1.1".
+	WCAG string
+
+	// Check returns the issues of a tree. The rule and the success
+	// criterion of the issues are set by Audit().
+	Check func(tree *Tree) []*Issue
+}
+
+/*
+DefaultRules are the rules checked by Audit() if no rules are specified.
+*/
+var DefaultRules = []*Rule{
+	ImageAltRule,
+	AccessibleNameRule,
+	LabelRule,
+	DuplicateIDRule,
+	AriaRoleRule,
+	AriaAttributeRule,
+	ColorContrastRule,
+}
+
+/*
+ImageAltRule checks that images have a text alternative. An empty alt
+attribute marks a decorative image.
+*/
+var ImageAltRule = &Rule{
+	ID:          "image-alt",
+	Description: "Images must have a text alternative",
+	WCAG:        "1.1.1",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		for _, element := range tree.Elements {
+			imageInput := "input" == element.Tag && "image" == strings.ToLower(element.Attributes["type"])
+			if "img" != element.Tag && !imageInput {
+				continue
+			}
+			if _, ok := element.Attribute("alt"); ok || hidden(element) {
+				continue
+			}
+			if nil != element.Node && "" != element.Node.Name {
+				// Named by aria-label, aria-labelledby or title.
+				continue
+			}
+			issues = append(issues, elementIssue(element, fmt.Sprintf("<%s> has no alt attribute", element.Tag)))
+		}
+		return issues
+	},
+}
+
+/*
+namedRoles are the roles that require an accessible name, form fields are
+checked by LabelRule.
+*/
+var namedRoles = map[string]bool{
+	"alertdialog": true, "button": true, "dialog": true, "heading": true, "img": true, "link": true,
+	"menuitem": true, "menuitemcheckbox": true, "menuitemradio": true, "meter": true,
+	"progressbar": true, "tab": true, "treeitem": true,
+}
+
+/*
+AccessibleNameRule checks that interactive elements, headings and images
+have an accessible name.
+*/
+var AccessibleNameRule = &Rule{
+	ID:          "accessible-name",
+	Description: "Interactive elements, headings and images must have an accessible name",
+	WCAG:        "4.1.2",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		tree.Walk(func(node *Node) {
+			if node.Ignored || !namedRoles[node.Role] || "" != strings.TrimSpace(node.Name) {
+				return
+			}
+			if nil != node.Element && "img" == node.Element.Tag {
+				// Checked by ImageAltRule.
+				return
+			}
+			issues = append(issues, nodeIssue(node, fmt.Sprintf("%s has no accessible name", node.Role)))
+		})
+		return issues
+	},
+}
+
+/*
+fieldRoles are the roles of form fields.
+*/
+var fieldRoles = map[string]bool{
+	"checkbox": true, "combobox": true, "listbox": true, "radio": true, "searchbox": true,
+	"slider": true, "spinbutton": true, "switch": true, "textbox": true,
+}
+
+/*
+LabelRule checks that form fields have a label.
+*/
+var LabelRule = &Rule{
+	ID:          "label",
+	Description: "Form fields must have a label",
+	WCAG:        "3.3.2",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		tree.Walk(func(node *Node) {
+			if node.Ignored || !fieldRoles[node.Role] || "" != strings.TrimSpace(node.Name) {
+				return
+			}
+			issues = append(issues, nodeIssue(node, fmt.Sprintf("%s has no label", node.Role)))
+		})
+		return issues
+	},
+}
+
+/*
+DuplicateIDRule checks that IDs are unique in each document and shadow root.
+*/
+var DuplicateIDRule = &Rule{
+	ID:          "duplicate-id",
+	Description: "IDs must be unique",
+	WCAG:        "4.1.1",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		seen := map[string]*Element{}
+		for _, element := range tree.Elements {
+			id := element.Attributes["id"]
+			if "" == id {
+				continue
+			}
+			key := scope(element.Path) + "#" + id
+			if first, ok := seen[key]; ok {
+				issues = append(issues, elementIssue(element, fmt.Sprintf("id %q is already used by %s", id, first.Path)))
+				continue
+			}
+			seen[key] = element
+		}
+		return issues
+	},
+}
+
+/*
+abstractRoles are the ARIA roles that must not be used in content.
+*/
+var abstractRoles = map[string]bool{
+	"command": true, "composite": true, "input": true, "landmark": true, "range": true,
+	"roletype": true, "section": true, "sectionhead": true, "select": true, "structure": true,
+	"widget": true, "window": true,
+}
+
+/*
+ariaRoles are the WAI-ARIA 1.2 roles. DPUB and graphics roles are recognized
+by prefix.
+*/
+var ariaRoles = map[string]bool{}
+
+func init() {
+	for _, role := range strings.Fields(`alert alertdialog application article banner blockquote
+		button caption cell checkbox code columnheader combobox complementary contentinfo definition
+		deletion dialog directory document emphasis feed figure form generic grid gridcell group
+		heading img insertion link list listbox listitem log main marquee math menu menubar menuitem
+		menuitemcheckbox menuitemradio meter navigation none note option paragraph presentation
+		progressbar radio radiogroup region row rowgroup rowheader scrollbar search searchbox
+		separator slider spinbutton status strong subscript superscript switch tab table tablist
+		tabpanel term textbox time timer toolbar tooltip tree treegrid treeitem`) {
+		ariaRoles[role] = true
+	}
+}
+
+/*
+AriaRoleRule checks that role attributes contain valid, non-abstract ARIA
+roles.
+*/
+var AriaRoleRule = &Rule{
+	ID:          "aria-role",
+	Description: "ARIA roles must be valid",
+	WCAG:        "4.1.2",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		for _, element := range tree.Elements {
+			value, ok := element.Attribute("role")
+			if !ok {
+				continue
+			}
+			for _, role := range strings.Fields(strings.ToLower(value)) {
+				switch {
+				case abstractRoles[role]:
+					issues = append(issues, elementIssue(element, fmt.Sprintf("role %q is abstract", role)))
+				case !ariaRoles[role] && !strings.HasPrefix(role, "doc-") && !strings.HasPrefix(role, "graphics-"):
+					issues = append(issues, elementIssue(element, fmt.Sprintf("role %q is not an ARIA role", role)))
+				}
+			}
+		}
+		return issues
+	},
+}
+
+/*
+ariaAttributes are the WAI-ARIA 1.2 attributes and their value types: a list
+of tokens, "idref", "idrefs", "integer" or "" for any string.
+*/
+var ariaAttributes = map[string]string{
+	"aria-activedescendant":       "idref",
+	"aria-atomic":                 "true false",
+	"aria-autocomplete":           "inline list both none",
+	"aria-braillelabel":           "",
+	"aria-brailleroledescription": "",
+	"aria-busy":                   "true false",
+	"aria-checked":                "true false mixed undefined",
+	"aria-colcount":               "integer",
+	"aria-colindex":               "integer",
+	"aria-colindextext":           "",
+	"aria-colspan":                "integer",
+	"aria-controls":               "idrefs",
+	"aria-current":                "page step location date time true false",
+	"aria-describedby":            "idrefs",
+	"aria-description":            "",
+	"aria-details":                "idref",
+	"aria-disabled":               "true false",
+	"aria-dropeffect":             "",
+	"aria-errormessage":           "idref",
+	"aria-expanded":               "true false undefined",
+	"aria-flowto":                 "idrefs",
+	"aria-grabbed":                "true false undefined",
+	"aria-haspopup":               "false true menu listbox tree grid dialog",
+	"aria-hidden":                 "true false undefined",
+	"aria-invalid":                "grammar false spelling true",
+	"aria-keyshortcuts":           "",
+	"aria-label":                  "",
+	"aria-labelledby":             "idrefs",
+	"aria-level":                  "integer",
+	"aria-live":                   "off polite assertive",
+	"aria-modal":                  "true false",
+	"aria-multiline":              "true false",
+	"aria-multiselectable":        "true false",
+	"aria-orientation":            "horizontal vertical undefined",
+	"aria-owns":                   "idrefs",
+	"aria-placeholder":            "",
+	"aria-posinset":               "integer",
+	"aria-pressed":                "true false mixed undefined",
+	"aria-readonly":               "true false",
+	"aria-relevant":               "",
+	"aria-required":               "true false",
+	"aria-roledescription":        "",
+	"aria-rowcount":               "integer",
+	"aria-rowindex":               "integer",
+	"aria-rowindextext":           "",
+	"aria-rowspan":                "integer",
+	"aria-selected":               "true false undefined",
+	"aria-setsize":                "integer",
+	"aria-sort":                   "ascending descending none other",
+	"aria-valuemax":               "",
+	"aria-valuemin":               "",
+	"aria-valuenow":               "",
+	"aria-valuetext":              "",
+}
+
+/*
+AriaAttributeRule checks that aria-* attributes are valid ARIA attributes
+with valid values, and that ID references point to elements of the same
+document.
+*/
+var AriaAttributeRule = &Rule{
+	ID:          "aria-attribute",
+	Description: "ARIA attributes must be valid",
+	WCAG:        "4.1.2",
+	Check: func(tree *Tree) []*Issue {
+		ids := map[string]bool{}
+		for _, element := range tree.Elements {
+			if id := element.Attributes["id"]; "" != id {
+				ids[scope(element.Path)+"#"+id] = true
+			}
+		}
+		issues := []*Issue{}
+		for _, element := range tree.Elements {
+			for _, name := range sortedKeys(element.Attributes) {
+				value := strings.TrimSpace(element.Attributes[name])
+				if !strings.HasPrefix(name, "aria-") {
+					continue
+				}
+				kind, ok := ariaAttributes[name]
+				if !ok {
+					issues = append(issues, elementIssue(element, fmt.Sprintf("%s is not an ARIA attribute", name)))
+					continue
+				}
+				if "" == value || "" == kind {
+					continue
+				}
+				if message := checkAriaValue(element, name, value, kind, ids); "" != message {
+					issues = append(issues, elementIssue(element, message))
+				}
+			}
+		}
+		return issues
+	},
+}
+
+/*
+checkAriaValue returns the issue of an ARIA attribute value, if any.
+*/
+func checkAriaValue(element *Element, name, value, kind string, ids map[string]bool) string {
+	switch kind {
+	case "idref", "idrefs":
+		refs := strings.Fields(value)
+		if "idref" == kind && len(refs) > 1 {
+			return fmt.Sprintf("%s must reference a single id", name)
+		}
+		for _, ref := range refs {
+			if !ids[scope(element.Path)+"#"+ref] {
+				return fmt.Sprintf("%s references the missing id %q", name, ref)
+			}
+		}
+	case "integer":
+		if _, err := strconv.Atoi(value); nil != err {
+			return fmt.Sprintf("%s=%q is not an integer", name, value)
+		}
+	default:
+		for _, token := range strings.Fields(kind) {
+			if strings.ToLower(value) == token {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s=%q is not one of %s", name, value, strings.Replace(kind, " ", ", ", -1))
+	}
+	return ""
+}
+
+/*
+ColorContrastRule checks that the contrast ratio of text and its background
+is at least 4.5:1, or 3:1 for large text. Text on backgrounds that can not be
+determined, e.g. images, and disabled controls are not checked.
+*/
+var ColorContrastRule = &Rule{
+	ID:          "color-contrast",
+	Description: "Text must have sufficient color contrast",
+	WCAG:        "1.4.3",
+	Check: func(tree *Tree) []*Issue {
+		issues := []*Issue{}
+		for _, element := range tree.Elements {
+			if !element.Text || nil == element.Style || 0 == len(element.Style.Backgrounds) {
+				continue
+			}
+			if nil != element.Node && (element.Node.Ignored || element.Node.Bool("disabled")) {
+				continue
+			}
+			style := element.Style
+			minimum := 4.5
+			if largeText(style) {
+				minimum = 3
+			}
+			for _, background := range style.Backgrounds {
+				ratio, err := ContrastRatio(style.Color, background)
+				if nil != err {
+					break
+				}
+				if ratio < minimum {
+					issues = append(issues, elementIssue(element, fmt.Sprintf(
+						"contrast ratio %.2f:1 of %s on %s is below %.1f:1",
+						ratio, style.Color, background, minimum,
+					)))
+					break
+				}
+			}
+		}
+		return issues
+	},
+}
+
+/*
+largeText returns whether text is large in the sense of WCAG, at least 18pt
+or 14pt bold.
+*/
+func largeText(style *Style) bool {
+	size, err := strconv.ParseFloat(strings.TrimSuffix(style.FontSize, "px"), 64)
+	if nil != err {
+		return false
+	}
+	weight, _ := strconv.Atoi(style.FontWeight)
+	return size >= 24 || (size >= 18.66 && weight >= 700)
+}
+
+/*
+hidden returns whether an element is hidden from assistive technology.
+*/
+func hidden(element *Element) bool {
+	if nil != element.Node && element.Node.Ignored {
+		return true
+	}
+	return "true" == element.Attributes["aria-hidden"]
+}
+
+/*
+scope returns the path of the document or shadow root of an element.
+*/
+func scope(path string) string {
+	index := strings.LastIndex(path, "::")
+	if index < 0 {
+		return ""
+	}
+	if end := strings.Index(path[index:], " "); end >= 0 {
+		return path[:index+end]
+	}
+	return path
+}
+
+func elementIssue(element *Element, message string) *Issue {
+	issue := &Issue{
+		BackendNodeID: element.BackendNodeID,
+		Message:       message,
+		Path:          element.Path,
+	}
+	if nil != element.Node {
+		issue.Role = element.Node.Role
+		issue.Name = element.Node.Name
+	}
+	return issue
+}
+
+func nodeIssue(node *Node, message string) *Issue {
+	issue := &Issue{
+		Message: message,
+		Name:    node.Name,
+		Path:    node.Path(),
+		Role:    node.Role,
+	}
+	if nil != node.Element {
+		issue.BackendNodeID = node.Element.BackendNodeID
+	}
+	return issue
+}
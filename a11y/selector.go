@@ -0,0 +1,327 @@
+package a11y
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+Selector selects elements by their role, accessible name and properties
+rather than by their markup:
+
+	role=button                   elements with the role button
+	role=button[name="Save"]      buttons with the accessible name "Save"
+	role=heading[level=2]         headings with the level property 2
+	role=checkbox[checked]        checkboxes with a true checked property
+	role=button[include-hidden]   buttons including hidden buttons
+	label=Email                   form fields labelled with "Email"
+	text="Sign in"                the elements showing the text "Sign in"
+
+Quoted texts match the whole accessible name, unquoted texts match a part of
+it ignoring case, /regular expressions/ with an optional i flag are matched
+against the name. Whitespace is normalized before matching.
+*/
+type Selector struct {
+	hidden     bool
+	kind       string
+	properties []*propertyMatcher
+	role       string
+	source     string
+	text       *textMatcher
+}
+
+type propertyMatcher struct {
+	name  string
+	value *string
+}
+
+type textMatcher struct {
+	exact bool
+	re    *regexp.Regexp
+	text  string
+}
+
+/*
+ParseSelector parses a role=, label= or text= selector.
+*/
+func ParseSelector(selector string) (*Selector, error) {
+	index := strings.Index(selector, "=")
+	if index < 0 {
+		return nil, fmt.Errorf("a11y: invalid selector %q, expected role=, label= or text=", selector)
+	}
+	parsed := &Selector{
+		kind:   strings.TrimSpace(selector[:index]),
+		source: selector,
+	}
+	value := strings.TrimSpace(selector[index+1:])
+	var err error
+	switch parsed.kind {
+	case "label", "text":
+		if "" == value {
+			return nil, fmt.Errorf("a11y: missing %s in selector %q", parsed.kind, selector)
+		}
+		parsed.text, err = parseTextMatcher(value)
+	case "role":
+		bracket := strings.Index(value, "[")
+		if bracket < 0 {
+			bracket = len(value)
+		}
+		parsed.role = strings.ToLower(strings.TrimSpace(value[:bracket]))
+		if "" == parsed.role || strings.ContainsAny(parsed.role, " \t") {
+			return nil, fmt.Errorf("a11y: invalid role in selector %q", selector)
+		}
+		err = parsed.parseAttributes(value[bracket:])
+	default:
+		return nil, fmt.Errorf("a11y: unknown selector type %q in %q", parsed.kind, selector)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("a11y: invalid selector %q: %s", selector, err)
+	}
+	return parsed, nil
+}
+
+/*
+String returns the selector as parsed.
+*/
+func (selector *Selector) String() string {
+	return selector.source
+}
+
+/*
+parseAttributes parses the [name=value] attributes of a role selector.
+*/
+func (selector *Selector) parseAttributes(attributes string) error {
+	for {
+		attributes = strings.TrimSpace(attributes)
+		if "" == attributes {
+			return nil
+		}
+		if '[' != attributes[0] {
+			return fmt.Errorf("unexpected %q", attributes)
+		}
+		end := strings.IndexAny(attributes, "=]")
+		if end < 0 {
+			return fmt.Errorf("unterminated attribute %q", attributes)
+		}
+		name := strings.ToLower(strings.TrimSpace(attributes[1:end]))
+		if "" == name {
+			return fmt.Errorf("missing attribute name in %q", attributes)
+		}
+		var raw *string
+		if '=' == attributes[end] {
+			value, rest, err := scanValue(attributes[end+1:])
+			if nil != err {
+				return err
+			}
+			rest = strings.TrimSpace(rest)
+			if "" == rest || ']' != rest[0] {
+				return fmt.Errorf("unterminated attribute %q", attributes)
+			}
+			raw = &value
+			attributes = rest[1:]
+		} else {
+			attributes = attributes[end+1:]
+		}
+
+		switch name {
+		case "name":
+			if nil == raw {
+				return fmt.Errorf("missing name value")
+			}
+			matcher, err := parseTextMatcher(*raw)
+			if nil != err {
+				return err
+			}
+			selector.text = matcher
+		case "include-hidden":
+			selector.hidden = nil == raw || "true" == unquote(*raw)
+		default:
+			property := &propertyMatcher{name: name}
+			if nil != raw {
+				value := unquote(*raw)
+				property.value = &value
+			}
+			selector.properties = append(selector.properties, property)
+		}
+	}
+}
+
+/*
+scanValue returns the raw attribute value at the start of text, including
+quotes and regular expression slashes, and the rest of the text.
+*/
+func scanValue(text string) (string, string, error) {
+	text = strings.TrimLeft(text, " ")
+	if "" == text {
+		return "", "", fmt.Errorf("missing attribute value")
+	}
+	switch delimiter := text[0]; delimiter {
+	case '"', '\'', '/':
+		for a := 1; a < len(text); a++ {
+			switch text[a] {
+			case '\\':
+				a++
+			case delimiter:
+				end := a + 1
+				if '/' == delimiter {
+					for end < len(text) && strings.IndexByte("imsU", text[end]) >= 0 {
+						end++
+					}
+				}
+				return text[:end], text[end:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated value %q", text)
+	}
+	end := strings.IndexByte(text, ']')
+	if end < 0 {
+		end = len(text)
+	}
+	return strings.TrimSpace(text[:end]), text[end:], nil
+}
+
+/*
+unquote removes the quotes and escapes of a quoted value.
+*/
+func unquote(value string) string {
+	if len(value) < 2 || ('"' != value[0] && '\'' != value[0]) || value[0] != value[len(value)-1] {
+		return value
+	}
+	unquoted := []byte{}
+	for a := 1; a < len(value)-1; a++ {
+		if '\\' == value[a] && a+1 < len(value)-1 {
+			a++
+		}
+		unquoted = append(unquoted, value[a])
+	}
+	return string(unquoted)
+}
+
+func parseTextMatcher(value string) (*textMatcher, error) {
+	switch {
+	case len(value) >= 2 && ('"' == value[0] || '\'' == value[0]) && value[0] == value[len(value)-1]:
+		return &textMatcher{exact: true, text: normalizeText(unquote(value))}, nil
+	case len(value) >= 2 && '/' == value[0]:
+		end := strings.LastIndex(value, "/")
+		if 0 == end {
+			return nil, fmt.Errorf("unterminated regular expression %s", value)
+		}
+		pattern := value[1:end]
+		if flags := value[end+1:]; "" != flags {
+			pattern = "(?" + flags + ")" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if nil != err {
+			return nil, err
+		}
+		return &textMatcher{re: re}, nil
+	}
+	return &textMatcher{text: strings.ToLower(normalizeText(value))}, nil
+}
+
+func (matcher *textMatcher) match(text string) bool {
+	text = normalizeText(text)
+	switch {
+	case nil != matcher.re:
+		return matcher.re.MatchString(text)
+	case matcher.exact:
+		return matcher.text == text
+	}
+	return strings.Contains(strings.ToLower(text), matcher.text)
+}
+
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+/*
+match returns whether a node matches the property, missing properties are
+false.
+*/
+func (property *propertyMatcher) match(node *Node) bool {
+	value, ok := node.Properties[property.name]
+	if nil == property.value {
+		switch value := value.(type) {
+		case bool:
+			return value
+		case string:
+			return "" != value && "false" != value
+		case float64:
+			return 0 != value
+		}
+		return ok && nil != value
+	}
+	if !ok || nil == value {
+		return "false" == strings.ToLower(*property.value)
+	}
+	return strings.ToLower(fmt.Sprint(value)) == strings.ToLower(*property.value)
+}
+
+/*
+matchNode returns whether a node matches a role= or label= selector.
+*/
+func (selector *Selector) matchNode(node *Node) bool {
+	if node.Ignored && !selector.hidden {
+		return false
+	}
+	if "label" == selector.kind {
+		return fieldRoles[node.Role] && selector.text.match(node.Name)
+	}
+	if selector.role != strings.ToLower(node.Role) {
+		return false
+	}
+	if nil != selector.text && !selector.text.match(node.Name) {
+		return false
+	}
+	for _, property := range selector.properties {
+		if !property.match(node) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Query returns the elements matching a selector in document order. Text
+selectors return the innermost elements containing the text.
+*/
+func (tree *Tree) Query(selector *Selector) []*Element {
+	matched := map[*Element]bool{}
+	if "text" == selector.kind {
+		var walk func(node *Node) bool
+		walk = func(node *Node) bool {
+			found := false
+			for _, child := range node.Children {
+				if walk(child) {
+					found = true
+				}
+			}
+			if found || node.Ignored || "RootWebArea" == node.Role || !selector.text.match(node.Name) {
+				return found
+			}
+			if element := node.ClosestElement(); nil != element {
+				matched[element] = true
+				return true
+			}
+			return false
+		}
+		for _, root := range tree.Roots {
+			walk(root)
+		}
+	} else {
+		tree.Walk(func(node *Node) {
+			if element := node.ClosestElement(); nil != element && selector.matchNode(node) {
+				matched[element] = true
+			}
+		})
+	}
+
+	elements := []*Element{}
+	for _, element := range tree.Elements {
+		if matched[element] {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,105 @@
+package a11y
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func selectorTree() *Tree {
+	tree := &Tree{}
+	var add func(parent *Node, role, name string, tag string, properties ...interface{}) *Node
+	add = func(parent *Node, role, name string, tag string, properties ...interface{}) *Node {
+		node := &Node{Role: role, Name: name, Parent: parent, Properties: map[string]interface{}{}}
+		for a := 0; a+1 < len(properties); a += 2 {
+			node.Properties[properties[a].(string)] = properties[a+1]
+		}
+		if "" != tag {
+			node.Element = &Element{BackendNodeID: len(tree.Elements) + 1, Tag: tag, Path: tag}
+			node.Element.Node = node
+			tree.Elements = append(tree.Elements, node.Element)
+		}
+		if nil == parent {
+			tree.Roots = append(tree.Roots, node)
+		} else {
+			parent.Children = append(parent.Children, node)
+		}
+		return node
+	}
+	root := add(nil, "RootWebArea", "Sign in", "")
+	save := add(root, "button", "Save", "button")
+	add(save, "StaticText", "Save", "")
+	add(root, "button", "Save draft", "button")
+	add(root, "button", "Save", "button").Ignored = true
+	add(root, "textbox", "Email address", "input", "focusable", true)
+	add(root, "checkbox", "Remember me", "input", "checked", "true")
+	add(root, "checkbox", "Newsletter", "input", "checked", "false")
+	add(root, "heading", "Sign in", "h1", "level", float64(1))
+	add(root, "heading", "Details", "h2", "level", float64(2))
+	paragraph := add(root, "paragraph", "", "p")
+	add(paragraph, "StaticText", "Please  sign in\nfirst", "")
+	frame := add(root, "Iframe", "", "iframe")
+	document := add(frame, "RootWebArea", "Frame", "")
+	link := add(document, "link", "Sign in with SSO", "a")
+	add(link, "StaticText", "Sign in with SSO", "")
+	return tree
+}
+
+func TestSelectorQuery(t *testing.T) {
+	tree := selectorTree()
+	for selector, expected := range map[string]string{
+		`role=button`:                              "1 2",
+		`role=BUTTON[name="Save"]`:                 "1",
+		`role=button[name=save]`:                   "1 2",
+		`role=button[name='Save'][include-hidden]`: "1 3",
+		`role=button [ name = /^save/i ]`:          "1 2",
+		`role=button[name="Save \"draft\""]`:       "",
+		`role=checkbox[checked]`:                   "5",
+		`role=checkbox[checked=false]`:             "6",
+		`role=heading[level=2]`:                    "8",
+		`role=textbox[focusable][name=email]`:      "4",
+		`label=Email`:                              "4",
+		`label="Remember me"`:                      "5",
+		`label=Save`:                               "",
+		`text=Save`:                                "1 2",
+		`text="Save"`:                              "1",
+		`text=sign in`:                             "7 9 11",
+		`text="Please sign in first"`:              "9",
+		`text=/SSO$/`:                              "11",
+	} {
+		parsed, err := ParseSelector(selector)
+		if nil != err {
+			t.Errorf("Could not parse %s: %s", selector, err)
+			continue
+		}
+		ids := []string{}
+		for _, element := range tree.Query(parsed) {
+			ids = append(ids, strconv.Itoa(element.BackendNodeID))
+		}
+		if expected != strings.Join(ids, " ") {
+			t.Errorf("Expected %q for %s, received %q", expected, selector, strings.Join(ids, " "))
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, selector := range []string{
+		`button`,
+		`css=button`,
+		`role=`,
+		`role=[name=x]`,
+		`role=button[name]`,
+		`role=button[name="x]`,
+		`role=button[name=/(/]`,
+		`role=button[name=x`,
+		`role=button name=x`,
+		`text=`,
+	} {
+		if _, err := ParseSelector(selector); nil == err {
+			t.Errorf("Expected an error for %s", selector)
+		}
+	}
+	if parsed, _ := ParseSelector(`role=button`); "role=button" != parsed.String() {
+		t.Errorf("Unexpected selector %s", parsed)
+	}
+}
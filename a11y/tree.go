@@ -0,0 +1,164 @@
+/*
+Package a11y audits the accessibility of a page. A Tree combines the
+accessibility tree computed by Chrome with the DOM elements and their
+colors, rules check the tree for WCAG issues and the report is written as
+JSON or JUnit XML for CI. Selectors find elements by role, accessible name
+and properties in a Tree.
+
+Trees are built from a live page by the Tab.AccessibilityTree() method of
+the chrome package, or by hand for tests.
+*/
+package a11y
+
+/*
+Tree is the accessibility tree of a page.
+*/
+type Tree struct {
+	// URL is the URL of the page.
+	URL string
+
+	// Roots are the root nodes of the tree, the first is the root of the
+	// page.
+	Roots []*Node
+
+	// Nodes are the accessibility nodes in document order.
+	Nodes []*Node
+
+	// Elements are the DOM elements of the page in document order,
+	// including the elements without an accessibility node.
+	Elements []*Element
+}
+
+/*
+Node is a node of the accessibility tree.
+*/
+type Node struct {
+	// ID is the ID of the node in the accessibility tree.
+	ID string
+
+	// Role is the computed role, e.g. "button".
+	Role string
+
+	// Name is the computed accessible name.
+	Name string
+
+	// Ignored is true if the node is not exposed to assistive technology,
+	// e.g. because it is hidden.
+	Ignored bool
+
+	// Properties are the other properties of the node, e.g. "focusable".
+	Properties map[string]interface{}
+
+	// Element is the DOM element of the node, if any.
+	Element *Element
+
+	// Parent is the parent node.
+	Parent *Node
+
+	// Children are the child nodes.
+	Children []*Node
+}
+
+/*
+Element is a DOM element.
+*/
+type Element struct {
+	// BackendNodeID identifies the element in the DOM domain.
+	BackendNodeID int
+
+	// Tag is the lower case tag name.
+	Tag string
+
+	// Attributes are the attributes of the element.
+	Attributes map[string]string
+
+	// Path is a selector-like path to the element, e.g.
+	// "html > body > main > a:nth-of-type(2)". Shadow roots and frame
+	// documents are marked with "::shadow-root" and "::document".
+	Path string
+
+	// Text is true if the element contains visible text.
+	Text bool
+
+	// Node is the accessibility node of the element, if any.
+	Node *Node
+
+	// Style is the style of the text of the element, only set for elements
+	// with text.
+	Style *Style
+}
+
+/*
+Style is the computed style of the text of an element.
+*/
+type Style struct {
+	// Color is the computed text color, e.g. "rgb(0, 0, 0)".
+	Color string
+
+	// Backgrounds are the colors behind the text, a single color for flat
+	// backgrounds and the color stops of gradients. Empty if the background
+	// could not be determined, e.g. for images.
+	Backgrounds []string
+
+	// FontSize is the computed font size, e.g. "16px".
+	FontSize string
+
+	// FontWeight is the computed font weight, e.g. "700".
+	FontWeight string
+}
+
+/*
+Attribute returns the value of an attribute and whether it is set.
+*/
+func (element *Element) Attribute(name string) (string, bool) {
+	value, ok := element.Attributes[name]
+	return value, ok
+}
+
+/*
+Bool returns a boolean property of the node, e.g. "focusable".
+*/
+func (node *Node) Bool(name string) bool {
+	value, _ := node.Properties[name].(bool)
+	return value
+}
+
+/*
+ClosestElement returns the element of the node, or the element of the closest
+ancestor with an element, e.g. for text nodes.
+*/
+func (node *Node) ClosestElement() *Element {
+	for ancestor := node; nil != ancestor; ancestor = ancestor.Parent {
+		if nil != ancestor.Element {
+			return ancestor.Element
+		}
+	}
+	return nil
+}
+
+/*
+Path returns the path of the element of the node, or the path of the closest
+ancestor with an element.
+*/
+func (node *Node) Path() string {
+	if element := node.ClosestElement(); nil != element {
+		return element.Path
+	}
+	return ""
+}
+
+/*
+Walk calls fn for each node of the tree in depth-first order.
+*/
+func (tree *Tree) Walk(fn func(node *Node)) {
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		fn(node)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	for _, root := range tree.Roots {
+		walk(root)
+	}
+}
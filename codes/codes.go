@@ -97,6 +97,216 @@ const (
 	WebsocketPanic
 )
 
+////////////////////////////////////////////////////////////////////////////
+// BeginFrame errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// BeginFrameTargetFailed - 7000: Could not create a BeginFrame controlled target.
+	BeginFrameTargetFailed std.Code = iota + 7000
+	// BeginFrameFailed - 7001: HeadlessExperimental.beginFrame failed.
+	BeginFrameFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Tracing errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// TraceStartFailed - 8000: Tracing.start failed.
+	TraceStartFailed std.Code = iota + 8000
+	// TraceEndFailed - 8001: Tracing.end failed or the trace did not complete.
+	TraceEndFailed
+	// StreamReadFailed - 8002: An IO stream could not be read.
+	StreamReadFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Metrics errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// MetricsFailed - 9000: Performance metrics could not be collected.
+	MetricsFailed std.Code = iota + 9000
+	// MetricsExportFailed - 9001: Performance metrics could not be exported.
+	MetricsExportFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Coverage errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// CoverageStartFailed - 10000: Coverage collection could not be started.
+	CoverageStartFailed std.Code = iota + 10000
+	// CoverageTakeFailed - 10001: Coverage could not be collected.
+	CoverageTakeFailed
+	// SourceMapFailed - 10002: A source map could not be loaded.
+	SourceMapFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Profiler errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// ProfilerStartFailed - 11000: Profiling could not be started.
+	ProfilerStartFailed std.Code = iota + 11000
+	// ProfilerStopFailed - 11001: Profiling could not be stopped.
+	ProfilerStopFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Heap profiler errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// HeapSamplingFailed - 12000: Heap sampling failed.
+	HeapSamplingFailed std.Code = iota + 12000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Heap snapshot errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// HeapSnapshotFailed - 13000: Heap snapshot failed.
+	HeapSnapshotFailed std.Code = iota + 13000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Leak check errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// LeakCheckFailed - 14000: Leak check failed.
+	LeakCheckFailed std.Code = iota + 14000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Debugger errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// DebuggerFailed - 15000: The debugger session failed.
+	DebuggerFailed std.Code = iota + 15000
+	// BreakpointFailed - 15001: The breakpoint could not be set.
+	BreakpointFailed
+	// EvaluateFailed - 15002: The expression could not be evaluated.
+	EvaluateFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Logpoint errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// LogpointFailed - 16000: The logpoint could not be set or removed.
+	LogpointFailed std.Code = iota + 16000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Crash report errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// CrashReportFailed - 17000: The crash report could not be created.
+	CrashReportFailed std.Code = iota + 17000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Hot reload
+////////////////////////////////////////////////////////////////////////////
+const (
+	// HotReloadFailed - 18000: The script edit could not be applied.
+	HotReloadFailed std.Code = iota + 18000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Archive
+////////////////////////////////////////////////////////////////////////////
+const (
+	// ArchiveFailed - 19000: The page archive could not be created.
+	ArchiveFailed std.Code = iota + 19000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Accessibility audit
+////////////////////////////////////////////////////////////////////////////
+const (
+	// AccessibilityAuditFailed - 20000: The accessibility audit failed.
+	AccessibilityAuditFailed std.Code = iota + 20000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Selectors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// SelectorInvalid - 21000: The selector is invalid.
+	SelectorInvalid std.Code = iota + 21000
+	// ElementNotFound - 21001: No element matches the selector.
+	ElementNotFound
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Actionability
+////////////////////////////////////////////////////////////////////////////
+const (
+	// ElementNotAttached - 22000: The element is not attached to the document.
+	ElementNotAttached std.Code = iota + 22000
+	// ElementNotVisible - 22001: The element is not visible.
+	ElementNotVisible
+	// ElementNotStable - 22002: The element is not stable.
+	ElementNotStable
+	// ElementNotEnabled - 22003: The element is not enabled.
+	ElementNotEnabled
+	// ElementNotEditable - 22004: The element is not editable.
+	ElementNotEditable
+	// ElementObscured - 22005: The element does not receive events at its position.
+	ElementObscured
+	// ActionFailed - 22006: The action failed.
+	ActionFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Waiting
+////////////////////////////////////////////////////////////////////////////
+const (
+	// WaitFailed - 23000: The wait failed or timed out.
+	WaitFailed std.Code = iota + 23000
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Frame errors
+////////////////////////////////////////////////////////////////////////////
+const (
+	// FrameDetached - 24000: The frame was detached.
+	FrameDetached std.Code = iota + 24000
+	// FrameFailed - 24001: The frame operation failed.
+	FrameFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Workers
+////////////////////////////////////////////////////////////////////////////
+const (
+	// WorkerClosed - 25000: The worker was closed.
+	WorkerClosed std.Code = iota + 25000
+	// WorkerFailed - 25001: The worker operation failed.
+	WorkerFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Service workers
+////////////////////////////////////////////////////////////////////////////
+const (
+	// ServiceWorkerFailed - 26000: The service worker operation failed.
+	ServiceWorkerFailed std.Code = iota + 26000
+	// ServiceWorkerEventFailed - 26001: The service worker event failed.
+	ServiceWorkerEventFailed
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Downloads
+////////////////////////////////////////////////////////////////////////////
+const (
+	// DownloadFailed - 27000: The download failed.
+	DownloadFailed std.Code = iota + 27000
+	// DownloadDenied - 27001: The download was denied.
+	DownloadDenied
+	// DownloadTimeout - 27002: The download timed out.
+	DownloadTimeout
+)
+
 func init() {
 	errs.Codes[Unspecified] = errs.ErrCode{Int: "The error code was unspecified", Ext: "An unknown error occurred", HTTP: 500}
 	errs.Codes[Unknown] = errs.ErrCode{Int: "An unspecified error occurred", Ext: "An unknown error occurred", HTTP: 500}
@@ -126,4 +336,67 @@ func init() {
 	errs.Codes[WebsocketConnectFailed] = errs.ErrCode{Int: "Websocket connection failed", Ext: "An unknown error occurred", HTTP: 500}
 	errs.Codes[WebsocketNotConnected] = errs.ErrCode{Int: "Websocket not connected", Ext: "An unknown error occurred", HTTP: 500}
 	errs.Codes[WebsocketPanic] = errs.ErrCode{Int: "A panic occurred while reading from a websocket", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[BeginFrameTargetFailed] = errs.ErrCode{Int: "Could not create a BeginFrame controlled target", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[BeginFrameFailed] = errs.ErrCode{Int: "HeadlessExperimental.beginFrame failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[TraceStartFailed] = errs.ErrCode{Int: "Tracing.start failed", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[TraceEndFailed] = errs.ErrCode{Int: "Tracing.end failed or the trace did not complete", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[StreamReadFailed] = errs.ErrCode{Int: "An IO stream could not be read", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[MetricsFailed] = errs.ErrCode{Int: "Performance metrics could not be collected", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[MetricsExportFailed] = errs.ErrCode{Int: "Performance metrics could not be exported", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[CoverageStartFailed] = errs.ErrCode{Int: "Coverage collection could not be started", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[CoverageTakeFailed] = errs.ErrCode{Int: "Coverage could not be collected", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[SourceMapFailed] = errs.ErrCode{Int: "A source map could not be loaded", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[ProfilerStartFailed] = errs.ErrCode{Int: "Profiling could not be started", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ProfilerStopFailed] = errs.ErrCode{Int: "Profiling could not be stopped", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[HeapSamplingFailed] = errs.ErrCode{Int: "Heap sampling failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[HeapSnapshotFailed] = errs.ErrCode{Int: "Heap snapshot failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[LeakCheckFailed] = errs.ErrCode{Int: "Leak check failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[DebuggerFailed] = errs.ErrCode{Int: "The debugger session failed", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[BreakpointFailed] = errs.ErrCode{Int: "The breakpoint could not be set", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[EvaluateFailed] = errs.ErrCode{Int: "The expression could not be evaluated", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[LogpointFailed] = errs.ErrCode{Int: "The logpoint could not be set or removed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[CrashReportFailed] = errs.ErrCode{Int: "The crash report could not be created", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[HotReloadFailed] = errs.ErrCode{Int: "The script edit could not be applied", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[ArchiveFailed] = errs.ErrCode{Int: "The page archive could not be created", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[AccessibilityAuditFailed] = errs.ErrCode{Int: "The accessibility audit failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[SelectorInvalid] = errs.ErrCode{Int: "The selector is invalid", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementNotFound] = errs.ErrCode{Int: "No element matches the selector", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[ElementNotAttached] = errs.ErrCode{Int: "The element is not attached to the document", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementNotVisible] = errs.ErrCode{Int: "The element is not visible", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementNotStable] = errs.ErrCode{Int: "The element is not stable", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementNotEnabled] = errs.ErrCode{Int: "The element is not enabled", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementNotEditable] = errs.ErrCode{Int: "The element is not editable", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ElementObscured] = errs.ErrCode{Int: "The element does not receive events at its position", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ActionFailed] = errs.ErrCode{Int: "The action failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[WaitFailed] = errs.ErrCode{Int: "The wait failed or timed out", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[FrameDetached] = errs.ErrCode{Int: "The frame was detached", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[FrameFailed] = errs.ErrCode{Int: "The frame operation failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[WorkerClosed] = errs.ErrCode{Int: "The worker was closed", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[WorkerFailed] = errs.ErrCode{Int: "The worker operation failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[ServiceWorkerFailed] = errs.ErrCode{Int: "The service worker operation failed", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[ServiceWorkerEventFailed] = errs.ErrCode{Int: "The service worker event failed", Ext: "An unknown error occurred", HTTP: 500}
+
+	errs.Codes[DownloadFailed] = errs.ErrCode{Int: "The download failed", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[DownloadDenied] = errs.ErrCode{Int: "The download was denied", Ext: "An unknown error occurred", HTTP: 500}
+	errs.Codes[DownloadTimeout] = errs.ErrCode{Int: "The download timed out", Ext: "An unknown error occurred", HTTP: 500}
 }
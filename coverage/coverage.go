@@ -0,0 +1,614 @@
+/*
+Package coverage merges JavaScript and CSS coverage collected from Chrome and
+writes LCOV, Istanbul JSON and HTML reports.
+
+Coverage ranges are offsets in UTF-16 code units into the script or
+stylesheet source, as reported by Profiler.takePreciseCoverage and
+CSS.stopRuleUsageTracking. Coverage of the same URL and source is summed across
+page loads. If a source map is set for a URL, coverage is reported for the
+original sources instead of the generated file.
+*/
+package coverage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+/*
+Range is a covered range of a source.
+*/
+type Range struct {
+	// Start offset, inclusive.
+	Start int
+
+	// End offset, exclusive.
+	End int
+
+	// Count is the number of times the range was executed or used.
+	Count int
+}
+
+/*
+Function is the block coverage of a JavaScript function. The first range
+covers the whole function, following ranges cover nested blocks.
+*/
+type Function struct {
+	// Name of the function, empty for anonymous functions.
+	Name string
+
+	// Ranges contains the function and block ranges.
+	Ranges []Range
+}
+
+/*
+Kind is the type of a covered resource.
+*/
+type Kind string
+
+const (
+	// KindScript is a JavaScript resource.
+	KindScript Kind = "script"
+	// KindStyleSheet is a CSS resource.
+	KindStyleSheet Kind = "stylesheet"
+)
+
+/*
+Line is the coverage of an instrumented line. Lines without code are not
+reported.
+*/
+type Line struct {
+	// Number is the one-based line number.
+	Number int
+
+	// StartColumn and EndColumn delimit the code on the line, zero-based.
+	StartColumn int
+	EndColumn   int
+
+	// Count is the lowest execution count of the code on the line.
+	Count int
+}
+
+/*
+FunctionHit is the coverage of a function.
+*/
+type FunctionHit struct {
+	// Name of the function.
+	Name string
+
+	// Line and Column are the one-based line and zero-based column of the
+	// function start.
+	Line   int
+	Column int
+
+	// EndLine and EndColumn delimit the function end, if known.
+	EndLine   int
+	EndColumn int
+
+	// Count is the number of calls.
+	Count int
+}
+
+/*
+File is the coverage of a generated or original source file.
+*/
+type File struct {
+	// Path is the URL or the original source path.
+	Path string
+
+	// Kind of the resource.
+	Kind Kind
+
+	// Source is the file content, empty if not available.
+	Source string
+
+	// Lines contains the instrumented lines, sorted by number.
+	Lines []*Line
+
+	// Functions contains the functions, sorted by position.
+	Functions []*FunctionHit
+}
+
+/*
+LinesHit returns the number of covered lines and the number of instrumented
+lines.
+*/
+func (file *File) LinesHit() (hit, total int) {
+	for _, line := range file.Lines {
+		if line.Count > 0 {
+			hit++
+		}
+	}
+	return hit, len(file.Lines)
+}
+
+/*
+FunctionsHit returns the number of called functions and the number of
+functions.
+*/
+func (file *File) FunctionsHit() (hit, total int) {
+	for _, function := range file.Functions {
+		if function.Count > 0 {
+			hit++
+		}
+	}
+	return hit, len(file.Functions)
+}
+
+/*
+New returns an empty coverage set.
+*/
+func New() *Coverage {
+	return &Coverage{
+		entries: map[string]*entry{},
+		mux:     &sync.Mutex{},
+	}
+}
+
+/*
+Coverage is a set of merged script and stylesheet coverage.
+*/
+type Coverage struct {
+	entries map[string]*entry
+	mux     *sync.Mutex
+}
+
+/*
+entry is the merged coverage of a resource.
+*/
+type entry struct {
+	url       string
+	kind      Kind
+	source    string
+	text      []uint16
+	counts    []int
+	functions map[int]*function
+	sourceMap *SourceMap
+}
+
+/*
+function is the merged coverage of a function by start offset.
+*/
+type function struct {
+	name  string
+	start int
+	end   int
+	count int
+}
+
+/*
+AddScript adds the coverage of a script from one page load.
+*/
+func (coverage *Coverage) AddScript(url, source string, functions []Function) {
+	ranges := []Range{}
+	for _, fn := range functions {
+		ranges = append(ranges, fn.Ranges...)
+	}
+
+	coverage.mux.Lock()
+	defer coverage.mux.Unlock()
+	entry := coverage.entry(url, KindScript, source)
+	entry.add(ranges)
+	for _, fn := range functions {
+		if 0 == len(fn.Ranges) {
+			continue
+		}
+		// The top level script is reported as an anonymous function.
+		if "" == fn.Name && 0 == fn.Ranges[0].Start && fn.Ranges[0].End >= len(entry.text) {
+			continue
+		}
+		hit, ok := entry.functions[fn.Ranges[0].Start]
+		if !ok {
+			hit = &function{name: fn.Name, start: fn.Ranges[0].Start, end: fn.Ranges[0].End}
+			entry.functions[hit.start] = hit
+		}
+		hit.count += fn.Ranges[0].Count
+	}
+}
+
+/*
+AddStyleSheet adds the rule usage of a stylesheet. Each range is a rule, used
+rules have a count of 1.
+*/
+func (coverage *Coverage) AddStyleSheet(url, source string, rules []Range) {
+	coverage.mux.Lock()
+	defer coverage.mux.Unlock()
+	coverage.entry(url, KindStyleSheet, source).add(rules)
+}
+
+/*
+SetSourceMap sets the source map of a script or stylesheet URL.
+*/
+func (coverage *Coverage) SetSourceMap(url string, sourceMap *SourceMap) {
+	coverage.mux.Lock()
+	defer coverage.mux.Unlock()
+	if entry, ok := coverage.entries[url]; ok {
+		entry.sourceMap = sourceMap
+	}
+}
+
+/*
+URLs returns the covered URLs, sorted.
+*/
+func (coverage *Coverage) URLs() []string {
+	coverage.mux.Lock()
+	defer coverage.mux.Unlock()
+	urls := []string{}
+	for url := range coverage.entries {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+/*
+Files returns the coverage by file, sorted by path. Resources with a source
+map are reported as their original sources.
+*/
+func (coverage *Coverage) Files() []*File {
+	coverage.mux.Lock()
+	defer coverage.mux.Unlock()
+
+	files := map[string]*File{}
+	for _, entry := range coverage.entries {
+		if nil == entry.sourceMap {
+			files[entry.url] = entry.file()
+			continue
+		}
+		for _, file := range entry.mapped() {
+			if existing, ok := files[file.Path]; ok {
+				mergeFile(existing, file)
+			} else {
+				files[file.Path] = file
+			}
+		}
+	}
+
+	result := []*File{}
+	for _, file := range files {
+		result = append(result, file)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Path < result[b].Path
+	})
+	return result
+}
+
+/*
+entry returns the coverage entry of a URL. If the source changed, coverage of
+the previous source is discarded.
+*/
+func (coverage *Coverage) entry(url string, kind Kind, source string) *entry {
+	existing, ok := coverage.entries[url]
+	if ok && existing.source == source {
+		return existing
+	}
+	text := utf16.Encode([]rune(source))
+	counts := make([]int, len(text))
+	for a := range counts {
+		counts[a] = -1
+	}
+	created := &entry{
+		url:       url,
+		kind:      kind,
+		source:    source,
+		text:      text,
+		counts:    counts,
+		functions: map[int]*function{},
+	}
+	if ok {
+		created.sourceMap = existing.sourceMap
+	}
+	coverage.entries[url] = created
+	return created
+}
+
+/*
+add applies the ranges of one page load and sums the counts. Ranges are
+applied outermost first so nested block ranges override their enclosing
+function. Offsets not covered by any range are not code and keep a count of
+-1.
+*/
+func (entry *entry) add(ranges []Range) {
+	sorted := append([]Range{}, ranges...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if sorted[a].Start == sorted[b].Start {
+			return sorted[a].End > sorted[b].End
+		}
+		return sorted[a].Start < sorted[b].Start
+	})
+
+	load := make([]int, len(entry.text))
+	for a := range load {
+		load[a] = -1
+	}
+	for _, r := range sorted {
+		for a := maxInt(r.Start, 0); a < r.End && a < len(load); a++ {
+			load[a] = r.Count
+		}
+	}
+	for a, count := range load {
+		if count < 0 {
+			continue
+		}
+		if entry.counts[a] < 0 {
+			entry.counts[a] = 0
+		}
+		entry.counts[a] += count
+	}
+}
+
+/*
+lines returns the start offset of each line of the text.
+*/
+func (entry *entry) lines() []int {
+	starts := []int{0}
+	for a, char := range entry.text {
+		if '\n' == char {
+			starts = append(starts, a+1)
+		}
+	}
+	return starts
+}
+
+/*
+span returns the lowest count and the first and last code offsets in the
+range, ignoring white space and offsets that are not code.
+*/
+func (entry *entry) span(start, end int) (count, first, last int, ok bool) {
+	for a := start; a < end && a < len(entry.text); a++ {
+		if entry.counts[a] < 0 || isSpace(entry.text[a]) {
+			continue
+		}
+		if !ok || entry.counts[a] < count {
+			count = entry.counts[a]
+		}
+		if !ok {
+			first = a
+		}
+		last = a
+		ok = true
+	}
+	return count, first, last, ok
+}
+
+/*
+position converts an offset to a zero-based line and column.
+*/
+func position(starts []int, offset int) (line, column int) {
+	line = sort.SearchInts(starts, offset+1) - 1
+	if line < 0 {
+		line = 0
+	}
+	return line, offset - starts[line]
+}
+
+/*
+file returns the coverage of the generated file.
+*/
+func (entry *entry) file() *File {
+	file := &File{
+		Path:   entry.url,
+		Kind:   entry.kind,
+		Source: entry.source,
+	}
+
+	starts := entry.lines()
+	for a, start := range starts {
+		end := len(entry.text)
+		if a+1 < len(starts) {
+			end = starts[a+1]
+		}
+		count, first, last, ok := entry.span(start, end)
+		if !ok {
+			continue
+		}
+		file.Lines = append(file.Lines, &Line{
+			Number:      a + 1,
+			StartColumn: first - start,
+			EndColumn:   last - start + 1,
+			Count:       count,
+		})
+	}
+
+	for _, fn := range entry.sortedFunctions() {
+		line, column := position(starts, fn.start)
+		endLine, endColumn := position(starts, fn.end)
+		file.Functions = append(file.Functions, &FunctionHit{
+			Name:      fn.name,
+			Line:      line + 1,
+			Column:    column,
+			EndLine:   endLine + 1,
+			EndColumn: endColumn,
+			Count:     fn.count,
+		})
+	}
+	nameAnonymous(file)
+	return file
+}
+
+/*
+mapped returns the coverage of the original sources of the entry. The count
+of an original line is the lowest count of the generated segments mapped to
+it.
+*/
+func (entry *entry) mapped() []*File {
+	sourceMap := entry.sourceMap
+	files := map[int]*File{}
+	lines := map[int]map[int]*Line{}
+	get := func(source int) *File {
+		file, ok := files[source]
+		if !ok {
+			file = &File{Path: sourceMap.SourcePath(source), Kind: entry.kind}
+			file.Source, _ = sourceMap.SourceContent(source)
+			files[source] = file
+			lines[source] = map[int]*Line{}
+		}
+		return file
+	}
+
+	starts := entry.lines()
+	for a, start := range starts {
+		end := len(entry.text)
+		if a+1 < len(starts) {
+			end = starts[a+1]
+		}
+		segments := sourceMap.Line(a)
+		for b, segment := range segments {
+			if segment.Source < 0 {
+				continue
+			}
+			segmentEnd := end
+			if b+1 < len(segments) {
+				segmentEnd = start + segments[b+1].GeneratedColumn
+			}
+			count, _, _, ok := entry.span(start+segment.GeneratedColumn, segmentEnd)
+			if !ok {
+				continue
+			}
+			get(segment.Source)
+			line, ok := lines[segment.Source][segment.OriginalLine]
+			if !ok {
+				line = &Line{
+					Number:      segment.OriginalLine + 1,
+					StartColumn: segment.OriginalColumn,
+					EndColumn:   segment.OriginalColumn + 1,
+					Count:       count,
+				}
+				lines[segment.Source][segment.OriginalLine] = line
+			}
+			line.StartColumn = minInt(line.StartColumn, segment.OriginalColumn)
+			line.EndColumn = maxInt(line.EndColumn, segment.OriginalColumn+1)
+			line.Count = minInt(line.Count, count)
+		}
+	}
+
+	for _, fn := range entry.sortedFunctions() {
+		line, column := position(starts, fn.start)
+		mapping, ok := sourceMap.Lookup(line, column)
+		if !ok {
+			continue
+		}
+		file := get(mapping.Source)
+		name := fn.name
+		if "" == name && mapping.Name >= 0 && mapping.Name < len(sourceMap.Names) {
+			name = sourceMap.Names[mapping.Name]
+		}
+		hit := &FunctionHit{
+			Name:   name,
+			Line:   mapping.OriginalLine + 1,
+			Column: mapping.OriginalColumn,
+			Count:  fn.count,
+		}
+		line, column = position(starts, maxInt(fn.end-1, fn.start))
+		if end, ok := sourceMap.Lookup(line, column); ok && end.Source == mapping.Source {
+			hit.EndLine, hit.EndColumn = end.OriginalLine+1, end.OriginalColumn+1
+		}
+		file.Functions = append(file.Functions, hit)
+	}
+
+	result := []*File{}
+	for source, file := range files {
+		for _, line := range lines[source] {
+			file.Lines = append(file.Lines, line)
+		}
+		sort.Slice(file.Lines, func(a, b int) bool {
+			return file.Lines[a].Number < file.Lines[b].Number
+		})
+		nameAnonymous(file)
+		result = append(result, file)
+	}
+	return result
+}
+
+/*
+sortedFunctions returns the functions of the entry by start offset.
+*/
+func (entry *entry) sortedFunctions() []*function {
+	functions := []*function{}
+	for _, fn := range entry.functions {
+		functions = append(functions, fn)
+	}
+	sort.Slice(functions, func(a, b int) bool {
+		return functions[a].start < functions[b].start
+	})
+	return functions
+}
+
+/*
+mergeFile merges the coverage of an original source that is bundled into more
+than one generated file.
+*/
+func mergeFile(file, other *File) {
+	lines := map[int]*Line{}
+	for _, line := range file.Lines {
+		lines[line.Number] = line
+	}
+	for _, line := range other.Lines {
+		if existing, ok := lines[line.Number]; ok {
+			existing.Count += line.Count
+		} else {
+			file.Lines = append(file.Lines, line)
+		}
+	}
+	sort.Slice(file.Lines, func(a, b int) bool {
+		return file.Lines[a].Number < file.Lines[b].Number
+	})
+
+	functions := map[[2]int]*FunctionHit{}
+	for _, fn := range file.Functions {
+		functions[[2]int{fn.Line, fn.Column}] = fn
+	}
+	for _, fn := range other.Functions {
+		if existing, ok := functions[[2]int{fn.Line, fn.Column}]; ok {
+			existing.Count += fn.Count
+		} else {
+			file.Functions = append(file.Functions, fn)
+		}
+	}
+	if "" == file.Source {
+		file.Source = other.Source
+	}
+}
+
+/*
+nameAnonymous sorts the functions of a file by position and names anonymous
+functions like Istanbul does.
+*/
+func nameAnonymous(file *File) {
+	sort.SliceStable(file.Functions, func(a, b int) bool {
+		if file.Functions[a].Line == file.Functions[b].Line {
+			return file.Functions[a].Column < file.Functions[b].Column
+		}
+		return file.Functions[a].Line < file.Functions[b].Line
+	})
+	for a, fn := range file.Functions {
+		if "" == fn.Name || strings.HasPrefix(fn.Name, "(anonymous_") {
+			fn.Name = "(anonymous_" + strconv.Itoa(a) + ")"
+		}
+	}
+}
+
+/*
+isSpace checks for white space code units.
+*/
+func isSpace(char uint16) bool {
+	return ' ' == char || '\t' == char || '\r' == char || '\n' == char || '\f' == char || '\v' == char
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
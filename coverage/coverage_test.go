@@ -0,0 +1,100 @@
+package coverage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCoverageMerge(t *testing.T) {
+	coverage := New()
+	source := "a();\n\nb();\n"
+	coverage.AddScript("https://example.com/app.js", source, []Function{
+		{Ranges: []Range{{Start: 0, End: 11, Count: 1}, {Start: 6, End: 11, Count: 0}}},
+	})
+	coverage.AddScript("https://example.com/app.js", source, []Function{
+		{Ranges: []Range{{Start: 0, End: 11, Count: 1}}},
+	})
+	coverage.AddStyleSheet("https://example.com/app.css", "a{color:red}\n\nb{color:blue}\n", []Range{
+		{Start: 0, End: 12, Count: 1},
+		{Start: 14, End: 27, Count: 0},
+	})
+
+	files := coverage.Files()
+	if 2 != len(files) {
+		t.Fatalf("Expected 2 files, received %d", len(files))
+	}
+	css, script := files[0], files[1]
+	if KindScript != script.Kind || KindStyleSheet != css.Kind {
+		t.Errorf("Unexpected kinds %s and %s", script.Kind, css.Kind)
+	}
+	if 2 != len(script.Lines) ||
+		1 != script.Lines[0].Number || 2 != script.Lines[0].Count ||
+		3 != script.Lines[1].Number || 1 != script.Lines[1].Count {
+		t.Errorf("Unexpected script lines %+v %+v", script.Lines[0], script.Lines[1])
+	}
+	if hit, total := css.LinesHit(); 1 != hit || 2 != total {
+		t.Errorf("Expected 1/2 CSS lines hit, received %d/%d", hit, total)
+	}
+
+	// A changed source replaces the previous coverage.
+	coverage.AddScript("https://example.com/app.js", "c();\n", []Function{
+		{Ranges: []Range{{Start: 0, End: 5, Count: 0}}},
+	})
+	script = coverage.Files()[1]
+	if 1 != len(script.Lines) || 0 != script.Lines[0].Count {
+		t.Errorf("Expected the coverage to be replaced, received %+v", script.Lines)
+	}
+}
+
+func TestReports(t *testing.T) {
+	coverage := New()
+	coverage.AddScript("https://example.com/app.js", "function a() {}\nfunction b() {}\na();\n", []Function{
+		{Ranges: []Range{{Start: 0, End: 37, Count: 1}}},
+		{Name: "a", Ranges: []Range{{Start: 0, End: 15, Count: 1}}},
+		{Name: "b", Ranges: []Range{{Start: 16, End: 31, Count: 0}}},
+	})
+	files := coverage.Files()
+
+	buf := &bytes.Buffer{}
+	if err := WriteLCOV(buf, files); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	for _, expected := range []string{
+		"SF:https://example.com/app.js\n",
+		"FN:2,b\n",
+		"FNDA:1,a\n",
+		"FNF:2\nFNH:1\n",
+		"DA:2,0\n",
+		"LF:3\nLH:2\nend_of_record\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected LCOV to contain '%s', received:\n%s", expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteIstanbul(buf, files); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	istanbul := map[string]*IstanbulFile{}
+	if err := json.Unmarshal(buf.Bytes(), &istanbul); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	file, ok := istanbul["https://example.com/app.js"]
+	if !ok {
+		t.Fatalf("Expected the script in the coverage map")
+	}
+	if 3 != len(file.S) || 0 != file.S["1"] || 1 != file.F["0"] || "b" != file.FnMap["1"].Name {
+		t.Errorf("Unexpected Istanbul coverage %+v", file)
+	}
+
+	buf.Reset()
+	if err := WriteHTML(buf, files); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<tr class="miss">`) || !strings.Contains(buf.String(), "66.7% (2/3)") {
+		t.Errorf("Unexpected HTML report:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,230 @@
+package coverage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+WriteLCOV writes the coverage in the LCOV tracefile format.
+
+http://ltp.sourceforge.net/coverage/lcov/geninfo.1.php
+*/
+func WriteLCOV(w io.Writer, files []*File) error {
+	writer := bufio.NewWriter(w)
+	for _, file := range files {
+		fmt.Fprintf(writer, "TN:\nSF:%s\n", file.Path)
+		for _, fn := range file.Functions {
+			fmt.Fprintf(writer, "FN:%d,%s\n", fn.Line, fn.Name)
+		}
+		for _, fn := range file.Functions {
+			fmt.Fprintf(writer, "FNDA:%d,%s\n", fn.Count, fn.Name)
+		}
+		hit, total := file.FunctionsHit()
+		fmt.Fprintf(writer, "FNF:%d\nFNH:%d\n", total, hit)
+		for _, line := range file.Lines {
+			fmt.Fprintf(writer, "DA:%d,%d\n", line.Number, line.Count)
+		}
+		hit, total = file.LinesHit()
+		fmt.Fprintf(writer, "LF:%d\nLH:%d\nend_of_record\n", total, hit)
+	}
+	return writer.Flush()
+}
+
+/*
+IstanbulPosition is a position in an Istanbul coverage map. Lines are
+one-based, columns are zero-based.
+*/
+type IstanbulPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+/*
+IstanbulLocation is a source range in an Istanbul coverage map.
+*/
+type IstanbulLocation struct {
+	Start IstanbulPosition `json:"start"`
+	End   IstanbulPosition `json:"end"`
+}
+
+/*
+IstanbulFunction is a function in an Istanbul coverage map.
+*/
+type IstanbulFunction struct {
+	Name string           `json:"name"`
+	Decl IstanbulLocation `json:"decl"`
+	Loc  IstanbulLocation `json:"loc"`
+	Line int              `json:"line"`
+}
+
+/*
+IstanbulFile is the coverage of a file in the Istanbul coverage-final.json
+format. Each instrumented line is reported as a statement, branches are not
+reported.
+*/
+type IstanbulFile struct {
+	Path         string                      `json:"path"`
+	StatementMap map[string]IstanbulLocation `json:"statementMap"`
+	FnMap        map[string]IstanbulFunction `json:"fnMap"`
+	BranchMap    map[string]interface{}      `json:"branchMap"`
+	S            map[string]int              `json:"s"`
+	F            map[string]int              `json:"f"`
+	B            map[string][]int            `json:"b"`
+}
+
+/*
+Istanbul converts the coverage to an Istanbul coverage map keyed by path.
+*/
+func Istanbul(files []*File) map[string]*IstanbulFile {
+	result := map[string]*IstanbulFile{}
+	for _, file := range files {
+		istanbul := &IstanbulFile{
+			Path:         file.Path,
+			StatementMap: map[string]IstanbulLocation{},
+			FnMap:        map[string]IstanbulFunction{},
+			BranchMap:    map[string]interface{}{},
+			S:            map[string]int{},
+			F:            map[string]int{},
+			B:            map[string][]int{},
+		}
+		for a, line := range file.Lines {
+			key := strconv.Itoa(a)
+			istanbul.StatementMap[key] = IstanbulLocation{
+				Start: IstanbulPosition{Line: line.Number, Column: line.StartColumn},
+				End:   IstanbulPosition{Line: line.Number, Column: line.EndColumn},
+			}
+			istanbul.S[key] = line.Count
+		}
+		for a, fn := range file.Functions {
+			key := strconv.Itoa(a)
+			start := IstanbulPosition{Line: fn.Line, Column: fn.Column}
+			end := IstanbulPosition{Line: fn.EndLine, Column: fn.EndColumn}
+			if 0 == fn.EndLine {
+				end = start
+			}
+			istanbul.FnMap[key] = IstanbulFunction{
+				Name: fn.Name,
+				Decl: IstanbulLocation{Start: start, End: start},
+				Loc:  IstanbulLocation{Start: start, End: end},
+				Line: fn.Line,
+			}
+			istanbul.F[key] = fn.Count
+		}
+		result[file.Path] = istanbul
+	}
+	return result
+}
+
+/*
+WriteIstanbul writes the coverage as an Istanbul coverage-final.json
+document that can be merged and reported with nyc.
+*/
+func WriteIstanbul(w io.Writer, files []*File) error {
+	return json.NewEncoder(w).Encode(Istanbul(files))
+}
+
+/*
+htmlLine is a source line of the HTML report.
+*/
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string
+	Count  string
+}
+
+/*
+htmlFile is a file of the HTML report.
+*/
+type htmlFile struct {
+	ID        string
+	Path      string
+	Lines     string
+	Functions string
+	Source    []htmlLine
+}
+
+var htmlReport = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 8px; text-align: left; }
+.summary td { border-bottom: 1px solid #ddd; }
+.source { font-family: monospace; white-space: pre; }
+.source td { padding: 0 8px; }
+.count { color: #888; text-align: right; }
+.hit { background: #e6ffed; }
+.miss { background: #ffeef0; }
+</style>
+</head>
+<body>
+<h1>Coverage report</h1>
+<table class="summary">
+<tr><th>File</th><th>Lines</th><th>Functions</th></tr>
+{{range .}}<tr><td><a href="#{{.ID}}">{{.Path}}</a></td><td>{{.Lines}}</td><td>{{.Functions}}</td></tr>
+{{end}}</table>
+{{range .}}<h2 id="{{.ID}}">{{.Path}}</h2>
+{{if .Source}}<table class="source">
+{{range .Source}}<tr class="{{.Class}}"><td class="count">{{.Number}}</td><td class="count">{{.Count}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{else}}<p>Source not available.</p>
+{{end}}{{end}}</body>
+</html>
+`))
+
+/*
+WriteHTML writes a self-contained HTML report with a summary table and the
+annotated source of each file.
+*/
+func WriteHTML(w io.Writer, files []*File) error {
+	data := []*htmlFile{}
+	for a, file := range files {
+		linesHit, linesTotal := file.LinesHit()
+		functionsHit, functionsTotal := file.FunctionsHit()
+		report := &htmlFile{
+			ID:        "file-" + strconv.Itoa(a),
+			Path:      file.Path,
+			Lines:     percent(linesHit, linesTotal),
+			Functions: percent(functionsHit, functionsTotal),
+		}
+		lines := map[int]*Line{}
+		for _, line := range file.Lines {
+			lines[line.Number] = line
+		}
+		if "" != file.Source {
+			for b, text := range strings.Split(file.Source, "\n") {
+				source := htmlLine{Number: b + 1, Text: strings.TrimRight(text, "\r")}
+				if line, ok := lines[b+1]; ok {
+					source.Count = strconv.Itoa(line.Count) + "x"
+					source.Class = "miss"
+					if line.Count > 0 {
+						source.Class = "hit"
+					}
+				}
+				report.Source = append(report.Source, source)
+			}
+		}
+		data = append(data, report)
+	}
+	return htmlReport.Execute(w, data)
+}
+
+/*
+percent formats a hit ratio.
+*/
+func percent(hit, total int) string {
+	if 0 == total {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%d/%d)", float64(hit)*100/float64(total), hit, total)
+}
@@ -0,0 +1,223 @@
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Mapping maps a generated position to a position in an original source. Lines
+and columns are zero-based.
+*/
+type Mapping struct {
+	// GeneratedLine is the line in the generated file.
+	GeneratedLine int
+
+	// GeneratedColumn is the column in the generated file.
+	GeneratedColumn int
+
+	// Source is the index of the original source, -1 if the segment is not
+	// mapped.
+	Source int
+
+	// OriginalLine is the line in the original source.
+	OriginalLine int
+
+	// OriginalColumn is the column in the original source.
+	OriginalColumn int
+
+	// Name is the index of the original name, -1 if the segment has no name.
+	Name int
+}
+
+/*
+SourceMap is a parsed revision 3 source map.
+
+https://sourcemaps.info/spec.html
+*/
+type SourceMap struct {
+	// Version of the source map format, always 3.
+	Version int `json:"version"`
+
+	// File is the name of the generated file.
+	File string `json:"file,omitempty"`
+
+	// SourceRoot is prepended to the source paths.
+	SourceRoot string `json:"sourceRoot,omitempty"`
+
+	// Sources contains the original source paths.
+	Sources []string `json:"sources"`
+
+	// SourcesContent contains the original source contents, if embedded.
+	SourcesContent []*string `json:"sourcesContent,omitempty"`
+
+	// Names contains the original symbol names.
+	Names []string `json:"names,omitempty"`
+
+	// Mappings is the VLQ encoded mapping data.
+	Mappings string `json:"mappings"`
+
+	// lines contains the decoded mappings by generated line, sorted by
+	// generated column.
+	lines [][]*Mapping
+}
+
+/*
+ParseSourceMap decodes a source map. Indexed source maps with sections are
+not supported.
+*/
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	// Source maps may be prefixed with a XSSI protection line.
+	if strings.HasPrefix(string(data), ")]}") {
+		if idx := strings.IndexByte(string(data), '\n'); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+
+	sourceMap := &SourceMap{}
+	if err := json.Unmarshal(data, sourceMap); nil != err {
+		return nil, fmt.Errorf("coverage: invalid source map: %s", err)
+	}
+	if 3 != sourceMap.Version {
+		return nil, fmt.Errorf("coverage: unsupported source map version %d", sourceMap.Version)
+	}
+	if err := sourceMap.decode(); nil != err {
+		return nil, err
+	}
+	return sourceMap, nil
+}
+
+/*
+Lookup returns the mapping of a generated position, that is the last segment
+on the line that starts at or before the column.
+*/
+func (sourceMap *SourceMap) Lookup(line, column int) (*Mapping, bool) {
+	if line < 0 || line >= len(sourceMap.lines) {
+		return nil, false
+	}
+	segments := sourceMap.lines[line]
+	idx := sort.Search(len(segments), func(a int) bool {
+		return segments[a].GeneratedColumn > column
+	}) - 1
+	if idx < 0 || segments[idx].Source < 0 {
+		return nil, false
+	}
+	return segments[idx], true
+}
+
+/*
+Line returns the mappings of a generated line, sorted by column.
+*/
+func (sourceMap *SourceMap) Line(line int) []*Mapping {
+	if line < 0 || line >= len(sourceMap.lines) {
+		return nil
+	}
+	return sourceMap.lines[line]
+}
+
+/*
+SourcePath returns the path of an original source including the source root.
+*/
+func (sourceMap *SourceMap) SourcePath(source int) string {
+	if source < 0 || source >= len(sourceMap.Sources) {
+		return ""
+	}
+	path := sourceMap.Sources[source]
+	if "" == sourceMap.SourceRoot || strings.Contains(path, "://") || strings.HasPrefix(path, "/") {
+		return path
+	}
+	return strings.TrimSuffix(sourceMap.SourceRoot, "/") + "/" + path
+}
+
+/*
+SourceContent returns the embedded content of an original source.
+*/
+func (sourceMap *SourceMap) SourceContent(source int) (string, bool) {
+	if source < 0 || source >= len(sourceMap.SourcesContent) || nil == sourceMap.SourcesContent[source] {
+		return "", false
+	}
+	return *sourceMap.SourcesContent[source], true
+}
+
+/*
+decode decodes the VLQ mappings. Fields other than the generated column are
+relative to the previous segment, the generated column is relative to the
+previous segment on the same line.
+*/
+func (sourceMap *SourceMap) decode() error {
+	var source, originalLine, originalColumn, name int
+	for line, group := range strings.Split(sourceMap.Mappings, ";") {
+		segments := []*Mapping{}
+		column := 0
+		for _, segment := range strings.Split(group, ",") {
+			if "" == segment {
+				continue
+			}
+			values, err := decodeVLQ(segment)
+			if nil != err {
+				return fmt.Errorf("coverage: invalid mapping on line %d: %s", line+1, err)
+			}
+			column += values[0]
+			mapping := &Mapping{
+				GeneratedLine:   line,
+				GeneratedColumn: column,
+				Source:          -1,
+				Name:            -1,
+			}
+			if len(values) >= 4 {
+				source += values[1]
+				originalLine += values[2]
+				originalColumn += values[3]
+				mapping.Source = source
+				mapping.OriginalLine = originalLine
+				mapping.OriginalColumn = originalColumn
+			}
+			if len(values) >= 5 {
+				name += values[4]
+				mapping.Name = name
+			}
+			segments = append(segments, mapping)
+		}
+		sort.SliceStable(segments, func(a, b int) bool {
+			return segments[a].GeneratedColumn < segments[b].GeneratedColumn
+		})
+		sourceMap.lines = append(sourceMap.lines, segments)
+	}
+	return nil
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+/*
+decodeVLQ decodes the base64 VLQ values of a mapping segment.
+*/
+func decodeVLQ(segment string) ([]int, error) {
+	values := []int{}
+	value, shift := 0, uint(0)
+	for a := 0; a < len(segment); a++ {
+		digit := strings.IndexByte(base64Chars, segment[a])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base64 character '%c'", segment[a])
+		}
+		value += (digit & 31) << shift
+		if 0 != digit&32 {
+			shift += 5
+			continue
+		}
+		if 1 == value&1 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if 0 != shift {
+		return nil, fmt.Errorf("truncated segment '%s'", segment)
+	}
+	if 1 != len(values) && 4 != len(values) && 5 != len(values) {
+		return nil, fmt.Errorf("segment '%s' has %d fields", segment, len(values))
+	}
+	return values, nil
+}
@@ -0,0 +1,62 @@
+package coverage
+
+import (
+	"testing"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	values, err := decodeVLQ("sBAEA")
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 4 != len(values) || 22 != values[0] || 0 != values[1] || 2 != values[2] || 0 != values[3] {
+		t.Errorf("Unexpected values %v", values)
+	}
+	if values, _ = decodeVLQ("D"); -1 != values[0] {
+		t.Errorf("Expected -1, received %d", values[0])
+	}
+	if _, err = decodeVLQ("s"); nil == err {
+		t.Errorf("Expected an error for a truncated segment")
+	}
+}
+
+func TestSourceMapCoverage(t *testing.T) {
+	sourceMap, err := ParseSourceMap([]byte(`)]}'
+{
+	"version": 3,
+	"sourceRoot": "webpack:///",
+	"sources": ["src/app.js"],
+	"sourcesContent": ["function a() { return 1 }\n\nfunction b() { return 2 }\n"],
+	"names": [],
+	"mappings": "AAAA,sBAEA"
+}`))
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if mapping, ok := sourceMap.Lookup(0, 30); !ok || 2 != mapping.OriginalLine {
+		t.Errorf("Expected original line 2, received %+v", mapping)
+	}
+
+	coverage := New()
+	coverage.AddScript("https://example.com/app.min.js", "function a(){return 1}function b(){return 2}", []Function{
+		{Ranges: []Range{{Start: 0, End: 44, Count: 1}}},
+		{Name: "a", Ranges: []Range{{Start: 0, End: 22, Count: 1}}},
+		{Name: "b", Ranges: []Range{{Start: 22, End: 44, Count: 0}}},
+	})
+	coverage.SetSourceMap("https://example.com/app.min.js", sourceMap)
+
+	files := coverage.Files()
+	if 1 != len(files) {
+		t.Fatalf("Expected 1 file, received %d", len(files))
+	}
+	file := files[0]
+	if "webpack:///src/app.js" != file.Path || "" == file.Source {
+		t.Errorf("Unexpected original file %s", file.Path)
+	}
+	if 2 != len(file.Lines) || 1 != file.Lines[0].Count || 3 != file.Lines[1].Number || 0 != file.Lines[1].Count {
+		t.Errorf("Unexpected lines %+v", file.Lines)
+	}
+	if 2 != len(file.Functions) || "b" != file.Functions[1].Name || 3 != file.Functions[1].Line {
+		t.Errorf("Unexpected functions %+v", file.Functions)
+	}
+}
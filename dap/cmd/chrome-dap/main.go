@@ -0,0 +1,39 @@
+/*
+Command chrome-dap is a Debug Adapter Protocol server for the JavaScript of
+Chrome tabs.
+
+By default a single debug session is run over stdin and stdout, which is how
+most editors start debug adapters. With -listen the server accepts client
+connections on a TCP address instead:
+
+	chrome-dap -listen localhost:4711
+*/
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/dap"
+)
+
+func main() {
+	listen := flag.String("listen", "", "TCP address to accept client connections on, stdio is used if empty")
+	flag.Parse()
+
+	// Log messages must not be written to stdout, which is the client
+	// connection in stdio mode.
+	log.SetOutput(os.Stderr)
+
+	var err error
+	if "" == *listen {
+		err = dap.NewSession(os.Stdin, os.Stdout).Run()
+	} else {
+		log.WithFields(log.Fields{"address": *listen}).Info("Listening for debug clients")
+		err = dap.ListenAndServe(*listen)
+	}
+	if nil != err {
+		log.Fatal(err)
+	}
+}
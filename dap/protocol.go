@@ -0,0 +1,273 @@
+/*
+Package dap implements a Debug Adapter Protocol server for the JavaScript of a
+Chrome tab.
+
+DAP-capable editors connect to the server over stdio or TCP and debug page
+scripts through the Debugger, Runtime and Page domains. Breakpoints are
+translated to URL breakpoints with chrome.Debugger, which supports launching a
+new Chrome instance as well as attaching to an open tab.
+
+See https://microsoft.github.io/debug-adapter-protocol/specification for the
+protocol.
+*/
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+Request is a client request.
+*/
+type Request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+/*
+Response is the response to a request.
+*/
+type Response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+/*
+Event is an event sent by the server.
+*/
+type Event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+/*
+ReadMessage reads the content of the next message. Messages have a header
+with the content length, separated from the content by a blank line:
+
+	Content-Length: 119\r\n
+	\r\n
+	{"seq": 153, "type": "request", ...}
+*/
+func ReadMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if nil != err {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if "" == line {
+			break
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if 2 == len(parts) && "content-length" == strings.ToLower(strings.TrimSpace(parts[0])) {
+			if length, err = strconv.Atoi(strings.TrimSpace(parts[1])); nil != err {
+				return nil, fmt.Errorf("dap: invalid content length %q", parts[1])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("dap: missing content length")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); nil != err {
+		return nil, err
+	}
+	return content, nil
+}
+
+/*
+WriteMessage writes a message with its header.
+*/
+func WriteMessage(writer io.Writer, message interface{}) error {
+	content, err := json.Marshal(message)
+	if nil != err {
+		return err
+	}
+	if _, err = fmt.Fprintf(writer, "Content-Length: %d\r\n\r\n", len(content)); nil != err {
+		return err
+	}
+	_, err = writer.Write(content)
+	return err
+}
+
+/*
+Capabilities are the features supported by the server.
+*/
+type Capabilities struct {
+	SupportsConfigurationDoneRequest bool                         `json:"supportsConfigurationDoneRequest"`
+	SupportsConditionalBreakpoints   bool                         `json:"supportsConditionalBreakpoints"`
+	SupportsEvaluateForHovers        bool                         `json:"supportsEvaluateForHovers"`
+	SupportsTerminateRequest         bool                         `json:"supportsTerminateRequest"`
+	ExceptionBreakpointFilters       []ExceptionBreakpointsFilter `json:"exceptionBreakpointFilters"`
+}
+
+/*
+ExceptionBreakpointsFilter is an exception breakpoint option shown by the
+client.
+*/
+type ExceptionBreakpointsFilter struct {
+	Filter  string `json:"filter"`
+	Label   string `json:"label"`
+	Default bool   `json:"default,omitempty"`
+}
+
+/*
+Source is a script file.
+*/
+type Source struct {
+	Name            string `json:"name,omitempty"`
+	Path            string `json:"path,omitempty"`
+	SourceReference int    `json:"sourceReference,omitempty"`
+}
+
+/*
+SourceBreakpoint is a breakpoint requested by the client.
+*/
+type SourceBreakpoint struct {
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
+	Condition string `json:"condition,omitempty"`
+}
+
+/*
+Breakpoint is a breakpoint set by the server.
+*/
+type Breakpoint struct {
+	Verified bool    `json:"verified"`
+	Message  string  `json:"message,omitempty"`
+	Source   *Source `json:"source,omitempty"`
+	Line     int     `json:"line,omitempty"`
+	Column   int     `json:"column,omitempty"`
+}
+
+/*
+Thread is a thread of the debuggee. A page has one JavaScript thread.
+*/
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+/*
+StackFrame is a frame of the call stack.
+*/
+type StackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *Source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+/*
+Scope is a variable scope of a stack frame.
+*/
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+/*
+Variable is a variable or an object property.
+*/
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+/*
+LaunchArguments are the arguments of the launch request.
+*/
+type LaunchArguments struct {
+	// URL is the page opened once the client is configured.
+	URL string `json:"url"`
+
+	// ChromePath is the path to the Chrome binary. Defaults to
+	// /usr/bin/google-chrome.
+	ChromePath string `json:"chromePath"`
+
+	// Port is the remote debugging port. Defaults to 9222.
+	Port int `json:"port"`
+
+	// Headless starts Chrome without a window.
+	Headless bool `json:"headless"`
+
+	// UserDataDir is the Chrome profile directory. Defaults to a temporary
+	// directory.
+	UserDataDir string `json:"userDataDir"`
+
+	// WebRoot is the local directory served at the root of the page URL.
+	WebRoot string `json:"webRoot"`
+}
+
+/*
+AttachArguments are the arguments of the attach request.
+*/
+type AttachArguments struct {
+	// Address is the remote debugging address. Defaults to localhost.
+	Address string `json:"address"`
+
+	// Port is the remote debugging port. Defaults to 9222.
+	Port int `json:"port"`
+
+	// Tab is the ID of the tab to attach to.
+	Tab string `json:"tab"`
+
+	// URL selects the first tab with a URL containing the value if no tab ID
+	// is specified. The first page is used if both are empty.
+	URL string `json:"url"`
+
+	// WebRoot is the local directory served at the root of the page URL.
+	WebRoot string `json:"webRoot"`
+}
+
+type setBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type setExceptionBreakpointsArguments struct {
+	Filters []string `json:"filters"`
+}
+
+type stackTraceArguments struct {
+	ThreadID   int `json:"threadId"`
+	StartFrame int `json:"startFrame"`
+	Levels     int `json:"levels"`
+}
+
+type scopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type variablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type evaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    int    `json:"frameId"`
+	Context    string `json:"context"`
+}
+
+type disconnectArguments struct {
+	TerminateDebuggee *bool `json:"terminateDebuggee"`
+}
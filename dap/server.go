@@ -0,0 +1,40 @@
+package dap
+
+import (
+	"net"
+
+	"github.com/bdlm/log"
+)
+
+/*
+ListenAndServe listens on a TCP address and runs a debug session for each
+client connection.
+*/
+func ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if nil != err {
+		return err
+	}
+	return Serve(listener)
+}
+
+/*
+Serve accepts client connections on a listener and runs a debug session for
+each connection. It returns when the listener fails, e.g. when it is closed.
+*/
+func Serve(listener net.Listener) error {
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if nil != err {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := NewSession(conn, conn).Run(); nil != err {
+				log.WithFields(log.Fields{"client": conn.RemoteAddr().String(), "error": err}).
+					Warn("debug session failed")
+			}
+		}()
+	}
+}
@@ -0,0 +1,756 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	chrome "github.com/mkenney/go-chrome/tot"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+/*
+threadID is the ID of the page thread, the only thread of a session.
+*/
+const threadID = 1
+
+/*
+NewSession returns a debug session for a client connection.
+*/
+func NewSession(reader io.Reader, writer io.Writer) *Session {
+	return &Session{
+		breakpoints: map[string][]*chrome.Breakpoint{},
+		mux:         &sync.Mutex{},
+		reader:      reader,
+		scriptRefs:  map[runtime.ScriptID]int{},
+		writer:      writer,
+		writeMux:    &sync.Mutex{},
+	}
+}
+
+/*
+Session translates the requests of a DAP client to a chrome.Debugger session.
+*/
+type Session struct {
+	breakpoints map[string][]*chrome.Breakpoint
+	browser     *chrome.Chrome
+	debugger    *chrome.Debugger
+	launched    bool
+	launchURL   string
+	mux         *sync.Mutex
+	reader      io.Reader
+	seq         int
+	stopReason  string
+	tab         *chrome.Tab
+	webRoot     string
+	writer      io.Writer
+	writeMux    *sync.Mutex
+
+	// handles maps the variables references and frame IDs of the current
+	// pause to frames, scopes and objects. They are only valid until the
+	// thread resumes.
+	handles []interface{}
+
+	// scriptRefs maps scripts without a local file to source references,
+	// which are valid for the whole session.
+	scriptRefs map[runtime.ScriptID]int
+	scripts    []runtime.ScriptID
+}
+
+/*
+Run handles requests until the client disconnects or closes the connection.
+A launched Chrome instance is closed when the session ends.
+*/
+func (session *Session) Run() error {
+	reader := bufio.NewReader(session.reader)
+	for {
+		content, err := ReadMessage(reader)
+		if nil != err {
+			session.stop(true)
+			if io.EOF == err {
+				return nil
+			}
+			return err
+		}
+		request := &Request{}
+		if err := json.Unmarshal(content, request); nil != err {
+			session.stop(true)
+			return fmt.Errorf("dap: invalid message: %s", err)
+		}
+		if "request" != request.Type {
+			continue
+		}
+
+		body, requestErr := session.dispatch(request)
+		if err := session.respond(request, body, requestErr); nil != err {
+			session.stop(true)
+			return err
+		}
+		if nil == requestErr {
+			switch request.Command {
+			case "launch", "attach":
+				session.send("initialized", nil)
+			case "disconnect":
+				return nil
+			}
+		}
+	}
+}
+
+/*
+dispatch runs a request and returns the response body.
+*/
+func (session *Session) dispatch(request *Request) (interface{}, error) {
+	if nil == session.debugger {
+		switch request.Command {
+		case "initialize", "launch", "attach", "disconnect":
+		default:
+			return nil, fmt.Errorf("no debuggee, launch or attach first")
+		}
+	}
+
+	switch request.Command {
+	case "initialize":
+		return &Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+			ExceptionBreakpointFilters: []ExceptionBreakpointsFilter{
+				{Filter: "all", Label: "All Exceptions"},
+				{Filter: "uncaught", Label: "Uncaught Exceptions"},
+			},
+		}, nil
+	case "launch":
+		args := &LaunchArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return nil, session.launch(args)
+	case "attach":
+		args := &AttachArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return nil, session.attach(args)
+	case "setBreakpoints":
+		args := &setBreakpointsArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.setBreakpoints(args), nil
+	case "setExceptionBreakpoints":
+		args := &setExceptionBreakpointsArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		state := "none"
+		for _, filter := range args.Filters {
+			if "all" == filter {
+				state = "all"
+			} else if "uncaught" == filter && "none" == state {
+				state = "uncaught"
+			}
+		}
+		return nil, session.debugger.PauseOnExceptions(state)
+	case "configurationDone":
+		if "" == session.launchURL {
+			return nil, nil
+		}
+		result := <-session.tab.Page().Navigate(&page.NavigateParams{URL: session.launchURL})
+		if nil != result.Err {
+			return nil, result.Err
+		}
+		if "" != result.ErrorText {
+			return nil, fmt.Errorf("navigation failed: %s", result.ErrorText)
+		}
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{
+			"threads": []Thread{{ID: threadID, Name: "Main Thread"}},
+		}, nil
+	case "stackTrace":
+		args := &stackTraceArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.stackTrace(args)
+	case "scopes":
+		args := &scopesArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.scopes(args)
+	case "variables":
+		args := &variablesArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.variables(args)
+	case "evaluate":
+		args := &evaluateArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.evaluate(args)
+	case "source":
+		args := &struct {
+			SourceReference int `json:"sourceReference"`
+		}{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		return session.source(args.SourceReference)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, session.resume("", session.debugger.Continue)
+	case "next":
+		return nil, session.resume("step", session.debugger.StepOver)
+	case "stepIn":
+		return nil, session.resume("step", session.debugger.StepInto)
+	case "stepOut":
+		return nil, session.resume("step", session.debugger.StepOut)
+	case "pause":
+		session.mux.Lock()
+		session.stopReason = "pause"
+		session.mux.Unlock()
+		return nil, session.debugger.Pause()
+	case "terminate":
+		session.stop(true)
+		session.send("terminated", nil)
+		return nil, nil
+	case "disconnect":
+		args := &disconnectArguments{}
+		if err := unmarshal(request, args); nil != err {
+			return nil, err
+		}
+		terminate := true
+		if nil != args.TerminateDebuggee {
+			terminate = *args.TerminateDebuggee
+		}
+		session.stop(terminate)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported command '%s'", request.Command)
+}
+
+/*
+unmarshal decodes the arguments of a request.
+*/
+func unmarshal(request *Request, args interface{}) error {
+	if 0 == len(request.Arguments) {
+		return nil
+	}
+	if err := json.Unmarshal(request.Arguments, args); nil != err {
+		return fmt.Errorf("invalid %s arguments: %s", request.Command, err)
+	}
+	return nil
+}
+
+/*
+launch starts Chrome with a blank tab. The page is opened once the client is
+configured, so that breakpoints in the initial scripts are hit.
+*/
+func (session *Session) launch(args *LaunchArguments) error {
+	if nil != session.debugger {
+		return fmt.Errorf("already debugging")
+	}
+	port := args.Port
+	if 0 == port {
+		port = 9222
+	}
+	flags := &chrome.Flags{
+		"addr":                     "localhost",
+		"port":                     port,
+		"remote-debugging-address": "127.0.0.1",
+		"remote-debugging-port":    port,
+	}
+	if args.Headless {
+		flags.Set("headless", nil)
+		flags.Set("disable-gpu", nil)
+	}
+	if "" != args.UserDataDir {
+		flags.Set("user-data-dir", args.UserDataDir)
+	}
+	// Chrome must not write to stdout, which may be the client connection.
+	browser := chrome.New(flags, args.ChromePath, "", os.DevNull, "")
+	if err := browser.Launch(); nil != err {
+		return err
+	}
+	tab, err := browser.NewTab("about:blank")
+	if nil != err {
+		browser.Close()
+		return err
+	}
+
+	session.browser = browser
+	session.launched = true
+	session.launchURL = args.URL
+	session.webRoot = args.WebRoot
+	if err := session.start(tab); nil != err {
+		session.stop(true)
+		return err
+	}
+	return nil
+}
+
+/*
+attach connects to an open tab.
+*/
+func (session *Session) attach(args *AttachArguments) error {
+	if nil != session.debugger {
+		return fmt.Errorf("already debugging")
+	}
+	address := args.Address
+	if "" == address {
+		address = "localhost"
+	}
+	port := args.Port
+	if 0 == port {
+		port = 9222
+	}
+	browser := chrome.New(&chrome.Flags{"addr": address, "port": port}, "", "", "", "")
+
+	tabID := args.Tab
+	if "" == tabID {
+		targets := []*chrome.TabData{}
+		if _, err := browser.Query("/json/list", url.Values{}, &targets); nil != err {
+			return err
+		}
+		for _, target := range targets {
+			if "page" == target.Type && strings.Contains(target.URL, args.URL) {
+				tabID = target.ID
+				break
+			}
+		}
+		if "" == tabID {
+			return fmt.Errorf("no page matching '%s' found", args.URL)
+		}
+	}
+	tab, err := browser.AttachTab(tabID)
+	if nil != err {
+		return err
+	}
+
+	session.browser = browser
+	session.webRoot = args.WebRoot
+	if err := session.start(tab); nil != err {
+		session.stop(false)
+		return err
+	}
+	return nil
+}
+
+/*
+start enables the debugger of the tab.
+*/
+func (session *Session) start(tab *chrome.Tab) error {
+	session.tab = tab
+	session.debugger = chrome.NewDebugger(tab)
+	session.debugger.OnPause(session.onPause)
+	return session.debugger.Enable()
+}
+
+/*
+stop ends the debug session. Chrome is only closed if it was launched by the
+session and the debuggee should be terminated.
+*/
+func (session *Session) stop(terminate bool) {
+	if nil == session.browser {
+		return
+	}
+	if nil != session.debugger {
+		// Disabling the debugger removes the breakpoints and resumes the
+		// page.
+		session.debugger.Disable()
+	}
+	if session.launched && terminate {
+		session.browser.Close()
+	} else if nil != session.tab {
+		session.tab.Socket().Stop()
+	}
+	session.browser = nil
+	session.debugger = nil
+	session.tab = nil
+}
+
+/*
+onPause sends a stopped event to the client.
+*/
+func (session *Session) onPause(pause *chrome.Pause) {
+	session.mux.Lock()
+	session.handles = nil
+	reason := session.stopReason
+	session.stopReason = ""
+	session.mux.Unlock()
+
+	body := map[string]interface{}{
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	}
+	switch {
+	case "exception" == pause.Reason || "promiseRejection" == pause.Reason:
+		reason = "exception"
+		if nil != pause.Exception {
+			body["text"] = describe(pause.Exception)
+		}
+	case len(pause.HitBreakpoints) > 0:
+		reason = "breakpoint"
+	case "" == reason:
+		reason = "debugger statement"
+	}
+	body["reason"] = reason
+	session.send("stopped", body)
+}
+
+/*
+resume resumes the thread. The reason is reported when the thread pauses
+again.
+*/
+func (session *Session) resume(reason string, command func() error) error {
+	session.mux.Lock()
+	session.handles = nil
+	session.stopReason = reason
+	session.mux.Unlock()
+	return command()
+}
+
+/*
+setBreakpoints replaces the breakpoints of a source file.
+*/
+func (session *Session) setBreakpoints(args *setBreakpointsArguments) map[string]interface{} {
+	key := args.Source.Path
+	if "" == key {
+		key = args.Source.Name
+	}
+	for _, breakpoint := range session.breakpoints[key] {
+		session.debugger.RemoveBreakpoint(breakpoint)
+	}
+	session.breakpoints[key] = nil
+
+	file := session.fileName(key)
+	breakpoints := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, requested := range args.Breakpoints {
+		breakpoint, err := session.debugger.SetBreakpoint(file, requested.Line, requested.Condition)
+		if nil != err {
+			breakpoints = append(breakpoints, Breakpoint{
+				Message: err.Error(),
+				Line:    requested.Line,
+			})
+			continue
+		}
+		session.breakpoints[key] = append(session.breakpoints[key], breakpoint)
+		source := args.Source
+		breakpoints = append(breakpoints, Breakpoint{
+			Verified: true,
+			Source:   &source,
+			Line:     breakpoint.Line,
+			Column:   breakpoint.Column,
+		})
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+/*
+fileName returns the file name of a source path used to match script URLs.
+Paths in the web root are matched by the path relative to the web root,
+other paths by the base name.
+*/
+func (session *Session) fileName(sourcePath string) string {
+	if strings.Contains(sourcePath, "://") {
+		return sourcePath
+	}
+	if "" != session.webRoot {
+		if rel, err := filepath.Rel(session.webRoot, sourcePath); nil == err && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(sourcePath)
+}
+
+/*
+sourceOf returns the source of a script. Scripts are mapped to files in the
+web root, scripts without a local file are referenced by ID and loaded with
+the source request.
+*/
+func (session *Session) sourceOf(scriptID runtime.ScriptID, scriptURL string) *Source {
+	source := &Source{Name: scriptURL}
+	if parsed, err := url.Parse(scriptURL); nil == err && "" != parsed.Path {
+		source.Name = path.Base(parsed.Path)
+		if "" != session.webRoot {
+			source.Path = filepath.Join(session.webRoot, filepath.FromSlash(parsed.Path))
+			return source
+		}
+	}
+
+	session.mux.Lock()
+	defer session.mux.Unlock()
+	ref, ok := session.scriptRefs[scriptID]
+	if !ok {
+		session.scripts = append(session.scripts, scriptID)
+		ref = len(session.scripts)
+		session.scriptRefs[scriptID] = ref
+	}
+	source.SourceReference = ref
+	return source
+}
+
+/*
+source returns the content of a referenced script.
+*/
+func (session *Session) source(ref int) (interface{}, error) {
+	session.mux.Lock()
+	if ref < 1 || ref > len(session.scripts) {
+		session.mux.Unlock()
+		return nil, fmt.Errorf("unknown source reference %d", ref)
+	}
+	scriptID := session.scripts[ref-1]
+	session.mux.Unlock()
+
+	content, err := session.debugger.ScriptSource(scriptID)
+	if nil != err {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"content":  content,
+		"mimeType": "text/javascript",
+	}, nil
+}
+
+/*
+newHandle returns a reference to a frame, scope or object of the current
+pause.
+*/
+func (session *Session) newHandle(value interface{}) int {
+	session.mux.Lock()
+	defer session.mux.Unlock()
+	session.handles = append(session.handles, value)
+	return len(session.handles)
+}
+
+/*
+lookup returns the value of a reference.
+*/
+func (session *Session) lookup(ref int) (interface{}, error) {
+	session.mux.Lock()
+	defer session.mux.Unlock()
+	if ref < 1 || ref > len(session.handles) {
+		return nil, fmt.Errorf("invalid reference %d", ref)
+	}
+	return session.handles[ref-1], nil
+}
+
+/*
+stackTrace returns the frames of the current pause.
+*/
+func (session *Session) stackTrace(args *stackTraceArguments) (interface{}, error) {
+	pause, ok := session.debugger.Paused()
+	if !ok {
+		return nil, fmt.Errorf("the thread is not paused")
+	}
+	frames := pause.Frames
+	if args.StartFrame > 0 && args.StartFrame < len(frames) {
+		frames = frames[args.StartFrame:]
+	} else if args.StartFrame > 0 {
+		frames = nil
+	}
+	if args.Levels > 0 && args.Levels < len(frames) {
+		frames = frames[:args.Levels]
+	}
+
+	stackFrames := make([]StackFrame, 0, len(frames))
+	for _, frame := range frames {
+		name := frame.Function
+		if "" == name {
+			name = "(anonymous)"
+		}
+		stackFrames = append(stackFrames, StackFrame{
+			ID:     session.newHandle(frame),
+			Name:   name,
+			Source: session.sourceOf(frame.ScriptID, frame.URL),
+			Line:   frame.Line,
+			Column: frame.Column,
+		})
+	}
+	return map[string]interface{}{
+		"stackFrames": stackFrames,
+		"totalFrames": len(pause.Frames),
+	}, nil
+}
+
+/*
+scopes returns the scopes of a frame.
+*/
+func (session *Session) scopes(args *scopesArguments) (interface{}, error) {
+	value, err := session.lookup(args.FrameID)
+	if nil != err {
+		return nil, err
+	}
+	frame, ok := value.(*chrome.Frame)
+	if !ok {
+		return nil, fmt.Errorf("invalid frame %d", args.FrameID)
+	}
+	scopes := make([]Scope, 0, len(frame.Scopes))
+	for _, scope := range frame.Scopes {
+		// The protocol may omit the type.
+		name := "Scope"
+		if "" != scope.Type {
+			name = strings.ToUpper(scope.Type[:1]) + scope.Type[1:]
+		}
+		if "" != scope.Name {
+			name = fmt.Sprintf("%s (%s)", name, scope.Name)
+		}
+		scopes = append(scopes, Scope{
+			Name:               name,
+			VariablesReference: session.newHandle(scope),
+			Expensive:          "global" == scope.Type,
+		})
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+/*
+variables returns the variables of a scope or the properties of an object.
+*/
+func (session *Session) variables(args *variablesArguments) (interface{}, error) {
+	value, err := session.lookup(args.VariablesReference)
+	if nil != err {
+		return nil, err
+	}
+	var properties []*chrome.Variable
+	switch value := value.(type) {
+	case *chrome.Scope:
+		properties, err = value.Variables()
+	case *runtime.RemoteObject:
+		properties, err = session.debugger.Properties(value)
+	default:
+		return nil, fmt.Errorf("invalid variables reference %d", args.VariablesReference)
+	}
+	if nil != err {
+		return nil, err
+	}
+	variables := make([]Variable, 0, len(properties))
+	for _, property := range properties {
+		variables = append(variables, Variable{
+			Name:               property.Name,
+			Value:              describe(property.Value),
+			Type:               property.Value.Type.String(),
+			VariablesReference: session.reference(property.Value),
+		})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+/*
+evaluate evaluates an expression in a frame or, without a frame, in the
+global scope.
+*/
+func (session *Session) evaluate(args *evaluateArguments) (interface{}, error) {
+	var result *runtime.RemoteObject
+	var err error
+	if 0 == args.FrameID {
+		result, err = session.debugger.Evaluate(args.Expression)
+	} else {
+		value, lookupErr := session.lookup(args.FrameID)
+		if nil != lookupErr {
+			return nil, lookupErr
+		}
+		frame, ok := value.(*chrome.Frame)
+		if !ok {
+			return nil, fmt.Errorf("invalid frame %d", args.FrameID)
+		}
+		result, err = frame.Evaluate(args.Expression)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"result":             describe(result),
+		"type":               result.Type.String(),
+		"variablesReference": session.reference(result),
+	}, nil
+}
+
+/*
+reference returns the variables reference of an object, 0 for primitive
+values.
+*/
+func (session *Session) reference(object *runtime.RemoteObject) int {
+	if nil == object || "" == object.ObjectID {
+		return 0
+	}
+	return session.newHandle(object)
+}
+
+/*
+describe returns the display value of a remote object.
+*/
+func describe(object *runtime.RemoteObject) string {
+	if nil == object {
+		return "undefined"
+	}
+	switch object.Type {
+	case runtime.ObjectType.String:
+		if value, ok := object.Value.(string); ok {
+			return strconv.Quote(value)
+		}
+	case runtime.ObjectType.Undefined:
+		return "undefined"
+	case runtime.ObjectType.Number, runtime.ObjectType.Boolean:
+		if nil != object.Value {
+			return fmt.Sprintf("%v", object.Value)
+		}
+	}
+	if "" != object.Description {
+		return object.Description
+	}
+	if runtime.ObjectSubtype.Null == object.Subtype {
+		return "null"
+	}
+	return object.Type.String()
+}
+
+/*
+respond sends the response to a request.
+*/
+func (session *Session) respond(request *Request, body interface{}, err error) error {
+	response := &Response{
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    nil == err,
+		Command:    request.Command,
+		Body:       body,
+	}
+	if nil != err {
+		response.Message = err.Error()
+		response.Body = nil
+	}
+	session.writeMux.Lock()
+	defer session.writeMux.Unlock()
+	session.seq++
+	response.Seq = session.seq
+	return WriteMessage(session.writer, response)
+}
+
+/*
+send sends an event.
+*/
+func (session *Session) send(event string, body interface{}) error {
+	session.writeMux.Lock()
+	defer session.writeMux.Unlock()
+	session.seq++
+	return WriteMessage(session.writer, &Event{
+		Seq:   session.seq,
+		Type:  "event",
+		Event: event,
+		Body:  body,
+	})
+}
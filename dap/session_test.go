@@ -0,0 +1,463 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+/*
+fakeChrome serves the tab list and a scripted DevTools websocket for a single
+tab.
+*/
+type fakeChrome struct {
+	conn       *websocket.Conn
+	mux        *sync.Mutex
+	responders map[string]func(params json.RawMessage) (interface{}, error)
+	server     *httptest.Server
+	t          *testing.T
+}
+
+func newFakeChrome(t *testing.T) *fakeChrome {
+	fake := &fakeChrome{
+		mux:        &sync.Mutex{},
+		responders: map[string]func(params json.RawMessage) (interface{}, error){},
+		t:          t,
+	}
+	upgrader := &websocket.Upgrader{}
+	fake.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/json/list":
+			json.NewEncoder(writer).Encode([]map[string]string{
+				{"id": "other", "type": "page", "url": "https://other.com/"},
+				{
+					"id":                   "tab-1",
+					"type":                 "page",
+					"url":                  "https://example.com/",
+					"webSocketDebuggerUrl": "ws://" + request.Host + "/devtools/page/tab-1",
+				},
+			})
+		case "/devtools/page/tab-1":
+			conn, err := upgrader.Upgrade(writer, request, nil)
+			if nil != err {
+				t.Errorf("Upgrade failed: %s", err)
+				return
+			}
+			fake.mux.Lock()
+			fake.conn = conn
+			fake.mux.Unlock()
+			fake.serve(conn)
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	return fake
+}
+
+/*
+Respond adds a responder for a command. Responders can fire events, which are
+received before the response.
+*/
+func (fake *fakeChrome) Respond(method string, responder func(params json.RawMessage) (interface{}, error)) {
+	fake.mux.Lock()
+	fake.responders[method] = responder
+	fake.mux.Unlock()
+}
+
+/*
+Fire sends an event to the client.
+*/
+func (fake *fakeChrome) Fire(method string, params interface{}) {
+	fake.write(map[string]interface{}{"method": method, "params": params})
+}
+
+func (fake *fakeChrome) write(message interface{}) {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	if err := fake.conn.WriteJSON(message); nil != err {
+		fake.t.Errorf("write failed: %s", err)
+	}
+}
+
+func (fake *fakeChrome) serve(conn *websocket.Conn) {
+	for {
+		command := struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}{}
+		if err := conn.ReadJSON(&command); nil != err {
+			return
+		}
+		fake.mux.Lock()
+		responder, ok := fake.responders[command.Method]
+		fake.mux.Unlock()
+		if !ok {
+			fake.write(map[string]interface{}{"id": command.ID, "result": map[string]interface{}{}})
+			continue
+		}
+		result, err := responder(command.Params)
+		if nil != err {
+			fake.write(map[string]interface{}{"id": command.ID, "error": map[string]interface{}{"code": -32000, "message": err.Error()}})
+		} else {
+			fake.write(map[string]interface{}{"id": command.ID, "result": result})
+		}
+	}
+}
+
+/*
+testMessage is a response or an event received by the test client.
+*/
+type testMessage struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func (message *testMessage) decode(t *testing.T, body interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(message.Body, body); nil != err {
+		t.Fatalf("could not decode %s body %s: %s", message.Command+message.Event, message.Body, err)
+	}
+}
+
+/*
+testClient is a scripted DAP client.
+*/
+type testClient struct {
+	conn   net.Conn
+	events []*testMessage
+	reader *bufio.Reader
+	seq    int
+	t      *testing.T
+}
+
+func (client *testClient) read() *testMessage {
+	client.t.Helper()
+	client.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	content, err := ReadMessage(client.reader)
+	if nil != err {
+		client.t.Fatalf("read failed: %s", err)
+	}
+	message := &testMessage{}
+	if err := json.Unmarshal(content, message); nil != err {
+		client.t.Fatalf("invalid message %s: %s", content, err)
+	}
+	return message
+}
+
+func (client *testClient) request(command string, args interface{}) *testMessage {
+	client.t.Helper()
+	client.seq++
+	request := map[string]interface{}{"seq": client.seq, "type": "request", "command": command}
+	if nil != args {
+		request["arguments"] = args
+	}
+	if err := WriteMessage(client.conn, request); nil != err {
+		client.t.Fatalf("write failed: %s", err)
+	}
+	for {
+		message := client.read()
+		if "event" == message.Type {
+			client.events = append(client.events, message)
+			continue
+		}
+		if client.seq != message.RequestSeq || command != message.Command {
+			client.t.Fatalf("unexpected response %v to %s", message, command)
+		}
+		return message
+	}
+}
+
+func (client *testClient) success(command string, args interface{}, body interface{}) {
+	client.t.Helper()
+	response := client.request(command, args)
+	if !response.Success {
+		client.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+	if nil != body {
+		response.decode(client.t, body)
+	}
+}
+
+func (client *testClient) event(name string) *testMessage {
+	client.t.Helper()
+	for a, event := range client.events {
+		if name == event.Event {
+			client.events = append(client.events[:a], client.events[a+1:]...)
+			return event
+		}
+	}
+	for {
+		message := client.read()
+		if "event" == message.Type && name == message.Event {
+			return message
+		}
+		if "event" == message.Type {
+			client.events = append(client.events, message)
+		}
+	}
+}
+
+func pausedEvent(line int, hitBreakpoints []string) map[string]interface{} {
+	return map[string]interface{}{
+		"reason":         "other",
+		"hitBreakpoints": hitBreakpoints,
+		"callFrames": []map[string]interface{}{{
+			"callFrameId":  "frame-1",
+			"functionName": "add",
+			"url":          "https://example.com/js/app.js",
+			"location":     map[string]interface{}{"scriptId": "7", "lineNumber": line - 1, "columnNumber": 2},
+			"scopeChain": []map[string]interface{}{
+				{"type": "local", "object": map[string]interface{}{"type": "object", "objectId": "local-1"}},
+				{"type": "closure", "name": "init", "object": map[string]interface{}{"type": "object", "objectId": "closure-1"}},
+				{"type": "global", "object": map[string]interface{}{"type": "object", "objectId": "global-1"}},
+				{"object": map[string]interface{}{"type": "object", "objectId": "untyped-1"}},
+			},
+			"this": map[string]interface{}{"type": "undefined"},
+		}},
+	}
+}
+
+func TestSession(t *testing.T) {
+	fake := newFakeChrome(t)
+	defer fake.server.Close()
+	calls := make(chan string, 100)
+	fake.Respond("Debugger.enable", func(params json.RawMessage) (interface{}, error) {
+		fake.Fire("Debugger.scriptParsed", map[string]interface{}{"scriptId": "7", "url": "https://example.com/js/app.js"})
+		return map[string]interface{}{}, nil
+	})
+	fake.Respond("Debugger.getPossibleBreakpoints", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"locations": []map[string]interface{}{
+			{"scriptId": "7", "lineNumber": 10, "columnNumber": 4},
+		}}, nil
+	})
+	fake.Respond("Debugger.setBreakpointByUrl", func(params json.RawMessage) (interface{}, error) {
+		calls <- "setBreakpointByUrl " + string(params)
+		return map[string]interface{}{"breakpointId": "bp-1", "locations": []interface{}{}}, nil
+	})
+	fake.Respond("Debugger.setPauseOnExceptions", func(params json.RawMessage) (interface{}, error) {
+		calls <- "setPauseOnExceptions " + string(params)
+		return map[string]interface{}{}, nil
+	})
+	fake.Respond("Runtime.getProperties", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			ObjectID string `json:"objectId"`
+		}{}
+		json.Unmarshal(params, &request)
+		properties := map[string][]map[string]interface{}{
+			"local-1": {
+				{"name": "a", "value": map[string]interface{}{"type": "number", "value": 2, "description": "2"}},
+				{"name": "options", "value": map[string]interface{}{"type": "object", "objectId": "obj-1", "className": "Object", "description": "Object"}},
+			},
+			"obj-1": {
+				{"name": "label", "value": map[string]interface{}{"type": "string", "value": "hi"}},
+				{"name": "none", "value": map[string]interface{}{"type": "object", "subtype": "null", "value": nil}},
+			},
+		}
+		return map[string]interface{}{"result": properties[request.ObjectID]}, nil
+	})
+	fake.Respond("Debugger.evaluateOnCallFrame", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "number", "value": 5, "description": "5"}}, nil
+	})
+	fake.Respond("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{
+			"result": map[string]interface{}{"type": "object"},
+			"exceptionDetails": map[string]interface{}{
+				"exceptionId": 1,
+				"text":        "Uncaught",
+				"exception":   map[string]interface{}{"type": "object", "description": "ReferenceError: missing is not defined"},
+			},
+		}, nil
+	})
+	fake.Respond("Debugger.stepOver", func(params json.RawMessage) (interface{}, error) {
+		fake.Fire("Debugger.paused", pausedEvent(12, nil))
+		return map[string]interface{}{}, nil
+	})
+	fake.Respond("Debugger.resume", func(params json.RawMessage) (interface{}, error) {
+		event := pausedEvent(14, nil)
+		event["reason"] = "exception"
+		event["data"] = map[string]interface{}{"type": "object", "subtype": "error", "description": "Error: boom"}
+		fake.Fire("Debugger.paused", event)
+		return map[string]interface{}{}, nil
+	})
+	fake.Respond("Debugger.disable", func(params json.RawMessage) (interface{}, error) {
+		calls <- "disable"
+		return map[string]interface{}{}, nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("Listen failed: %s", err)
+	}
+	go Serve(listener)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("Dial failed: %s", err)
+	}
+	defer conn.Close()
+	client := &testClient{conn: conn, reader: bufio.NewReader(conn), t: t}
+
+	capabilities := &Capabilities{}
+	client.success("initialize", map[string]interface{}{"adapterID": "chrome"}, capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest || 2 != len(capabilities.ExceptionBreakpointFilters) {
+		t.Errorf("Unexpected capabilities %v", capabilities)
+	}
+	if response := client.request("threads", nil); response.Success {
+		t.Errorf("Expected requests to fail before attaching")
+	}
+
+	port := fake.server.Listener.Addr().(*net.TCPAddr).Port
+	client.success("attach", map[string]interface{}{
+		"address": "127.0.0.1",
+		"port":    port,
+		"url":     "example.com",
+		"webRoot": "/srv/www",
+	}, nil)
+	client.event("initialized")
+
+	breakpoints := struct{ Breakpoints []Breakpoint }{}
+	client.success("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "/srv/www/js/app.js"},
+		"breakpoints": []map[string]interface{}{{"line": 10, "condition": "a == 1"}},
+	}, &breakpoints)
+	if 1 != len(breakpoints.Breakpoints) || !breakpoints.Breakpoints[0].Verified || 11 != breakpoints.Breakpoints[0].Line {
+		t.Errorf("Unexpected breakpoints %v", breakpoints)
+	}
+	if call := <-calls; !strings.Contains(call, `"lineNumber":10`) || !strings.Contains(call, `js/app\\.js`) ||
+		!strings.Contains(call, `"condition":"a == 1"`) {
+		t.Errorf("Unexpected breakpoint %s", call)
+	}
+	client.success("setExceptionBreakpoints", map[string]interface{}{"filters": []string{"uncaught"}}, nil)
+	if call := <-calls; `setPauseOnExceptions {"state":"uncaught"}` != call {
+		t.Errorf("Unexpected exception state %s", call)
+	}
+	client.success("configurationDone", nil, nil)
+
+	// The page hits the breakpoint.
+	fake.Fire("Debugger.paused", pausedEvent(11, []string{"bp-1"}))
+	stopped := struct {
+		Reason   string
+		ThreadID int
+		Text     string
+	}{}
+	client.event("stopped").decode(t, &stopped)
+	if "breakpoint" != stopped.Reason || threadID != stopped.ThreadID {
+		t.Errorf("Unexpected stopped event %v", stopped)
+	}
+
+	threads := struct{ Threads []Thread }{}
+	client.success("threads", nil, &threads)
+	if 1 != len(threads.Threads) {
+		t.Errorf("Expected one thread, received %v", threads)
+	}
+	stackTrace := struct {
+		StackFrames []StackFrame
+		TotalFrames int
+	}{}
+	client.success("stackTrace", map[string]interface{}{"threadId": threadID}, &stackTrace)
+	if 1 != len(stackTrace.StackFrames) || 1 != stackTrace.TotalFrames {
+		t.Fatalf("Unexpected stack trace %v", stackTrace)
+	}
+	frame := stackTrace.StackFrames[0]
+	if "add" != frame.Name || 11 != frame.Line || 3 != frame.Column || "/srv/www/js/app.js" != frame.Source.Path {
+		t.Errorf("Unexpected frame %v %v", frame, frame.Source)
+	}
+
+	scopes := struct{ Scopes []Scope }{}
+	client.success("scopes", map[string]interface{}{"frameId": frame.ID}, &scopes)
+	if 4 != len(scopes.Scopes) || "Local" != scopes.Scopes[0].Name || "Closure (init)" != scopes.Scopes[1].Name ||
+		!scopes.Scopes[2].Expensive || "Scope" != scopes.Scopes[3].Name {
+		t.Fatalf("Unexpected scopes %v", scopes)
+	}
+	variables := struct{ Variables []Variable }{}
+	client.success("variables", map[string]interface{}{"variablesReference": scopes.Scopes[0].VariablesReference}, &variables)
+	if 2 != len(variables.Variables) || "2" != variables.Variables[0].Value || "Object" != variables.Variables[1].Value ||
+		0 == variables.Variables[1].VariablesReference {
+		t.Fatalf("Unexpected variables %v", variables)
+	}
+	client.success("variables", map[string]interface{}{"variablesReference": variables.Variables[1].VariablesReference}, &variables)
+	if 2 != len(variables.Variables) || `"hi"` != variables.Variables[0].Value || "null" != variables.Variables[1].Value {
+		t.Errorf("Unexpected properties %v", variables)
+	}
+
+	result := struct{ Result string }{}
+	client.success("evaluate", map[string]interface{}{"expression": "a + 3", "frameId": frame.ID}, &result)
+	if "5" != result.Result {
+		t.Errorf("Unexpected result %v", result)
+	}
+	if response := client.request("evaluate", map[string]interface{}{"expression": "missing"}); response.Success ||
+		"ReferenceError: missing is not defined" != response.Message {
+		t.Errorf("Expected a ReferenceError, received %v", response)
+	}
+
+	client.success("next", map[string]interface{}{"threadId": threadID}, nil)
+	client.event("stopped").decode(t, &stopped)
+	if "step" != stopped.Reason {
+		t.Errorf("Expected a step, received %v", stopped)
+	}
+	client.success("continue", map[string]interface{}{"threadId": threadID}, nil)
+	client.event("stopped").decode(t, &stopped)
+	if "exception" != stopped.Reason || "Error: boom" != stopped.Text {
+		t.Errorf("Expected an exception, received %v", stopped)
+	}
+
+	client.success("disconnect", nil, nil)
+	if call := <-calls; "disable" != call {
+		t.Errorf("Expected the debugger to be disabled, received %s", call)
+	}
+	if _, err := ReadMessage(client.reader); nil == err {
+		t.Errorf("Expected the connection to be closed")
+	}
+}
+
+func TestSessionLaunchFailure(t *testing.T) {
+	reader, requests := net.Pipe()
+	session := NewSession(reader, reader)
+	done := make(chan error)
+	go func() {
+		done <- session.Run()
+	}()
+	client := &testClient{conn: requests, reader: bufio.NewReader(requests), t: t}
+	response := client.request("launch", map[string]interface{}{
+		"chromePath": "/nonexistent/chrome",
+		"url":        "https://example.com/",
+	})
+	if response.Success || "" == response.Message {
+		t.Errorf("Expected the launch to fail, received %v", response)
+	}
+	if response := client.request("stackTrace", nil); response.Success {
+		t.Errorf("Expected stackTrace to fail without a debuggee")
+	}
+	requests.Close()
+	if err := <-done; nil != err {
+		t.Errorf("Expected the session to end when the client closes the connection, received %s", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("Content-Type: application/json\r\ncontent-length: 2\r\n\r\n{}Content-Length: x\r\n\r\n"))
+	content, err := ReadMessage(reader)
+	if nil != err || "{}" != string(content) {
+		t.Errorf("Unexpected message %q: %v", content, err)
+	}
+	if _, err := ReadMessage(reader); nil == err {
+		t.Errorf("Expected an error for an invalid content length")
+	}
+	if _, err := ReadMessage(bufio.NewReader(strings.NewReader("\r\n"))); nil == err {
+		t.Errorf("Expected an error for a missing content length")
+	}
+}
@@ -0,0 +1,377 @@
+package heapsnapshot
+
+import (
+	"sort"
+)
+
+/*
+index builds the reverse edge index, the dominator tree and the retained sizes
+on first use.
+*/
+func (snapshot *Snapshot) index() {
+	if nil != snapshot.retained {
+		return
+	}
+	count := snapshot.NodeCount()
+
+	// Reverse edge index: retainers[firstRetainer[n]:firstRetainer[n+1]]
+	// contains the offsets of the edges referencing node n.
+	firstRetainer := make([]int, count+1)
+	for offset := 0; offset < len(snapshot.edges); offset += snapshot.edgeFields {
+		firstRetainer[snapshot.edges[offset+snapshot.edgeToNode]/snapshot.nodeFields+1]++
+	}
+	for a := 1; a <= count; a++ {
+		firstRetainer[a] += firstRetainer[a-1]
+	}
+	retainers := make([]int, len(snapshot.edges)/snapshot.edgeFields)
+	retainerFrom := make([]int, len(retainers))
+	next := append([]int{}, firstRetainer...)
+	for from := 0; from < count; from++ {
+		for offset := snapshot.firstEdge[from]; offset < snapshot.firstEdge[from+1]; offset += snapshot.edgeFields {
+			to := snapshot.edges[offset+snapshot.edgeToNode] / snapshot.nodeFields
+			retainers[next[to]] = offset
+			retainerFrom[next[to]] = from
+			next[to]++
+		}
+	}
+	snapshot.firstRetainer = firstRetainer
+	snapshot.retainers = retainers
+	snapshot.retainerFrom = retainerFrom
+
+	snapshot.dominate()
+
+	snapshot.retained = make([]int64, count)
+	for _, node := range snapshot.postorder {
+		snapshot.retained[node] += int64(snapshot.Node(node).SelfSize())
+		if dominator := snapshot.dominators[node]; node != dominator && dominator >= 0 {
+			snapshot.retained[dominator] += snapshot.retained[node]
+		}
+	}
+	for node := 0; node < count; node++ {
+		if snapshot.dominators[node] < 0 {
+			snapshot.retained[node] = int64(snapshot.Node(node).SelfSize())
+		}
+	}
+}
+
+/*
+followed checks if an edge is part of the retaining graph. Weak references
+don't retain objects, shortcut edges of the root are synthetic.
+*/
+func (snapshot *Snapshot) followed(from, offset int) bool {
+	switch snapshot.edgeTypeName(offset) {
+	case "weak":
+		return false
+	case "shortcut":
+		return 0 != from
+	}
+	return true
+}
+
+func (snapshot *Snapshot) edgeTypeName(offset int) string {
+	edgeType := snapshot.edges[offset+snapshot.edgeType]
+	if edgeType < 0 || edgeType >= len(snapshot.edgeTypes) {
+		return ""
+	}
+	return snapshot.edgeTypes[edgeType]
+}
+
+/*
+dominate computes the immediate dominator of every node reachable from the
+root, using the iterative algorithm of Cooper, Harvey and Kennedy.
+Unreachable nodes have no dominator (-1).
+*/
+func (snapshot *Snapshot) dominate() {
+	count := snapshot.NodeCount()
+
+	// Depth first postorder from the root.
+	postIndex := make([]int, count)
+	for a := range postIndex {
+		postIndex[a] = -1
+	}
+	visited := make([]bool, count)
+	postorder := []int{}
+	type frame struct{ node, offset int }
+	stack := []frame{{0, snapshot.firstEdge[0]}}
+	visited[0] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.offset >= snapshot.firstEdge[top.node+1] {
+			postIndex[top.node] = len(postorder)
+			postorder = append(postorder, top.node)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		offset := top.offset
+		top.offset += snapshot.edgeFields
+		if !snapshot.followed(top.node, offset) {
+			continue
+		}
+		to := snapshot.edges[offset+snapshot.edgeToNode] / snapshot.nodeFields
+		if !visited[to] {
+			visited[to] = true
+			stack = append(stack, frame{to, snapshot.firstEdge[to]})
+		}
+	}
+
+	dominators := make([]int, count)
+	for a := range dominators {
+		dominators[a] = -1
+	}
+	dominators[0] = 0
+	intersect := func(a, b int) int {
+		for a != b {
+			for postIndex[a] < postIndex[b] {
+				a = dominators[a]
+			}
+			for postIndex[b] < postIndex[a] {
+				b = dominators[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for a := len(postorder) - 2; a >= 0; a-- {
+			node := postorder[a]
+			dominator := -1
+			for b := snapshot.firstRetainer[node]; b < snapshot.firstRetainer[node+1]; b++ {
+				from := snapshot.retainerFrom[b]
+				if postIndex[from] < 0 || dominators[from] < 0 || !snapshot.followed(from, snapshot.retainers[b]) {
+					continue
+				}
+				if dominator < 0 {
+					dominator = from
+				} else {
+					dominator = intersect(from, dominator)
+				}
+			}
+			if dominator != dominators[node] {
+				dominators[node] = dominator
+				changed = true
+			}
+		}
+	}
+
+	snapshot.dominators = dominators
+	snapshot.postorder = postorder
+}
+
+/*
+RetainedSize returns the size of the node plus the size of all nodes that are
+only reachable through it, i.e. the memory freed if the node was collected.
+*/
+func (node Node) RetainedSize() int64 {
+	node.snapshot.index()
+	return node.snapshot.retained[node.Index]
+}
+
+/*
+Dominator returns the immediate dominator of the node. It returns false for
+the root and for nodes that are unreachable from the root.
+*/
+func (node Node) Dominator() (Node, bool) {
+	node.snapshot.index()
+	dominator := node.snapshot.dominators[node.Index]
+	if dominator < 0 || 0 == node.Index {
+		return Node{}, false
+	}
+	return node.snapshot.Node(dominator), true
+}
+
+/*
+Retainers returns the references to the node.
+*/
+func (node Node) Retainers() []Edge {
+	snapshot := node.snapshot
+	snapshot.index()
+	edges := []Edge{}
+	for a := snapshot.firstRetainer[node.Index]; a < snapshot.firstRetainer[node.Index+1]; a++ {
+		edges = append(edges, snapshot.edge(snapshot.retainerFrom[a], snapshot.retainers[a]))
+	}
+	return edges
+}
+
+/*
+Path is a chain of references starting at the root.
+*/
+type Path []Edge
+
+/*
+String formats the path like a property access starting at the first object
+below the root, e.g. "Window.cache[3].element".
+*/
+func (path Path) String() string {
+	if 0 == len(path) {
+		return ""
+	}
+	str := path[0].To.ClassName()
+	for _, edge := range path[1:] {
+		str += edge.String()
+	}
+	return str
+}
+
+/*
+RetainerPath returns the shortest chain of references from the root to the
+node, ignoring weak references. It returns nil if the node is unreachable.
+*/
+func (node Node) RetainerPath() Path {
+	snapshot := node.snapshot
+	snapshot.index()
+	if nil == snapshot.pathEdge {
+		count := snapshot.NodeCount()
+		snapshot.pathEdge = make([]int, count)
+		snapshot.pathFrom = make([]int, count)
+		for a := range snapshot.pathFrom {
+			snapshot.pathFrom[a] = -1
+		}
+		snapshot.pathFrom[0] = 0
+		queue := []int{0}
+		for len(queue) > 0 {
+			from := queue[0]
+			queue = queue[1:]
+			for offset := snapshot.firstEdge[from]; offset < snapshot.firstEdge[from+1]; offset += snapshot.edgeFields {
+				if !snapshot.followed(from, offset) {
+					continue
+				}
+				to := snapshot.edges[offset+snapshot.edgeToNode] / snapshot.nodeFields
+				if snapshot.pathFrom[to] < 0 {
+					snapshot.pathFrom[to] = from
+					snapshot.pathEdge[to] = offset
+					queue = append(queue, to)
+				}
+			}
+		}
+	}
+
+	if snapshot.pathFrom[node.Index] < 0 {
+		return nil
+	}
+	path := Path{}
+	for current := node.Index; 0 != current; current = snapshot.pathFrom[current] {
+		path = append(Path{snapshot.edge(snapshot.pathFrom[current], snapshot.pathEdge[current])}, path...)
+	}
+	return path
+}
+
+/*
+DetachedNodes returns the DOM nodes that are no longer attached to a document
+but are still reachable from the root, largest retained size first.
+*/
+func (snapshot *Snapshot) DetachedNodes() []Node {
+	snapshot.index()
+	nodes := []Node{}
+	for a := 0; a < snapshot.NodeCount(); a++ {
+		node := snapshot.Node(a)
+		if node.Detached() && snapshot.dominators[a] >= 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.SliceStable(nodes, func(a, b int) bool {
+		return nodes[a].RetainedSize() > nodes[b].RetainedSize()
+	})
+	return nodes
+}
+
+/*
+Class is the summary of all nodes with the same class name.
+*/
+type Class struct {
+	// Name is the class name, see Node.ClassName().
+	Name string
+
+	// Count is the number of nodes.
+	Count int
+
+	// SelfSize is the sum of the shallow sizes.
+	SelfSize int64
+
+	// RetainedSize is the memory retained by the nodes. Nodes dominated by
+	// another node of the same class are only counted once.
+	RetainedSize int64
+
+	// Nodes contains the ordinal indexes of the nodes.
+	Nodes []int
+}
+
+/*
+Aggregate summarizes the reachable nodes by class name, like the DevTools
+summary view. Classes are sorted by retained size.
+*/
+func (snapshot *Snapshot) Aggregate() []*Class {
+	snapshot.index()
+	count := snapshot.NodeCount()
+	classes := map[string]*Class{}
+	for a := 1; a < count; a++ {
+		if snapshot.dominators[a] < 0 {
+			continue
+		}
+		node := snapshot.Node(a)
+		name := node.ClassName()
+		class, ok := classes[name]
+		if !ok {
+			class = &Class{Name: name}
+			classes[name] = class
+		}
+		class.Count++
+		class.SelfSize += int64(node.SelfSize())
+		class.Nodes = append(class.Nodes, a)
+	}
+
+	// Walk the dominator tree and only count the retained size of the
+	// outermost node of each class.
+	firstChild := make([]int, count+1)
+	for a := 1; a < count; a++ {
+		if dominator := snapshot.dominators[a]; dominator >= 0 {
+			firstChild[dominator+1]++
+		}
+	}
+	for a := 1; a <= count; a++ {
+		firstChild[a] += firstChild[a-1]
+	}
+	children := make([]int, firstChild[count])
+	next := append([]int{}, firstChild...)
+	for a := 1; a < count; a++ {
+		if dominator := snapshot.dominators[a]; dominator >= 0 {
+			children[next[dominator]] = a
+			next[dominator]++
+		}
+	}
+	active := map[string]int{}
+	type frame struct {
+		node, child int
+		name        string
+	}
+	stack := []frame{{node: 0, child: firstChild[0]}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child >= firstChild[top.node+1] {
+			if "" != top.name {
+				active[top.name]--
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := children[top.child]
+		top.child++
+		name := snapshot.Node(child).ClassName()
+		if 0 == active[name] {
+			classes[name].RetainedSize += snapshot.retained[child]
+		}
+		active[name]++
+		stack = append(stack, frame{node: child, child: firstChild[child], name: name})
+	}
+
+	result := make([]*Class, 0, len(classes))
+	for _, class := range classes {
+		result = append(result, class)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].RetainedSize != result[b].RetainedSize {
+			return result[a].RetainedSize > result[b].RetainedSize
+		}
+		return result[a].Name < result[b].Name
+	})
+	return result
+}
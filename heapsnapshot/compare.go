@@ -0,0 +1,96 @@
+package heapsnapshot
+
+import (
+	"sort"
+)
+
+/*
+ClassDelta is the difference of the nodes of a class between two snapshots.
+*/
+type ClassDelta struct {
+	// Name is the class name, see Node.ClassName().
+	Name string
+
+	// Added is the number of nodes that only exist in the current snapshot.
+	Added int
+
+	// Removed is the number of nodes that only exist in the base snapshot.
+	Removed int
+
+	// SizeDelta is the self size of the added nodes minus the self size of
+	// the removed nodes.
+	SizeDelta int64
+
+	// New contains the added nodes of the current snapshot.
+	New []Node
+}
+
+/*
+Count returns the change of the number of nodes.
+*/
+func (delta *ClassDelta) Count() int {
+	return delta.Added - delta.Removed
+}
+
+/*
+Compare returns the reachable nodes that were added or removed between two
+snapshots of the same page, grouped by class name. Nodes are matched by their
+snapshot object ID, which is stable across snapshots taken in the same
+DevTools session. Classes are sorted by the change of the number of nodes,
+largest growth first, classes without changes are omitted.
+*/
+func Compare(base, current *Snapshot) []*ClassDelta {
+	base.index()
+	current.index()
+	baseIDs := map[int]bool{}
+	for a := 1; a < base.NodeCount(); a++ {
+		if base.dominators[a] >= 0 {
+			baseIDs[base.Node(a).ID()] = true
+		}
+	}
+	currentIDs := map[int]bool{}
+	for a := 1; a < current.NodeCount(); a++ {
+		if current.dominators[a] >= 0 {
+			currentIDs[current.Node(a).ID()] = true
+		}
+	}
+
+	deltas := map[string]*ClassDelta{}
+	class := func(name string) *ClassDelta {
+		delta, ok := deltas[name]
+		if !ok {
+			delta = &ClassDelta{Name: name}
+			deltas[name] = delta
+		}
+		return delta
+	}
+	for a := 1; a < current.NodeCount(); a++ {
+		node := current.Node(a)
+		if current.dominators[a] >= 0 && !baseIDs[node.ID()] {
+			delta := class(node.ClassName())
+			delta.Added++
+			delta.SizeDelta += int64(node.SelfSize())
+			delta.New = append(delta.New, node)
+		}
+	}
+	for a := 1; a < base.NodeCount(); a++ {
+		node := base.Node(a)
+		if base.dominators[a] >= 0 && !currentIDs[node.ID()] {
+			delta := class(node.ClassName())
+			delta.Removed++
+			delta.SizeDelta -= int64(node.SelfSize())
+		}
+	}
+
+	result := make([]*ClassDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		result = append(result, delta)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Count() != result[b].Count() {
+			return result[a].Count() > result[b].Count()
+		}
+		return result[a].Name < result[b].Name
+	})
+	return result
+}
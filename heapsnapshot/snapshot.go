@@ -0,0 +1,500 @@
+/*
+Package heapsnapshot parses V8 .heapsnapshot files and analyses the heap
+graph.
+
+A snapshot is a graph of nodes (objects) and edges (references) stored as flat
+integer arrays described by the snapshot meta data. Parse() streams the file
+and keeps only the flat arrays and the string table in memory. Retained sizes
+are computed from the dominator tree of the graph, rooted at the synthetic
+root node.
+*/
+package heapsnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+/*
+Meta describes the layout of the flat snapshot arrays.
+*/
+type Meta struct {
+	NodeFields     []string        `json:"node_fields"`
+	NodeTypes      json.RawMessage `json:"node_types"`
+	EdgeFields     []string        `json:"edge_fields"`
+	EdgeTypes      json.RawMessage `json:"edge_types"`
+	LocationFields []string        `json:"location_fields"`
+}
+
+/*
+header is the "snapshot" object of the file.
+*/
+type header struct {
+	Meta      Meta `json:"meta"`
+	NodeCount int  `json:"node_count"`
+	EdgeCount int  `json:"edge_count"`
+}
+
+/*
+Snapshot is a parsed heap snapshot.
+*/
+type Snapshot struct {
+	// Meta is the snapshot meta data.
+	Meta Meta
+
+	// Strings is the string table.
+	Strings []string
+
+	nodes     []int
+	edges     []int
+	locations map[int]*Location
+
+	nodeFields int
+	nodeTypes  []string
+	edgeFields int
+	edgeTypes  []string
+
+	// Node field offsets.
+	nodeType, nodeName, nodeID, nodeSelfSize, nodeEdgeCount, nodeDetachedness int
+
+	// Edge field offsets.
+	edgeType, edgeName, edgeToNode int
+
+	// firstEdge contains the offset of the first edge of each node in the
+	// edges array, with a final entry for the end of the array.
+	firstEdge []int
+
+	// Reverse edge index, see index().
+	firstRetainer []int
+	retainers     []int
+	retainerFrom  []int
+
+	dominators []int
+	postorder  []int
+	retained   []int64
+
+	// Shortest retainer paths, see RetainerPath().
+	pathFrom []int
+	pathEdge []int
+}
+
+/*
+Location is the source location of a closure or object allocation.
+Lines and columns are zero-based.
+*/
+type Location struct {
+	ScriptID int
+	Line     int
+	Column   int
+}
+
+/*
+Parse reads a heap snapshot.
+*/
+func Parse(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	var locations []int
+	var head *header
+
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); nil != err || json.Delim('{') != token {
+		return nil, fmt.Errorf("heapsnapshot: expected a JSON object")
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if nil != err {
+			return nil, fmt.Errorf("heapsnapshot: invalid snapshot: %s", err)
+		}
+		switch key {
+		case "snapshot":
+			head = &header{}
+			err = decoder.Decode(head)
+		case "nodes":
+			snapshot.nodes, err = decodeInts(decoder)
+		case "edges":
+			snapshot.edges, err = decodeInts(decoder)
+		case "locations":
+			locations, err = decodeInts(decoder)
+		case "strings":
+			err = decoder.Decode(&snapshot.Strings)
+		default:
+			var skip json.RawMessage
+			err = decoder.Decode(&skip)
+		}
+		if nil != err {
+			return nil, fmt.Errorf("heapsnapshot: invalid value for '%v': %s", key, err)
+		}
+	}
+	if nil == head {
+		return nil, fmt.Errorf("heapsnapshot: missing snapshot meta data")
+	}
+	snapshot.Meta = head.Meta
+
+	if err := snapshot.init(locations); nil != err {
+		return nil, err
+	}
+	if head.NodeCount > 0 && head.NodeCount != snapshot.NodeCount() {
+		return nil, fmt.Errorf("heapsnapshot: expected %d nodes, found %d", head.NodeCount, snapshot.NodeCount())
+	}
+	return snapshot, nil
+}
+
+/*
+ParseFile reads a .heapsnapshot file.
+*/
+func ParseFile(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+/*
+decodeInts decodes a JSON array of integers one value at a time.
+*/
+func decodeInts(decoder *json.Decoder) ([]int, error) {
+	if token, err := decoder.Token(); nil != err || json.Delim('[') != token {
+		return nil, fmt.Errorf("expected an array")
+	}
+	decoder.UseNumber()
+	values := []int{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if nil != err {
+			return nil, err
+		}
+		number, ok := token.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, found %v", token)
+		}
+		value, err := strconv.Atoi(string(number))
+		if nil != err {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	_, err := decoder.Token()
+	return values, err
+}
+
+/*
+init resolves the field layout and indexes the edges of each node.
+*/
+func (snapshot *Snapshot) init(locations []int) error {
+	meta := snapshot.Meta
+	snapshot.nodeFields = len(meta.NodeFields)
+	snapshot.edgeFields = len(meta.EdgeFields)
+	if 0 == snapshot.nodeFields || 0 == snapshot.edgeFields {
+		return fmt.Errorf("heapsnapshot: missing node or edge fields")
+	}
+
+	index := func(fields []string, name string) int {
+		for a, field := range fields {
+			if name == field {
+				return a
+			}
+		}
+		return -1
+	}
+	snapshot.nodeType = index(meta.NodeFields, "type")
+	snapshot.nodeName = index(meta.NodeFields, "name")
+	snapshot.nodeID = index(meta.NodeFields, "id")
+	snapshot.nodeSelfSize = index(meta.NodeFields, "self_size")
+	snapshot.nodeEdgeCount = index(meta.NodeFields, "edge_count")
+	snapshot.nodeDetachedness = index(meta.NodeFields, "detachedness")
+	snapshot.edgeType = index(meta.EdgeFields, "type")
+	snapshot.edgeName = index(meta.EdgeFields, "name_or_index")
+	snapshot.edgeToNode = index(meta.EdgeFields, "to_node")
+	for name, field := range map[string]int{
+		"type":          snapshot.nodeType,
+		"name":          snapshot.nodeName,
+		"id":            snapshot.nodeID,
+		"self_size":     snapshot.nodeSelfSize,
+		"edge_count":    snapshot.nodeEdgeCount,
+		"edge type":     snapshot.edgeType,
+		"name_or_index": snapshot.edgeName,
+		"to_node":       snapshot.edgeToNode,
+	} {
+		if field < 0 {
+			return fmt.Errorf("heapsnapshot: missing field '%s'", name)
+		}
+	}
+
+	var err error
+	if snapshot.nodeTypes, err = enumTypes(meta.NodeTypes, snapshot.nodeType); nil != err {
+		return fmt.Errorf("heapsnapshot: invalid node types: %s", err)
+	}
+	if snapshot.edgeTypes, err = enumTypes(meta.EdgeTypes, snapshot.edgeType); nil != err {
+		return fmt.Errorf("heapsnapshot: invalid edge types: %s", err)
+	}
+
+	if 0 != len(snapshot.nodes)%snapshot.nodeFields || 0 != len(snapshot.edges)%snapshot.edgeFields {
+		return fmt.Errorf("heapsnapshot: truncated node or edge array")
+	}
+	count := snapshot.NodeCount()
+	snapshot.firstEdge = make([]int, count+1)
+	offset := 0
+	for a := 0; a < count; a++ {
+		snapshot.firstEdge[a] = offset
+		offset += snapshot.nodes[a*snapshot.nodeFields+snapshot.nodeEdgeCount] * snapshot.edgeFields
+	}
+	snapshot.firstEdge[count] = offset
+	if offset != len(snapshot.edges) {
+		return fmt.Errorf("heapsnapshot: edge counts don't match the edge array")
+	}
+	for a := 0; a < len(snapshot.edges); a += snapshot.edgeFields {
+		to := snapshot.edges[a+snapshot.edgeToNode]
+		if to < 0 || to >= len(snapshot.nodes) || 0 != to%snapshot.nodeFields {
+			return fmt.Errorf("heapsnapshot: edge %d references invalid node offset %d", a/snapshot.edgeFields, to)
+		}
+	}
+
+	snapshot.locations = map[int]*Location{}
+	fields := len(meta.LocationFields)
+	objectIndex := index(meta.LocationFields, "object_index")
+	scriptID := index(meta.LocationFields, "script_id")
+	line := index(meta.LocationFields, "line")
+	column := index(meta.LocationFields, "column")
+	if fields > 0 && objectIndex >= 0 && scriptID >= 0 && line >= 0 && column >= 0 {
+		for a := 0; a+fields <= len(locations); a += fields {
+			snapshot.locations[locations[a+objectIndex]/snapshot.nodeFields] = &Location{
+				ScriptID: locations[a+scriptID],
+				Line:     locations[a+line],
+				Column:   locations[a+column],
+			}
+		}
+	}
+	return nil
+}
+
+/*
+enumTypes returns the names of an enumerated field type. Field types are
+either a list of names or a type name such as "string" or "number".
+*/
+func enumTypes(data json.RawMessage, field int) ([]string, error) {
+	types := []json.RawMessage{}
+	if err := json.Unmarshal(data, &types); nil != err {
+		return nil, err
+	}
+	if field >= len(types) {
+		return nil, fmt.Errorf("no type for field %d", field)
+	}
+	names := []string{}
+	if err := json.Unmarshal(types[field], &names); nil != err {
+		return nil, err
+	}
+	return names, nil
+}
+
+/*
+NodeCount returns the number of nodes.
+*/
+func (snapshot *Snapshot) NodeCount() int {
+	return len(snapshot.nodes) / snapshot.nodeFields
+}
+
+/*
+EdgeCount returns the number of edges.
+*/
+func (snapshot *Snapshot) EdgeCount() int {
+	return len(snapshot.edges) / snapshot.edgeFields
+}
+
+/*
+Root returns the synthetic root node.
+*/
+func (snapshot *Snapshot) Root() Node {
+	return Node{snapshot: snapshot, Index: 0}
+}
+
+/*
+Node returns the node with the specified ordinal index.
+*/
+func (snapshot *Snapshot) Node(index int) Node {
+	return Node{snapshot: snapshot, Index: index}
+}
+
+/*
+NodeByID returns the node with the specified snapshot object ID.
+*/
+func (snapshot *Snapshot) NodeByID(id int) (Node, bool) {
+	for a := 0; a < snapshot.NodeCount(); a++ {
+		if id == snapshot.nodes[a*snapshot.nodeFields+snapshot.nodeID] {
+			return snapshot.Node(a), true
+		}
+	}
+	return Node{}, false
+}
+
+/*
+Node is a heap object.
+*/
+type Node struct {
+	snapshot *Snapshot
+
+	// Index is the ordinal index of the node.
+	Index int
+}
+
+func (node Node) field(field int) int {
+	return node.snapshot.nodes[node.Index*node.snapshot.nodeFields+field]
+}
+
+/*
+Type returns the node type, e.g. "object", "closure" or "string".
+*/
+func (node Node) Type() string {
+	value := node.field(node.snapshot.nodeType)
+	if value < 0 || value >= len(node.snapshot.nodeTypes) {
+		return "unknown"
+	}
+	return node.snapshot.nodeTypes[value]
+}
+
+/*
+Name returns the node name. For objects this is the constructor name.
+*/
+func (node Node) Name() string {
+	return node.snapshot.str(node.field(node.snapshot.nodeName))
+}
+
+/*
+ID returns the snapshot object ID of the node. IDs are stable across
+snapshots taken in the same session.
+*/
+func (node Node) ID() int {
+	return node.field(node.snapshot.nodeID)
+}
+
+/*
+SelfSize returns the shallow size of the node in bytes.
+*/
+func (node Node) SelfSize() int {
+	return node.field(node.snapshot.nodeSelfSize)
+}
+
+/*
+Edges returns the outgoing references of the node.
+*/
+func (node Node) Edges() []Edge {
+	snapshot := node.snapshot
+	edges := []Edge{}
+	for offset := snapshot.firstEdge[node.Index]; offset < snapshot.firstEdge[node.Index+1]; offset += snapshot.edgeFields {
+		edges = append(edges, snapshot.edge(node.Index, offset))
+	}
+	return edges
+}
+
+/*
+Location returns the source location of the node, if recorded.
+*/
+func (node Node) Location() (*Location, bool) {
+	location, ok := node.snapshot.locations[node.Index]
+	return location, ok
+}
+
+/*
+ClassName returns the name used to aggregate nodes, as displayed in the
+DevTools summary view.
+*/
+func (node Node) ClassName() string {
+	switch node.Type() {
+	case "object", "native":
+		return node.Name()
+	case "hidden":
+		return "(system)"
+	case "code":
+		return "(compiled code)"
+	default:
+		return "(" + node.Type() + ")"
+	}
+}
+
+/*
+Detached checks if the node is a DOM node that is no longer attached to a
+document.
+*/
+func (node Node) Detached() bool {
+	// Detachedness is 0 (unknown), 1 (attached) or 2 (detached).
+	if node.snapshot.nodeDetachedness >= 0 {
+		if detachedness := node.field(node.snapshot.nodeDetachedness); 0 != detachedness {
+			return 2 == detachedness
+		}
+	}
+	name := node.Name()
+	return "native" == node.Type() && len(name) > 9 && "Detached " == name[:9]
+}
+
+/*
+String implements Stringer.
+*/
+func (node Node) String() string {
+	return fmt.Sprintf("%s @%d", node.ClassName(), node.ID())
+}
+
+/*
+Edge is a reference between two nodes.
+*/
+type Edge struct {
+	// Type of the edge, e.g. "property", "element" or "context".
+	Type string
+
+	// Name is the property name or the element index.
+	Name string
+
+	// From is the retaining node.
+	From Node
+
+	// To is the retained node.
+	To Node
+}
+
+/*
+String implements Stringer.
+*/
+func (edge Edge) String() string {
+	switch edge.Type {
+	case "element", "hidden":
+		return "[" + edge.Name + "]"
+	case "context":
+		return "<context>." + edge.Name
+	default:
+		return "." + edge.Name
+	}
+}
+
+/*
+edge decodes the edge at an offset of the edges array.
+*/
+func (snapshot *Snapshot) edge(from, offset int) Edge {
+	edge := Edge{
+		From: snapshot.Node(from),
+		To:   snapshot.Node(snapshot.edges[offset+snapshot.edgeToNode] / snapshot.nodeFields),
+	}
+	edgeType := snapshot.edges[offset+snapshot.edgeType]
+	if edgeType >= 0 && edgeType < len(snapshot.edgeTypes) {
+		edge.Type = snapshot.edgeTypes[edgeType]
+	}
+	name := snapshot.edges[offset+snapshot.edgeName]
+	if "element" == edge.Type || "hidden" == edge.Type {
+		edge.Name = strconv.Itoa(name)
+	} else {
+		edge.Name = snapshot.str(name)
+	}
+	return edge
+}
+
+/*
+str returns an entry of the string table.
+*/
+func (snapshot *Snapshot) str(index int) string {
+	if index < 0 || index >= len(snapshot.Strings) {
+		return ""
+	}
+	return snapshot.Strings[index]
+}
@@ -0,0 +1,192 @@
+package heapsnapshot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+testSnapshot is a small heap:
+
+	(root) -> Window -> .foo Foo -> .next Foo
+	                 -> .list Array -> [0] Detached HTMLDivElement -> .text "hello"
+	                                -> [1] "hello"
+	                 -> .cache (weak) Bar
+	Lost (unreachable)
+*/
+const testSnapshot = `{
+"snapshot": {
+	"meta": {
+		"node_fields": ["type","name","id","self_size","edge_count","trace_node_id","detachedness"],
+		"node_types": [["hidden","array","string","object","code","closure","regexp","number","native","synthetic"],"string","number","number","number","number","number"],
+		"edge_fields": ["type","name_or_index","to_node"],
+		"edge_types": [["context","element","property","internal","hidden","shortcut","weak"],"string_or_number","node"],
+		"location_fields": ["object_index","script_id","line","column"]
+	},
+	"node_count": 9,
+	"edge_count": 8
+},
+"nodes": [
+	9,0,1,0,1,0,0,
+	3,1,3,10,3,0,1,
+	3,2,5,20,1,0,0,
+	3,2,7,30,0,0,0,
+	3,3,9,40,2,0,0,
+	8,4,11,50,1,0,2,
+	2,5,13,60,0,0,0,
+	3,6,15,70,0,0,0,
+	3,7,17,80,0,0,0
+],
+"edges": [
+	1,1,7,
+	2,8,14, 2,9,28, 6,12,56,
+	2,10,21,
+	1,0,35, 1,1,42,
+	2,11,42
+],
+"trace_function_infos": [],
+"locations": [14,3,10,4],
+"strings": ["","Window","Foo","Array","Detached HTMLDivElement","hello","Lost","Bar","foo","list","next","text","cache"]
+}`
+
+func TestParse(t *testing.T) {
+	snapshot, err := Parse(strings.NewReader(testSnapshot))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if 9 != snapshot.NodeCount() || 8 != snapshot.EdgeCount() {
+		t.Fatalf("Expected 9 nodes and 8 edges, got %d and %d", snapshot.NodeCount(), snapshot.EdgeCount())
+	}
+
+	node, ok := snapshot.NodeByID(5)
+	if !ok {
+		t.Fatalf("Node @5 not found")
+	}
+	if "object" != node.Type() || "Foo" != node.Name() || 20 != node.SelfSize() || 2 != node.Index {
+		t.Errorf("Unexpected node %s %s %d", node.Type(), node.Name(), node.SelfSize())
+	}
+	edges := node.Edges()
+	if 1 != len(edges) || "property" != edges[0].Type || "next" != edges[0].Name || 7 != edges[0].To.ID() {
+		t.Errorf("Unexpected edges %v", edges)
+	}
+	location, ok := node.Location()
+	if !ok || 3 != location.ScriptID || 10 != location.Line || 4 != location.Column {
+		t.Errorf("Unexpected location %v", location)
+	}
+
+	if _, err := Parse(strings.NewReader(`{"nodes": [1,2,3]}`)); nil == err {
+		t.Errorf("Expected an error for a snapshot without meta data")
+	}
+	truncated := strings.Replace(testSnapshot, "2,11,42\n", "2,11\n", 1)
+	if _, err := Parse(strings.NewReader(truncated)); nil == err {
+		t.Errorf("Expected an error for a truncated edge array")
+	}
+}
+
+func TestRetainedSize(t *testing.T) {
+	snapshot, err := Parse(strings.NewReader(testSnapshot))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	expected := map[int]int64{0: 210, 1: 210, 2: 50, 3: 30, 4: 150, 5: 50, 6: 60, 7: 70, 8: 80}
+	for index, size := range expected {
+		if retained := snapshot.Node(index).RetainedSize(); size != retained {
+			t.Errorf("Expected node %d to retain %d bytes, got %d", index, size, retained)
+		}
+	}
+
+	dominator, ok := snapshot.Node(6).Dominator()
+	if !ok || 4 != dominator.Index {
+		t.Errorf("Expected the string to be dominated by the array, got %v", dominator)
+	}
+	if _, ok := snapshot.Node(8).Dominator(); ok {
+		t.Errorf("Expected the weakly referenced node to be unreachable")
+	}
+	if 2 != len(snapshot.Node(6).Retainers()) {
+		t.Errorf("Expected 2 retainers, got %v", snapshot.Node(6).Retainers())
+	}
+}
+
+func TestRetainerPath(t *testing.T) {
+	snapshot, err := Parse(strings.NewReader(testSnapshot))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	path := snapshot.Node(6).RetainerPath()
+	if "[1] -> .list -> [1]" != fmt.Sprintf("%s -> %s -> %s", path[0], path[1], path[2]) || 3 != len(path) {
+		t.Errorf("Unexpected retainer path %v", path)
+	}
+	if "Window.list[1]" != path.String() {
+		t.Errorf("Unexpected path string %s", path)
+	}
+	if nil != snapshot.Node(7).RetainerPath() {
+		t.Errorf("Expected no path to an unreachable node")
+	}
+	if 0 != len(snapshot.Root().RetainerPath()) {
+		t.Errorf("Expected an empty path to the root")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	snapshot, err := Parse(strings.NewReader(testSnapshot))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	expected := []Class{
+		{Name: "Window", Count: 1, SelfSize: 10, RetainedSize: 210},
+		{Name: "Array", Count: 1, SelfSize: 40, RetainedSize: 150},
+		{Name: "(string)", Count: 1, SelfSize: 60, RetainedSize: 60},
+		{Name: "Detached HTMLDivElement", Count: 1, SelfSize: 50, RetainedSize: 50},
+		{Name: "Foo", Count: 2, SelfSize: 50, RetainedSize: 50},
+	}
+	classes := snapshot.Aggregate()
+	if len(expected) != len(classes) {
+		t.Fatalf("Expected %d classes, got %d", len(expected), len(classes))
+	}
+	for a, class := range classes {
+		if expected[a].Name != class.Name ||
+			expected[a].Count != class.Count ||
+			expected[a].SelfSize != class.SelfSize ||
+			expected[a].RetainedSize != class.RetainedSize {
+			t.Errorf("Expected %v, got %v", expected[a], *class)
+		}
+	}
+
+	detached := snapshot.DetachedNodes()
+	if 1 != len(detached) || 11 != detached[0].ID() {
+		t.Errorf("Expected the detached div, got %v", detached)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base, err := Parse(strings.NewReader(testSnapshot))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	// Replace the Array (@9) with a new one (@19) and add a third Foo (@21).
+	current, err := Parse(strings.NewReader(strings.NewReplacer(
+		"3,3,9,40,2,0,0", "3,3,19,40,2,0,0",
+		"3,2,7,30,0,0,0", "3,2,7,30,1,0,0",
+		"2,10,21,\n", "2,10,21,\n\t2,10,49,\n",
+		"3,6,15,70,0,0,0", "3,2,21,35,0,0,0",
+		`"edge_count": 8`, `"edge_count": 9`,
+	).Replace(testSnapshot)))
+	if nil != err {
+		t.Fatalf("Parse failed: %s", err)
+	}
+
+	deltas := Compare(base, current)
+	if 2 != len(deltas) {
+		t.Fatalf("Expected 2 changed classes, got %d", len(deltas))
+	}
+	if "Foo" != deltas[0].Name || 1 != deltas[0].Added || 0 != deltas[0].Removed || 35 != deltas[0].SizeDelta {
+		t.Errorf("Unexpected delta %v", *deltas[0])
+	}
+	if "Window.foo.next.next" != deltas[0].New[0].RetainerPath().String() {
+		t.Errorf("Unexpected retainer path %s", deltas[0].New[0].RetainerPath())
+	}
+	if "Array" != deltas[1].Name || 1 != deltas[1].Added || 1 != deltas[1].Removed || 0 != deltas[1].Count() {
+		t.Errorf("Unexpected delta %v", *deltas[1])
+	}
+}
@@ -0,0 +1,174 @@
+package pprof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mkenney/go-chrome/tot/profiler"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+/*
+frameKey identifies a JavaScript call frame. Lines and columns are one-based,
+as in the profile. Locations without a function are identified by address.
+*/
+type frameKey struct {
+	name    string
+	url     string
+	line    int
+	column  int
+	address uint64
+}
+
+/*
+builder deduplicates the functions and locations of a converted profile.
+*/
+type builder struct {
+	profile   *Profile
+	mapping   *Mapping
+	functions map[frameKey]*Function
+	locations map[frameKey]*Location
+}
+
+func newBuilder(profile *Profile) *builder {
+	mapping := &Mapping{
+		ID:             1,
+		File:           "javascript",
+		HasFunctions:   true,
+		HasFilenames:   true,
+		HasLineNumbers: true,
+	}
+	profile.Mapping = []*Mapping{mapping}
+	return &builder{
+		profile:   profile,
+		mapping:   mapping,
+		functions: map[frameKey]*Function{},
+		locations: map[frameKey]*Location{},
+	}
+}
+
+/*
+location returns the location of a call frame. Chrome line and column numbers
+are zero-based.
+*/
+func (b *builder) location(frame *runtime.CallFrame) *Location {
+	name := frame.FunctionName
+	if "" == name {
+		name = "(anonymous)"
+	}
+	line := int64(frame.LineNumber + 1)
+	column := int64(frame.ColumnNumber + 1)
+	key := frameKey{name: name, url: frame.URL, line: int(line), column: int(column)}
+	if location, ok := b.locations[key]; ok {
+		return location
+	}
+
+	functionKey := frameKey{name: name, url: frame.URL, line: int(line)}
+	function, ok := b.functions[functionKey]
+	if !ok {
+		function = &Function{
+			ID:         uint64(len(b.profile.Function) + 1),
+			Name:       name,
+			SystemName: name,
+			Filename:   frame.URL,
+			StartLine:  line,
+		}
+		b.functions[functionKey] = function
+		b.profile.Function = append(b.profile.Function, function)
+	}
+
+	location := &Location{
+		ID:      uint64(len(b.profile.Location) + 1),
+		Mapping: b.mapping,
+		Line: []Line{{
+			Function: function,
+			Line:     line,
+			Column:   column,
+		}},
+	}
+	b.locations[key] = location
+	b.profile.Location = append(b.profile.Location, location)
+	return location
+}
+
+/*
+FromCPUProfile converts a profile returned by Profiler.stop. Samples are
+aggregated per call stack with a sample count and the CPU time in
+nanoseconds. The time of a sample is the delta to the next sample, the last
+sample is assigned the average sampling interval.
+
+Chrome profile timestamps are monotonic, TimeNanos is left unset.
+*/
+func FromCPUProfile(cpuProfile *profiler.Profile) (*Profile, error) {
+	if nil == cpuProfile {
+		return nil, fmt.Errorf("pprof: no profile")
+	}
+	nodes := map[int]*profiler.ProfileNode{}
+	parents := map[int]int{}
+	for _, node := range cpuProfile.Nodes {
+		nodes[node.ID] = node
+		for _, child := range node.Children {
+			parents[child] = node.ID
+		}
+	}
+
+	profile := &Profile{
+		SampleType: []*ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		DefaultSampleType: "cpu",
+		PeriodType:        &ValueType{Type: "cpu", Unit: "nanoseconds"},
+		DurationNanos:     int64(cpuProfile.EndTime-cpuProfile.StartTime) * 1000,
+	}
+	if len(cpuProfile.Samples) > 0 {
+		profile.Period = profile.DurationNanos / int64(len(cpuProfile.Samples))
+	}
+
+	counts := map[int]int64{}
+	times := map[int]int64{}
+	order := []int{}
+	for a, id := range cpuProfile.Samples {
+		if _, ok := nodes[id]; !ok {
+			return nil, fmt.Errorf("pprof: sample %d references unknown node %d", a, id)
+		}
+		duration := profile.Period
+		if a+1 < len(cpuProfile.TimeDeltas) {
+			duration = int64(cpuProfile.TimeDeltas[a+1]) * 1000
+		}
+		if duration < 0 {
+			duration = 0
+		}
+		if _, ok := counts[id]; !ok {
+			order = append(order, id)
+		}
+		counts[id]++
+		times[id] += duration
+	}
+
+	b := newBuilder(profile)
+	for _, id := range order {
+		sample := &Sample{Value: []int64{counts[id], times[id]}}
+		for node, ok := nodes[id], true; ok; node, ok = nodes[parents[node.ID]] {
+			if nil == node.CallFrame || "(root)" == node.CallFrame.FunctionName {
+				break
+			}
+			sample.Location = append(sample.Location, b.location(node.CallFrame))
+			if _, hasParent := parents[node.ID]; !hasParent {
+				break
+			}
+		}
+		profile.Sample = append(profile.Sample, sample)
+	}
+
+	return profile, nil
+}
+
+/*
+WriteCPUProfile writes a profile in the .cpuprofile JSON format that can be
+loaded in the DevTools Performance panel.
+*/
+func WriteCPUProfile(w io.Writer, cpuProfile *profiler.Profile) error {
+	return json.NewEncoder(w).Encode(cpuProfile)
+}
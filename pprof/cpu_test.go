@@ -0,0 +1,105 @@
+package pprof
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mkenney/go-chrome/tot/profiler"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func testCPUProfile() *profiler.Profile {
+	return &profiler.Profile{
+		Nodes: []*profiler.ProfileNode{
+			{ID: 1, CallFrame: &runtime.CallFrame{FunctionName: "(root)"}, Children: []int{2, 4}},
+			{ID: 2, CallFrame: &runtime.CallFrame{FunctionName: "main", URL: "https://example.com/app.js", LineNumber: 9, ColumnNumber: 4}, Children: []int{3}},
+			{ID: 3, CallFrame: &runtime.CallFrame{URL: "https://example.com/app.js", LineNumber: 19}},
+			{ID: 4, CallFrame: &runtime.CallFrame{FunctionName: "(idle)"}},
+		},
+		StartTime:  1000,
+		EndTime:    5000,
+		Samples:    []int{2, 3, 3, 4},
+		TimeDeltas: []int{100, 1000, 1000, 500},
+	}
+}
+
+func TestFromCPUProfile(t *testing.T) {
+	profile, err := FromCPUProfile(testCPUProfile())
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 3 != len(profile.Sample) {
+		t.Fatalf("Expected 3 samples, received %d", len(profile.Sample))
+	}
+	if 4000000 != profile.DurationNanos || 1000000 != profile.Period {
+		t.Errorf("Unexpected duration %d and period %d", profile.DurationNanos, profile.Period)
+	}
+
+	// main: one sample, time until the next sample.
+	sample := profile.Sample[0]
+	if 1 != len(sample.Location) || "main" != sample.Location[0].Line[0].Function.Name || 10 != sample.Location[0].Line[0].Line {
+		t.Errorf("Unexpected main stack %+v", sample.Location)
+	}
+	if 1 != sample.Value[0] || 1000000 != sample.Value[1] {
+		t.Errorf("Unexpected main values %v", sample.Value)
+	}
+
+	// anonymous function called by main, leaf first.
+	sample = profile.Sample[1]
+	if 2 != len(sample.Location) ||
+		"(anonymous)" != sample.Location[0].Line[0].Function.Name ||
+		"main" != sample.Location[1].Line[0].Function.Name {
+		t.Errorf("Unexpected anonymous stack %+v", sample.Location)
+	}
+	if 2 != sample.Value[0] || 1500000 != sample.Value[1] {
+		t.Errorf("Unexpected anonymous values %v", sample.Value)
+	}
+
+	// The last sample is assigned the average interval.
+	if 1000000 != profile.Sample[2].Value[1] {
+		t.Errorf("Expected the average interval, received %d", profile.Sample[2].Value[1])
+	}
+}
+
+func TestProfileRoundTrip(t *testing.T) {
+	profile, _ := FromCPUProfile(testCPUProfile())
+	buf := &bytes.Buffer{}
+	if err := profile.Write(buf); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	parsed, err := Parse(buf)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 2 != len(parsed.SampleType) || "cpu" != parsed.SampleType[1].Type || "nanoseconds" != parsed.SampleType[1].Unit {
+		t.Errorf("Unexpected sample types %+v", parsed.SampleType)
+	}
+	if "cpu" != parsed.DefaultSampleType || profile.DurationNanos != parsed.DurationNanos || profile.Period != parsed.Period {
+		t.Errorf("Unexpected profile header %+v", parsed)
+	}
+	if len(profile.Sample) != len(parsed.Sample) || len(profile.Location) != len(parsed.Location) || len(profile.Function) != len(parsed.Function) {
+		t.Fatalf("Unexpected profile contents %+v", parsed)
+	}
+	line := parsed.Sample[1].Location[0].Line[0]
+	if "https://example.com/app.js" != line.Function.Filename || 20 != line.Line || 1 != line.Column {
+		t.Errorf("Unexpected line %+v", line)
+	}
+	if "javascript" != parsed.Location[0].Mapping.File {
+		t.Errorf("Unexpected mapping %+v", parsed.Location[0].Mapping)
+	}
+}
+
+func TestWriteCPUProfile(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteCPUProfile(buf, testCPUProfile()); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	decoded := &profiler.Profile{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 4 != len(decoded.Nodes) || 4 != len(decoded.TimeDeltas) {
+		t.Errorf("Unexpected profile %+v", decoded)
+	}
+}
@@ -0,0 +1,209 @@
+package pprof
+
+import (
+	"fmt"
+
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+)
+
+/*
+DefaultHeapSamplingInterval is the default average sampling interval of
+HeapProfiler.startSampling in bytes.
+*/
+const DefaultHeapSamplingInterval = 32768
+
+/*
+FromHeapProfile converts a sampling heap profile returned by
+HeapProfiler.getSamplingProfile or HeapProfiler.stopSampling to
+inuse_objects and inuse_space samples. The sampling heap profile only contains
+allocations that are still alive, so it describes the in-use heap at the time
+it was taken.
+
+Chrome reports the estimated total size of each allocation site. The object
+count is estimated from the individual samples, it is 0 if the profile has no
+samples (Chrome before version 66).
+*/
+func FromHeapProfile(heapProfile *heap.SamplingHeapProfile, interval int) (*Profile, error) {
+	if nil == heapProfile || nil == heapProfile.Head {
+		return nil, fmt.Errorf("pprof: no heap profile")
+	}
+	if interval <= 0 {
+		interval = DefaultHeapSamplingInterval
+	}
+
+	samples := map[int]int64{}
+	sampledSize := map[int]float64{}
+	for _, sample := range heapProfile.Samples {
+		samples[sample.NodeID]++
+		sampledSize[sample.NodeID] += sample.Size
+	}
+
+	profile := &Profile{
+		SampleType: []*ValueType{
+			{Type: "inuse_objects", Unit: "count"},
+			{Type: "inuse_space", Unit: "bytes"},
+		},
+		DefaultSampleType: "inuse_space",
+		PeriodType:        &ValueType{Type: "space", Unit: "bytes"},
+		Period:            int64(interval),
+	}
+	b := newBuilder(profile)
+
+	var walk func(node *heap.SamplingHeapProfileNode, stack []*Location)
+	walk = func(node *heap.SamplingHeapProfileNode, stack []*Location) {
+		if nil != node.CallFrame && "(root)" != node.CallFrame.FunctionName {
+			// Stacks are leaf first.
+			stack = append([]*Location{b.location(node.CallFrame)}, stack...)
+		}
+		if node.SelfSize > 0 && len(stack) > 0 {
+			objects := samples[node.ID]
+			if sampledSize[node.ID] > 0 {
+				objects = int64(float64(objects)*float64(node.SelfSize)/sampledSize[node.ID] + 0.5)
+			}
+			profile.Sample = append(profile.Sample, &Sample{
+				Location: stack,
+				Value:    []int64{objects, int64(node.SelfSize)},
+			})
+		}
+		for _, child := range node.Children {
+			walk(child, stack)
+		}
+	}
+	walk(heapProfile.Head, nil)
+
+	return profile, nil
+}
+
+/*
+Diff returns a profile with the sample values of current minus base. Both
+profiles must have the same sample types. Call stacks are matched by function
+name, file, line and column, stacks with no difference are dropped. The
+result can be viewed with `go tool pprof -http`, negative values are freed
+memory.
+*/
+func Diff(base, current *Profile) (*Profile, error) {
+	if len(base.SampleType) != len(current.SampleType) {
+		return nil, fmt.Errorf("pprof: incompatible sample types")
+	}
+	for a, sampleType := range base.SampleType {
+		if sampleType.Type != current.SampleType[a].Type || sampleType.Unit != current.SampleType[a].Unit {
+			return nil, fmt.Errorf("pprof: incompatible sample types %s/%s and %s/%s",
+				sampleType.Type, sampleType.Unit, current.SampleType[a].Type, current.SampleType[a].Unit)
+		}
+	}
+
+	diff := &Profile{
+		SampleType:        current.SampleType,
+		DefaultSampleType: current.DefaultSampleType,
+		PeriodType:        current.PeriodType,
+		Period:            current.Period,
+		TimeNanos:         current.TimeNanos,
+		DurationNanos:     current.DurationNanos,
+		Comments:          append(append([]string{}, current.Comments...), "diff"),
+	}
+	m := &merger{
+		builder: newBuilder(diff),
+		samples: map[string]*Sample{},
+	}
+	m.add(current, 1)
+	m.add(base, -1)
+
+	for _, key := range m.order {
+		sample := m.samples[key]
+		for _, value := range sample.Value {
+			if 0 != value {
+				diff.Sample = append(diff.Sample, sample)
+				break
+			}
+		}
+	}
+	return diff, nil
+}
+
+/*
+merger sums samples with matching call stacks.
+*/
+type merger struct {
+	*builder
+	order   []string
+	samples map[string]*Sample
+}
+
+/*
+add adds the scaled samples of a profile.
+*/
+func (m *merger) add(profile *Profile, scale int64) {
+	for _, sample := range profile.Sample {
+		stack := make([]*Location, 0, len(sample.Location))
+		key := ""
+		for _, location := range sample.Location {
+			copied := m.copyLocation(location)
+			stack = append(stack, copied)
+			key += fmt.Sprintf("%d,", copied.ID)
+		}
+		merged, ok := m.samples[key]
+		if !ok {
+			merged = &Sample{Location: stack, Value: make([]int64, len(sample.Value)), Label: sample.Label}
+			m.samples[key] = merged
+			m.order = append(m.order, key)
+		}
+		for a, value := range sample.Value {
+			if a < len(merged.Value) {
+				merged.Value[a] += scale * value
+			}
+		}
+	}
+}
+
+/*
+copyLocation returns the location of the merged profile matching a location.
+Locations are matched by the function and position of their first line, or
+by address. A location with neither is not matched with other locations.
+*/
+func (m *merger) copyLocation(location *Location) *Location {
+	key := frameKey{address: location.Address}
+	if len(location.Line) > 0 && nil != location.Line[0].Function {
+		line := location.Line[0]
+		key = frameKey{
+			name:   line.Function.Name,
+			url:    line.Function.Filename,
+			line:   int(line.Line),
+			column: int(line.Column),
+		}
+	}
+	matched := frameKey{} != key
+	if copied, ok := m.locations[key]; ok && matched {
+		return copied
+	}
+
+	copied := &Location{
+		ID:      uint64(len(m.profile.Location) + 1),
+		Mapping: m.mapping,
+		Address: location.Address,
+	}
+	for _, line := range location.Line {
+		copiedLine := Line{Line: line.Line, Column: line.Column}
+		if nil != line.Function {
+			functionKey := frameKey{name: line.Function.Name, url: line.Function.Filename, line: int(line.Function.StartLine)}
+			function, ok := m.functions[functionKey]
+			if !ok {
+				function = &Function{
+					ID:         uint64(len(m.profile.Function) + 1),
+					Name:       line.Function.Name,
+					SystemName: line.Function.SystemName,
+					Filename:   line.Function.Filename,
+					StartLine:  line.Function.StartLine,
+				}
+				m.functions[functionKey] = function
+				m.profile.Function = append(m.profile.Function, function)
+			}
+			copiedLine.Function = function
+		}
+		copied.Line = append(copied.Line, copiedLine)
+	}
+	if matched {
+		m.locations[key] = copied
+	}
+	m.profile.Location = append(m.profile.Location, copied)
+	return copied
+}
@@ -0,0 +1,99 @@
+package pprof
+
+import (
+	"testing"
+
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func testHeapProfile(leak int) *heap.SamplingHeapProfile {
+	return &heap.SamplingHeapProfile{
+		Head: &heap.SamplingHeapProfileNode{
+			ID:        1,
+			CallFrame: &runtime.CallFrame{FunctionName: "(root)"},
+			Children: []*heap.SamplingHeapProfileNode{{
+				ID:        2,
+				CallFrame: &runtime.CallFrame{FunctionName: "render", URL: "https://example.com/app.js", LineNumber: 4},
+				SelfSize:  1024,
+				Children: []*heap.SamplingHeapProfileNode{{
+					ID:        3,
+					CallFrame: &runtime.CallFrame{FunctionName: "cache", URL: "https://example.com/app.js", LineNumber: 20},
+					SelfSize:  leak,
+				}},
+			}},
+		},
+		Samples: []*heap.SamplingHeapProfileSample{
+			{Size: 512, NodeID: 2, Ordinal: 1},
+			{Size: 512, NodeID: 2, Ordinal: 2},
+			{Size: 256, NodeID: 3, Ordinal: 3},
+		},
+	}
+}
+
+func TestFromHeapProfile(t *testing.T) {
+	profile, err := FromHeapProfile(testHeapProfile(1024), 0)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if "inuse_space" != profile.DefaultSampleType || DefaultHeapSamplingInterval != profile.Period {
+		t.Errorf("Unexpected profile header %+v", profile)
+	}
+	if 2 != len(profile.Sample) {
+		t.Fatalf("Expected 2 samples, received %d", len(profile.Sample))
+	}
+	sample := profile.Sample[0]
+	if 2 != sample.Value[0] || 1024 != sample.Value[1] || 1 != len(sample.Location) {
+		t.Errorf("Unexpected render sample %v", sample.Value)
+	}
+	sample = profile.Sample[1]
+	if 4 != sample.Value[0] || 1024 != sample.Value[1] {
+		t.Errorf("Expected the object count to be scaled, received %v", sample.Value)
+	}
+	if 2 != len(sample.Location) || "cache" != sample.Location[0].Line[0].Function.Name {
+		t.Errorf("Expected a leaf first stack, received %+v", sample.Location)
+	}
+
+	if _, err = FromHeapProfile(&heap.SamplingHeapProfile{}, 0); nil == err {
+		t.Errorf("Expected an error for an empty profile")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base, _ := FromHeapProfile(testHeapProfile(1024), 0)
+	current, _ := FromHeapProfile(testHeapProfile(4096), 0)
+	diff, err := Diff(base, current)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 1 != len(diff.Sample) {
+		t.Fatalf("Expected 1 sample, received %d", len(diff.Sample))
+	}
+	if 3072 != diff.Sample[0].Value[1] || "cache" != diff.Sample[0].Location[0].Line[0].Function.Name {
+		t.Errorf("Unexpected diff sample %+v", diff.Sample[0])
+	}
+
+	if len(current.Function) != len(diff.Function) {
+		t.Errorf("Expected %d functions, received %d", len(current.Function), len(diff.Function))
+	}
+
+	// Locations without lines are matched by address.
+	unknown := func(values ...int64) *Profile {
+		profile := &Profile{SampleType: base.SampleType}
+		for a, value := range values {
+			location := &Location{ID: uint64(a + 1), Address: uint64(0x100 * (a + 1))}
+			profile.Location = append(profile.Location, location)
+			profile.Sample = append(profile.Sample, &Sample{Location: []*Location{location}, Value: []int64{1, value}})
+		}
+		return profile
+	}
+	diff, _ = Diff(unknown(10, 20), unknown(10, 50))
+	if 1 != len(diff.Sample) || 30 != diff.Sample[0].Value[1] || 0x200 != diff.Sample[0].Location[0].Address || 2 != len(diff.Location) {
+		t.Errorf("Unexpected diff of locations without lines %+v", diff.Sample)
+	}
+
+	cpu, _ := FromCPUProfile(testCPUProfile())
+	if _, err = Diff(base, cpu); nil == err {
+		t.Errorf("Expected an error for incompatible profiles")
+	}
+}
@@ -0,0 +1,493 @@
+/*
+Package pprof converts Chrome CPU and sampling heap profiles to the pprof
+profile.proto format read by `go tool pprof`.
+
+The Profile types mirror the messages of profile.proto, see
+https://github.com/google/pprof/blob/master/proto/profile.proto
+Profiles are written gzip compressed, as produced by runtime/pprof.
+*/
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+/*
+ValueType describes the type and unit of a sample value.
+*/
+type ValueType struct {
+	Type string
+	Unit string
+}
+
+/*
+Label is a sample label. Either Str or Num is set.
+*/
+type Label struct {
+	Key     string
+	Str     string
+	Num     int64
+	NumUnit string
+}
+
+/*
+Sample is a set of values recorded for a call stack.
+*/
+type Sample struct {
+	// Location contains the call stack, leaf first.
+	Location []*Location
+
+	// Value contains one value per sample type.
+	Value []int64
+
+	// Label contains additional sample context.
+	Label []*Label
+}
+
+/*
+Mapping is a mapped binary or, for JavaScript, a group of scripts.
+*/
+type Mapping struct {
+	ID              uint64
+	Start           uint64
+	Limit           uint64
+	Offset          uint64
+	File            string
+	BuildID         string
+	HasFunctions    bool
+	HasFilenames    bool
+	HasLineNumbers  bool
+	HasInlineFrames bool
+}
+
+/*
+Location is a unique place in the program.
+*/
+type Location struct {
+	ID      uint64
+	Mapping *Mapping
+	Address uint64
+	Line    []Line
+}
+
+/*
+Line is a source position of a location. Lines are one-based, columns are
+one-based and 0 if unknown.
+*/
+type Line struct {
+	Function *Function
+	Line     int64
+	Column   int64
+}
+
+/*
+Function is a program function.
+*/
+type Function struct {
+	ID         uint64
+	Name       string
+	SystemName string
+	Filename   string
+	StartLine  int64
+}
+
+/*
+Profile is a pprof profile.
+*/
+type Profile struct {
+	SampleType        []*ValueType
+	DefaultSampleType string
+	Sample            []*Sample
+	Mapping           []*Mapping
+	Location          []*Location
+	Function          []*Function
+	Comments          []string
+	TimeNanos         int64
+	DurationNanos     int64
+	PeriodType        *ValueType
+	Period            int64
+}
+
+/*
+Write writes the gzip compressed profile.
+*/
+func (profile *Profile) Write(w io.Writer) error {
+	writer := gzip.NewWriter(w)
+	if _, err := writer.Write(profile.Marshal()); nil != err {
+		return err
+	}
+	return writer.Close()
+}
+
+/*
+Marshal encodes the profile as an uncompressed profile.proto message.
+*/
+func (profile *Profile) Marshal() []byte {
+	strings := []string{""}
+	index := map[string]int64{"": 0}
+	str := func(value string) int64 {
+		if idx, ok := index[value]; ok {
+			return idx
+		}
+		index[value] = int64(len(strings))
+		strings = append(strings, value)
+		return index[value]
+	}
+	valueType := func(valueType *ValueType) func(buf *buffer) {
+		return func(buf *buffer) {
+			buf.int64(1, str(valueType.Type))
+			buf.int64(2, str(valueType.Unit))
+		}
+	}
+
+	buf := &buffer{}
+	for _, sampleType := range profile.SampleType {
+		buf.message(1, valueType(sampleType))
+	}
+	for _, sample := range profile.Sample {
+		buf.message(2, func(msg *buffer) {
+			ids := make([]uint64, len(sample.Location))
+			for a, location := range sample.Location {
+				ids[a] = location.ID
+			}
+			msg.packedUint64(1, ids)
+			msg.packedInt64(2, sample.Value)
+			for _, label := range sample.Label {
+				msg.message(3, func(lbl *buffer) {
+					lbl.int64(1, str(label.Key))
+					lbl.int64(2, str(label.Str))
+					lbl.int64(3, label.Num)
+					lbl.int64(4, str(label.NumUnit))
+				})
+			}
+		})
+	}
+	for _, mapping := range profile.Mapping {
+		buf.message(3, func(msg *buffer) {
+			msg.uint64(1, mapping.ID)
+			msg.uint64(2, mapping.Start)
+			msg.uint64(3, mapping.Limit)
+			msg.uint64(4, mapping.Offset)
+			msg.int64(5, str(mapping.File))
+			msg.int64(6, str(mapping.BuildID))
+			msg.bool(7, mapping.HasFunctions)
+			msg.bool(8, mapping.HasFilenames)
+			msg.bool(9, mapping.HasLineNumbers)
+			msg.bool(10, mapping.HasInlineFrames)
+		})
+	}
+	for _, location := range profile.Location {
+		buf.message(4, func(msg *buffer) {
+			msg.uint64(1, location.ID)
+			if nil != location.Mapping {
+				msg.uint64(2, location.Mapping.ID)
+			}
+			msg.uint64(3, location.Address)
+			for _, line := range location.Line {
+				msg.message(4, func(ln *buffer) {
+					if nil != line.Function {
+						ln.uint64(1, line.Function.ID)
+					}
+					ln.int64(2, line.Line)
+					ln.int64(3, line.Column)
+				})
+			}
+		})
+	}
+	for _, function := range profile.Function {
+		buf.message(5, func(msg *buffer) {
+			msg.uint64(1, function.ID)
+			msg.int64(2, str(function.Name))
+			msg.int64(3, str(function.SystemName))
+			msg.int64(4, str(function.Filename))
+			msg.int64(5, function.StartLine)
+		})
+	}
+	buf.int64(9, profile.TimeNanos)
+	buf.int64(10, profile.DurationNanos)
+	if nil != profile.PeriodType {
+		buf.message(11, valueType(profile.PeriodType))
+	}
+	buf.int64(12, profile.Period)
+	comments := []int64{}
+	for _, comment := range profile.Comments {
+		comments = append(comments, str(comment))
+	}
+	buf.packedInt64(13, comments)
+	buf.int64(14, str(profile.DefaultSampleType))
+
+	// The string table is encoded last so that all strings are indexed.
+	table := &buffer{}
+	for _, value := range strings {
+		table.bytes(6, []byte(value))
+	}
+	return append(buf.data, table.data...)
+}
+
+/*
+Parse reads a gzip compressed or uncompressed profile.
+*/
+func Parse(r io.Reader) (*Profile, error) {
+	data, err := ioutil.ReadAll(r)
+	if nil != err {
+		return nil, err
+	}
+	if len(data) >= 2 && 0x1f == data[0] && 0x8b == data[1] {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if nil != err {
+			return nil, fmt.Errorf("pprof: invalid gzip data: %s", err)
+		}
+		if data, err = ioutil.ReadAll(reader); nil != err {
+			return nil, fmt.Errorf("pprof: invalid gzip data: %s", err)
+		}
+	}
+	return Unmarshal(data)
+}
+
+/*
+rawSample is a sample before location IDs are resolved.
+*/
+type rawSample struct {
+	locations []uint64
+	values    []int64
+	labels    [][4]int64
+}
+
+/*
+Unmarshal decodes an uncompressed profile.proto message.
+*/
+func Unmarshal(data []byte) (*Profile, error) {
+	profile := &Profile{}
+	strings := []string{}
+	valueTypes := [][2]int64{}
+	samples := []*rawSample{}
+	mappings := map[uint64]*Mapping{}
+	mappingStrings := map[*Mapping][2]int64{}
+	locations := map[uint64]*Location{}
+	locationMappings := map[*Location]uint64{}
+	locationLines := map[*Location][][3]int64{}
+	functions := map[uint64]*Function{}
+	functionStrings := map[*Function][3]int64{}
+	var periodType *[2]int64
+	var defaultSampleType int64
+	comments := []int64{}
+
+	decodeValueType := func(data []byte) ([2]int64, error) {
+		result := [2]int64{}
+		err := each(data, func(f *field) error {
+			if 1 == f.number || 2 == f.number {
+				result[f.number-1] = int64(f.value)
+			}
+			return nil
+		})
+		return result, err
+	}
+
+	err := each(data, func(f *field) error {
+		switch f.number {
+		case 1:
+			valueType, err := decodeValueType(f.data)
+			valueTypes = append(valueTypes, valueType)
+			return err
+		case 2:
+			sample := &rawSample{}
+			samples = append(samples, sample)
+			return each(f.data, func(f *field) error {
+				switch f.number {
+				case 1:
+					values, err := f.uint64s()
+					sample.locations = append(sample.locations, values...)
+					return err
+				case 2:
+					values, err := f.uint64s()
+					for _, value := range values {
+						sample.values = append(sample.values, int64(value))
+					}
+					return err
+				case 3:
+					label := [4]int64{}
+					sample.labels = append(sample.labels, label)
+					idx := len(sample.labels) - 1
+					return each(f.data, func(f *field) error {
+						if f.number >= 1 && f.number <= 4 {
+							sample.labels[idx][f.number-1] = int64(f.value)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		case 3:
+			mapping := &Mapping{}
+			refs := [2]int64{}
+			err := each(f.data, func(f *field) error {
+				switch f.number {
+				case 1:
+					mapping.ID = f.value
+				case 2:
+					mapping.Start = f.value
+				case 3:
+					mapping.Limit = f.value
+				case 4:
+					mapping.Offset = f.value
+				case 5:
+					refs[0] = int64(f.value)
+				case 6:
+					refs[1] = int64(f.value)
+				case 7:
+					mapping.HasFunctions = 0 != f.value
+				case 8:
+					mapping.HasFilenames = 0 != f.value
+				case 9:
+					mapping.HasLineNumbers = 0 != f.value
+				case 10:
+					mapping.HasInlineFrames = 0 != f.value
+				}
+				return nil
+			})
+			mappings[mapping.ID] = mapping
+			mappingStrings[mapping] = refs
+			profile.Mapping = append(profile.Mapping, mapping)
+			return err
+		case 4:
+			location := &Location{}
+			err := each(f.data, func(f *field) error {
+				switch f.number {
+				case 1:
+					location.ID = f.value
+				case 2:
+					locationMappings[location] = f.value
+				case 3:
+					location.Address = f.value
+				case 4:
+					line := [3]int64{}
+					err := each(f.data, func(f *field) error {
+						if f.number >= 1 && f.number <= 3 {
+							line[f.number-1] = int64(f.value)
+						}
+						return nil
+					})
+					locationLines[location] = append(locationLines[location], line)
+					return err
+				}
+				return nil
+			})
+			locations[location.ID] = location
+			profile.Location = append(profile.Location, location)
+			return err
+		case 5:
+			function := &Function{}
+			refs := [3]int64{}
+			err := each(f.data, func(f *field) error {
+				switch f.number {
+				case 1:
+					function.ID = f.value
+				case 2, 3, 4:
+					refs[f.number-2] = int64(f.value)
+				case 5:
+					function.StartLine = int64(f.value)
+				}
+				return nil
+			})
+			functions[function.ID] = function
+			functionStrings[function] = refs
+			profile.Function = append(profile.Function, function)
+			return err
+		case 6:
+			strings = append(strings, string(f.data))
+		case 9:
+			profile.TimeNanos = int64(f.value)
+		case 10:
+			profile.DurationNanos = int64(f.value)
+		case 11:
+			valueType, err := decodeValueType(f.data)
+			periodType = &valueType
+			return err
+		case 12:
+			profile.Period = int64(f.value)
+		case 13:
+			values, err := f.uint64s()
+			for _, value := range values {
+				comments = append(comments, int64(value))
+			}
+			return err
+		case 14:
+			defaultSampleType = int64(f.value)
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	str := func(idx int64) (string, error) {
+		if idx < 0 || idx >= int64(len(strings)) {
+			return "", fmt.Errorf("pprof: invalid string index %d", idx)
+		}
+		return strings[idx], nil
+	}
+	var strErr error
+	s := func(idx int64) string {
+		value, err := str(idx)
+		if nil != err && nil == strErr {
+			strErr = err
+		}
+		return value
+	}
+
+	for _, valueType := range valueTypes {
+		profile.SampleType = append(profile.SampleType, &ValueType{Type: s(valueType[0]), Unit: s(valueType[1])})
+	}
+	if nil != periodType {
+		profile.PeriodType = &ValueType{Type: s(periodType[0]), Unit: s(periodType[1])}
+	}
+	profile.DefaultSampleType = s(defaultSampleType)
+	for _, comment := range comments {
+		profile.Comments = append(profile.Comments, s(comment))
+	}
+	for mapping, refs := range mappingStrings {
+		mapping.File, mapping.BuildID = s(refs[0]), s(refs[1])
+	}
+	for function, refs := range functionStrings {
+		function.Name, function.SystemName, function.Filename = s(refs[0]), s(refs[1]), s(refs[2])
+	}
+	for _, location := range profile.Location {
+		if id, ok := locationMappings[location]; ok {
+			location.Mapping = mappings[id]
+		}
+		for _, line := range locationLines[location] {
+			function, ok := functions[uint64(line[0])]
+			if !ok && 0 != line[0] {
+				return nil, fmt.Errorf("pprof: location %d references unknown function %d", location.ID, line[0])
+			}
+			location.Line = append(location.Line, Line{Function: function, Line: line[1], Column: line[2]})
+		}
+	}
+	for _, raw := range samples {
+		sample := &Sample{Value: raw.values}
+		for _, id := range raw.locations {
+			location, ok := locations[id]
+			if !ok {
+				return nil, fmt.Errorf("pprof: sample references unknown location %d", id)
+			}
+			sample.Location = append(sample.Location, location)
+		}
+		for _, label := range raw.labels {
+			sample.Label = append(sample.Label, &Label{
+				Key:     s(label[0]),
+				Str:     s(label[1]),
+				Num:     label[2],
+				NumUnit: s(label[3]),
+			})
+		}
+		profile.Sample = append(profile.Sample, sample)
+	}
+	if nil != strErr {
+		return nil, strErr
+	}
+	return profile, nil
+}
@@ -0,0 +1,215 @@
+package pprof
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+Wire types of the protocol buffer encoding.
+*/
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+/*
+buffer is a minimal protocol buffer encoder for the profile.proto messages.
+*/
+type buffer struct {
+	data []byte
+}
+
+func (buf *buffer) varint(value uint64) {
+	for value >= 0x80 {
+		buf.data = append(buf.data, byte(value)|0x80)
+		value >>= 7
+	}
+	buf.data = append(buf.data, byte(value))
+}
+
+func (buf *buffer) key(field int, wire int) {
+	buf.varint(uint64(field)<<3 | uint64(wire))
+}
+
+/*
+uint64 encodes a varint field, zero values are omitted.
+*/
+func (buf *buffer) uint64(field int, value uint64) {
+	if 0 == value {
+		return
+	}
+	buf.key(field, wireVarint)
+	buf.varint(value)
+}
+
+/*
+int64 encodes a varint field, zero values are omitted.
+*/
+func (buf *buffer) int64(field int, value int64) {
+	buf.uint64(field, uint64(value))
+}
+
+/*
+bool encodes a boolean field, false is omitted.
+*/
+func (buf *buffer) bool(field int, value bool) {
+	if value {
+		buf.uint64(field, 1)
+	}
+}
+
+/*
+bytes encodes a length-delimited field.
+*/
+func (buf *buffer) bytes(field int, value []byte) {
+	buf.key(field, wireBytes)
+	buf.varint(uint64(len(value)))
+	buf.data = append(buf.data, value...)
+}
+
+/*
+message encodes an embedded message.
+*/
+func (buf *buffer) message(field int, encode func(buf *buffer)) {
+	msg := &buffer{}
+	encode(msg)
+	buf.bytes(field, msg.data)
+}
+
+/*
+packedUint64 encodes a packed repeated varint field.
+*/
+func (buf *buffer) packedUint64(field int, values []uint64) {
+	if 0 == len(values) {
+		return
+	}
+	packed := &buffer{}
+	for _, value := range values {
+		packed.varint(value)
+	}
+	buf.bytes(field, packed.data)
+}
+
+/*
+packedInt64 encodes a packed repeated varint field.
+*/
+func (buf *buffer) packedInt64(field int, values []int64) {
+	if 0 == len(values) {
+		return
+	}
+	packed := &buffer{}
+	for _, value := range values {
+		packed.varint(uint64(value))
+	}
+	buf.bytes(field, packed.data)
+}
+
+var errTruncated = errors.New("pprof: truncated protocol buffer")
+
+/*
+decoder reads the fields of a protocol buffer message.
+*/
+type decoder struct {
+	data []byte
+}
+
+func (dec *decoder) varint() (uint64, error) {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if 0 == len(dec.data) {
+			return 0, errTruncated
+		}
+		b := dec.data[0]
+		dec.data = dec.data[1:]
+		value |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("pprof: varint overflow")
+}
+
+/*
+field is a decoded field. Varint fields set value, length-delimited fields
+set data.
+*/
+type field struct {
+	number int
+	wire   int
+	value  uint64
+	data   []byte
+}
+
+/*
+next decodes the next field. Fixed width fields are skipped.
+*/
+func (dec *decoder) next() (*field, error) {
+	key, err := dec.varint()
+	if nil != err {
+		return nil, err
+	}
+	f := &field{number: int(key >> 3), wire: int(key & 7)}
+	switch f.wire {
+	case wireVarint:
+		f.value, err = dec.varint()
+	case wireBytes:
+		var length uint64
+		if length, err = dec.varint(); nil == err {
+			if uint64(len(dec.data)) < length {
+				return nil, errTruncated
+			}
+			f.data = dec.data[:length]
+			dec.data = dec.data[length:]
+		}
+	case 1:
+		if len(dec.data) < 8 {
+			return nil, errTruncated
+		}
+		dec.data = dec.data[8:]
+	case 5:
+		if len(dec.data) < 4 {
+			return nil, errTruncated
+		}
+		dec.data = dec.data[4:]
+	default:
+		return nil, fmt.Errorf("pprof: unsupported wire type %d", f.wire)
+	}
+	return f, err
+}
+
+/*
+each calls fn for every field of a message.
+*/
+func each(data []byte, fn func(f *field) error) error {
+	dec := &decoder{data: data}
+	for len(dec.data) > 0 {
+		f, err := dec.next()
+		if nil != err {
+			return err
+		}
+		if err = fn(f); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+uint64s decodes a packed or unpacked repeated varint field.
+*/
+func (f *field) uint64s() ([]uint64, error) {
+	if wireVarint == f.wire {
+		return []uint64{f.value}, nil
+	}
+	values := []uint64{}
+	dec := &decoder{data: f.data}
+	for len(dec.data) > 0 {
+		value, err := dec.varint()
+		if nil != err {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
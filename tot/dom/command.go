@@ -112,8 +112,8 @@ DiscardSearchResultsParams represents DOM.discardSearchResults parameters.
 https://chromedevtools.github.io/devtools-protocol/tot/DOM/#method-discardSearchResults
 */
 type DiscardSearchResultsParams struct {
-	// Node description.
-	Node *Node `json:"node"`
+	// Unique search session identifier.
+	SearchID string `json:"searchId"`
 }
 
 /*
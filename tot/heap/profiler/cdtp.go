@@ -31,6 +31,10 @@ type SamplingHeapProfileNode struct {
 
 	// Child nodes.
 	Children []*SamplingHeapProfileNode `json:"children"`
+
+	// Node ID. Ids are unique across all profiles collected between
+	// startSampling and stopSampling.
+	ID int `json:"id,omitempty"`
 }
 
 /*
@@ -40,4 +44,24 @@ https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#type-Sampli
 */
 type SamplingHeapProfile struct {
 	Head *SamplingHeapProfileNode `json:"head"`
+
+	// Samples contains the individual allocation samples.
+	Samples []*SamplingHeapProfileSample `json:"samples,omitempty"`
+}
+
+/*
+SamplingHeapProfileSample is a single sample from a sampling profile.
+
+https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#type-SamplingHeapProfileSample
+*/
+type SamplingHeapProfileSample struct {
+	// Allocation size in bytes attributed to the sample.
+	Size float64 `json:"size"`
+
+	// ID of the corresponding profile tree node.
+	NodeID int `json:"nodeId"`
+
+	// Time-ordered sample ordinal number. It is unique across all profiles
+	// retrieved between startSampling and stopSampling.
+	Ordinal float64 `json:"ordinal"`
 }
@@ -111,8 +111,8 @@ GetSamplingProfileParams represents HeapProfiler.getSamplingProfile parameters.
 https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#method-getSamplingProfile
 */
 type GetSamplingProfileParams struct {
-	// Return the sampling profile being collected.
-	Profile *SamplingHeapProfile `json:"profile"`
+	// Deprecated: the profile is returned in the result.
+	Profile *SamplingHeapProfile `json:"profile,omitempty"`
 }
 
 /*
@@ -121,6 +121,9 @@ GetSamplingProfileResult represents the result of calls to HeapProfiler.getSampl
 https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#method-getSamplingProfile
 */
 type GetSamplingProfileResult struct {
+	// The sampling heap profile.
+	Profile *SamplingHeapProfile `json:"profile"`
+
 	// Error information related to executing this method
 	Err error `json:"-"`
 }
@@ -173,8 +176,8 @@ StopSamplingParams represents HeapProfiler.stopSampling parameters.
 https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#method-stopSampling
 */
 type StopSamplingParams struct {
-	// Recorded sampling heap profile.
-	Profile *SamplingHeapProfile `json:"profile"`
+	// Deprecated: the profile is returned in the result.
+	Profile *SamplingHeapProfile `json:"profile,omitempty"`
 }
 
 /*
@@ -183,6 +186,9 @@ StopSamplingResult represents the result of calls to HeapProfiler.stopSampling.
 https://chromedevtools.github.io/devtools-protocol/tot/HeapProfiler/#method-stopSampling
 */
 type StopSamplingResult struct {
+	// The sampling heap profile.
+	Profile *SamplingHeapProfile `json:"profile"`
+
 	// Error information related to executing this method
 	Err error `json:"-"`
 }
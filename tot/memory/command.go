@@ -6,9 +6,14 @@ GetDOMCountersParams represents Memory.getDOMCounters parameters.
 https://chromedevtools.github.io/devtools-protocol/tot/Memory/#method-getDOMCounters
 */
 type GetDOMCountersParams struct {
-	Documents        int `json:"documents"`
-	Nodes            int `json:"nodes"`
-	JsEventListeners int `json:"jsEventListeners"`
+	// Deprecated: the counters are returned in the result.
+	Documents int `json:"documents,omitempty"`
+
+	// Deprecated: the counters are returned in the result.
+	Nodes int `json:"nodes,omitempty"`
+
+	// Deprecated: the counters are returned in the result.
+	JsEventListeners int `json:"jsEventListeners,omitempty"`
 }
 
 /*
@@ -17,6 +22,10 @@ GetDOMCountersResult represents the result of calls to Memory.getDOMCounters.
 https://chromedevtools.github.io/devtools-protocol/tot/Memory/#method-getDOMCounters
 */
 type GetDOMCountersResult struct {
+	Documents        int `json:"documents"`
+	Nodes            int `json:"nodes"`
+	JsEventListeners int `json:"jsEventListeners"`
+
 	// Error information related to executing this method
 	Err error `json:"-"`
 }
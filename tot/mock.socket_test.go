@@ -1,15 +1,20 @@
 package chrome
 
 import (
+	"encoding/json"
 	"net/url"
+	"sync"
 
 	"github.com/mkenney/go-chrome/tot/socket"
 )
 
 func NewMockSocket(url *url.URL) *MockSocket {
 	mockSocket := &MockSocket{
-		url:   url,
-		errCh: make(chan error, 3),
+		url:        url,
+		errCh:      make(chan error, 3),
+		handlers:   map[string][]socket.EventHandler{},
+		mux:        &sync.Mutex{},
+		responders: map[string]MockResponder{},
 	}
 
 	mockSocket.accessibility = &socket.AccessibilityProtocol{Socket: mockSocket}
@@ -58,9 +63,12 @@ func NewMockSocket(url *url.URL) *MockSocket {
 Socket is a Socketer implementation.
 */
 type MockSocket struct {
-	url       *url.URL
-	commandID int
-	errCh     chan error
+	url        *url.URL
+	commandID  int
+	errCh      chan error
+	handlers   map[string][]socket.EventHandler
+	mux        *sync.Mutex
+	responders map[string]MockResponder
 
 	// Protocol interfaces for the API.
 	accessibility        *socket.AccessibilityProtocol
@@ -109,6 +117,38 @@ AddEventHandler is a Socketer implementation.
 func (socket *MockSocket) AddEventHandler(
 	handler socket.EventHandler,
 ) {
+	socket.mux.Lock()
+	socket.handlers[handler.Name()] = append(socket.handlers[handler.Name()], handler)
+	socket.mux.Unlock()
+}
+
+/*
+MockResponder generates the result for a mocked command. A non-nil error is
+returned to the caller as a socket error.
+*/
+type MockResponder func(params json.RawMessage) (interface{}, error)
+
+/*
+Respond registers a responder for a command method. Commands without a
+responder never receive a response.
+*/
+func (socket *MockSocket) Respond(method string, responder MockResponder) {
+	socket.mux.Lock()
+	socket.responders[method] = responder
+	socket.mux.Unlock()
+}
+
+/*
+Fire delivers an event to the registered event handlers.
+*/
+func (mock *MockSocket) Fire(method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	mock.mux.Lock()
+	handlers := append([]socket.EventHandler{}, mock.handlers[method]...)
+	mock.mux.Unlock()
+	for _, handler := range handlers {
+		handler.Handle(&socket.Response{Method: method, Params: data})
+	}
 }
 
 /*
@@ -136,6 +176,8 @@ func (socket *MockSocket) Listen() {
 NextCommandID generates and returns the next command ID.
 */
 func (socket *MockSocket) NextCommandID() int {
+	socket.mux.Lock()
+	defer socket.mux.Unlock()
 	socket.commandID++
 	return socket.commandID
 }
@@ -146,13 +188,38 @@ RemoveEventHandler is a Socketer implementation.
 func (socket *MockSocket) RemoveEventHandler(
 	handler socket.EventHandler,
 ) error {
+	socket.mux.Lock()
+	defer socket.mux.Unlock()
+	handlers := socket.handlers[handler.Name()]
+	for a, hndlr := range handlers {
+		if hndlr == handler {
+			socket.handlers[handler.Name()] = append(handlers[:a], handlers[a+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
 /*
 SendCommand is a Socketer implementation.
 */
-func (socket *MockSocket) SendCommand(command socket.Commander) chan *socket.Response {
+func (mock *MockSocket) SendCommand(command socket.Commander) chan *socket.Response {
+	mock.mux.Lock()
+	responder, ok := mock.responders[command.Method()]
+	mock.mux.Unlock()
+	if ok {
+		go func() {
+			params, _ := json.Marshal(command.Params())
+			response := &socket.Response{ID: command.ID(), Error: &socket.Error{}}
+			result, err := responder(params)
+			if nil != err {
+				response.Error = &socket.Error{Code: 1, Message: err.Error()}
+			} else {
+				response.Result, _ = json.Marshal(result)
+			}
+			command.Respond(response)
+		}()
+	}
 	return command.Response()
 }
 
@@ -434,3 +501,13 @@ Tracing is a Protocoller implementation.
 func (socket *MockSocket) Tracing() *socket.TracingProtocol {
 	return socket.tracing
 }
+
+/*
+NewMockTab returns a tab connected to a MockSocket for testing the framework
+API.
+*/
+func NewMockTab(uri string) (*Tab, *MockSocket) {
+	browser := NewMock(&Flags{}, "", "", "", "")
+	tab, _ := browser.NewTab(uri)
+	return tab, tab.Socket().(*MockSocket)
+}
@@ -15,5 +15,5 @@ type Metric struct {
 	Name string `json:"name"`
 
 	// Metric value.
-	Value int `json:"value"`
+	Value float64 `json:"value"`
 }
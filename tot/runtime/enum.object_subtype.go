@@ -6,62 +6,71 @@ import (
 )
 
 type objectSubtypeEnum struct {
-	Array      ObjectSubtypeEnum
-	Null       ObjectSubtypeEnum
-	Node       ObjectSubtypeEnum
-	Regexp     ObjectSubtypeEnum
-	Date       ObjectSubtypeEnum
-	Map        ObjectSubtypeEnum
-	Set        ObjectSubtypeEnum
-	Weakmap    ObjectSubtypeEnum
-	Weakset    ObjectSubtypeEnum
-	Iterator   ObjectSubtypeEnum
-	Generator  ObjectSubtypeEnum
-	Error      ObjectSubtypeEnum
-	Proxy      ObjectSubtypeEnum
-	Promise    ObjectSubtypeEnum
-	Typedarray ObjectSubtypeEnum
+	Array       ObjectSubtypeEnum
+	Null        ObjectSubtypeEnum
+	Node        ObjectSubtypeEnum
+	Regexp      ObjectSubtypeEnum
+	Date        ObjectSubtypeEnum
+	Map         ObjectSubtypeEnum
+	Set         ObjectSubtypeEnum
+	Weakmap     ObjectSubtypeEnum
+	Weakset     ObjectSubtypeEnum
+	Iterator    ObjectSubtypeEnum
+	Generator   ObjectSubtypeEnum
+	Error       ObjectSubtypeEnum
+	Proxy       ObjectSubtypeEnum
+	Promise     ObjectSubtypeEnum
+	Typedarray  ObjectSubtypeEnum
+	Arraybuffer ObjectSubtypeEnum
+	Dataview    ObjectSubtypeEnum
+	Weakref     ObjectSubtypeEnum
 }
 
 /*
 ObjectSubtype provides named acces to the ObjectSubtypeEnum values.
 */
 var ObjectSubtype = objectSubtypeEnum{
-	Array:      objectSubtypeArray,
-	Null:       objectSubtypeNull,
-	Node:       objectSubtypeNode,
-	Regexp:     objectSubtypeRegexp,
-	Date:       objectSubtypeDate,
-	Map:        objectSubtypeMap,
-	Set:        objectSubtypeSet,
-	Weakmap:    objectSubtypeWeakmap,
-	Weakset:    objectSubtypeWeakset,
-	Iterator:   objectSubtypeIterator,
-	Generator:  objectSubtypeGenerator,
-	Error:      objectSubtypeError,
-	Proxy:      objectSubtypeProxy,
-	Promise:    objectSubtypePromise,
-	Typedarray: objectSubtypeTypedarray,
+	Array:       objectSubtypeArray,
+	Null:        objectSubtypeNull,
+	Node:        objectSubtypeNode,
+	Regexp:      objectSubtypeRegexp,
+	Date:        objectSubtypeDate,
+	Map:         objectSubtypeMap,
+	Set:         objectSubtypeSet,
+	Weakmap:     objectSubtypeWeakmap,
+	Weakset:     objectSubtypeWeakset,
+	Iterator:    objectSubtypeIterator,
+	Generator:   objectSubtypeGenerator,
+	Error:       objectSubtypeError,
+	Proxy:       objectSubtypeProxy,
+	Promise:     objectSubtypePromise,
+	Typedarray:  objectSubtypeTypedarray,
+	Arraybuffer: objectSubtypeArraybuffer,
+	Dataview:    objectSubtypeDataview,
+	Weakref:     objectSubtypeWeakref,
 }
 
 /*
 ObjectSubtypeEnum represents an object subtype hint. Specified for object type
 values only. Allowed values:
-	- ObjectSubtype.Array      "array"
-	- ObjectSubtype.Null       "null"
-	- ObjectSubtype.Node       "node"
-	- ObjectSubtype.Regexp     "regexp"
-	- ObjectSubtype.Date       "date"
-	- ObjectSubtype.Map        "map"
-	- ObjectSubtype.Set        "set"
-	- ObjectSubtype.Weakmap    "weakmap"
-	- ObjectSubtype.Weakset    "weakset"
-	- ObjectSubtype.Iterator   "iterator"
-	- ObjectSubtype.Generator  "generator"
-	- ObjectSubtype.Error      "error"
-	- ObjectSubtype.Proxy      "proxy"
-	- ObjectSubtype.Promise    "promise"
-	- ObjectSubtype.Typedarray "typedarray"
+	- ObjectSubtype.Array       "array"
+	- ObjectSubtype.Null        "null"
+	- ObjectSubtype.Node        "node"
+	- ObjectSubtype.Regexp      "regexp"
+	- ObjectSubtype.Date        "date"
+	- ObjectSubtype.Map         "map"
+	- ObjectSubtype.Set         "set"
+	- ObjectSubtype.Weakmap     "weakmap"
+	- ObjectSubtype.Weakset     "weakset"
+	- ObjectSubtype.Iterator    "iterator"
+	- ObjectSubtype.Generator   "generator"
+	- ObjectSubtype.Error       "error"
+	- ObjectSubtype.Proxy       "proxy"
+	- ObjectSubtype.Promise     "promise"
+	- ObjectSubtype.Typedarray  "typedarray"
+	- ObjectSubtype.Arraybuffer "arraybuffer"
+	- ObjectSubtype.Dataview    "dataview"
+	- ObjectSubtype.Weakref     "weakref"
 
 https://chromedevtools.github.io/devtools-protocol/tot/Runtime/#type-RemoteObject
 https://chromedevtools.github.io/devtools-protocol/tot/Runtime/#type-ObjectPreview
@@ -136,23 +145,32 @@ const (
 	objectSubtypePromise
 	// objectSubtypeTypedarray represents the "typedarray" value.
 	objectSubtypeTypedarray
+	// objectSubtypeArraybuffer represents the "arraybuffer" value.
+	objectSubtypeArraybuffer
+	// objectSubtypeDataview represents the "dataview" value.
+	objectSubtypeDataview
+	// objectSubtypeWeakref represents the "weakref" value.
+	objectSubtypeWeakref
 )
 
 var _objectSubtypeEnums = map[ObjectSubtypeEnum]string{
-	ObjectSubtypeEnum(0):    "",
-	objectSubtypeArray:      "array",
-	objectSubtypeNull:       "null",
-	objectSubtypeNode:       "node",
-	objectSubtypeRegexp:     "regexp",
-	objectSubtypeDate:       "date",
-	objectSubtypeMap:        "map",
-	objectSubtypeSet:        "set",
-	objectSubtypeWeakmap:    "weakmap",
-	objectSubtypeWeakset:    "weakset",
-	objectSubtypeIterator:   "iterator",
-	objectSubtypeGenerator:  "generator",
-	objectSubtypeError:      "error",
-	objectSubtypeProxy:      "proxy",
-	objectSubtypePromise:    "promise",
-	objectSubtypeTypedarray: "typedarray",
+	ObjectSubtypeEnum(0):     "",
+	objectSubtypeArray:       "array",
+	objectSubtypeNull:        "null",
+	objectSubtypeNode:        "node",
+	objectSubtypeRegexp:      "regexp",
+	objectSubtypeDate:        "date",
+	objectSubtypeMap:         "map",
+	objectSubtypeSet:         "set",
+	objectSubtypeWeakmap:     "weakmap",
+	objectSubtypeWeakset:     "weakset",
+	objectSubtypeIterator:    "iterator",
+	objectSubtypeGenerator:   "generator",
+	objectSubtypeError:       "error",
+	objectSubtypeProxy:       "proxy",
+	objectSubtypePromise:     "promise",
+	objectSubtypeTypedarray:  "typedarray",
+	objectSubtypeArraybuffer: "arraybuffer",
+	objectSubtypeDataview:    "dataview",
+	objectSubtypeWeakref:     "weakref",
 }
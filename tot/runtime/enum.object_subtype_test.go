@@ -240,4 +240,43 @@ func TestEnumObjectSubtype4(t *testing.T) {
 	if ObjectSubtype.Typedarray != enum {
 		t.Errorf("Expcected %d, got %d", ObjectSubtype.Typedarray, enum)
 	}
+
+	enum = ObjectSubtype.Arraybuffer
+	result, err = json.Marshal(enum)
+	if nil != err {
+		t.Errorf("Expected nil, got error")
+	}
+	if `"arraybuffer"` != string(result) {
+		t.Errorf("Expected '\"arraybuffer\"', got '%s'", result)
+	}
+	json.Unmarshal([]byte(`"arraybuffer"`), &enum)
+	if ObjectSubtype.Arraybuffer != enum {
+		t.Errorf("Expcected %d, got %d", ObjectSubtype.Arraybuffer, enum)
+	}
+
+	enum = ObjectSubtype.Dataview
+	result, err = json.Marshal(enum)
+	if nil != err {
+		t.Errorf("Expected nil, got error")
+	}
+	if `"dataview"` != string(result) {
+		t.Errorf("Expected '\"dataview\"', got '%s'", result)
+	}
+	json.Unmarshal([]byte(`"dataview"`), &enum)
+	if ObjectSubtype.Dataview != enum {
+		t.Errorf("Expcected %d, got %d", ObjectSubtype.Dataview, enum)
+	}
+
+	enum = ObjectSubtype.Weakref
+	result, err = json.Marshal(enum)
+	if nil != err {
+		t.Errorf("Expected nil, got error")
+	}
+	if `"weakref"` != string(result) {
+		t.Errorf("Expected '\"weakref\"', got '%s'", result)
+	}
+	json.Unmarshal([]byte(`"weakref"`), &enum)
+	if ObjectSubtype.Weakref != enum {
+		t.Errorf("Expcected %d, got %d", ObjectSubtype.Weakref, enum)
+	}
 }
@@ -138,4 +138,17 @@ func TestEnumObjectType3(t *testing.T) {
 	if ObjectType.Accessor != enum {
 		t.Errorf("Expcected %d, got %d", ObjectType.Accessor, enum)
 	}
+
+	enum = ObjectType.Bigint
+	result, err = json.Marshal(enum)
+	if nil != err {
+		t.Errorf("Expected nil, got error")
+	}
+	if `"bigint"` != string(result) {
+		t.Errorf("Expected '\"bigint\"', got '%s'", result)
+	}
+	json.Unmarshal([]byte(`"bigint"`), &enum)
+	if ObjectType.Bigint != enum {
+		t.Errorf("Expcected %d, got %d", ObjectType.Bigint, enum)
+	}
 }
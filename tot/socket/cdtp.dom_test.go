@@ -183,40 +183,7 @@ func TestDOMDiscardSearchResults(t *testing.T) {
 	defer mockSocket.Stop()
 
 	params := &dom.DiscardSearchResultsParams{
-		Node: &dom.Node{
-			NodeID:           dom.NodeID(1),
-			ParentID:         dom.NodeID(2),
-			BackendNodeID:    dom.BackendNodeID(1),
-			NodeType:         1,
-			NodeName:         "node-name",
-			LocalName:        "local-name",
-			NodeValue:        "node-value",
-			ChildNodeCount:   1,
-			Children:         []*dom.Node{},
-			Attributes:       []string{"attr1", "attr2"},
-			DocumentURL:      "http://document.url",
-			BaseURL:          "http://base.url",
-			PublicID:         "public-id",
-			SystemID:         "system-id",
-			InternalSubset:   "internal-subset",
-			XMLVersion:       "v1",
-			Name:             "name",
-			Value:            "value",
-			PseudoType:       dom.PseudoType("pseudo-type"),
-			ShadowRootType:   dom.ShadowRootType("shadow-root-type"),
-			FrameID:          page.FrameID("frame-id"),
-			ContentDocument:  &dom.Node{},
-			ShadowRoots:      []*dom.Node{},
-			TemplateContent:  &dom.Node{},
-			PseudoElements:   []*dom.Node{},
-			ImportedDocument: &dom.Node{},
-			DistributedNodes: []*dom.BackendNode{{
-				NodeType:      1,
-				NodeName:      "node name",
-				BackendNodeID: dom.BackendNodeID(1),
-			}},
-			IsSVG: true,
-		},
+		SearchID: "search-id",
 	}
 	resultChan := mockSocket.DOM().DiscardSearchResults(params)
 	mockResult := &dom.DiscardSearchResultsResult{}
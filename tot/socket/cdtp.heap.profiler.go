@@ -179,6 +179,8 @@ func (protocol *HeapProfilerProtocol) GetSamplingProfile(
 		response := <-protocol.Socket.SendCommand(command)
 		if nil != response.Error && 0 != response.Error.Code {
 			result.Err = response.Error
+		} else {
+			result.Err = json.Unmarshal(response.Result, &result)
 		}
 		resultChan <- result
 		close(resultChan)
@@ -254,6 +256,8 @@ func (protocol *HeapProfilerProtocol) StopSampling(
 		response := <-protocol.Socket.SendCommand(command)
 		if nil != response.Error && 0 != response.Error.Code {
 			result.Err = response.Error
+		} else {
+			result.Err = json.Unmarshal(response.Result, &result)
 		}
 		resultChan <- result
 		close(resultChan)
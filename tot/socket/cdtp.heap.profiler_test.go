@@ -266,7 +266,7 @@ func TestHeapProfilerGetSamplingProfile(t *testing.T) {
 		},
 	}
 	resultChan := mockSocket.HeapProfiler().GetSamplingProfile(params)
-	mockResult := &profiler.GetSamplingProfileResult{}
+	mockResult := &profiler.GetSamplingProfileResult{Profile: params.Profile}
 	mockResultBytes, _ := json.Marshal(mockResult)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
 		ID:     mockSocket.CurCommandID(),
@@ -277,6 +277,9 @@ func TestHeapProfilerGetSamplingProfile(t *testing.T) {
 	if nil != result.Err {
 		t.Errorf("Expected nil, got error: '%s'", result.Err.Error())
 	}
+	if nil == result.Profile || mockResult.Profile.Head.SelfSize != result.Profile.Head.SelfSize {
+		t.Errorf("Expected %v, got %v", mockResult.Profile, result.Profile)
+	}
 
 	resultChan = mockSocket.HeapProfiler().GetSamplingProfile(params)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
@@ -388,7 +391,7 @@ func TestHeapProfilerStopSampling(t *testing.T) {
 		},
 	}
 	resultChan := mockSocket.HeapProfiler().StopSampling(params)
-	mockResult := &profiler.StopSamplingResult{}
+	mockResult := &profiler.StopSamplingResult{Profile: params.Profile}
 	mockResultBytes, _ := json.Marshal(mockResult)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
 		ID:     mockSocket.CurCommandID(),
@@ -399,6 +402,9 @@ func TestHeapProfilerStopSampling(t *testing.T) {
 	if nil != result.Err {
 		t.Errorf("Expected nil, got error: '%s'", result.Err.Error())
 	}
+	if nil == result.Profile || mockResult.Profile.Head.SelfSize != result.Profile.Head.SelfSize {
+		t.Errorf("Expected %v, got %v", mockResult.Profile, result.Profile)
+	}
 
 	resultChan = mockSocket.HeapProfiler().StopSampling(params)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
@@ -1,6 +1,8 @@
 package socket
 
 import (
+	"encoding/json"
+
 	"github.com/mkenney/go-chrome/tot/memory"
 )
 
@@ -30,6 +32,8 @@ func (protocol *MemoryProtocol) GetDOMCounters(
 		response := <-protocol.Socket.SendCommand(command)
 		if nil != response.Error && 0 != response.Error.Code {
 			result.Err = response.Error
+		} else {
+			result.Err = json.Unmarshal(response.Result, &result)
 		}
 		resultChan <- result
 		close(resultChan)
@@ -20,7 +20,11 @@ func TestMemoryGetDOMCounters(t *testing.T) {
 		JsEventListeners: 1,
 	}
 	resultChan := mockSocket.Memory().GetDOMCounters(params)
-	mockResult := &memory.GetDOMCountersResult{}
+	mockResult := &memory.GetDOMCountersResult{
+		Documents:        2,
+		Nodes:            3,
+		JsEventListeners: 4,
+	}
 	mockResultBytes, _ := json.Marshal(mockResult)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
 		ID:     mockSocket.CurCommandID(),
@@ -31,6 +35,11 @@ func TestMemoryGetDOMCounters(t *testing.T) {
 	if nil != result.Err {
 		t.Errorf("Expected nil, got error: '%s'", result.Err.Error())
 	}
+	if mockResult.Documents != result.Documents ||
+		mockResult.Nodes != result.Nodes ||
+		mockResult.JsEventListeners != result.JsEventListeners {
+		t.Errorf("Expected %v, got %v", mockResult, result)
+	}
 
 	resultChan = mockSocket.Memory().GetDOMCounters(params)
 	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
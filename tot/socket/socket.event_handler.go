@@ -1,5 +1,9 @@
 package socket
 
+import (
+	"sync"
+)
+
 /*
 NewEventHandler returns a pointer to an event handler.
 */
@@ -40,3 +44,123 @@ Name is an EventHandler implementation.
 func (handler *Handler) Name() string {
 	return handler.name
 }
+
+/*
+NewOrderedEventHandler returns a pointer to an event handler that receives
+events in the order they were read from the socket.
+
+Event handlers are normally executed concurrently, so a handler may observe
+events out of order. Ordered handlers queue events instead and execute the
+callback for one event at a time, which is required for events that must be
+reassembled, e.g. HeapProfiler.addHeapSnapshotChunk.
+*/
+func NewOrderedEventHandler(
+	name string,
+	callback func(response *Response),
+) *OrderedHandler {
+	return NewOrderedEventHandlers(callback, name)[0]
+}
+
+/*
+NewOrderedEventHandlers returns ordered event handlers for several events
+that share one queue, so that the callback receives the events of all of
+them in the order they were read from the socket, e.g. a
+Network.responseReceived event before the Network.loadingFinished event of
+the request.
+*/
+func NewOrderedEventHandlers(
+	callback func(response *Response),
+	names ...string,
+) []*OrderedHandler {
+	queue := &eventQueue{callback: callback}
+	queue.idle = sync.NewCond(&queue.mux)
+	handlers := make([]*OrderedHandler, 0, len(names))
+	for _, name := range names {
+		handlers = append(handlers, &OrderedHandler{
+			name:  name,
+			queue: queue,
+		})
+	}
+	return handlers
+}
+
+/*
+OrderedHandler provides an EventHandler interface for an event handler that
+executes its callback sequentially.
+*/
+type OrderedHandler struct {
+	name  string
+	queue *eventQueue
+}
+
+/*
+eventQueue is the queue of one or more ordered event handlers.
+*/
+type eventQueue struct {
+	callback func(response *Response)
+	idle     *sync.Cond
+	mux      sync.Mutex
+	queue    []*Response
+	running  bool
+}
+
+/*
+Handle queues the event for the event handler callback and returns
+immediately.
+
+Handle is an EventHandler implementation.
+*/
+func (handler *OrderedHandler) Handle(
+	response *Response,
+) {
+	queue := handler.queue
+	queue.mux.Lock()
+	defer queue.mux.Unlock()
+	queue.queue = append(queue.queue, response)
+	if !queue.running {
+		queue.running = true
+		go queue.run()
+	}
+}
+
+/*
+run executes the callback for queued events until the queue is empty.
+*/
+func (queue *eventQueue) run() {
+	for {
+		queue.mux.Lock()
+		if 0 == len(queue.queue) {
+			queue.running = false
+			queue.idle.Broadcast()
+			queue.mux.Unlock()
+			return
+		}
+		response := queue.queue[0]
+		queue.queue = queue.queue[1:]
+		queue.mux.Unlock()
+
+		queue.callback(response)
+	}
+}
+
+/*
+Wait blocks until all queued events have been handled. Handlers that share a
+queue wait for the events of all of them.
+*/
+func (handler *OrderedHandler) Wait() {
+	queue := handler.queue
+	queue.mux.Lock()
+	defer queue.mux.Unlock()
+	for queue.running {
+		queue.idle.Wait()
+	}
+}
+
+/*
+Name returns the name of the event the handler is assigned to.
+
+Name is an EventHandler implementation.
+*/
+func (handler *OrderedHandler) Name() string {
+	return handler.name
+}
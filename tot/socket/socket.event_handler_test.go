@@ -70,3 +70,77 @@ func TestHandleEvent(t *testing.T) {
 		t.Errorf("Invalid result: expected 'Mock Target Crashed', received '%s'", response3.Result)
 	}
 }
+
+func TestOrderedEventHandler(t *testing.T) {
+	socketURL, _ := url.Parse("https://test:9222/TestOrderedEventHandler")
+	mockSocket := NewMock(socketURL)
+	mockSocket.Listen()
+	defer mockSocket.Stop()
+
+	chunks := ""
+	handler := NewOrderedEventHandler("Some.chunk", func(response *Response) {
+		chunks += string(response.Params)
+	})
+	mockSocket.AddEventHandler(handler)
+
+	done := make(chan bool)
+	mockSocket.AddEventHandler(NewEventHandler("Some.done", func(response *Response) {
+		done <- true
+	}))
+	for _, chunk := range []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"} {
+		mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
+			Error:  &Error{},
+			Method: "Some.chunk",
+			Params: []byte(chunk),
+		})
+	}
+	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
+		Error:  &Error{},
+		Method: "Some.done",
+	})
+	<-done
+	handler.Wait()
+
+	if "123456789" != chunks {
+		t.Errorf("Expected events in order, received '%s'", chunks)
+	}
+}
+
+func TestOrderedEventHandlers(t *testing.T) {
+	socketURL, _ := url.Parse("https://test:9222/TestOrderedEventHandlers")
+	mockSocket := NewMock(socketURL)
+	mockSocket.Listen()
+	defer mockSocket.Stop()
+
+	events := ""
+	handlers := NewOrderedEventHandlers(func(response *Response) {
+		events += response.Method[len("Some."):] + string(response.Params) + " "
+	}, "Some.begin", "Some.end")
+	for _, handler := range handlers {
+		mockSocket.AddEventHandler(handler)
+	}
+
+	done := make(chan bool)
+	mockSocket.AddEventHandler(NewEventHandler("Some.done", func(response *Response) {
+		done <- true
+	}))
+	for _, chunk := range []string{"1", "2", "3", "4", "5"} {
+		for _, method := range []string{"Some.begin", "Some.end"} {
+			mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
+				Error:  &Error{},
+				Method: method,
+				Params: []byte(chunk),
+			})
+		}
+	}
+	mockSocket.Conn().(*MockChromeWebSocket).AddMockData(&Response{
+		Error:  &Error{},
+		Method: "Some.done",
+	})
+	<-done
+	handlers[0].Wait()
+
+	if "begin1 end1 begin2 end2 begin3 end3 begin4 end4 begin5 end5 " != events {
+		t.Errorf("Expected events in order, received '%s'", events)
+	}
+}
@@ -0,0 +1,143 @@
+package socket
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+/*
+NewSession returns a socket for a target session that is attached through the
+socket of another target, e.g. for an out-of-process iframe attached by the
+Target domain of its page. Commands and events of the session are tunnelled
+through Target.sendMessageToTarget and Target.receivedMessageFromTarget. The
+socket stops when the session is detached.
+*/
+func NewSession(parent Socketer, sessionID target.SessionID) *Socket {
+	sessionURL := *parent.URL()
+	sessionURL.Fragment = string(sessionID)
+
+	conn := &sessionConn{
+		parent:    parent,
+		sessionID: sessionID,
+	}
+	conn.cond = sync.NewCond(&conn.mux)
+	conn.handlers = []EventHandler{
+		NewOrderedEventHandler("Target.receivedMessageFromTarget", conn.onMessage),
+	}
+	for _, handler := range conn.handlers {
+		parent.AddEventHandler(handler)
+	}
+
+	socket := open(&sessionURL, func(socketURL *url.URL) (WebSocketer, error) {
+		return conn, nil
+	})
+	detached := NewOrderedEventHandler("Target.detachedFromTarget", func(response *Response) {
+		event := &target.DetachedFromTargetEvent{}
+		if err := json.Unmarshal(response.Params, event); nil != err || sessionID != event.SessionID {
+			return
+		}
+		// The read loop ends after the closed connection fails the read.
+		socket.setListening(false)
+		conn.Close()
+	})
+	conn.mux.Lock()
+	conn.handlers = append(conn.handlers, detached)
+	conn.mux.Unlock()
+	parent.AddEventHandler(detached)
+	return socket
+}
+
+/*
+sessionConn provides a WebSocketer interface for a target session.
+*/
+type sessionConn struct {
+	closed    bool
+	cond      *sync.Cond
+	handlers  []EventHandler
+	messages  []string
+	mux       sync.Mutex
+	parent    Socketer
+	sessionID target.SessionID
+}
+
+/*
+onMessage queues the messages of the session.
+*/
+func (conn *sessionConn) onMessage(response *Response) {
+	event := &target.ReceivedMessageFromTargetEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err || conn.sessionID != event.SessionID {
+		return
+	}
+	conn.mux.Lock()
+	defer conn.mux.Unlock()
+	conn.messages = append(conn.messages, event.Message)
+	conn.cond.Broadcast()
+}
+
+/*
+Close detaches the connection from the parent socket.
+
+Close is a WebSocketer implementation.
+*/
+func (conn *sessionConn) Close() error {
+	conn.mux.Lock()
+	if conn.closed {
+		conn.mux.Unlock()
+		return nil
+	}
+	conn.closed = true
+	handlers := conn.handlers
+	conn.cond.Broadcast()
+	conn.mux.Unlock()
+
+	for _, handler := range handlers {
+		conn.parent.RemoveEventHandler(handler)
+	}
+	return nil
+}
+
+/*
+ReadJSON waits for the next message of the session and unmarshalls it into
+the provided variable.
+
+ReadJSON is a WebSocketer implementation.
+*/
+func (conn *sessionConn) ReadJSON(v interface{}) error {
+	conn.mux.Lock()
+	for 0 == len(conn.messages) && !conn.closed {
+		conn.cond.Wait()
+	}
+	if 0 == len(conn.messages) {
+		conn.mux.Unlock()
+		return errs.New(codes.SocketNotConnected, "session detached")
+	}
+	message := conn.messages[0]
+	conn.messages = conn.messages[1:]
+	conn.mux.Unlock()
+	return json.Unmarshal([]byte(message), v)
+}
+
+/*
+WriteJSON marshalls the provided data as JSON and sends it to the session.
+
+WriteJSON is a WebSocketer implementation.
+*/
+func (conn *sessionConn) WriteJSON(v interface{}) error {
+	message, err := json.Marshal(v)
+	if nil != err {
+		return errs.Wrap(err, codes.SocketWriteFailed, "could not marshal the session message")
+	}
+	response := <-conn.parent.SendCommand(NewCommand(conn.parent, "Target.sendMessageToTarget", &target.SendMessageToTargetParams{
+		Message:   string(message),
+		SessionID: conn.sessionID,
+	}))
+	if nil != response.Error && 0 != response.Error.Code {
+		return errs.Wrap(response.Error, codes.SocketWriteFailed, "Target.sendMessageToTarget failed")
+	}
+	return nil
+}
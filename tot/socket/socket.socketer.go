@@ -17,13 +17,20 @@ New returns a pointer to a websocket struct that implements Socketer interface
 listening to the specified URL.
 */
 func New(url *url.URL) *Socket {
+	return open(url, NewWebsocket)
+}
+
+/*
+open returns a listening socket that connects with connect.
+*/
+func open(url *url.URL, connect func(socketURL *url.URL) (WebSocketer, error)) *Socket {
 	socket := &Socket{
 		commandIDMux: &sync.Mutex{},
 		commands:     NewCommandMap(),
 		errCh:        make(chan error, 3),
 		handlers:     NewEventHandlerMap(),
 		mux:          &sync.Mutex{},
-		newSocket:    NewWebsocket,
+		newSocket:    connect,
 		socketID:     NextSocketID(),
 		url:          url,
 	}
@@ -104,6 +111,7 @@ type Socket struct {
 	handlers     EventHandlerMapper
 	listenCh     chan bool
 	listening    bool
+	listenMux    sync.Mutex
 	mux          *sync.Mutex
 	newSocket    func(socketURL *url.URL) (WebSocketer, error)
 	socketID     int
@@ -216,7 +224,12 @@ func (socket *Socket) handleEvent(
 		for a, event := range handlers {
 			log.WithFields(log.Fields{"event": response.Method, "handler#": a, "socketID": socket.socketID}).
 				Info("Executing handler")
-			go event.Handle(response)
+			if ordered, ok := event.(*OrderedHandler); ok {
+				// Ordered handlers queue events without blocking.
+				ordered.Handle(response)
+			} else {
+				go event.Handle(response)
+			}
 		}
 	}
 }
@@ -263,10 +276,31 @@ Listen is a Socketer implementation.
 */
 func (socket *Socket) Listen() {
 	socket.listenCh = make(chan bool)
-	socket.listening = true
+	socket.setListening(true)
 	go socket.listen(socket.errCh)
 }
 
+/*
+setListening sets whether the read loop runs and returns the previous value.
+The loop may be stopped from other routines, e.g. when a session is detached.
+*/
+func (socket *Socket) setListening(listening bool) bool {
+	socket.listenMux.Lock()
+	defer socket.listenMux.Unlock()
+	previous := socket.listening
+	socket.listening = listening
+	return previous
+}
+
+/*
+isListening returns whether the read loop runs.
+*/
+func (socket *Socket) isListening() bool {
+	socket.listenMux.Lock()
+	defer socket.listenMux.Unlock()
+	return socket.listening
+}
+
 func (socket *Socket) listen(errCh chan error) {
 	var err error
 
@@ -331,7 +365,7 @@ func (socket *Socket) listen(errCh chan error) {
 			socket.handleUnknown(response)
 		}
 
-		if !socket.listening {
+		if !socket.isListening() {
 			log.WithFields(log.Fields{"socketID": socket.socketID, "url": socket.url.String()}).
 				Info("Socket shutting down")
 			go func() {
@@ -344,7 +378,7 @@ func (socket *Socket) listen(errCh chan error) {
 		}
 	}
 
-	socket.listening = false
+	socket.setListening(false)
 	if nil != err {
 		errCh <- errs.Wrap(err, 0, "socket closed")
 		return
@@ -406,8 +440,9 @@ SendCommand is a Socketer implementation.
 Workflow:
 	1. The socket's command mutex is locked.
 	2. The command counter is incremented.
-	3. The payload is sent to the socket connection and the mutex is unlocked.
-	4. The command is stored using the generated ID.
+	3. The command is stored using the generated ID, before the payload is
+	sent so that an immediate response finds it.
+	4. The payload is sent to the socket connection and the mutex is unlocked.
 	5. When the command has been executed and the socket responds,
 	socket.HandleCmd() is triggered from the command instance to generate the
 	response and the command unlocks itself.
@@ -422,7 +457,9 @@ func (socket *Socket) SendCommand(command Commander) chan *Response {
 			Params: command.Params(),
 		}
 
+		socket.commands.Set(command)
 		if err := socket.WriteJSON(payload); err != nil {
+			socket.commands.Delete(command.ID())
 			err = errs.Wrap(err, 0, "write failed: could not write data to websocket")
 			command.Respond(&Response{Error: &Error{
 				Code:    1,
@@ -431,8 +468,6 @@ func (socket *Socket) SendCommand(command Commander) chan *Response {
 			}})
 			return
 		}
-
-		socket.commands.Set(command)
 	}()
 
 	return command.Response()
@@ -445,8 +480,7 @@ websocket connection.
 Stop is a Socketer implementation.
 */
 func (socket *Socket) Stop() {
-	if socket.listening {
-		socket.listening = false
+	if socket.setListening(false) {
 		select {
 		case <-socket.listenCh:
 		case <-time.After(1 * time.Second):
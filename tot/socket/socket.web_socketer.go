@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 
 	errs "github.com/bdlm/errors"
 	"github.com/bdlm/log"
@@ -36,7 +37,7 @@ func NewWebsocket(socketURL *url.URL) (WebSocketer, error) {
 	log.WithFields(log.Fields{"status": response.Status, "url": socketURL.String()}).
 		Info("Websocket connection established")
 
-	return &ChromeWebSocket{conn: websocket}, nil
+	return &ChromeWebSocket{conn: websocket, mux: &sync.Mutex{}}, nil
 }
 
 /*
@@ -48,6 +49,9 @@ ChromeWebSocket represents a WebSocketer interface
 type ChromeWebSocket struct {
 	conn          *websocket.Conn
 	mockResponses []*Response
+
+	// mux serializes writes, the connection supports one concurrent writer.
+	mux *sync.Mutex
 }
 
 /*
@@ -93,5 +97,7 @@ func (socket *ChromeWebSocket) WriteJSON(v interface{}) error {
 	if len(tmp) > 1*1024*1024 {
 		return fmt.Errorf("payload too large. chrome supports a maximum payload size of 1MB. See https://github.com/gorilla/websocket/issues/245")
 	}
+	socket.mux.Lock()
+	defer socket.mux.Unlock()
 	return socket.conn.WriteJSON(v)
 }
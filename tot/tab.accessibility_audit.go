@@ -0,0 +1,298 @@
+package chrome
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mkenney/go-chrome/a11y"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/accessibility"
+	"github.com/mkenney/go-chrome/tot/css"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+/*
+axNode is an accessibility node as returned by
+Accessibility.getPartialAXTree. Roles, property names and value types are
+decoded as strings, newer Chrome versions add values unknown to the
+accessibility package.
+*/
+type axNode struct {
+	NodeID  string `json:"nodeId"`
+	Ignored bool   `json:"ignored"`
+	Role    *struct {
+		Value interface{} `json:"value"`
+	} `json:"role"`
+	Name *struct {
+		Value interface{} `json:"value"`
+	} `json:"name"`
+	Properties []*struct {
+		Name  string `json:"name"`
+		Value *struct {
+			Value interface{} `json:"value"`
+		} `json:"value"`
+	} `json:"properties"`
+	ChildIDs         []string          `json:"childIds"`
+	BackendDOMNodeID dom.BackendNodeID `json:"backendDOMNodeId"`
+}
+
+/*
+noTextElements are the elements whose text is not rendered as text.
+*/
+var noTextElements = map[string]bool{
+	"head":     true,
+	"noscript": true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"title":    true,
+}
+
+/*
+AccessibilityTree returns the full accessibility tree of the page with the
+DOM elements of the nodes and the text colors of the elements with text.
+
+Chrome only returns parts of the accessibility tree, the tree is merged from
+the partial trees of the DOM elements whose children are not known from a
+previous part. Same-process frames and shadow roots are included.
+*/
+func (tab *Tab) AccessibilityTree() (*a11y.Tree, error) {
+	return tab.accessibilityTree(true)
+}
+
+/*
+accessibilityTree returns the accessibility tree of the page, with the text
+styles if styles is true.
+*/
+func (tab *Tab) accessibilityTree(styles bool) (*a11y.Tree, error) {
+	if result := <-tab.DOM().Enable(); nil != result.Err {
+		return nil, wrapError(result.Err, codes.AccessibilityAuditFailed, "could not enable the DOM domain")
+	}
+	if result := <-tab.CSS().Enable(); nil != result.Err {
+		return nil, wrapError(result.Err, codes.AccessibilityAuditFailed, "could not enable the CSS domain")
+	}
+	document, err := tab.domDocument()
+	if nil != err {
+		return nil, wrapError(err, codes.AccessibilityAuditFailed, "could not get the document")
+	}
+
+	tree := &a11y.Tree{URL: document.DocumentURL}
+	nodeIDs := map[*a11y.Element]dom.NodeID{}
+	elements := map[dom.BackendNodeID]*a11y.Element{}
+	var walk func(node *domNode, path string)
+	walk = func(node *domNode, path string) {
+		counts := map[string]int{}
+		for _, child := range node.Children {
+			if 1 == child.NodeType {
+				counts[strings.ToLower(child.LocalName)]++
+			}
+		}
+		index := map[string]int{}
+		for _, child := range node.Children {
+			if 1 != child.NodeType {
+				continue
+			}
+			tag := strings.ToLower(child.LocalName)
+			index[tag]++
+			segment := tag
+			if id, ok := child.Attribute("id"); ok && "" != id {
+				segment += "#" + id
+			}
+			if counts[tag] > 1 {
+				segment += fmt.Sprintf(":nth-of-type(%d)", index[tag])
+			}
+			if "" != path {
+				segment = path + " > " + segment
+			}
+			element := &a11y.Element{
+				BackendNodeID: int(child.BackendNodeID),
+				Tag:           tag,
+				Attributes:    map[string]string{},
+				Path:          segment,
+			}
+			for a := 0; a+1 < len(child.Attributes); a += 2 {
+				element.Attributes[child.Attributes[a]] = child.Attributes[a+1]
+			}
+			for _, text := range child.Children {
+				if 3 == text.NodeType && "" != strings.TrimSpace(text.NodeValue) && !noTextElements[tag] {
+					element.Text = true
+				}
+			}
+			tree.Elements = append(tree.Elements, element)
+			nodeIDs[element] = child.NodeID
+			elements[child.BackendNodeID] = element
+
+			for _, root := range child.ShadowRoots {
+				if "user-agent" != root.ShadowRootType {
+					walk(root, segment+" > ::shadow-root")
+				}
+			}
+			if nil != child.ContentDocument {
+				walk(child.ContentDocument, segment+" > ::document")
+			}
+			walk(child, segment)
+		}
+	}
+	walk(document, "")
+
+	axNodes := map[string]*axNode{}
+	order := []string{}
+	byBackendNodeID := map[dom.BackendNodeID]*axNode{}
+	requested := map[dom.BackendNodeID]bool{}
+	complete := func(backendNodeID dom.BackendNodeID) bool {
+		if requested[backendNodeID] {
+			return true
+		}
+		node, ok := byBackendNodeID[backendNodeID]
+		if !ok {
+			return false
+		}
+		for _, childID := range node.ChildIDs {
+			if _, ok := axNodes[childID]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	for _, element := range tree.Elements {
+		backendNodeID := dom.BackendNodeID(element.BackendNodeID)
+		if complete(backendNodeID) {
+			continue
+		}
+		result := &struct {
+			Nodes []*axNode `json:"nodes"`
+		}{}
+		err := tab.sendCommand(
+			"Accessibility.getPartialAXTree",
+			&accessibility.PartialAXTreeParams{NodeID: nodeIDs[element], FetchRelatives: true},
+			result,
+		)
+		if nil != err {
+			return nil, wrapError(err, codes.AccessibilityAuditFailed, fmt.Sprintf("could not get the accessibility tree of %s", element.Path))
+		}
+		requested[backendNodeID] = true
+		for _, node := range result.Nodes {
+			if _, ok := axNodes[node.NodeID]; !ok {
+				order = append(order, node.NodeID)
+			}
+			axNodes[node.NodeID] = node
+			byBackendNodeID[node.BackendDOMNodeID] = node
+		}
+	}
+
+	nodes := map[string]*a11y.Node{}
+	for _, id := range order {
+		ax := axNodes[id]
+		node := &a11y.Node{
+			ID:         ax.NodeID,
+			Ignored:    ax.Ignored,
+			Properties: map[string]interface{}{},
+		}
+		if nil != ax.Role {
+			node.Role, _ = ax.Role.Value.(string)
+		}
+		if nil != ax.Name {
+			node.Name, _ = ax.Name.Value.(string)
+		}
+		for _, property := range ax.Properties {
+			if nil != property.Value {
+				node.Properties[property.Name] = property.Value.Value
+			}
+		}
+		if element, ok := elements[ax.BackendDOMNodeID]; ok {
+			node.Element = element
+			element.Node = node
+		}
+		nodes[id] = node
+	}
+	for _, id := range order {
+		node := nodes[id]
+		for _, childID := range axNodes[id].ChildIDs {
+			if child, ok := nodes[childID]; ok && nil == child.Parent && child != node {
+				child.Parent = node
+				node.Children = append(node.Children, child)
+			}
+		}
+	}
+	for _, id := range order {
+		node := nodes[id]
+		if nil != node.Parent {
+			continue
+		}
+		if document.BackendNodeID == axNodes[id].BackendDOMNodeID {
+			tree.Roots = append([]*a11y.Node{node}, tree.Roots...)
+		} else {
+			tree.Roots = append(tree.Roots, node)
+		}
+	}
+	tree.Walk(func(node *a11y.Node) {
+		tree.Nodes = append(tree.Nodes, node)
+	})
+
+	for _, element := range tree.Elements {
+		if !styles || !element.Text || (nil != element.Node && element.Node.Ignored) {
+			continue
+		}
+		style, err := tab.textStyle(nodeIDs[element])
+		if nil != err {
+			return nil, wrapError(err, codes.AccessibilityAuditFailed, fmt.Sprintf("could not get the colors of %s", element.Path))
+		}
+		element.Style = style
+	}
+	return tree, nil
+}
+
+/*
+AuditAccessibility checks the accessibility tree of the page with the
+specified rules, or with a11y.DefaultRules if no rules are specified.
+*/
+func (tab *Tab) AuditAccessibility(rules ...*a11y.Rule) (*a11y.Report, error) {
+	tree, err := tab.AccessibilityTree()
+	if nil != err {
+		return nil, err
+	}
+	return a11y.Audit(tree, rules...), nil
+}
+
+/*
+textStyle returns the text color, background colors and font of an element.
+*/
+func (tab *Tab) textStyle(nodeID dom.NodeID) (*a11y.Style, error) {
+	computed := &struct {
+		ComputedStyle []*struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"computedStyle"`
+	}{}
+	if err := tab.sendCommand("CSS.getComputedStyleForNode", &css.GetComputedStyleForNodeParams{NodeID: nodeID}, computed); nil != err {
+		return nil, err
+	}
+	style := &a11y.Style{}
+	for _, property := range computed.ComputedStyle {
+		switch property.Name {
+		case "color":
+			style.Color = property.Value
+		case "font-size":
+			style.FontSize = property.Value
+		case "font-weight":
+			style.FontWeight = property.Value
+		}
+	}
+
+	backgrounds := &struct {
+		BackgroundColors   []string `json:"backgroundColors"`
+		ComputedFontSize   string   `json:"computedFontSize"`
+		ComputedFontWeight string   `json:"computedFontWeight"`
+	}{}
+	if err := tab.sendCommand("CSS.getBackgroundColors", &css.GetBackgroundColorsParams{NodeID: nodeID}, backgrounds); nil != err {
+		return nil, err
+	}
+	style.Backgrounds = backgrounds.BackgroundColors
+	if "" != backgrounds.ComputedFontSize {
+		style.FontSize = backgrounds.ComputedFontSize
+	}
+	if "" != backgrounds.ComputedFontWeight {
+		style.FontWeight = backgrounds.ComputedFontWeight
+	}
+	return style, nil
+}
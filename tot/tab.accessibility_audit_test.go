@@ -0,0 +1,200 @@
+package chrome
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mkenney/go-chrome/a11y"
+)
+
+const testAXDocument = `{"root": {
+	"nodeId": 1, "backendNodeId": 1, "nodeType": 9, "nodeName": "#document", "documentURL": "https://example.com/",
+	"children": [
+		{"nodeId": 2, "backendNodeId": 2, "nodeType": 1, "nodeName": "HTML", "localName": "html", "children": [
+			{"nodeId": 3, "backendNodeId": 3, "nodeType": 1, "nodeName": "HEAD", "localName": "head", "children": [
+				{"nodeId": 4, "backendNodeId": 4, "nodeType": 1, "nodeName": "TITLE", "localName": "title", "children": [
+					{"nodeId": 40, "backendNodeId": 40, "nodeType": 3, "nodeName": "#text", "nodeValue": "Test"}
+				]}
+			]},
+			{"nodeId": 5, "backendNodeId": 5, "nodeType": 1, "nodeName": "BODY", "localName": "body", "children": [
+				{"nodeId": 6, "backendNodeId": 6, "nodeType": 1, "nodeName": "IMG", "localName": "img", "attributes": ["src", "a.png"]},
+				{"nodeId": 7, "backendNodeId": 7, "nodeType": 1, "nodeName": "IMG", "localName": "img", "attributes": ["src", "b.png", "alt", ""]},
+				{"nodeId": 8, "backendNodeId": 8, "nodeType": 1, "nodeName": "BUTTON", "localName": "button"},
+				{"nodeId": 9, "backendNodeId": 9, "nodeType": 1, "nodeName": "INPUT", "localName": "input", "attributes": ["id", "q"]},
+				{"nodeId": 10, "backendNodeId": 10, "nodeType": 1, "nodeName": "INPUT", "localName": "input", "attributes": ["id", "q", "aria-label", "Query"]},
+				{"nodeId": 12, "backendNodeId": 12, "nodeType": 1, "nodeName": "P", "localName": "p", "children": [
+					{"nodeId": 41, "backendNodeId": 41, "nodeType": 3, "nodeName": "#text", "nodeValue": "low contrast"}
+				]},
+				{"nodeId": 13, "backendNodeId": 13, "nodeType": 1, "nodeName": "H1", "localName": "h1", "children": [
+					{"nodeId": 42, "backendNodeId": 42, "nodeType": 3, "nodeName": "#text", "nodeValue": "Big"}
+				]},
+				{"nodeId": 14, "backendNodeId": 14, "nodeType": 1, "nodeName": "DIV", "localName": "div",
+				 "shadowRoots": [{"nodeId": 30, "backendNodeId": 30, "nodeType": 11, "nodeName": "#document-fragment", "shadowRootType": "open", "children": [
+					{"nodeId": 15, "backendNodeId": 15, "nodeType": 1, "nodeName": "SPAN", "localName": "span", "attributes": ["id", "q"], "children": [
+						{"nodeId": 43, "backendNodeId": 43, "nodeType": 3, "nodeName": "#text", "nodeValue": "shadow"}
+					]}
+				 ]}]},
+				{"nodeId": 16, "backendNodeId": 16, "nodeType": 1, "nodeName": "IFRAME", "localName": "iframe", "frameId": "F2",
+				 "contentDocument": {"nodeId": 17, "backendNodeId": 17, "nodeType": 9, "nodeName": "#document", "children": [
+					{"nodeId": 18, "backendNodeId": 18, "nodeType": 1, "nodeName": "HTML", "localName": "html", "children": [
+						{"nodeId": 19, "backendNodeId": 19, "nodeType": 1, "nodeName": "BODY", "localName": "body", "children": [
+							{"nodeId": 20, "backendNodeId": 20, "nodeType": 1, "nodeName": "A", "localName": "a", "attributes": ["href", "/"]}
+						]}
+					]}
+				 ]}}
+			]}
+		]}
+	]
+}}`
+
+/*
+testAXNodes are the accessibility nodes by the DOM node they are returned
+for, each call returns a part of the tree.
+*/
+var testAXNodes = map[int]string{
+	2: `[
+		{"nodeId": "1", "ignored": false, "role": {"type": "role", "value": "RootWebArea"}, "name": {"type": "computedString", "value": "Test"}, "childIds": ["5"], "backendDOMNodeId": 1},
+		{"nodeId": "5", "ignored": false, "role": {"type": "role", "value": "generic"}, "childIds": ["6", "7", "8", "9", "10"], "backendDOMNodeId": 5},
+		{"nodeId": "6", "ignored": false, "role": {"type": "role", "value": "img"}, "name": {"type": "computedString", "value": ""}, "backendDOMNodeId": 6},
+		{"nodeId": "7", "ignored": true, "role": {"type": "role", "value": "none"}, "backendDOMNodeId": 7},
+		{"nodeId": "8", "ignored": false, "role": {"type": "role", "value": "button"}, "name": {"type": "computedString", "value": ""},
+		 "properties": [{"name": "focusable", "value": {"type": "booleanOrUndefined", "value": true}}], "backendDOMNodeId": 8},
+		{"nodeId": "9", "ignored": false, "role": {"type": "role", "value": "textbox"}, "name": {"type": "computedString", "value": ""}, "backendDOMNodeId": 9},
+		{"nodeId": "10", "ignored": false, "role": {"type": "role", "value": "textbox"}, "name": {"type": "computedString", "value": "Query"}, "backendDOMNodeId": 10}
+	]`,
+	12: `[
+		{"nodeId": "5", "ignored": false, "role": {"type": "role", "value": "generic"}, "childIds": ["6", "7", "8", "9", "10", "12", "13", "14", "16"], "backendDOMNodeId": 5},
+		{"nodeId": "12", "ignored": false, "role": {"type": "role", "value": "paragraph"}, "backendDOMNodeId": 12},
+		{"nodeId": "13", "ignored": false, "role": {"type": "role", "value": "heading"}, "name": {"type": "computedString", "value": "Big"}, "backendDOMNodeId": 13},
+		{"nodeId": "14", "ignored": false, "role": {"type": "role", "value": "generic"}, "childIds": ["15"], "backendDOMNodeId": 14},
+		{"nodeId": "16", "ignored": false, "role": {"type": "role", "value": "Iframe"}, "childIds": ["17"], "backendDOMNodeId": 16}
+	]`,
+	15: `[
+		{"nodeId": "15", "ignored": false, "role": {"type": "role", "value": "generic"}, "backendDOMNodeId": 15}
+	]`,
+	18: `[
+		{"nodeId": "17", "ignored": false, "role": {"type": "internalRole", "value": "RootWebArea"}, "childIds": ["20"], "backendDOMNodeId": 17},
+		{"nodeId": "20", "ignored": false, "role": {"type": "role", "value": "link"}, "name": {"type": "computedString", "value": ""}, "backendDOMNodeId": 20}
+	]`,
+}
+
+func TestAccessibilityTree(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestAccessibilityTree")
+	for _, method := range []string{"DOM.enable", "CSS.enable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testAXDocument), nil
+	})
+	requested := []int{}
+	mockSocket.Respond("Accessibility.getPartialAXTree", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ NodeID int }{}
+		json.Unmarshal(params, &request)
+		requested = append(requested, request.NodeID)
+		nodes, ok := testAXNodes[request.NodeID]
+		if !ok {
+			nodes = "[]"
+		}
+		return json.RawMessage(`{"nodes": ` + nodes + `}`), nil
+	})
+	colors := map[int][]string{
+		12: {"rgb(170, 170, 170)", "16px", "400"},
+		13: {"rgb(148, 148, 148)", "32px", "700"},
+		15: {"rgb(0, 0, 0)", "16px", "400"},
+	}
+	styled := []int{}
+	mockSocket.Respond("CSS.getComputedStyleForNode", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ NodeID int }{}
+		json.Unmarshal(params, &request)
+		styled = append(styled, request.NodeID)
+		color, ok := colors[request.NodeID]
+		if !ok {
+			return nil, errors.New("unexpected node")
+		}
+		return map[string]interface{}{"computedStyle": []map[string]string{
+			{"name": "color", "value": color[0]},
+			{"name": "font-size", "value": "1px"},
+			{"name": "font-weight", "value": color[2]},
+		}}, nil
+	})
+	mockSocket.Respond("CSS.getBackgroundColors", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ NodeID int }{}
+		json.Unmarshal(params, &request)
+		return map[string]interface{}{
+			"backgroundColors": []string{"rgb(255, 255, 255)"},
+			"computedFontSize": colors[request.NodeID][1],
+		}, nil
+	})
+
+	tree, err := tab.AccessibilityTree()
+	if nil != err {
+		t.Fatalf("AccessibilityTree failed: %s", err)
+	}
+	if "https://example.com/" != tree.URL || 1 != len(tree.Roots) || "RootWebArea" != tree.Roots[0].Role || "Test" != tree.Roots[0].Name {
+		t.Fatalf("Unexpected roots %v", tree.Roots)
+	}
+	if "[2 3 4 12 14 15 16 18 19]" != fmt.Sprint(requested) {
+		t.Errorf("Unexpected partial tree requests %v", requested)
+	}
+	if "[12 13 15]" != fmt.Sprint(styled) {
+		t.Errorf("Unexpected style requests %v", styled)
+	}
+
+	paths := []string{}
+	for _, element := range tree.Elements {
+		paths = append(paths, element.Path)
+	}
+	for _, expected := range []string{
+		"html > body > img:nth-of-type(1)",
+		"html > body > input#q:nth-of-type(2)",
+		"html > body > div > ::shadow-root > span#q",
+		"html > body > iframe > ::document > html > body > a",
+	} {
+		if !strings.Contains(strings.Join(paths, "\n"), expected) {
+			t.Errorf("Expected the path %s in %v", expected, paths)
+		}
+	}
+
+	roles := []string{}
+	tree.Walk(func(node *a11y.Node) {
+		roles = append(roles, node.Role+":"+node.Path())
+	})
+	if 14 != len(tree.Nodes) || "link:html > body > iframe > ::document > html > body > a" != roles[len(roles)-1] {
+		t.Errorf("Unexpected nodes %v", roles)
+	}
+	if button := tree.Elements[6].Node; "button" != button.Role || !button.Bool("focusable") || "5" != button.Parent.ID {
+		t.Errorf("Unexpected button %v", button)
+	}
+	if style := tree.Elements[10].Style; "h1" != tree.Elements[10].Tag || "rgb(148, 148, 148)" != style.Color || "32px" != style.FontSize || "700" != style.FontWeight {
+		t.Errorf("Unexpected style %v", style)
+	}
+
+	report, err := tab.AuditAccessibility()
+	if nil != err {
+		t.Fatalf("AuditAccessibility failed: %s", err)
+	}
+	issues := []string{}
+	for _, issue := range report.Issues {
+		issues = append(issues, issue.Rule+" "+issue.Path)
+	}
+	if strings.Join([]string{
+		"image-alt html > body > img:nth-of-type(1)",
+		"accessible-name html > body > button",
+		"accessible-name html > body > iframe > ::document > html > body > a",
+		"label html > body > input#q:nth-of-type(1)",
+		"duplicate-id html > body > input#q:nth-of-type(2)",
+		"color-contrast html > body > p",
+	}, "\n") != strings.Join(issues, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(issues, "\n"))
+	}
+	buf := &bytes.Buffer{}
+	if err := report.WriteJUnit(buf); nil != err || !strings.Contains(buf.String(), `failures="5"`) {
+		t.Errorf("Unexpected JUnit report %s (%v)", buf.String(), err)
+	}
+}
@@ -0,0 +1,350 @@
+package chrome
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	std "github.com/bdlm/std/error"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+	"github.com/mkenney/go-chrome/tot/input"
+)
+
+/*
+actionChecks are the checks of an action in addition to attached, visible
+and stable.
+*/
+type actionChecks struct {
+	editable bool
+	enabled  bool
+	hitTest  bool
+}
+
+/*
+actionFailure is a failed actionability check.
+*/
+type actionFailure struct {
+	code    std.Code
+	message string
+}
+
+/*
+actionabilityCheck checks that the element is attached, visible, stable
+across two animation frames and, if requested, enabled and editable. It
+scrolls the element into view and returns the name of the failed check.
+*/
+const actionabilityCheck = `async function(options) {
+	const element = Node.ELEMENT_NODE === this.nodeType ? this : this.parentElement;
+	if (!this.isConnected || !element) {
+		return 'attached';
+	}
+	const box = () => {
+		const rect = element.getBoundingClientRect();
+		return [rect.x, rect.y, rect.width, rect.height].join();
+	};
+	const rect = element.getBoundingClientRect();
+	if ('hidden' === getComputedStyle(element).visibility || 0 === rect.width || 0 === rect.height) {
+		return 'visible';
+	}
+	if (element.scrollIntoViewIfNeeded) {
+		element.scrollIntoViewIfNeeded(true);
+	} else {
+		element.scrollIntoView({block: 'center', inline: 'center'});
+	}
+	const frame = () => new Promise(resolve => requestAnimationFrame(resolve));
+	const before = box();
+	await frame();
+	await frame();
+	if (before !== box()) {
+		return 'stable';
+	}
+	if (options.enabled && (element.disabled || 'true' === element.getAttribute('aria-disabled') ||
+		(element.closest('fieldset[disabled]') && !element.closest('fieldset[disabled] > legend:first-of-type')))) {
+		return 'enabled';
+	}
+	if (options.editable) {
+		const field = element instanceof HTMLInputElement || element instanceof HTMLTextAreaElement;
+		if (field ? element.readOnly : !element.isContentEditable) {
+			return 'editable';
+		}
+	}
+	return '';
+}`
+
+/*
+Click clicks the center of the element with the left mouse button once it is
+actionable, see Element.WaitForActionable().
+*/
+func (element *Element) Click(ctx context.Context) error {
+	x, y, err := element.WaitForActionable(ctx, "click")
+	if nil != err {
+		return err
+	}
+	for _, event := range []input.MouseEventEnum{input.MouseEvent.MouseMoved, input.MouseEvent.MousePressed, input.MouseEvent.MouseReleased} {
+		params := &input.DispatchMouseEventParams{Type: event, X: x, Y: y}
+		if input.MouseEvent.MouseMoved != event {
+			params.Button = input.ButtonEvent.Left
+			params.ClickCount = 1
+		}
+		if result := <-element.tab.Input().DispatchMouseEvent(params); nil != result.Err {
+			return wrapError(result.Err, codes.ActionFailed, fmt.Sprintf("could not click '%s'", element.Selector))
+		}
+	}
+	return nil
+}
+
+/*
+Hover moves the mouse over the center of the element once it is attached,
+visible, stable and receives events.
+*/
+func (element *Element) Hover(ctx context.Context) error {
+	x, y, err := element.actionable(ctx, "hover", actionChecks{hitTest: true})
+	if nil != err {
+		return err
+	}
+	result := <-element.tab.Input().DispatchMouseEvent(&input.DispatchMouseEventParams{
+		Type: input.MouseEvent.MouseMoved,
+		X:    x,
+		Y:    y,
+	})
+	if nil != result.Err {
+		return wrapError(result.Err, codes.ActionFailed, fmt.Sprintf("could not hover '%s'", element.Selector))
+	}
+	return nil
+}
+
+/*
+Type focuses the element once it is actionable and editable and types the
+text with key events. Newlines are typed as Enter.
+*/
+func (element *Element) Type(ctx context.Context, text string) error {
+	if _, _, err := element.actionable(ctx, "type", actionChecks{editable: true, enabled: true, hitTest: true}); nil != err {
+		return err
+	}
+	if err := element.callFunction(`function() { this.focus(); }`, nil); nil != err {
+		return wrapError(err, codes.ActionFailed, fmt.Sprintf("could not focus '%s'", element.Selector))
+	}
+	for _, char := range text {
+		down := &input.DispatchKeyEventParams{Type: input.KeyEvent.KeyDown, Key: string(char), Text: string(char)}
+		if '\n' == char || '\r' == char {
+			down = &input.DispatchKeyEventParams{Type: input.KeyEvent.KeyDown, Key: "Enter", Code: "Enter", Text: "\r", WindowsVirtualKeyCode: 13}
+		}
+		up := &input.DispatchKeyEventParams{Type: input.KeyEvent.KeyUp, Key: down.Key, Code: down.Code, WindowsVirtualKeyCode: down.WindowsVirtualKeyCode}
+		for _, params := range []*input.DispatchKeyEventParams{down, up} {
+			if result := <-element.tab.Input().DispatchKeyEvent(params); nil != result.Err {
+				return wrapError(result.Err, codes.ActionFailed, fmt.Sprintf("could not type into '%s'", element.Selector))
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Select selects the options of a <select> element by value or label once it
+is actionable, and fires the input and change events. It returns the values
+of the selected options.
+*/
+func (element *Element) Select(ctx context.Context, values ...string) ([]string, error) {
+	if _, _, err := element.actionable(ctx, "select", actionChecks{enabled: true, hitTest: true}); nil != err {
+		return nil, err
+	}
+	selected := []string{}
+	err := element.callFunction(`function(values) {
+		if (!(this instanceof HTMLSelectElement)) {
+			throw new Error('Element is not a <select> element');
+		}
+		if (values.length > 1 && !this.multiple) {
+			throw new Error('Element does not allow multiple selections');
+		}
+		const options = Array.from(this.options);
+		for (const value of values) {
+			if (!options.some(option => value === option.value || value === option.label)) {
+				throw new Error('No option ' + JSON.stringify(value));
+			}
+		}
+		for (const option of options) {
+			option.selected = values.some(value => value === option.value || value === option.label);
+		}
+		this.dispatchEvent(new Event('input', {bubbles: true}));
+		this.dispatchEvent(new Event('change', {bubbles: true}));
+		return options.filter(option => option.selected).map(option => option.value);
+	}`, &selected, values)
+	if nil != err {
+		return nil, wrapError(err, codes.ActionFailed, fmt.Sprintf("could not select %q in '%s'", values, element.Selector))
+	}
+	return selected, nil
+}
+
+/*
+WaitForActionable waits until the element is attached to the document,
+visible, stable across two animation frames, enabled and receives events at
+its center, i.e. is not covered by another element. It returns the
+coordinates of the center.
+
+It waits until the Timeout of the element or until ctx is done, the error
+says which check failed last.
+*/
+func (element *Element) WaitForActionable(ctx context.Context, action string) (int, int, error) {
+	return element.actionable(ctx, action, actionChecks{enabled: true, hitTest: true})
+}
+
+func (element *Element) actionable(ctx context.Context, action string, checks actionChecks) (int, int, error) {
+	timeout := element.Timeout
+	if 0 == timeout {
+		timeout = DefaultActionTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	wait := 20 * time.Millisecond
+	for {
+		x, y, failure := element.check(checks)
+		if nil == failure {
+			return x, y, nil
+		}
+		message := fmt.Sprintf("%s '%s': %s", action, element.Selector, failure.message)
+		select {
+		case <-ctx.Done():
+			return 0, 0, wrapError(ctx.Err(), failure.code, fmt.Sprintf("%s", message))
+		case <-deadline.C:
+			return 0, 0, errs.New(failure.code, fmt.Sprintf("%s after %s", message, timeout))
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > 500*time.Millisecond {
+			wait = 500 * time.Millisecond
+		}
+	}
+}
+
+/*
+check runs the actionability checks once and returns the center of the
+element or the failed check.
+*/
+func (element *Element) check(checks actionChecks) (int, int, *actionFailure) {
+	failed := ""
+	err := element.callFunction(actionabilityCheck, &failed, map[string]bool{
+		"editable": checks.editable,
+		"enabled":  checks.enabled,
+	})
+	if _, ok := err.(errElementDetached); ok {
+		failed = "attached"
+	} else if nil != err {
+		return 0, 0, &actionFailure{codes.ActionFailed, fmt.Sprintf("check failed: %s", err)}
+	}
+	switch failed {
+	case "":
+	case "attached":
+		return 0, 0, &actionFailure{codes.ElementNotAttached, "element is not attached to the document"}
+	case "visible":
+		return 0, 0, &actionFailure{codes.ElementNotVisible, "element is not visible"}
+	case "stable":
+		return 0, 0, &actionFailure{codes.ElementNotStable, "element is not stable"}
+	case "enabled":
+		return 0, 0, &actionFailure{codes.ElementNotEnabled, "element is not enabled"}
+	case "editable":
+		return 0, 0, &actionFailure{codes.ElementNotEditable, "element is not editable"}
+	default:
+		return 0, 0, &actionFailure{codes.ActionFailed, fmt.Sprintf("unknown check %q", failed)}
+	}
+
+	box := &struct {
+		Model struct {
+			Content []float64 `json:"content"`
+		} `json:"model"`
+	}{}
+	if err := element.tab.sendCommand("DOM.getBoxModel", &dom.GetBoxModelParams{BackendNodeID: element.BackendNodeID}, box); nil != err || len(box.Model.Content) < 8 {
+		return 0, 0, &actionFailure{codes.ElementNotVisible, "element has no box"}
+	}
+	var x, y float64
+	for a := 0; a < 8; a += 2 {
+		x += box.Model.Content[a] / 4
+		y += box.Model.Content[a+1] / 4
+	}
+	if !checks.hitTest {
+		return round(x), round(y), nil
+	}
+
+	hit := &struct {
+		BackendNodeID dom.BackendNodeID `json:"backendNodeId"`
+	}{}
+	if err := element.tab.sendCommand("DOM.getNodeForLocation", &dom.GetNodeForLocationParams{X: int64(round(x)), Y: int64(round(y))}, hit); nil != err {
+		return 0, 0, &actionFailure{codes.ElementObscured, fmt.Sprintf("nothing at %d,%d", round(x), round(y))}
+	}
+	if hit.BackendNodeID != element.BackendNodeID {
+		covered, description, err := element.covered(hit.BackendNodeID)
+		if nil != err {
+			return 0, 0, &actionFailure{codes.ActionFailed, fmt.Sprintf("hit test failed: %s", err)}
+		}
+		if covered {
+			return 0, 0, &actionFailure{codes.ElementObscured, fmt.Sprintf("element is covered by %s at %d,%d", description, round(x), round(y))}
+		}
+	}
+	return round(x), round(y), nil
+}
+
+/*
+covered returns whether the hit node is outside of the element, including
+its shadow roots and frames, and a description of the hit element.
+*/
+func (element *Element) covered(hitID dom.BackendNodeID) (bool, string, error) {
+	subtree := &struct {
+		Node *domNode `json:"node"`
+	}{}
+	err := element.tab.sendCommand("DOM.describeNode", &dom.DescribeNodeParams{BackendNodeID: element.BackendNodeID, Depth: -1, Pierce: true}, subtree)
+	if nil != err {
+		return false, "", err
+	}
+	var contains func(node *domNode) bool
+	contains = func(node *domNode) bool {
+		if nil == node {
+			return false
+		}
+		if hitID == node.BackendNodeID || contains(node.ContentDocument) {
+			return true
+		}
+		for _, children := range [][]*domNode{node.Children, node.ShadowRoots} {
+			for _, child := range children {
+				if contains(child) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if contains(subtree.Node) {
+		return false, "", nil
+	}
+
+	hit := &struct {
+		Node *domNode `json:"node"`
+	}{}
+	if err := element.tab.sendCommand("DOM.describeNode", &dom.DescribeNodeParams{BackendNodeID: hitID}, hit); nil != err || nil == hit.Node {
+		return true, "another node", nil
+	}
+	description := strings.ToLower(hit.Node.LocalName)
+	if "" == description {
+		description = hit.Node.NodeName
+	}
+	if id, ok := hit.Node.Attribute("id"); ok && "" != id {
+		description += "#" + id
+	}
+	if class, ok := hit.Node.Attribute("class"); ok {
+		for _, name := range strings.Fields(class) {
+			description += "." + name
+		}
+	}
+	return true, description, nil
+}
+
+/*
+round rounds a coordinate to the nearest pixel.
+*/
+func round(value float64) int {
+	if value < 0 {
+		return int(value - 0.5)
+	}
+	return int(value + 0.5)
+}
@@ -0,0 +1,218 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+)
+
+type actionTab struct {
+	*MockSocket
+	checks   []string
+	events   []string
+	hit      int
+	mux      sync.Mutex
+	options  []string
+	released int
+}
+
+func newActionTab() (*Tab, *actionTab) {
+	tab, mockSocket := NewMockTab("https://TestActions")
+	mock := &actionTab{MockSocket: mockSocket, hit: 10}
+	mockSocket.Respond("DOM.resolveNode", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ BackendNodeID int }{}
+		json.Unmarshal(params, &request)
+		if 10 != request.BackendNodeID {
+			return nil, errors.New("No node with given id found")
+		}
+		return map[string]interface{}{"object": map[string]string{"type": "object", "objectId": "obj-10"}}, nil
+	})
+	mockSocket.Respond("Runtime.releaseObject", func(params json.RawMessage) (interface{}, error) {
+		mock.mux.Lock()
+		defer mock.mux.Unlock()
+		mock.released++
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("Runtime.callFunctionOn", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			FunctionDeclaration string
+			ObjectID            string
+			Arguments           []struct{ Value json.RawMessage }
+		}{}
+		json.Unmarshal(params, &request)
+		mock.mux.Lock()
+		defer mock.mux.Unlock()
+		switch {
+		case "obj-10" != request.ObjectID:
+			return nil, errors.New("Could not find object with given id")
+		case strings.Contains(request.FunctionDeclaration, "requestAnimationFrame"):
+			mock.options = append(mock.options, string(request.Arguments[0].Value))
+			failed := ""
+			if len(mock.checks) > 0 {
+				failed, mock.checks = mock.checks[0], mock.checks[1:]
+				if len(mock.checks) == 0 && "" != failed {
+					mock.checks = []string{failed}
+				}
+			}
+			return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": failed}}, nil
+		case strings.Contains(request.FunctionDeclaration, "focus"):
+			mock.events = append(mock.events, "focus")
+			return map[string]interface{}{"result": map[string]interface{}{"type": "undefined"}}, nil
+		case strings.Contains(request.FunctionDeclaration, "HTMLSelectElement"):
+			values := []string{}
+			json.Unmarshal(request.Arguments[0].Value, &values)
+			if 1 == len(values) && "missing" == values[0] {
+				return map[string]interface{}{
+					"result":           map[string]interface{}{"type": "object"},
+					"exceptionDetails": map[string]interface{}{"text": "Uncaught", "exception": map[string]string{"description": `Error: No option "missing"`}},
+				}, nil
+			}
+			return map[string]interface{}{"result": map[string]interface{}{"type": "object", "value": values}}, nil
+		}
+		return nil, errors.New("unexpected function")
+	})
+	mockSocket.Respond("DOM.getBoxModel", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"model": map[string]interface{}{
+			"content": []float64{10, 20, 30, 20, 30, 41, 10, 41},
+			"width":   20,
+			"height":  21,
+		}}, nil
+	})
+	mockSocket.Respond("DOM.getNodeForLocation", func(params json.RawMessage) (interface{}, error) {
+		mock.mux.Lock()
+		defer mock.mux.Unlock()
+		return map[string]interface{}{"backendNodeId": mock.hit}, nil
+	})
+	mockSocket.Respond("DOM.describeNode", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			BackendNodeID int
+			Depth         int
+		}{}
+		json.Unmarshal(params, &request)
+		if -1 == request.Depth {
+			return json.RawMessage(`{"node": {"backendNodeId": 10, "nodeType": 1, "localName": "button",
+				"shadowRoots": [{"backendNodeId": 11, "nodeType": 11, "children": [{"backendNodeId": 12, "nodeType": 1, "localName": "span"}]}]}}`), nil
+		}
+		return json.RawMessage(`{"node": {"backendNodeId": 77, "nodeType": 1, "nodeName": "DIV", "localName": "div", "attributes": ["id", "overlay", "class", "modal open"]}}`), nil
+	})
+	for _, method := range []string{"Input.dispatchMouseEvent", "Input.dispatchKeyEvent"} {
+		method := method
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			event := map[string]interface{}{}
+			json.Unmarshal(params, &event)
+			mock.mux.Lock()
+			defer mock.mux.Unlock()
+			if strings.HasSuffix(method, "MouseEvent") {
+				mock.events = append(mock.events, fmt.Sprintf("%s %v,%v %v", event["type"], event["x"], event["y"], event["button"]))
+			} else {
+				mock.events = append(mock.events, fmt.Sprintf("%s %v", event["type"], event["key"]))
+			}
+			return map[string]interface{}{}, nil
+		})
+	}
+	return tab, mock
+}
+
+func TestElementClick(t *testing.T) {
+	tab, mock := newActionTab()
+	element := tab.newElement(10, "role=button")
+	mock.checks = []string{"visible", "stable", ""}
+	if err := element.Click(context.Background()); nil != err {
+		t.Fatalf("Click failed: %s", err)
+	}
+	if "[mouseMoved 20,31 <nil> mousePressed 20,31 left mouseReleased 20,31 left]" != fmt.Sprint(mock.events) {
+		t.Errorf("Unexpected events %v", mock.events)
+	}
+	if 3 != len(mock.options) || `{"editable":false,"enabled":true}` != mock.options[2] || 3 != mock.released {
+		t.Errorf("Unexpected checks %v, %d objects released", mock.options, mock.released)
+	}
+
+	// Hits on the content of the element, e.g. in its shadow root, count.
+	mock.events = nil
+	mock.hit = 12
+	if err := element.Hover(context.Background()); nil != err {
+		t.Fatalf("Hover failed: %s", err)
+	}
+	if "[mouseMoved 20,31 <nil>]" != fmt.Sprint(mock.events) || `{"editable":false,"enabled":false}` != mock.options[3] {
+		t.Errorf("Unexpected events %v", mock.events)
+	}
+}
+
+func TestElementNotActionable(t *testing.T) {
+	tab, mock := newActionTab()
+	element := tab.newElement(10, "css=#save")
+	element.Timeout = 100 * time.Millisecond
+
+	mock.hit = 77
+	start := time.Now()
+	err := element.Click(context.Background())
+	if nil == err || codes.ElementObscured != err.(errs.Err).Code() {
+		t.Fatalf("Expected ElementObscured, received %v", err)
+	}
+	if !strings.Contains(err.Error(), "click 'css=#save': element is covered by div#overlay.modal.open at 20,31 after 100ms") {
+		t.Errorf("Unexpected error %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Unexpected wait %s", elapsed)
+	}
+	if 0 != len(mock.events) {
+		t.Errorf("Unexpected events %v", mock.events)
+	}
+
+	mock.hit = 10
+	for check, code := range map[string]interface{}{
+		"visible":  codes.ElementNotVisible,
+		"stable":   codes.ElementNotStable,
+		"enabled":  codes.ElementNotEnabled,
+		"editable": codes.ElementNotEditable,
+	} {
+		mock.checks = []string{check}
+		if err := element.Type(context.Background(), "x"); nil == err || code != err.(errs.Err).Code() || !strings.Contains(err.Error(), "element is not "+check) {
+			t.Errorf("Expected the %s check to fail, received %v", check, err)
+		}
+	}
+
+	detached := tab.newElement(11, "text=gone")
+	detached.Timeout = 50 * time.Millisecond
+	if err := detached.Click(context.Background()); nil == err || codes.ElementNotAttached != err.(errs.Err).Code() {
+		t.Errorf("Expected ElementNotAttached, received %v", err)
+	}
+
+	mock.checks = []string{"visible"}
+	element.Timeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := element.Click(ctx); nil == err || codes.ElementNotVisible != err.(errs.Err).Code() || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Expected ElementNotVisible with the context error, received %v", err)
+	}
+}
+
+func TestElementTypeSelect(t *testing.T) {
+	tab, mock := newActionTab()
+	element := tab.newElement(10, "label=Name")
+	if err := element.Type(context.Background(), "a\n"); nil != err {
+		t.Fatalf("Type failed: %s", err)
+	}
+	if "[focus keyDown a keyUp a keyDown Enter keyUp Enter]" != fmt.Sprint(mock.events) {
+		t.Errorf("Unexpected events %v", mock.events)
+	}
+	if `{"editable":true,"enabled":true}` != mock.options[0] {
+		t.Errorf("Unexpected checks %v", mock.options)
+	}
+
+	selected, err := element.Select(context.Background(), "red", "Blue")
+	if nil != err || "[red Blue]" != fmt.Sprint(selected) {
+		t.Errorf("Unexpected selection %v (%v)", selected, err)
+	}
+	if _, err := element.Select(context.Background(), "missing"); nil == err || !strings.Contains(err.Error(), `No option "missing"`) {
+		t.Errorf("Expected a missing option error, received %v", err)
+	}
+}
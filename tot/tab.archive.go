@@ -0,0 +1,680 @@
+package chrome
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	stdio "io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/page"
+)
+
+const (
+	// ArchiveHTML is a single HTML file with the resources and frames
+	// inlined as data URIs.
+	ArchiveHTML = "html"
+
+	// ArchiveMHTML is a MHTML (multipart/related) file.
+	ArchiveMHTML = "mhtml"
+)
+
+/*
+Archive saves the page with its frames and resources to w. The documents are
+serialized from the current DOM, so the archive shows the page as rendered
+after its scripts ran. Scripts are removed from the archive, the resources
+are the contents of the page resource tree.
+
+The format is ArchiveMHTML or ArchiveHTML.
+*/
+func (tab *Tab) Archive(ctx context.Context, w stdio.Writer, format string) error {
+	if ArchiveHTML != format && ArchiveMHTML != format {
+		return errs.New(codes.ArchiveFailed, fmt.Sprintf("unknown archive format '%s'", format))
+	}
+	archive := &pageArchive{
+		ctx:       ctx,
+		documents: map[string]*domNode{},
+		frames:    map[string]*archiveFrame{},
+		html:      map[string]string{},
+		inline:    ArchiveHTML == format,
+		missing:   map[string]bool{},
+		resources: map[string]*archiveResource{},
+		tab:       tab,
+	}
+	if err := archive.load(); nil != err {
+		return err
+	}
+	if archive.inline {
+		html, err := archive.frameHTML(archive.main)
+		if nil != err {
+			return err
+		}
+		if _, err := stdio.WriteString(w, html); nil != err {
+			return wrapError(err, codes.ArchiveFailed, "could not write the archive")
+		}
+		return nil
+	}
+	return archive.writeMHTML(w)
+}
+
+/*
+pageArchive is the state of an Archive() call.
+*/
+type pageArchive struct {
+	ctx       context.Context
+	documents map[string]*domNode
+	frames    map[string]*archiveFrame
+	html      map[string]string
+	inline    bool
+	main      *archiveFrame
+	missing   map[string]bool
+	order     []*archiveResource
+	resources map[string]*archiveResource
+	tab       *Tab
+}
+
+/*
+archiveFrame is a frame of the resource tree. It is decoded into a local
+type, unknown resource types of newer Chrome versions would fail the enum
+types of the page package.
+*/
+type archiveFrame struct {
+	Frame struct {
+		ID       string `json:"id"`
+		URL      string `json:"url"`
+		MimeType string `json:"mimeType"`
+	} `json:"frame"`
+	ChildFrames []*archiveFrame `json:"childFrames"`
+	Resources   []struct {
+		URL      string `json:"url"`
+		Type     string `json:"type"`
+		MimeType string `json:"mimeType"`
+		Failed   bool   `json:"failed"`
+		Canceled bool   `json:"canceled"`
+	} `json:"resources"`
+}
+
+/*
+archiveResource is a resource of the page.
+*/
+type archiveResource struct {
+	url      string
+	mimeType string
+	data     []byte
+	inlining bool
+	dataURI  string
+}
+
+/*
+load reads the resource tree, the resources and the DOM of the page.
+*/
+func (archive *pageArchive) load() error {
+	tree := &struct {
+		FrameTree *archiveFrame `json:"frameTree"`
+	}{}
+	if err := archive.send("Page.getResourceTree", nil, tree); nil != err {
+		return err
+	}
+	if nil == tree.FrameTree {
+		return errs.New(codes.ArchiveFailed, "empty resource tree")
+	}
+	archive.main = tree.FrameTree
+	if err := archive.loadFrame(tree.FrameTree); nil != err {
+		return err
+	}
+
+	if err := archive.ctx.Err(); nil != err {
+		return err
+	}
+	root, err := archive.tab.domDocument()
+	if nil != err {
+		return wrapError(err, codes.ArchiveFailed, "DOM.getDocument failed")
+	}
+	archive.documents[archive.main.Frame.ID] = root
+	archive.findDocuments(root)
+	return nil
+}
+
+/*
+loadFrame reads the resources of a frame and its child frames.
+*/
+func (archive *pageArchive) loadFrame(frame *archiveFrame) error {
+	archive.frames[frame.Frame.ID] = frame
+	for _, resource := range frame.Resources {
+		if resource.Failed || resource.Canceled || page.ResourceType.Document.String() == resource.Type {
+			continue
+		}
+		if _, ok := archive.resources[resource.URL]; ok || strings.HasPrefix(resource.URL, "data:") {
+			continue
+		}
+		data, err := archive.content(frame.Frame.ID, resource.URL)
+		if nil != err {
+			if nil != archive.ctx.Err() {
+				return err
+			}
+			// Evicted resources stay references to the network.
+			continue
+		}
+		archived := &archiveResource{url: resource.URL, mimeType: resource.MimeType, data: data}
+		archive.resources[resource.URL] = archived
+		archive.order = append(archive.order, archived)
+	}
+	for _, child := range frame.ChildFrames {
+		if err := archive.loadFrame(child); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+findDocuments maps the frame IDs of the frame owner elements to their content
+documents.
+*/
+func (archive *pageArchive) findDocuments(node *domNode) {
+	if "" != node.FrameID && nil != node.ContentDocument {
+		archive.documents[node.FrameID] = node.ContentDocument
+	}
+	for _, nodes := range [][]*domNode{node.Children, node.ShadowRoots, {node.ContentDocument}} {
+		for _, child := range nodes {
+			if nil != child {
+				archive.findDocuments(child)
+			}
+		}
+	}
+}
+
+/*
+content returns the content of a resource of a frame.
+*/
+func (archive *pageArchive) content(frameID, resourceURL string) ([]byte, error) {
+	result := &page.GetResourceContentResult{}
+	if err := archive.send("Page.getResourceContent", &page.GetResourceContentParams{
+		FrameID: page.FrameID(frameID),
+		URL:     resourceURL,
+	}, result); nil != err {
+		return nil, err
+	}
+	if !result.Base64Encoded {
+		return []byte(result.Content), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Content)
+	if nil != err {
+		return nil, wrapError(err, codes.ArchiveFailed, fmt.Sprintf("invalid base64 content of '%s'", resourceURL))
+	}
+	return data, nil
+}
+
+/*
+send sends a command and decodes the result.
+*/
+func (archive *pageArchive) send(method string, params interface{}, result interface{}) error {
+	if err := archive.ctx.Err(); nil != err {
+		return err
+	}
+	if err := archive.tab.sendCommand(method, params, result); nil != err {
+		return wrapError(err, codes.ArchiveFailed, method+" failed")
+	}
+	return nil
+}
+
+/*
+frameHTML returns the HTML of a frame. Frames without a DOM, e.g. out of
+process frames, are archived as loaded from the network.
+*/
+func (archive *pageArchive) frameHTML(frame *archiveFrame) (string, error) {
+	if html, ok := archive.html[frame.Frame.ID]; ok {
+		return html, nil
+	}
+	var html string
+	if document, ok := archive.documents[frame.Frame.ID]; ok {
+		base := document.BaseURL
+		if "" == base {
+			base = frame.Frame.URL
+		}
+		serializer := &archiveSerializer{archive: archive, buf: &bytes.Buffer{}}
+		serializer.base, _ = url.Parse(base)
+		if err := serializer.node(document, false); nil != err {
+			return "", err
+		}
+		html = serializer.buf.String()
+	} else {
+		data, err := archive.content(frame.Frame.ID, frame.Frame.URL)
+		if nil != err {
+			return "", err
+		}
+		html = string(data)
+	}
+	archive.html[frame.Frame.ID] = html
+	return html, nil
+}
+
+/*
+frameURL returns the URL of a frame in the archive. Frames that can not be
+archived keep their URL.
+*/
+func (archive *pageArchive) frameURL(frameID string) (string, bool, error) {
+	frame, ok := archive.frames[frameID]
+	if !ok || archive.missing[frameID] {
+		return "", false, nil
+	}
+	html, err := archive.frameHTML(frame)
+	if nil != err {
+		if nil != archive.ctx.Err() {
+			return "", false, err
+		}
+		archive.missing[frameID] = true
+		return "", false, nil
+	}
+	if !archive.inline {
+		return mhtmlFrameID(frameID), true, nil
+	}
+	return "data:text/html;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(html)), true, nil
+}
+
+/*
+resourceURL returns the URL of a resource in the archive. References are
+absolute in MHTML archives and data URIs in HTML archives.
+*/
+func (archive *pageArchive) resourceURL(base *url.URL, reference string) string {
+	reference = strings.TrimSpace(reference)
+	if "" == reference || strings.HasPrefix(reference, "data:") || strings.HasPrefix(reference, "#") {
+		return reference
+	}
+	absolute := reference
+	if nil != base {
+		if parsed, err := base.Parse(reference); nil == err {
+			absolute = parsed.String()
+		}
+	}
+	if !archive.inline {
+		return absolute
+	}
+	resource, ok := archive.resources[absolute]
+	if !ok {
+		return absolute
+	}
+	if "" == resource.dataURI {
+		if resource.inlining {
+			// A cycle of style sheet imports.
+			return absolute
+		}
+		resource.inlining = true
+		data := resource.data
+		if "text/css" == resource.mimeType {
+			resourceBase, _ := url.Parse(resource.url)
+			data = []byte(archive.css(resourceBase, string(data)))
+		}
+		resource.dataURI = "data:" + resource.mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		resource.inlining = false
+	}
+	return resource.dataURI
+}
+
+var (
+	cssURLRegex    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)`)
+	cssImportRegex = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+/*
+css rewrites the references of a style sheet.
+*/
+func (archive *pageArchive) css(base *url.URL, css string) string {
+	css = replaceAllSubmatch(cssURLRegex, css, func(match []string) string {
+		return fmt.Sprintf(`url("%s")`, archive.resourceURL(base, strings.Join(match[1:], "")))
+	})
+	return replaceAllSubmatch(cssImportRegex, css, func(match []string) string {
+		return fmt.Sprintf(`@import url("%s")`, archive.resourceURL(base, strings.Join(match[1:], "")))
+	})
+}
+
+/*
+replaceAllSubmatch replaces the matches of a regular expression with the
+result of a function of the submatches.
+*/
+func replaceAllSubmatch(regex *regexp.Regexp, text string, replace func(match []string) string) string {
+	result := &bytes.Buffer{}
+	last := 0
+	for _, index := range regex.FindAllStringSubmatchIndex(text, -1) {
+		match := make([]string, len(index)/2)
+		for a := range match {
+			if index[2*a] >= 0 {
+				match[a] = text[index[2*a]:index[2*a+1]]
+			}
+		}
+		result.WriteString(text[last:index[0]])
+		result.WriteString(replace(match))
+		last = index[1]
+	}
+	result.WriteString(text[last:])
+	return result.String()
+}
+
+/*
+writeMHTML writes the archive as MHTML. The first part is the main frame,
+the child frames are referenced by Content-ID.
+*/
+func (archive *pageArchive) writeMHTML(w stdio.Writer) error {
+	writer := multipart.NewWriter(w)
+	header := fmt.Sprintf(
+		"From: <Saved by go-chrome>\r\nSnapshot-Content-Location: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n"+
+			"Content-Type: multipart/related;\r\n\ttype=\"text/html\";\r\n\tboundary=\"%s\"\r\n\r\n",
+		archive.main.Frame.URL, time.Now().Format(time.RFC1123Z), writer.Boundary(),
+	)
+	if _, err := stdio.WriteString(w, header); nil != err {
+		return wrapError(err, codes.ArchiveFailed, "could not write the archive")
+	}
+
+	// Child frames that can not be archived are skipped, as are their child
+	// frames, the parent frame keeps their URL.
+	var writeFrame func(frame *archiveFrame) error
+	writeFrame = func(frame *archiveFrame) error {
+		if frame != archive.main {
+			if _, ok, err := archive.frameURL(frame.Frame.ID); nil != err || !ok {
+				return err
+			}
+		}
+		html, err := archive.frameHTML(frame)
+		if nil != err {
+			return err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		header.Set("Content-Location", frame.Frame.URL)
+		if frame != archive.main {
+			header.Set("Content-ID", "<"+strings.TrimPrefix(mhtmlFrameID(frame.Frame.ID), "cid:")+">")
+		}
+		if err := writeMHTMLPart(writer, header, []byte(html), true); nil != err {
+			return err
+		}
+		for _, child := range frame.ChildFrames {
+			if err := writeFrame(child); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := writeFrame(archive.main); nil != err {
+		return err
+	}
+	for _, resource := range archive.order {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", resource.mimeType)
+		header.Set("Content-Location", resource.url)
+		if err := writeMHTMLPart(writer, header, resource.data, isTextMimeType(resource.mimeType)); nil != err {
+			return err
+		}
+	}
+	if err := writer.Close(); nil != err {
+		return wrapError(err, codes.ArchiveFailed, "could not write the archive")
+	}
+	return nil
+}
+
+/*
+writeMHTMLPart writes a part of a MHTML archive, text as quoted-printable and
+binary data as base64.
+*/
+func writeMHTMLPart(writer *multipart.Writer, header textproto.MIMEHeader, data []byte, text bool) error {
+	if text {
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+	} else {
+		header.Set("Content-Transfer-Encoding", "base64")
+	}
+	part, err := writer.CreatePart(header)
+	if nil != err {
+		return wrapError(err, codes.ArchiveFailed, "could not write the archive")
+	}
+	if text {
+		encoder := quotedprintable.NewWriter(part)
+		if _, err = encoder.Write(data); nil == err {
+			err = encoder.Close()
+		}
+	} else {
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 0 && nil == err {
+			line := encoded
+			if len(line) > 76 {
+				line = line[:76]
+			}
+			encoded = encoded[len(line):]
+			_, err = stdio.WriteString(part, line+"\r\n")
+		}
+	}
+	if nil != err {
+		return wrapError(err, codes.ArchiveFailed, "could not write the archive")
+	}
+	return nil
+}
+
+/*
+mhtmlFrameID returns the Content-ID URL of a frame.
+*/
+func mhtmlFrameID(frameID string) string {
+	return "cid:frame-" + frameID + "@mhtml.blink"
+}
+
+/*
+isTextMimeType returns whether a MIME type is text.
+*/
+func isTextMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || strings.HasSuffix(mimeType, "+xml") ||
+		"application/javascript" == mimeType || "application/json" == mimeType
+}
+
+/*
+archiveSerializer serializes the DOM of a frame as HTML.
+*/
+type archiveSerializer struct {
+	archive *pageArchive
+	base    *url.URL
+	buf     *bytes.Buffer
+}
+
+var (
+	// archiveVoidElements are the elements without an end tag.
+	archiveVoidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "keygen": true, "link": true, "meta": true, "param": true, "source": true,
+		"track": true, "wbr": true,
+	}
+
+	// archiveRawTextElements are the elements with unescaped text.
+	archiveRawTextElements = map[string]bool{
+		"iframe": true, "noembed": true, "noframes": true, "noscript": true, "plaintext": true,
+		"style": true, "xmp": true,
+	}
+
+	// archiveResourceAttributes are the attributes referencing resources,
+	// other URL attributes are made absolute.
+	archiveResourceAttributes = map[string]bool{
+		"background": true, "data": true, "poster": true, "src": true,
+	}
+
+	// archiveSVGResourceElements are the SVG elements referencing a
+	// resource with href.
+	archiveSVGResourceElements = map[string]bool{
+		"feImage": true, "image": true, "use": true,
+	}
+
+	// archiveLinkAttributes are the attributes referencing documents.
+	archiveLinkAttributes = map[string]bool{
+		"action": true, "cite": true, "href": true, "longdesc": true,
+	}
+
+	archiveTextEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;")
+	archiveAttributeEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;", "\u00a0", "&nbsp;")
+)
+
+/*
+node serializes a node and its children.
+*/
+func (serializer *archiveSerializer) node(node *domNode, raw bool) error {
+	buf := serializer.buf
+	switch node.NodeType {
+	case 1:
+		return serializer.element(node)
+	case 3:
+		if raw {
+			buf.WriteString(node.NodeValue)
+		} else {
+			buf.WriteString(archiveTextEscaper.Replace(node.NodeValue))
+		}
+	case 4:
+		buf.WriteString("<![CDATA[" + node.NodeValue + "]]>")
+	case 8:
+		buf.WriteString("<!--" + node.NodeValue + "-->")
+	case 9, 11:
+		return serializer.children(node.Children, false)
+	case 10:
+		buf.WriteString("<!DOCTYPE " + node.NodeName)
+		if "" != node.PublicID {
+			buf.WriteString(fmt.Sprintf(` PUBLIC "%s"`, node.PublicID))
+			if "" != node.SystemID {
+				buf.WriteString(fmt.Sprintf(` "%s"`, node.SystemID))
+			}
+		} else if "" != node.SystemID {
+			buf.WriteString(fmt.Sprintf(` SYSTEM "%s"`, node.SystemID))
+		}
+		buf.WriteString(">")
+	}
+	return nil
+}
+
+/*
+children serializes a list of nodes.
+*/
+func (serializer *archiveSerializer) children(nodes []*domNode, raw bool) error {
+	for _, child := range nodes {
+		if err := serializer.node(child, raw); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+element serializes an element. Scripts and content security policies are
+removed, references are rewritten and shadow roots are serialized as
+declarative shadow DOM.
+*/
+func (serializer *archiveSerializer) element(node *domNode) error {
+	name := node.LocalName
+	if "" == name {
+		name = strings.ToLower(node.NodeName)
+	}
+	attributes := map[string]string{}
+	for a := 0; a+1 < len(node.Attributes); a += 2 {
+		attributes[strings.ToLower(node.Attributes[a])] = node.Attributes[a+1]
+	}
+	if "script" == name || ("meta" == name && "" != attributes["http-equiv"] &&
+		("content-security-policy" == strings.ToLower(attributes["http-equiv"]) ||
+			"content-type" == strings.ToLower(attributes["http-equiv"]))) {
+		return nil
+	}
+
+	buf := serializer.buf
+	buf.WriteString("<" + name)
+	frameURL, isFrame, err := serializer.archive.frameURL(node.FrameID)
+	if nil != err {
+		return err
+	}
+	for a := 0; a+1 < len(node.Attributes); a += 2 {
+		attribute, value := node.Attributes[a], node.Attributes[a+1]
+		lower := strings.ToLower(attribute)
+		switch {
+		case isFrame && "srcdoc" == lower:
+			continue
+		case isFrame && "src" == lower:
+			value = frameURL
+		case "meta" == name && "charset" == lower:
+			value = "utf-8"
+		case strings.HasPrefix(lower, "on"):
+			// Event handlers do not run without the scripts.
+			continue
+		case "style" == lower:
+			value = serializer.archive.css(serializer.base, value)
+		case "srcset" == lower:
+			value = serializer.srcset(value)
+		case archiveResourceAttributes[lower] || ("href" == lower && ("link" == name || archiveSVGResourceElements[name])):
+			value = serializer.archive.resourceURL(serializer.base, value)
+		case archiveLinkAttributes[lower] || "xlink:href" == lower:
+			value = serializer.absolute(value)
+		}
+		buf.WriteString(" " + attribute + `="` + archiveAttributeEscaper.Replace(value) + `"`)
+	}
+	if isFrame && "" == attributes["src"] {
+		buf.WriteString(` src="` + archiveAttributeEscaper.Replace(frameURL) + `"`)
+	}
+	buf.WriteString(">")
+	if archiveVoidElements[name] {
+		return nil
+	}
+
+	for _, root := range node.ShadowRoots {
+		if "open" != root.ShadowRootType && "closed" != root.ShadowRootType {
+			continue
+		}
+		buf.WriteString(`<template shadowrootmode="` + root.ShadowRootType + `">`)
+		if err := serializer.children(root.Children, false); nil != err {
+			return err
+		}
+		buf.WriteString("</template>")
+	}
+	if "style" == name {
+		text := &bytes.Buffer{}
+		for _, child := range node.Children {
+			text.WriteString(child.NodeValue)
+		}
+		buf.WriteString(serializer.archive.css(serializer.base, text.String()))
+	} else if nil != node.TemplateContent {
+		if err := serializer.children(node.TemplateContent.Children, false); nil != err {
+			return err
+		}
+	} else if !isFrame {
+		if err := serializer.children(node.Children, archiveRawTextElements[name]); nil != err {
+			return err
+		}
+	}
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+/*
+srcset rewrites the URLs of a srcset attribute.
+*/
+func (serializer *archiveSerializer) srcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for a, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if 0 == len(fields) {
+			continue
+		}
+		fields[0] = serializer.archive.resourceURL(serializer.base, fields[0])
+		candidates[a] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+/*
+absolute returns the absolute URL of a reference.
+*/
+func (serializer *archiveSerializer) absolute(reference string) string {
+	if nil == serializer.base || strings.HasPrefix(strings.TrimSpace(reference), "#") {
+		return reference
+	}
+	parsed, err := serializer.base.Parse(strings.TrimSpace(reference))
+	if nil != err {
+		return reference
+	}
+	return parsed.String()
+}
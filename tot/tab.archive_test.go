@@ -0,0 +1,245 @@
+package chrome
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testArchiveTree = `{"frameTree": {
+	"frame": {"id": "F1", "url": "https://example.com/index.html", "mimeType": "text/html"},
+	"resources": [
+		{"url": "https://example.com/index.html", "type": "Document", "mimeType": "text/html"},
+		{"url": "https://example.com/css/app.css", "type": "Stylesheet", "mimeType": "text/css"},
+		{"url": "https://example.com/img/logo.png", "type": "Image", "mimeType": "image/png"},
+		{"url": "https://example.com/js/app.js", "type": "Script", "mimeType": "application/javascript"},
+		{"url": "https://example.com/missing.png", "type": "Image", "mimeType": "image/png", "failed": true},
+		{"url": "https://example.com/beacon", "type": "SomethingNew", "mimeType": "text/plain", "canceled": true}
+	],
+	"childFrames": [
+		{"frame": {"id": "F2", "parentId": "F1", "url": "https://example.com/frame.html", "mimeType": "text/html"},
+		 "resources": [{"url": "https://example.com/img/logo.png", "type": "Image", "mimeType": "image/png"}]},
+		{"frame": {"id": "F3", "parentId": "F1", "url": "https://ads.example.net/ad.html", "mimeType": "text/html"},
+		 "resources": [],
+		 "childFrames": [
+			{"frame": {"id": "F4", "parentId": "F3", "url": "https://ads.example.net/pixel.html", "mimeType": "text/html"},
+			 "resources": []}
+		 ]}
+	]
+}}`
+
+const testArchiveDocument = `{"root": {
+	"nodeType": 9, "nodeName": "#document", "documentURL": "https://example.com/index.html", "baseURL": "https://example.com/index.html",
+	"children": [
+		{"nodeType": 10, "nodeName": "html"},
+		{"nodeType": 1, "nodeName": "HTML", "localName": "html", "children": [
+			{"nodeType": 1, "nodeName": "HEAD", "localName": "head", "children": [
+				{"nodeType": 1, "nodeName": "META", "localName": "meta", "attributes": ["charset", "iso-8859-1"]},
+				{"nodeType": 1, "nodeName": "META", "localName": "meta", "attributes": ["http-equiv", "Content-Security-Policy", "content", "default-src 'self'"]},
+				{"nodeType": 1, "nodeName": "LINK", "localName": "link", "attributes": ["rel", "stylesheet", "href", "css/app.css"]},
+				{"nodeType": 1, "nodeName": "SCRIPT", "localName": "script", "attributes": ["src", "js/app.js"]},
+				{"nodeType": 1, "nodeName": "STYLE", "localName": "style", "children": [
+					{"nodeType": 3, "nodeName": "#text", "nodeValue": "h1 > b { background: url(img/logo.png) }"}
+				]}
+			]},
+			{"nodeType": 1, "nodeName": "BODY", "localName": "body", "children": [
+				{"nodeType": 1, "nodeName": "H1", "localName": "h1", "attributes": ["onclick", "go()"], "children": [
+					{"nodeType": 3, "nodeName": "#text", "nodeValue": "Hello <world> & \"you\""}
+				]},
+				{"nodeType": 8, "nodeName": "#comment", "nodeValue": " note "},
+				{"nodeType": 1, "nodeName": "IMG", "localName": "img", "attributes": ["src", "img/logo.png", "srcset", "img/logo.png 2x, /img/big.png 3x", "alt", "a \"logo\""]},
+				{"nodeType": 1, "nodeName": "A", "localName": "a", "attributes": ["href", "/about", "style", "background: url('img/logo.png')"]},
+				{"nodeType": 1, "nodeName": "IFRAME", "localName": "iframe", "frameId": "F2", "attributes": ["srcdoc", "<p>old</p>"],
+				 "contentDocument": {"nodeType": 9, "nodeName": "#document", "baseURL": "https://example.com/frame.html", "children": [
+					{"nodeType": 1, "nodeName": "P", "localName": "p", "children": [{"nodeType": 3, "nodeName": "#text", "nodeValue": "inner"}]},
+					{"nodeType": 1, "nodeName": "IMG", "localName": "img", "attributes": ["src", "img/logo.png"]}
+				 ]}},
+				{"nodeType": 1, "nodeName": "IFRAME", "localName": "iframe", "frameId": "F3", "attributes": ["src", "https://ads.example.net/ad.html"]},
+				{"nodeType": 1, "nodeName": "DIV", "localName": "div",
+				 "shadowRoots": [
+					{"nodeType": 11, "nodeName": "#document-fragment", "shadowRootType": "open", "children": [
+						{"nodeType": 1, "nodeName": "SPAN", "localName": "span", "children": [{"nodeType": 3, "nodeName": "#text", "nodeValue": "shadow"}]}
+					]},
+					{"nodeType": 11, "nodeName": "#document-fragment", "shadowRootType": "user-agent", "children": []}
+				 ],
+				 "children": [{"nodeType": 1, "nodeName": "BR", "localName": "br"}]}
+			]}
+		]}
+	]
+}}`
+
+func newArchiveTab(unavailable ...string) *Tab {
+	tab, mockSocket := NewMockTab("https://TestArchive")
+	mockSocket.Respond("Page.getResourceTree", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testArchiveTree), nil
+	})
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testArchiveDocument), nil
+	})
+	contents := map[string]map[string]interface{}{
+		"https://example.com/css/app.css":  {"content": "body { background: url('../img/logo.png') }\n@import 'print.css';"},
+		"https://example.com/img/logo.png": {"content": base64.StdEncoding.EncodeToString([]byte("PNG")), "base64Encoded": true},
+		"https://example.com/js/app.js":    {"content": "go()"},
+		"https://ads.example.net/ad.html":  {"content": "<p>ad</p>"},
+	}
+	for _, resourceURL := range unavailable {
+		delete(contents, resourceURL)
+	}
+	mockSocket.Respond("Page.getResourceContent", func(params json.RawMessage) (interface{}, error) {
+		request := map[string]string{}
+		json.Unmarshal(params, &request)
+		if content, ok := contents[request["url"]]; ok {
+			return content, nil
+		}
+		return nil, errors.New("No resource with given URL found")
+	})
+	return tab
+}
+
+func decodeDataURI(t *testing.T, uri string) string {
+	index := strings.Index(uri, ";base64,")
+	if !strings.HasPrefix(uri, "data:") || index < 0 {
+		t.Fatalf("Expected a base64 data URI, received %s", uri)
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[index+8:])
+	if nil != err {
+		t.Fatalf("Invalid data URI %s: %s", uri, err)
+	}
+	return string(data)
+}
+
+func TestArchiveHTML(t *testing.T) {
+	tab := newArchiveTab()
+	buf := &bytes.Buffer{}
+	if err := tab.Archive(context.Background(), buf, ArchiveHTML); nil != err {
+		t.Fatalf("Archive failed: %s", err)
+	}
+	html := buf.String()
+	logo := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("PNG"))
+	for _, expected := range []string{
+		`<!DOCTYPE html><html><head><meta charset="utf-8">`,
+		`<style>h1 > b { background: url("` + logo + `") }</style>`,
+		`<h1>Hello &lt;world&gt; &amp; "you"</h1><!-- note -->`,
+		`<img src="` + logo + `" srcset="` + logo + ` 2x, https://example.com/img/big.png 3x" alt="a &quot;logo&quot;">`,
+		`<a href="https://example.com/about" style="background: url(&quot;` + logo + `&quot;)"></a>`,
+		`<div><template shadowrootmode="open"><span>shadow</span></template><br></div>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected %s in %s", expected, html)
+		}
+	}
+	for _, unexpected := range []string{"<script", "onclick", "Content-Security-Policy", "srcdoc", "user-agent"} {
+		if strings.Contains(html, unexpected) {
+			t.Errorf("Unexpected %s in %s", unexpected, html)
+		}
+	}
+
+	uris := regexp.MustCompile(`<(?:link|iframe)[^>]* (?:href|src)="([^"]*)"`).FindAllStringSubmatch(html, -1)
+	if 3 != len(uris) {
+		t.Fatalf("Expected a style sheet and two frames, received %v", uris)
+	}
+	if css := decodeDataURI(t, uris[0][1]); `body { background: url("`+logo+`") }`+"\n"+`@import url("https://example.com/css/print.css");` != css {
+		t.Errorf("Unexpected style sheet %s", css)
+	}
+	if frame := decodeDataURI(t, uris[1][1]); `<p>inner</p><img src="`+logo+`">` != frame {
+		t.Errorf("Unexpected frame %s", frame)
+	}
+	if frame := decodeDataURI(t, uris[2][1]); "<p>ad</p>" != frame {
+		t.Errorf("Unexpected out of process frame %s", frame)
+	}
+}
+
+func readMHTML(t *testing.T, buf *bytes.Buffer) ([]string, map[string]string) {
+	reader := textproto.NewReader(bufio.NewReader(buf))
+	header, err := reader.ReadMIMEHeader()
+	if nil != err {
+		t.Fatalf("Invalid MHTML header: %s", err)
+	}
+	if "https://example.com/index.html" != header.Get("Snapshot-Content-Location") {
+		t.Errorf("Unexpected header %v", header)
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if nil != err || "multipart/related" != mediaType || "text/html" != params["type"] {
+		t.Fatalf("Unexpected content type %s: %v", header.Get("Content-Type"), err)
+	}
+
+	parts := multipart.NewReader(reader.R, params["boundary"])
+	locations := []string{}
+	contents := map[string]string{}
+	for {
+		part, err := parts.NextPart()
+		if nil != err {
+			break
+		}
+		data, _ := ioutil.ReadAll(part)
+		if "base64" == part.Header.Get("Content-Transfer-Encoding") {
+			data, _ = base64.StdEncoding.DecodeString(strings.Replace(string(data), "\r\n", "", -1))
+		}
+		location := part.Header.Get("Content-Location")
+		locations = append(locations, location+" "+part.Header.Get("Content-ID"))
+		contents[location] = string(data)
+	}
+	return locations, contents
+}
+
+func TestArchiveMHTML(t *testing.T) {
+	tab := newArchiveTab()
+	buf := &bytes.Buffer{}
+	if err := tab.Archive(context.Background(), buf, ArchiveMHTML); nil != err {
+		t.Fatalf("Archive failed: %s", err)
+	}
+	locations, contents := readMHTML(t, buf)
+	if "https://example.com/index.html |https://example.com/frame.html <frame-F2@mhtml.blink>|https://ads.example.net/ad.html <frame-F3@mhtml.blink>|https://example.com/css/app.css |https://example.com/img/logo.png |https://example.com/js/app.js " != strings.Join(locations, "|") {
+		t.Errorf("Unexpected parts %v", locations)
+	}
+	main := contents["https://example.com/index.html"]
+	for _, expected := range []string{
+		`<link rel="stylesheet" href="https://example.com/css/app.css">`,
+		`<iframe src="cid:frame-F2@mhtml.blink"></iframe>`,
+		`<iframe src="cid:frame-F3@mhtml.blink"></iframe>`,
+	} {
+		if !strings.Contains(main, expected) {
+			t.Errorf("Expected %s in %s", expected, main)
+		}
+	}
+	if "PNG" != contents["https://example.com/img/logo.png"] || !strings.HasPrefix(contents["https://example.com/css/app.css"], "body { background: url('../img/logo.png') }") {
+		t.Errorf("Unexpected resources %v", contents)
+	}
+}
+
+func TestArchiveMHTMLMissingFrame(t *testing.T) {
+	tab := newArchiveTab("https://ads.example.net/ad.html")
+	buf := &bytes.Buffer{}
+	if err := tab.Archive(context.Background(), buf, ArchiveMHTML); nil != err {
+		t.Fatalf("Archive failed: %s", err)
+	}
+	locations, contents := readMHTML(t, buf)
+	if "https://example.com/index.html |https://example.com/frame.html <frame-F2@mhtml.blink>|https://example.com/css/app.css |https://example.com/img/logo.png |https://example.com/js/app.js " != strings.Join(locations, "|") {
+		t.Errorf("Unexpected parts %v", locations)
+	}
+	if main := contents["https://example.com/index.html"]; !strings.Contains(main, `<iframe src="https://ads.example.net/ad.html"></iframe>`) {
+		t.Errorf("Expected the out of process frame to keep its URL in %s", main)
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	tab := newArchiveTab()
+	if err := tab.Archive(context.Background(), &bytes.Buffer{}, "pdf"); nil == err {
+		t.Errorf("Expected an error for an unknown format")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tab.Archive(ctx, &bytes.Buffer{}, ArchiveMHTML); context.Canceled != err {
+		t.Errorf("Expected the context error, received %v", err)
+	}
+}
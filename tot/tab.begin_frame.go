@@ -0,0 +1,281 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/headless/experimental"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+/*
+EnableBeginFrameControl sets the Chromium flags required to drive rendering
+with HeadlessExperimental.beginFrame. It must be called before Launch():
+
+	--enable-begin-frame-control
+	--run-all-compositor-stages-before-draw
+
+BeginFrame control is only available in headless mode.
+*/
+func EnableBeginFrameControl(flags ChromiumFlags) error {
+	for _, flag := range []string{
+		"enable-begin-frame-control",
+		"run-all-compositor-stages-before-draw",
+	} {
+		if err := flags.Set(flag, nil); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+NewBeginFrameTab creates a new target with BeginFrame control enabled and
+returns a reference to it. Targets created this way do not render on their
+own, frames must be issued with a BeginFrameController.
+
+Chromium must have been launched with the flags set by
+EnableBeginFrameControl().
+*/
+func (chrome *Chrome) NewBeginFrameTab(uri string) (*Tab, error) {
+	if "" == uri {
+		uri = "about:blank"
+	}
+	targetURL, err := url.Parse(uri)
+	if nil != err {
+		return nil, wrapError(err, codes.TabURLInvalid, "invalid URL")
+	}
+
+	version, err := chrome.Version()
+	if nil != err {
+		return nil, wrapError(err, codes.BeginFrameTargetFailed, "could not query the browser endpoint")
+	}
+	browserURL, err := url.Parse(version.WebSocketDebuggerURL)
+	if nil != err {
+		return nil, wrapError(err, codes.TabWebsocketURLInvalid, fmt.Sprintf("invalid websocket URL '%s'", version.WebSocketDebuggerURL))
+	}
+
+	// Targets can only be created with BeginFrame control from the browser
+	// endpoint.
+	browser := socket.New(browserURL)
+	defer browser.Stop()
+	result := <-browser.Target().CreateTarget(&target.CreateTargetParams{
+		URL:                     uri,
+		EnableBeginFrameControl: true,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.BeginFrameTargetFailed, "Target.createTarget failed")
+	}
+
+	tab, err := chrome.AttachTab(string(result.ID))
+	if nil != err {
+		return nil, err
+	}
+	tab.url = targetURL
+	return tab, nil
+}
+
+/*
+RenderedFrame holds the outcome of a single BeginFrame.
+*/
+type RenderedFrame struct {
+	// Index is the sequence number of the frame, starting at 0.
+	Index int
+
+	// FrameTime is the virtual time the frame was issued for.
+	FrameTime time.Time
+
+	// HasDamage is true if the frame resulted in damage and a new frame was
+	// committed to the display.
+	HasDamage bool
+
+	// MainFrameContentUpdated is true if the main frame submitted a new
+	// display frame in response to the BeginFrame.
+	MainFrameContentUpdated bool
+
+	// Screenshot contains the decoded image data if a screenshot was
+	// requested and the frame had damage.
+	Screenshot []byte
+}
+
+/*
+NewBeginFrameController returns a controller that issues BeginFrames to a tab
+created with NewBeginFrameTab(). Frame times advance by interval for each
+frame regardless of how long the frame takes to render, which allows frame
+exact capture of animations.
+*/
+func NewBeginFrameController(tab *Tab, interval time.Duration) *BeginFrameController {
+	if interval <= 0 {
+		interval = time.Second / 60
+	}
+	return &BeginFrameController{
+		interval: interval,
+		mux:      &sync.Mutex{},
+		tab:      tab,
+	}
+}
+
+/*
+BeginFrameController issues HeadlessExperimental.beginFrame commands to a tab
+at a fixed virtual interval.
+*/
+type BeginFrameController struct {
+	// handler listens for HeadlessExperimental.needsBeginFramesChanged.
+	handler socket.EventHandler
+
+	// interval is the virtual time between frames.
+	interval time.Duration
+
+	// mux guards the frame counter and needsFrames.
+	mux *sync.Mutex
+
+	// needsFrames is the last value reported by the target.
+	needsFrames bool
+
+	// next is the index of the next frame.
+	next int
+
+	// start is the virtual time of the first frame.
+	start time.Time
+
+	// tab is the BeginFrame controlled tab.
+	tab *Tab
+}
+
+/*
+Enable enables HeadlessExperimental events and starts tracking whether the
+target needs BeginFrames.
+*/
+func (controller *BeginFrameController) Enable() error {
+	if nil == controller.handler {
+		controller.handler = socket.NewEventHandler(
+			"HeadlessExperimental.needsBeginFramesChanged",
+			func(response *socket.Response) {
+				event := &experimental.NeedsBeginFramesChangedEvent{}
+				if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+					log.WithFields(log.Fields{"error": err}).Debug("invalid needsBeginFramesChanged event")
+					return
+				}
+				controller.mux.Lock()
+				controller.needsFrames = event.NeedsBeginFrames
+				controller.mux.Unlock()
+			},
+		)
+		controller.tab.AddEventHandler(controller.handler)
+	}
+	if result := <-controller.tab.HeadlessExperimental().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.BeginFrameFailed, "HeadlessExperimental.enable failed")
+	}
+	return nil
+}
+
+/*
+Disable stops tracking HeadlessExperimental events.
+*/
+func (controller *BeginFrameController) Disable() error {
+	if nil != controller.handler {
+		controller.tab.RemoveEventHandler(controller.handler)
+		controller.handler = nil
+	}
+	if result := <-controller.tab.HeadlessExperimental().Disable(); nil != result.Err {
+		return wrapError(result.Err, codes.BeginFrameFailed, "HeadlessExperimental.disable failed")
+	}
+	return nil
+}
+
+/*
+NeedsBeginFrames returns the last state reported by the
+HeadlessExperimental.needsBeginFramesChanged event. Enable() must be called
+first.
+*/
+func (controller *BeginFrameController) NeedsBeginFrames() bool {
+	controller.mux.Lock()
+	defer controller.mux.Unlock()
+	return controller.needsFrames
+}
+
+/*
+Next issues the next BeginFrame and waits for it to complete. If screenshot is
+not nil a screenshot is captured from the resulting frame.
+*/
+func (controller *BeginFrameController) Next(
+	screenshot *experimental.ScreenshotParams,
+) (*RenderedFrame, error) {
+	controller.mux.Lock()
+	if controller.start.IsZero() {
+		controller.start = time.Now()
+	}
+	index := controller.next
+	controller.next++
+	controller.mux.Unlock()
+
+	frameTime := controller.start.Add(time.Duration(index) * controller.interval)
+	result := <-controller.tab.HeadlessExperimental().BeginFrame(&experimental.BeginFrameParams{
+		FrameTime:  runtime.Timestamp(math.Round(float64(frameTime.UnixNano()) / float64(time.Millisecond))),
+		Interval:   float64(controller.interval) / float64(time.Millisecond),
+		Screenshot: screenshot,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.BeginFrameFailed, fmt.Sprintf("frame #%d failed", index))
+	}
+
+	frame := &RenderedFrame{
+		Index:                   index,
+		FrameTime:               frameTime,
+		HasDamage:               result.HasDamage,
+		MainFrameContentUpdated: result.MainFrameContentUpdated,
+	}
+	if "" != result.ScreenshotData {
+		data, err := base64.StdEncoding.DecodeString(result.ScreenshotData)
+		if nil != err {
+			return nil, wrapError(err, codes.BeginFrameFailed, fmt.Sprintf("frame #%d screenshot could not be decoded", index))
+		}
+		frame.Screenshot = data
+	}
+	return frame, nil
+}
+
+/*
+Run issues count frames, one per interval of wall-clock time, and passes each
+result to callback. A count of 0 runs until the context is done or callback
+returns an error.
+*/
+func (controller *BeginFrameController) Run(
+	ctx context.Context,
+	count int,
+	screenshot *experimental.ScreenshotParams,
+	callback func(frame *RenderedFrame) error,
+) error {
+	ticker := time.NewTicker(controller.interval)
+	defer ticker.Stop()
+
+	for a := 1; ; a++ {
+		frame, err := controller.Next(screenshot)
+		if nil != err {
+			return err
+		}
+		if err = callback(frame); nil != err {
+			return err
+		}
+		// Return after the last frame without waiting for the next tick.
+		if 0 != count && a >= count {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
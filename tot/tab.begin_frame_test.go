@@ -0,0 +1,97 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/headless/experimental"
+)
+
+func TestEnableBeginFrameControl(t *testing.T) {
+	flags := &Flags{}
+	if err := EnableBeginFrameControl(flags); nil != err {
+		t.Errorf("Expected nil, received error: %v", err)
+	}
+	if !flags.Has("enable-begin-frame-control") {
+		t.Errorf("Expected enable-begin-frame-control to be set")
+	}
+	if !flags.Has("run-all-compositor-stages-before-draw") {
+		t.Errorf("Expected run-all-compositor-stages-before-draw to be set")
+	}
+}
+
+func TestBeginFrameControllerNext(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestBeginFrameControllerNext")
+	frameTimes := make(chan *experimental.BeginFrameParams, 2)
+	mockSocket.Respond("HeadlessExperimental.beginFrame", func(params json.RawMessage) (interface{}, error) {
+		frameParams := &experimental.BeginFrameParams{}
+		json.Unmarshal(params, frameParams)
+		frameTimes <- frameParams
+		return &experimental.BeginFrameResult{
+			HasDamage:      true,
+			ScreenshotData: base64.StdEncoding.EncodeToString([]byte("image")),
+		}, nil
+	})
+
+	controller := NewBeginFrameController(tab, 100*time.Millisecond)
+	first, err := controller.Next(&experimental.ScreenshotParams{Format: experimental.Format.Png})
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	second, err := controller.Next(nil)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+
+	if 0 != first.Index || 1 != second.Index {
+		t.Errorf("Expected frames 0 and 1, received %d and %d", first.Index, second.Index)
+	}
+	if !first.HasDamage {
+		t.Errorf("Expected damage, received none")
+	}
+	if "image" != string(first.Screenshot) {
+		t.Errorf("Expected 'image', received '%s'", first.Screenshot)
+	}
+	if 100*time.Millisecond != second.FrameTime.Sub(first.FrameTime) {
+		t.Errorf("Expected 100ms between frames, received %s", second.FrameTime.Sub(first.FrameTime))
+	}
+
+	a, b := <-frameTimes, <-frameTimes
+	if 100 != b.FrameTime-a.FrameTime {
+		t.Errorf("Expected frameTime to advance by 100, received %d", b.FrameTime-a.FrameTime)
+	}
+	if 100 != a.Interval {
+		t.Errorf("Expected interval 100, received %f", a.Interval)
+	}
+}
+
+func TestBeginFrameControllerRun(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestBeginFrameControllerRun")
+	mockSocket.Respond("HeadlessExperimental.beginFrame", func(params json.RawMessage) (interface{}, error) {
+		return &experimental.BeginFrameResult{}, nil
+	})
+
+	count := 0
+	controller := NewBeginFrameController(tab, time.Millisecond)
+	err := controller.Run(context.Background(), 3, nil, func(frame *RenderedFrame) error {
+		count++
+		return nil
+	})
+	if nil != err {
+		t.Errorf("Expected nil, received error: %v", err)
+	}
+	if 3 != count {
+		t.Errorf("Expected 3 frames, received %d", count)
+	}
+
+	// Run returns after the last frame without waiting for the interval.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	controller = NewBeginFrameController(tab, time.Hour)
+	if err := controller.Run(ctx, 1, nil, func(frame *RenderedFrame) error { return nil }); nil != err {
+		t.Errorf("Expected nil, received error: %v", err)
+	}
+}
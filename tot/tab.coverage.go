@@ -0,0 +1,326 @@
+package chrome
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/coverage"
+	"github.com/mkenney/go-chrome/tot/css"
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/profiler"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+NewCoverageCollector returns a collector for the JavaScript and CSS coverage
+of the tab.
+*/
+func NewCoverageCollector(tab *Tab) *CoverageCollector {
+	return &CoverageCollector{
+		Client:      http.DefaultClient,
+		coverage:    coverage.New(),
+		mux:         &sync.Mutex{},
+		scripts:     map[runtime.ScriptID]*debugger.ScriptParsedEvent{},
+		sourceMaps:  map[string]bool{},
+		styleSheets: map[css.StyleSheetID]*css.StyleSheetHeader{},
+		tab:         tab,
+	}
+}
+
+/*
+CoverageCollector collects precise JavaScript coverage and CSS rule usage and
+merges them across page loads. Coverage is lost when a page unloads, so Take()
+must be called before each navigation away from a covered page.
+*/
+type CoverageCollector struct {
+	// Client is used to fetch external source maps.
+	Client *http.Client
+
+	coverage    *coverage.Coverage
+	handlers    []socket.EventHandler
+	mux         *sync.Mutex
+	scripts     map[runtime.ScriptID]*debugger.ScriptParsedEvent
+	sourceMaps  map[string]bool
+	started     bool
+	styleSheets map[css.StyleSheetID]*css.StyleSheetHeader
+	tab         *Tab
+}
+
+/*
+Start enables the Debugger, CSS and Profiler domains and starts precise
+coverage with call counts and block coverage, and CSS rule usage tracking.
+*/
+func (collector *CoverageCollector) Start() error {
+	collector.mux.Lock()
+	defer collector.mux.Unlock()
+	if collector.started {
+		return errs.New(codes.CoverageStartFailed, "coverage already started")
+	}
+
+	collector.handlers = []socket.EventHandler{
+		socket.NewEventHandler("Debugger.scriptParsed", func(response *socket.Response) {
+			event := &debugger.ScriptParsedEvent{}
+			if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+				return
+			}
+			collector.mux.Lock()
+			collector.scripts[event.ScriptID] = event
+			collector.mux.Unlock()
+		}),
+		socket.NewEventHandler("CSS.styleSheetAdded", func(response *socket.Response) {
+			event := &css.StyleSheetAddedEvent{}
+			if err := json.Unmarshal([]byte(response.Params), event); nil != err || nil == event.Header {
+				return
+			}
+			collector.mux.Lock()
+			collector.styleSheets[event.Header.StyleSheetID] = event.Header
+			collector.mux.Unlock()
+		}),
+	}
+	for _, handler := range collector.handlers {
+		collector.tab.AddEventHandler(handler)
+	}
+
+	if result := <-collector.tab.Profiler().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "Profiler.enable failed")
+	}
+	if result := <-collector.tab.Profiler().StartPreciseCoverage(&profiler.StartPreciseCoverageParams{
+		CallCount: true,
+		Detailed:  true,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "Profiler.startPreciseCoverage failed")
+	}
+	if result := <-collector.tab.Debugger().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "Debugger.enable failed")
+	}
+	if result := <-collector.tab.DOM().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "DOM.enable failed")
+	}
+	if result := <-collector.tab.CSS().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "CSS.enable failed")
+	}
+	if result := <-collector.tab.CSS().StartRuleUsageTracking(); nil != result.Err {
+		return wrapError(result.Err, codes.CoverageStartFailed, "CSS.startRuleUsageTracking failed")
+	}
+
+	collector.started = true
+	return nil
+}
+
+/*
+Take adds the coverage of the current page to the collected coverage and
+resets the counters.
+*/
+func (collector *CoverageCollector) Take() error {
+	return collector.take(true)
+}
+
+/*
+Stop takes the final coverage, stops coverage collection and returns the
+merged coverage. The Debugger and DOM domains are left enabled.
+*/
+func (collector *CoverageCollector) Stop() (*coverage.Coverage, error) {
+	if err := collector.take(false); nil != err {
+		return nil, err
+	}
+
+	collector.mux.Lock()
+	defer collector.mux.Unlock()
+	collector.started = false
+	for _, handler := range collector.handlers {
+		collector.tab.RemoveEventHandler(handler)
+	}
+	collector.handlers = nil
+
+	if result := <-collector.tab.Profiler().StopPreciseCoverage(); nil != result.Err {
+		return nil, wrapError(result.Err, codes.CoverageTakeFailed, "Profiler.stopPreciseCoverage failed")
+	}
+	if result := <-collector.tab.Profiler().Disable(); nil != result.Err {
+		return nil, wrapError(result.Err, codes.CoverageTakeFailed, "Profiler.disable failed")
+	}
+	return collector.coverage, nil
+}
+
+/*
+Coverage returns the coverage collected so far.
+*/
+func (collector *CoverageCollector) Coverage() *coverage.Coverage {
+	return collector.coverage
+}
+
+/*
+take collects the JavaScript and CSS coverage of the current page. CSS rule
+usage tracking is restarted if requested.
+*/
+func (collector *CoverageCollector) take(restart bool) error {
+	collector.mux.Lock()
+	started := collector.started
+	collector.mux.Unlock()
+	if !started {
+		return errs.New(codes.CoverageTakeFailed, "coverage not started")
+	}
+
+	scripts := <-collector.tab.Profiler().TakePreciseCoverage()
+	if nil != scripts.Err {
+		return wrapError(scripts.Err, codes.CoverageTakeFailed, "Profiler.takePreciseCoverage failed")
+	}
+	for _, script := range scripts.Result {
+		if "" == script.URL {
+			continue
+		}
+		source := <-collector.tab.Debugger().GetScriptSource(&debugger.GetScriptSourceParams{
+			ScriptID: script.ScriptID,
+		})
+		if nil != source.Err {
+			log.WithFields(log.Fields{"url": script.URL, "error": source.Err}).Warn("script source not available")
+			continue
+		}
+		functions := make([]coverage.Function, 0, len(script.Functions))
+		for _, fn := range script.Functions {
+			function := coverage.Function{Name: fn.FunctionName}
+			for _, r := range fn.Ranges {
+				function.Ranges = append(function.Ranges, coverage.Range{
+					Start: r.StartOffset,
+					End:   r.EndOffset,
+					Count: r.Count,
+				})
+			}
+			functions = append(functions, function)
+		}
+		collector.coverage.AddScript(script.URL, source.ScriptSource, functions)
+
+		collector.mux.Lock()
+		parsed := collector.scripts[script.ScriptID]
+		collector.mux.Unlock()
+		if nil != parsed {
+			collector.addSourceMap(script.URL, parsed.SourceMapURL)
+		}
+	}
+
+	rules := <-collector.tab.CSS().StopRuleUsageTracking()
+	if nil != rules.Err {
+		return wrapError(rules.Err, codes.CoverageTakeFailed, "CSS.stopRuleUsageTracking failed")
+	}
+	usage := map[css.StyleSheetID][]coverage.Range{}
+	for _, rule := range rules.RuleUsage {
+		count := 0
+		if rule.Used {
+			count = 1
+		}
+		usage[rule.StyleSheetID] = append(usage[rule.StyleSheetID], coverage.Range{
+			Start: int(rule.StartOffset),
+			End:   int(rule.EndOffset),
+			Count: count,
+		})
+	}
+	for id, ranges := range usage {
+		collector.mux.Lock()
+		header := collector.styleSheets[id]
+		collector.mux.Unlock()
+		if nil == header || "" == header.SourceURL {
+			continue
+		}
+		text := <-collector.tab.CSS().GetStyleSheetText(&css.GetStyleSheetTextParams{StyleSheetID: id})
+		if nil != text.Err {
+			log.WithFields(log.Fields{"url": header.SourceURL, "error": text.Err}).Warn("stylesheet text not available")
+			continue
+		}
+		collector.coverage.AddStyleSheet(header.SourceURL, text.Text, ranges)
+		collector.addSourceMap(header.SourceURL, header.SourceMapURL)
+	}
+
+	if restart {
+		if result := <-collector.tab.CSS().StartRuleUsageTracking(); nil != result.Err {
+			return wrapError(result.Err, codes.CoverageTakeFailed, "CSS.startRuleUsageTracking failed")
+		}
+	}
+	return nil
+}
+
+/*
+addSourceMap loads the source map of a resource once. Source maps that can't
+be loaded are logged and the resource is reported as is.
+*/
+func (collector *CoverageCollector) addSourceMap(resourceURL, sourceMapURL string) {
+	if "" == sourceMapURL {
+		return
+	}
+	collector.mux.Lock()
+	loaded := collector.sourceMaps[resourceURL]
+	collector.sourceMaps[resourceURL] = true
+	collector.mux.Unlock()
+	if loaded {
+		return
+	}
+
+	sourceMap, err := collector.loadSourceMap(resourceURL, sourceMapURL)
+	if nil != err {
+		log.WithFields(log.Fields{"url": resourceURL, "sourceMap": sourceMapURL, "error": err}).Warn("source map not loaded")
+		return
+	}
+	collector.coverage.SetSourceMap(resourceURL, sourceMap)
+}
+
+/*
+loadSourceMap reads an inline data: source map or fetches it relative to the
+resource URL.
+*/
+func (collector *CoverageCollector) loadSourceMap(resourceURL, sourceMapURL string) (*coverage.SourceMap, error) {
+	var data []byte
+	if strings.HasPrefix(sourceMapURL, "data:") {
+		idx := strings.IndexByte(sourceMapURL, ',')
+		if idx < 0 {
+			return nil, errs.New(codes.SourceMapFailed, "invalid data URL")
+		}
+		header, payload := sourceMapURL[:idx], sourceMapURL[idx+1:]
+		if strings.HasSuffix(header, ";base64") {
+			decoded, err := base64.StdEncoding.DecodeString(payload)
+			if nil != err {
+				return nil, wrapError(err, codes.SourceMapFailed, "invalid base64 data URL")
+			}
+			data = decoded
+		} else {
+			decoded, err := url.PathUnescape(payload)
+			if nil != err {
+				return nil, wrapError(err, codes.SourceMapFailed, "invalid data URL")
+			}
+			data = []byte(decoded)
+		}
+
+	} else {
+		base, err := url.Parse(resourceURL)
+		if nil != err {
+			return nil, wrapError(err, codes.SourceMapFailed, "invalid resource URL")
+		}
+		ref, err := url.Parse(sourceMapURL)
+		if nil != err {
+			return nil, wrapError(err, codes.SourceMapFailed, "invalid source map URL")
+		}
+		response, err := collector.Client.Get(base.ResolveReference(ref).String())
+		if nil != err {
+			return nil, wrapError(err, codes.SourceMapFailed, "source map request failed")
+		}
+		defer response.Body.Close()
+		if http.StatusOK != response.StatusCode {
+			return nil, errs.New(codes.SourceMapFailed, "source map request returned "+response.Status)
+		}
+		if data, err = ioutil.ReadAll(response.Body); nil != err {
+			return nil, wrapError(err, codes.SourceMapFailed, "could not read source map")
+		}
+	}
+
+	sourceMap, err := coverage.ParseSourceMap(data)
+	if nil != err {
+		return nil, wrapError(err, codes.SourceMapFailed, "invalid source map")
+	}
+	return sourceMap, nil
+}
@@ -0,0 +1,96 @@
+package chrome
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/mkenney/go-chrome/tot/css"
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/profiler"
+)
+
+func TestCoverageCollector(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestCoverageCollector")
+	for _, method := range []string{
+		"Profiler.enable",
+		"Profiler.startPreciseCoverage",
+		"Profiler.stopPreciseCoverage",
+		"Profiler.disable",
+		"Debugger.enable",
+		"DOM.enable",
+		"CSS.enable",
+		"CSS.startRuleUsageTracking",
+	} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return struct{}{}, nil
+		})
+	}
+	mockSocket.Respond("Profiler.takePreciseCoverage", func(params json.RawMessage) (interface{}, error) {
+		return &profiler.TakePreciseCoverageResult{Result: []*profiler.ScriptCoverage{{
+			ScriptID: "1",
+			URL:      "https://example.com/app.min.js",
+			Functions: []*profiler.FunctionCoverage{
+				{Ranges: []*profiler.CoverageRange{{StartOffset: 0, EndOffset: 44, Count: 1}}},
+				{FunctionName: "a", Ranges: []*profiler.CoverageRange{{StartOffset: 0, EndOffset: 22, Count: 1}}},
+				{FunctionName: "b", Ranges: []*profiler.CoverageRange{{StartOffset: 22, EndOffset: 44, Count: 0}}},
+			},
+		}}}, nil
+	})
+	mockSocket.Respond("Debugger.getScriptSource", func(params json.RawMessage) (interface{}, error) {
+		return &debugger.GetScriptSourceResult{ScriptSource: "function a(){return 1}function b(){return 2}"}, nil
+	})
+	mockSocket.Respond("CSS.stopRuleUsageTracking", func(params json.RawMessage) (interface{}, error) {
+		return &css.StopRuleUsageTrackingResult{RuleUsage: []*css.RuleUsage{
+			{StyleSheetID: "s1", StartOffset: 0, EndOffset: 12, Used: true},
+			{StyleSheetID: "s1", StartOffset: 13, EndOffset: 26, Used: false},
+		}}, nil
+	})
+	mockSocket.Respond("CSS.getStyleSheetText", func(params json.RawMessage) (interface{}, error) {
+		return &css.GetStyleSheetTextResult{Text: "a{color:red}\nb{color:blue}"}, nil
+	})
+
+	collector := NewCoverageCollector(tab)
+	if err := collector.Start(); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	sourceMap := `{"version":3,"sources":["src/app.js"],"names":[],"mappings":"AAAA,sBAEA"}`
+	mockSocket.Fire("Debugger.scriptParsed", &debugger.ScriptParsedEvent{
+		ScriptID:     "1",
+		URL:          "https://example.com/app.min.js",
+		SourceMapURL: "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(sourceMap)),
+	})
+	mockSocket.Fire("CSS.styleSheetAdded", &css.StyleSheetAddedEvent{Header: &css.StyleSheetHeader{
+		StyleSheetID: "s1",
+		SourceURL:    "https://example.com/app.css",
+		Origin:       css.StyleSheetOrigin.Log,
+	}})
+
+	if err := collector.Take(); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	result, err := collector.Stop()
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+
+	files := result.Files()
+	if 2 != len(files) {
+		t.Fatalf("Expected 2 files, received %d", len(files))
+	}
+	if "https://example.com/app.css" != files[0].Path {
+		t.Errorf("Expected the stylesheet, received %s", files[0].Path)
+	}
+	if hit, total := files[0].LinesHit(); 1 != hit || 2 != total {
+		t.Errorf("Expected 1/2 CSS lines hit, received %d/%d", hit, total)
+	}
+	if "src/app.js" != files[1].Path {
+		t.Errorf("Expected the original source, received %s", files[1].Path)
+	}
+	if hit, total := files[1].FunctionsHit(); 1 != hit || 2 != total {
+		t.Errorf("Expected 1/2 functions hit, received %d/%d", hit, total)
+	}
+	if 2 != files[1].Functions[0].Count {
+		t.Errorf("Expected calls to be merged across takes, received %d", files[1].Functions[0].Count)
+	}
+}
@@ -0,0 +1,49 @@
+package chrome
+
+import (
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/profiler"
+)
+
+/*
+StartCPUProfile enables the Profiler domain and starts sampling the
+JavaScript call stacks of the tab. If interval is 0 the default sampling
+interval of 1ms is used.
+
+The profile returned by StopCPUProfile() can be converted for go tool pprof
+with pprof.FromCPUProfile() or saved for DevTools with
+pprof.WriteCPUProfile().
+*/
+func (tab *Tab) StartCPUProfile(interval time.Duration) error {
+	if result := <-tab.Profiler().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.ProfilerStartFailed, "Profiler.enable failed")
+	}
+	if interval > 0 {
+		if result := <-tab.Profiler().SetSamplingInterval(&profiler.SetSamplingIntervalParams{
+			Interval: int(interval / time.Microsecond),
+		}); nil != result.Err {
+			return wrapError(result.Err, codes.ProfilerStartFailed, "Profiler.setSamplingInterval failed")
+		}
+	}
+	if result := <-tab.Profiler().Start(); nil != result.Err {
+		return wrapError(result.Err, codes.ProfilerStartFailed, "Profiler.start failed")
+	}
+	return nil
+}
+
+/*
+StopCPUProfile stops sampling and returns the recorded profile.
+*/
+func (tab *Tab) StopCPUProfile() (*profiler.Profile, error) {
+	result := <-tab.Profiler().Stop()
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.ProfilerStopFailed, "Profiler.stop failed")
+	}
+	if nil == result.Profile {
+		return nil, errs.New(codes.ProfilerStopFailed, "Profiler.stop returned no profile")
+	}
+	return result.Profile, nil
+}
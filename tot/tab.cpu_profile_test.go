@@ -0,0 +1,109 @@
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/profiler"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func TestCPUProfile(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestCPUProfile")
+	calls := []string{}
+	interval := 0
+	mockSocket.Respond("Profiler.enable", func(params json.RawMessage) (interface{}, error) {
+		calls = append(calls, "enable")
+		return &profiler.EnableResult{}, nil
+	})
+	mockSocket.Respond("Profiler.setSamplingInterval", func(params json.RawMessage) (interface{}, error) {
+		request := &profiler.SetSamplingIntervalParams{}
+		json.Unmarshal(params, request)
+		calls = append(calls, "setSamplingInterval")
+		interval = request.Interval
+		return &profiler.SetSamplingIntervalResult{}, nil
+	})
+	mockSocket.Respond("Profiler.start", func(params json.RawMessage) (interface{}, error) {
+		calls = append(calls, "start")
+		return &profiler.StartResult{}, nil
+	})
+	mockSocket.Respond("Profiler.stop", func(params json.RawMessage) (interface{}, error) {
+		calls = append(calls, "stop")
+		return &profiler.StopResult{Profile: &profiler.Profile{
+			Nodes: []*profiler.ProfileNode{
+				{ID: 1, CallFrame: &runtime.CallFrame{FunctionName: "(root)"}, Children: []int{2}},
+				{ID: 2, CallFrame: &runtime.CallFrame{FunctionName: "render", URL: "https://example.com/app.js"}},
+			},
+			StartTime:  1000,
+			EndTime:    3000,
+			Samples:    []int{2, 2},
+			TimeDeltas: []int{1000, 1000},
+		}}, nil
+	})
+
+	if err := tab.StartCPUProfile(250 * time.Microsecond); nil != err {
+		t.Fatalf("StartCPUProfile failed: %s", err)
+	}
+	if "[enable setSamplingInterval start]" != fmt.Sprint(calls) || 250 != interval {
+		t.Errorf("Unexpected calls %v, interval %d", calls, interval)
+	}
+	profile, err := tab.StopCPUProfile()
+	if nil != err {
+		t.Fatalf("StopCPUProfile failed: %s", err)
+	}
+	if 2 != len(profile.Nodes) || "render" != profile.Nodes[1].CallFrame.FunctionName || 2 != len(profile.Samples) {
+		t.Errorf("Unexpected profile %v", profile)
+	}
+
+	calls = []string{}
+	if err := tab.StartCPUProfile(0); nil != err {
+		t.Fatalf("StartCPUProfile failed: %s", err)
+	}
+	if "[enable start]" != fmt.Sprint(calls) {
+		t.Errorf("Expected the default sampling interval, received calls %v", calls)
+	}
+}
+
+func TestCPUProfileErrors(t *testing.T) {
+	for _, failing := range []string{"Profiler.enable", "Profiler.setSamplingInterval", "Profiler.start"} {
+		tab, mockSocket := NewMockTab("https://TestCPUProfileErrors")
+		for _, method := range []string{"Profiler.enable", "Profiler.setSamplingInterval", "Profiler.start"} {
+			method := method
+			mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+				if failing == method {
+					return nil, errors.New("Profiler is not enabled")
+				}
+				return map[string]interface{}{}, nil
+			})
+		}
+		err := tab.StartCPUProfile(time.Millisecond)
+		if nil == err || codes.ProfilerStartFailed != err.(errs.Err).Code() {
+			t.Errorf("Expected ProfilerStartFailed for %s, received %v", failing, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), failing+" failed") || !strings.Contains(err.Error(), "Profiler is not enabled") {
+			t.Errorf("Expected the cause of %s, received %s", failing, err.Error())
+		}
+	}
+
+	tab, mockSocket := NewMockTab("https://TestCPUProfileErrors")
+	mockSocket.Respond("Profiler.stop", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("Profile is not started")
+	})
+	if _, err := tab.StopCPUProfile(); nil == err || codes.ProfilerStopFailed != err.(errs.Err).Code() ||
+		!strings.Contains(err.Error(), "Profile is not started") {
+		t.Errorf("Expected ProfilerStopFailed, received %v", err)
+	}
+	mockSocket.Respond("Profiler.stop", func(params json.RawMessage) (interface{}, error) {
+		return &profiler.StopResult{}, nil
+	})
+	if _, err := tab.StopCPUProfile(); nil == err || codes.ProfilerStopFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected ProfilerStopFailed for a missing profile, received %v", err)
+	}
+}
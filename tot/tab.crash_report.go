@@ -0,0 +1,614 @@
+package chrome
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	stdio "io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/network"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+NewCrashReporter returns a crash reporter for the tab.
+*/
+func NewCrashReporter(tab *Tab) *CrashReporter {
+	reporter := &CrashReporter{
+		ConsoleSize:       100,
+		Format:            "zip",
+		RequestSize:       100,
+		Screenshot:        true,
+		ScreenshotTimeout: 5 * time.Second,
+		debugger:          NewDebugger(tab),
+		mux:               &sync.Mutex{},
+		requestIndex:      map[string]*RequestRecord{},
+		tab:               tab,
+	}
+	reporter.debugger.OnPause(reporter.onPause)
+	return reporter
+}
+
+/*
+CrashReporter captures the context of uncaught exceptions. The debugger
+pauses on uncaught exceptions, the reporter collects the call stack with the
+local variables of each frame, a screenshot and the recent console messages
+and network requests, and resumes the page:
+
+	reporter := chrome.NewCrashReporter(tab)
+	reporter.Dir = "/tmp/crashes"
+	reporter.OnReport(func(report *chrome.CrashReport) {
+		log.Error(report.Exception.Description)
+	})
+	reporter.Start()
+	defer reporter.Stop()
+
+The reporter owns the debugger of the tab while it is started, other pauses
+are resumed immediately.
+*/
+type CrashReporter struct {
+	// ConsoleSize is the number of recent console messages in a report.
+	ConsoleSize int
+
+	// Dir is the directory reports are written to, one bundle file per
+	// report. Reports are not written if it is empty.
+	Dir string
+
+	// Format is the bundle format: "zip" or "json".
+	Format string
+
+	// RequestSize is the number of recent network requests in a report.
+	RequestSize int
+
+	// Screenshot enables screenshots.
+	Screenshot bool
+
+	// ScreenshotTimeout is the maximum time to wait for a screenshot.
+	ScreenshotTimeout time.Duration
+
+	callbacks    []func(report *CrashReport)
+	console      []*ConsoleRecord
+	debugger     *Debugger
+	handlers     []*socket.OrderedHandler
+	mux          *sync.Mutex
+	requestIndex map[string]*RequestRecord
+	requests     []*RequestRecord
+	started      bool
+	tab          *Tab
+}
+
+/*
+CrashReport is the context of an uncaught exception.
+*/
+type CrashReport struct {
+	// Time is the time of the exception.
+	Time time.Time `json:"time"`
+
+	// URL is the URL of the page.
+	URL string `json:"url"`
+
+	// Reason is the pause reason: exception or promiseRejection.
+	Reason string `json:"reason"`
+
+	// Exception is the thrown value.
+	Exception *CrashValue `json:"exception"`
+
+	// Frames is the call stack, top frame first.
+	Frames []*CrashFrame `json:"frames"`
+
+	// Console contains the recent console messages, oldest first.
+	Console []*ConsoleRecord `json:"console"`
+
+	// Requests contains the recent network requests, oldest first.
+	Requests []*RequestRecord `json:"requests"`
+
+	// Screenshot is the PNG screenshot of the page.
+	Screenshot []byte `json:"screenshot,omitempty"`
+
+	// Errors contains the parts of the report that could not be collected.
+	Errors []string `json:"errors,omitempty"`
+}
+
+/*
+CrashFrame is a frame of the call stack of a crash report.
+*/
+type CrashFrame struct {
+	Function  string                 `json:"function"`
+	URL       string                 `json:"url"`
+	Line      int                    `json:"line"`
+	Column    int                    `json:"column"`
+	Variables map[string]*CrashValue `json:"variables"`
+}
+
+/*
+CrashValue is a JavaScript value of a crash report. Value is only set for
+primitive values.
+*/
+type CrashValue struct {
+	Type        string      `json:"type"`
+	Value       interface{} `json:"value,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+/*
+ConsoleRecord is a console message.
+*/
+type ConsoleRecord struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Text string    `json:"text"`
+	URL  string    `json:"url,omitempty"`
+	Line int       `json:"line,omitempty"`
+}
+
+/*
+RequestRecord is a network request.
+*/
+type RequestRecord struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	URL      string    `json:"url"`
+	Type     string    `json:"type,omitempty"`
+	Status   int       `json:"status,omitempty"`
+	MimeType string    `json:"mimeType,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Finished bool      `json:"finished"`
+
+	// Duration is the time from the request to the end of loading in
+	// milliseconds.
+	Duration float64 `json:"duration,omitempty"`
+
+	timestamp float64
+}
+
+/*
+OnReport adds a callback that is called for each crash report.
+*/
+func (reporter *CrashReporter) OnReport(callback func(report *CrashReport)) {
+	reporter.mux.Lock()
+	reporter.callbacks = append(reporter.callbacks, callback)
+	reporter.mux.Unlock()
+}
+
+/*
+Start starts recording console messages and network requests and enables
+pausing on uncaught exceptions.
+*/
+func (reporter *CrashReporter) Start() error {
+	reporter.mux.Lock()
+	if reporter.started {
+		reporter.mux.Unlock()
+		return nil
+	}
+	reporter.started = true
+	// The events share one queue, so that the events of a request are
+	// recorded in order.
+	reporter.handlers = socket.NewOrderedEventHandlers(
+		reporter.onEvent,
+		"Runtime.consoleAPICalled",
+		"Network.requestWillBeSent",
+		"Network.responseReceived",
+		"Network.loadingFinished",
+		"Network.loadingFailed",
+	)
+	reporter.mux.Unlock()
+
+	for _, handler := range reporter.handlers {
+		reporter.tab.AddEventHandler(handler)
+	}
+	if result := <-reporter.tab.Runtime().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.CrashReportFailed, "Runtime.enable failed")
+	}
+	if result := <-reporter.tab.Network().Enable(&network.EnableParams{}); nil != result.Err {
+		return wrapError(result.Err, codes.CrashReportFailed, "Network.enable failed")
+	}
+	if err := reporter.debugger.Enable(); nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not enable the debugger")
+	}
+	if err := reporter.debugger.PauseOnExceptions("uncaught"); nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not pause on exceptions")
+	}
+	return nil
+}
+
+/*
+Stop stops the reporter and disables the debugger.
+*/
+func (reporter *CrashReporter) Stop() error {
+	reporter.mux.Lock()
+	if !reporter.started {
+		reporter.mux.Unlock()
+		return nil
+	}
+	reporter.started = false
+	handlers := reporter.handlers
+	reporter.handlers = nil
+	reporter.mux.Unlock()
+
+	for _, handler := range handlers {
+		reporter.tab.RemoveEventHandler(handler)
+	}
+	if err := reporter.debugger.Disable(); nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not disable the debugger")
+	}
+	return nil
+}
+
+/*
+onEvent records the console messages and network requests.
+*/
+func (reporter *CrashReporter) onEvent(response *socket.Response) {
+	if "Runtime.consoleAPICalled" == response.Method {
+		reporter.onConsole(response)
+	} else {
+		reporter.onRequest(response)
+	}
+}
+
+func (reporter *CrashReporter) onConsole(response *socket.Response) {
+	record := consoleRecord(response.Params)
+	if nil == record {
+		return
+	}
+
+	reporter.mux.Lock()
+	defer reporter.mux.Unlock()
+	reporter.console = append(reporter.console, record)
+	if len(reporter.console) > reporter.ConsoleSize {
+		reporter.console = reporter.console[len(reporter.console)-reporter.ConsoleSize:]
+	}
+}
+
+/*
+consoleRecord returns the record of a Runtime.consoleAPICalled event, nil for
+logpoint messages.
+*/
+func consoleRecord(params json.RawMessage) *ConsoleRecord {
+	// The arguments are decoded one by one, so that a value of an unknown
+	// type does not discard the message.
+	event := &struct {
+		Type       string              `json:"type"`
+		Args       []json.RawMessage   `json:"args"`
+		Timestamp  float64             `json:"timestamp"`
+		StackTrace *runtime.StackTrace `json:"stackTrace"`
+	}{}
+	if err := json.Unmarshal(params, event); nil != err {
+		return nil
+	}
+	texts := make([]string, 0, len(event.Args))
+	for _, arg := range event.Args {
+		object := &runtime.RemoteObject{}
+		if err := json.Unmarshal(arg, object); nil != err {
+			texts = append(texts, unknownObjectText(arg))
+			continue
+		}
+		if logpointMarker == object.Value {
+			// Logpoint messages are recorded by the debugger.
+			return nil
+		}
+		texts = append(texts, remoteObjectText(object))
+	}
+	record := &ConsoleRecord{
+		Time: time.Unix(0, int64(event.Timestamp*float64(time.Millisecond))),
+		Type: event.Type,
+		Text: strings.Join(texts, " "),
+	}
+	if nil != event.StackTrace && len(event.StackTrace.CallFrames) > 0 {
+		record.URL = event.StackTrace.CallFrames[0].URL
+		record.Line = event.StackTrace.CallFrames[0].LineNumber + 1
+	}
+	return record
+}
+
+func (reporter *CrashReporter) onRequest(response *socket.Response) {
+	// Only the required fields are decoded, unknown resource types of newer
+	// Chrome versions would fail the enum types of the network package.
+	event := &struct {
+		RequestID string  `json:"requestId"`
+		Timestamp float64 `json:"timestamp"`
+		WallTime  float64 `json:"wallTime"`
+		Type      string  `json:"type"`
+		ErrorText string  `json:"errorText"`
+		Request   *struct {
+			Method string `json:"method"`
+			URL    string `json:"url"`
+		} `json:"request"`
+		Response *struct {
+			Status   int    `json:"status"`
+			MimeType string `json:"mimeType"`
+		} `json:"response"`
+	}{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+
+	reporter.mux.Lock()
+	defer reporter.mux.Unlock()
+	record, ok := reporter.requestIndex[event.RequestID]
+	switch response.Method {
+	case "Network.requestWillBeSent":
+		if nil == event.Request {
+			return
+		}
+		// Redirects reuse the request ID, the redirected request is a new
+		// record.
+		record = &RequestRecord{
+			ID:        event.RequestID,
+			Time:      time.Unix(0, int64(event.WallTime*float64(time.Second))),
+			Method:    event.Request.Method,
+			URL:       event.Request.URL,
+			Type:      event.Type,
+			timestamp: event.Timestamp,
+		}
+		reporter.requestIndex[event.RequestID] = record
+		reporter.requests = append(reporter.requests, record)
+		if len(reporter.requests) > reporter.RequestSize {
+			for _, dropped := range reporter.requests[:len(reporter.requests)-reporter.RequestSize] {
+				if dropped == reporter.requestIndex[dropped.ID] {
+					delete(reporter.requestIndex, dropped.ID)
+				}
+			}
+			reporter.requests = reporter.requests[len(reporter.requests)-reporter.RequestSize:]
+		}
+	case "Network.responseReceived":
+		if ok && nil != event.Response {
+			record.Status = event.Response.Status
+			record.MimeType = event.Response.MimeType
+		}
+	case "Network.loadingFinished", "Network.loadingFailed":
+		if ok {
+			record.Finished = true
+			record.Error = event.ErrorText
+			record.Duration = (event.Timestamp - record.timestamp) * 1000
+		}
+	}
+}
+
+/*
+onPause creates a report for exceptions and resumes the page.
+*/
+func (reporter *CrashReporter) onPause(pause *Pause) {
+	if "exception" == pause.Reason || "promiseRejection" == pause.Reason {
+		report := reporter.report(pause)
+		reporter.mux.Lock()
+		callbacks := reporter.callbacks
+		dir := reporter.Dir
+		reporter.mux.Unlock()
+
+		if err := reporter.debugger.Continue(); nil != err {
+			log.WithFields(log.Fields{"error": err}).Warn("could not resume after an exception")
+		}
+		if "" != dir {
+			if _, err := reporter.write(dir, report); nil != err {
+				log.WithFields(log.Fields{"dir": dir, "error": err}).Warn("could not write crash report")
+			}
+		}
+		for _, callback := range callbacks {
+			callback(report)
+		}
+		return
+	}
+	if err := reporter.debugger.Continue(); nil != err {
+		log.WithFields(log.Fields{"error": err}).Warn("could not resume")
+	}
+}
+
+/*
+report collects the context of an exception while the page is paused.
+*/
+func (reporter *CrashReporter) report(pause *Pause) *CrashReport {
+	report := &CrashReport{
+		Time:      time.Now(),
+		Reason:    pause.Reason,
+		Exception: crashValue(pause.Exception),
+		Frames:    make([]*CrashFrame, 0, len(pause.Frames)),
+	}
+	if location, err := reporter.debugger.Evaluate("location.href"); nil == err {
+		report.URL, _ = location.Value.(string)
+	}
+
+	for _, frame := range pause.Frames {
+		crashFrame := &CrashFrame{
+			Function:  frame.Function,
+			URL:       frame.URL,
+			Line:      frame.Line,
+			Column:    frame.Column,
+			Variables: map[string]*CrashValue{},
+		}
+		variables, err := frame.Variables()
+		if nil != err {
+			report.Errors = append(report.Errors, fmt.Sprintf("variables of %s: %s", frame.Function, err))
+		}
+		for _, variable := range variables {
+			crashFrame.Variables[variable.Name] = crashValue(variable.Value)
+		}
+		report.Frames = append(report.Frames, crashFrame)
+	}
+
+	if reporter.Screenshot {
+		screenshot, err := reporter.screenshot()
+		if nil != err {
+			report.Errors = append(report.Errors, fmt.Sprintf("screenshot: %s", err))
+		}
+		report.Screenshot = screenshot
+	}
+
+	// Record the events that were received before the exception.
+	reporter.mux.Lock()
+	handlers := reporter.handlers
+	reporter.mux.Unlock()
+	if len(handlers) > 0 {
+		handlers[0].Wait()
+	}
+
+	reporter.mux.Lock()
+	report.Console = append([]*ConsoleRecord{}, reporter.console...)
+	report.Requests = make([]*RequestRecord, 0, len(reporter.requests))
+	for _, record := range reporter.requests {
+		copied := *record
+		report.Requests = append(report.Requests, &copied)
+	}
+	reporter.mux.Unlock()
+	return report
+}
+
+/*
+screenshot captures a PNG screenshot. The page may not render while it is
+paused, the screenshot is skipped after the timeout.
+*/
+func (reporter *CrashReporter) screenshot() ([]byte, error) {
+	select {
+	case result := <-reporter.tab.Page().CaptureScreenshot(&page.CaptureScreenshotParams{
+		Format: page.Format.Png,
+	}):
+		if nil != result.Err {
+			return nil, result.Err
+		}
+		return base64.StdEncoding.DecodeString(result.Data)
+	case <-time.After(reporter.ScreenshotTimeout):
+		return nil, fmt.Errorf("timed out after %s", reporter.ScreenshotTimeout)
+	}
+}
+
+/*
+write writes a report bundle to a directory and returns the file path.
+*/
+func (reporter *CrashReporter) write(dir string, report *CrashReport) (string, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return "", err
+	}
+	format := reporter.Format
+	if "json" != format {
+		format = "zip"
+	}
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s.%s", report.Time.Format("20060102-150405.000000"), format))
+	file, err := os.Create(path)
+	if nil != err {
+		return "", err
+	}
+	if "json" == format {
+		err = report.WriteJSON(file)
+	} else {
+		err = report.WriteZip(file)
+	}
+	if closeErr := file.Close(); nil == err {
+		err = closeErr
+	}
+	return path, err
+}
+
+/*
+WriteJSON writes the report as a single JSON document, the screenshot is
+base64 encoded.
+*/
+func (report *CrashReport) WriteJSON(w stdio.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not write crash report")
+	}
+	return nil
+}
+
+/*
+WriteZip writes the report as a zip archive with a report.json file and a
+screenshot.png file.
+*/
+func (report *CrashReport) WriteZip(w stdio.Writer) error {
+	archive := zip.NewWriter(w)
+	withoutScreenshot := *report
+	withoutScreenshot.Screenshot = nil
+	file, err := archive.Create("report.json")
+	if nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not write crash report")
+	}
+	if err := withoutScreenshot.WriteJSON(file); nil != err {
+		return err
+	}
+	if len(report.Screenshot) > 0 {
+		file, err := archive.Create("screenshot.png")
+		if nil != err {
+			return wrapError(err, codes.CrashReportFailed, "could not write crash report")
+		}
+		if _, err := file.Write(report.Screenshot); nil != err {
+			return wrapError(err, codes.CrashReportFailed, "could not write crash report")
+		}
+	}
+	if err := archive.Close(); nil != err {
+		return wrapError(err, codes.CrashReportFailed, "could not write crash report")
+	}
+	return nil
+}
+
+/*
+crashValue returns the report value of a remote object.
+*/
+func crashValue(object *runtime.RemoteObject) *CrashValue {
+	if nil == object {
+		return nil
+	}
+	value := &CrashValue{
+		Type:        object.Type.String(),
+		Description: object.Description,
+	}
+	if "" == object.ObjectID {
+		value.Value = object.Value
+	}
+	if runtime.ObjectSubtype.Null == object.Subtype {
+		value.Type = "null"
+	}
+	return value
+}
+
+/*
+remoteObjectText returns the console text of a remote object.
+*/
+func remoteObjectText(object *runtime.RemoteObject) string {
+	if runtime.ObjectType.Undefined == object.Type {
+		return "undefined"
+	}
+	if "" == object.ObjectID && nil != object.Value {
+		if text, ok := object.Value.(string); ok {
+			return text
+		}
+		return fmt.Sprintf("%v", object.Value)
+	}
+	if "" != object.Description {
+		return object.Description
+	}
+	if runtime.ObjectSubtype.Null == object.Subtype {
+		return "null"
+	}
+	return object.Type.String()
+}
+
+/*
+unknownObjectText returns the text of a remote object that does not decode,
+e.g. because of a type or subtype unknown to the runtime package.
+*/
+func unknownObjectText(data json.RawMessage) string {
+	object := &struct {
+		Type        string      `json:"type"`
+		Value       interface{} `json:"value"`
+		Description string      `json:"description"`
+	}{}
+	json.Unmarshal(data, object)
+	if nil != object.Value {
+		return fmt.Sprintf("%v", object.Value)
+	}
+	if "" != object.Description {
+		return object.Description
+	}
+	return object.Type
+}
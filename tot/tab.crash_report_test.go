@@ -0,0 +1,184 @@
+package chrome
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func TestCrashReporter(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestCrashReporter")
+	mux := &sync.Mutex{}
+	resumed := 0
+	pauseState := ""
+	for _, method := range []string{"Runtime.enable", "Network.enable", "Debugger.enable", "Debugger.disable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("Debugger.setPauseOnExceptions", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.SetPauseOnExceptionsParams{}
+		json.Unmarshal(params, request)
+		pauseState = request.State.String()
+		return &debugger.SetPauseOnExceptionsResult{}, nil
+	})
+	mockSocket.Respond("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return &runtime.EvaluateResult{
+			Result: &runtime.RemoteObject{Type: runtime.ObjectType.String, Value: "https://example.com/app"},
+		}, nil
+	})
+	mockSocket.Respond("Runtime.getProperties", func(params json.RawMessage) (interface{}, error) {
+		request := &runtime.GetPropertiesParams{}
+		json.Unmarshal(params, request)
+		if "local-1" != request.ObjectID {
+			return &runtime.GetPropertiesResult{}, nil
+		}
+		return &runtime.GetPropertiesResult{Result: []*runtime.PropertyDescriptor{
+			{Name: "a", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 2}},
+			{Name: "items", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Object, ObjectID: "obj-1", Description: "Array(3)"}},
+		}}, nil
+	})
+	mockSocket.Respond("Page.captureScreenshot", func(params json.RawMessage) (interface{}, error) {
+		return &page.CaptureScreenshotResult{Data: base64.StdEncoding.EncodeToString([]byte("PNG"))}, nil
+	})
+	mockSocket.Respond("Debugger.resume", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		resumed++
+		mux.Unlock()
+		return &debugger.ResumeResult{}, nil
+	})
+
+	dir, err := ioutil.TempDir("", "crash-report")
+	if nil != err {
+		t.Fatalf("TempDir failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	reporter := NewCrashReporter(tab)
+	reporter.Dir = dir
+	reporter.RequestSize = 2
+	reports := make(chan *CrashReport, 1)
+	reporter.OnReport(func(report *CrashReport) {
+		reports <- report
+	})
+	if err := reporter.Start(); nil != err {
+		t.Fatalf("Start failed: %s", err)
+	}
+	if "uncaught" != pauseState {
+		t.Errorf("Expected to pause on uncaught exceptions, received %s", pauseState)
+	}
+
+	mockSocket.Fire("Runtime.consoleAPICalled", map[string]interface{}{
+		"type":      "log",
+		"timestamp": 1500000000000.0,
+		"args": []map[string]interface{}{
+			{"type": "string", "value": "loaded"},
+			{"type": "number", "value": 3},
+			{"type": "object", "subtype": "somethingnew", "objectId": "x"},
+		},
+		"stackTrace": map[string]interface{}{"callFrames": []map[string]interface{}{
+			{"functionName": "init", "url": "https://example.com/app.js", "lineNumber": 4},
+		}},
+	})
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent("logpoint-1", "value", &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 1}))
+	for a, url := range []string{"https://example.com/a.js", "https://example.com/api", "https://example.com/b.css"} {
+		id := string(rune('1' + a))
+		mockSocket.Fire("Network.requestWillBeSent", map[string]interface{}{
+			"requestId": id,
+			"timestamp": 10.0 + float64(a),
+			"wallTime":  1500000000.0,
+			"type":      "Fetch",
+			"request":   map[string]interface{}{"method": "GET", "url": url},
+		})
+	}
+	mockSocket.Fire("Network.responseReceived", map[string]interface{}{
+		"requestId": "2",
+		"response":  map[string]interface{}{"status": 500, "mimeType": "application/json"},
+	})
+	mockSocket.Fire("Network.loadingFinished", map[string]interface{}{"requestId": "2", "timestamp": 11.25})
+	mockSocket.Fire("Network.loadingFailed", map[string]interface{}{"requestId": "3", "timestamp": 12.5, "errorText": "net::ERR_FAILED"})
+
+	// A debugger statement is resumed without a report.
+	mockSocket.Fire("Debugger.paused", testPausedEvent(10))
+	paused := map[string]interface{}{}
+	data, _ := json.Marshal(testPausedEvent(11))
+	json.Unmarshal(data, &paused)
+	paused["reason"] = "exception"
+	paused["data"] = map[string]interface{}{"type": "object", "subtype": "error", "className": "TypeError", "description": "TypeError: x is undefined"}
+	// Wait for the first pause to be resumed.
+	for deadline := time.Now().Add(time.Second); ; {
+		mux.Lock()
+		done := 1 == resumed
+		mux.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mockSocket.Fire("Debugger.paused", paused)
+
+	var report *CrashReport
+	select {
+	case report = <-reports:
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the crash report")
+	}
+	if 2 != resumed {
+		t.Errorf("Expected both pauses to be resumed, received %d", resumed)
+	}
+	if "https://example.com/app" != report.URL || "exception" != report.Reason ||
+		"TypeError: x is undefined" != report.Exception.Description || 0 != len(report.Errors) {
+		t.Errorf("Unexpected report %v", report)
+	}
+	if 1 != len(report.Frames) || 12 != report.Frames[0].Line || 2.0 != report.Frames[0].Variables["a"].Value ||
+		nil != report.Frames[0].Variables["items"].Value || "Array(3)" != report.Frames[0].Variables["items"].Description {
+		t.Errorf("Unexpected frames %v", report.Frames[0])
+	}
+	if 1 != len(report.Console) || "loaded 3 object" != report.Console[0].Text || 5 != report.Console[0].Line {
+		t.Errorf("Unexpected console %v", report.Console)
+	}
+	if 2 != len(report.Requests) || "https://example.com/api" != report.Requests[0].URL ||
+		500 != report.Requests[0].Status || 250 != report.Requests[0].Duration ||
+		"net::ERR_FAILED" != report.Requests[1].Error || !report.Requests[1].Finished {
+		t.Errorf("Unexpected requests %v %v", report.Requests[0], report.Requests[1])
+	}
+	if "PNG" != string(report.Screenshot) {
+		t.Errorf("Unexpected screenshot %q", report.Screenshot)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "crash-*.zip"))
+	if 1 != len(files) {
+		t.Fatalf("Expected a zip bundle, received %v", files)
+	}
+	archive, err := zip.OpenReader(files[0])
+	if nil != err {
+		t.Fatalf("OpenReader failed: %s", err)
+	}
+	defer archive.Close()
+	if 2 != len(archive.File) || "report.json" != archive.File[0].Name || "screenshot.png" != archive.File[1].Name {
+		t.Errorf("Unexpected bundle files %v", archive.File)
+	}
+	buf := &bytes.Buffer{}
+	if err := report.WriteJSON(buf); nil != err {
+		t.Fatalf("WriteJSON failed: %s", err)
+	}
+	decoded := &CrashReport{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); nil != err || "PNG" != string(decoded.Screenshot) {
+		t.Errorf("Expected the JSON bundle to contain the screenshot: %v", err)
+	}
+
+	if err := reporter.Stop(); nil != err {
+		t.Errorf("Stop failed: %s", err)
+	}
+}
@@ -0,0 +1,766 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+NewDebugger returns a JavaScript debugger session for the tab.
+*/
+func NewDebugger(tab *Tab) *Debugger {
+	return &Debugger{
+		breakpoints: map[debugger.BreakpointID]*Breakpoint{},
+		logpoints:   map[string]*Logpoint{},
+		mux:         &sync.Mutex{},
+		pauseChan:   make(chan bool),
+		scripts:     map[runtime.ScriptID]*debugger.ScriptParsedEvent{},
+		tab:         tab,
+	}
+}
+
+/*
+Debugger is a stateful JavaScript debugger session. It tracks the parsed
+scripts, the breakpoints and the current pause so that tests can assert on
+the state of the page at a given line:
+
+	dbg := chrome.NewDebugger(tab)
+	dbg.Enable()
+	dbg.SetBreakpoint("app.js", 42, "")
+	// trigger the code
+	pause, _ := dbg.WaitForPause(ctx)
+	count, _ := pause.Top().Value("items.length")
+	dbg.Continue()
+
+Line and column numbers of the session are 1-based.
+*/
+type Debugger struct {
+	asyncStacks      bool
+	breakpoints      map[debugger.BreakpointID]*Breakpoint
+	consoleEnabled   bool
+	enabled          bool
+	handlers         []socket.EventHandler
+	logpointHandlers []func(hit *LogpointHit)
+	logpointID       int
+	logpoints        map[string]*Logpoint
+	mux              *sync.Mutex
+	paused           *Pause
+	pauseChan        chan bool
+	pauseHandlers    []func(pause *Pause)
+	scriptHandler    *socket.OrderedHandler
+	scriptOrder      []runtime.ScriptID
+	scripts          map[runtime.ScriptID]*debugger.ScriptParsedEvent
+	tab              *Tab
+}
+
+/*
+Breakpoint is a breakpoint set by file and line.
+*/
+type Breakpoint struct {
+	// ID is the breakpoint ID.
+	ID debugger.BreakpointID
+
+	// File is the file name or URL the breakpoint was set for.
+	File string
+
+	// Line is the resolved line of the breakpoint. It is the first line at
+	// or after the requested line that has a possible break location.
+	Line int
+
+	// Column is the resolved column of the breakpoint.
+	Column int
+
+	// Condition is the breakpoint condition, empty for unconditional
+	// breakpoints.
+	Condition string
+
+	locations []*debugger.Location
+	mux       *sync.Mutex
+}
+
+/*
+Locations returns the script locations the breakpoint is bound to. Scripts
+parsed after the breakpoint was set are added as they are resolved.
+*/
+func (breakpoint *Breakpoint) Locations() []*debugger.Location {
+	breakpoint.mux.Lock()
+	defer breakpoint.mux.Unlock()
+	return append([]*debugger.Location{}, breakpoint.locations...)
+}
+
+/*
+Pause describes a paused JavaScript thread.
+*/
+type Pause struct {
+	// Reason is the pause reason, e.g. "other" for breakpoints and steps or
+	// "exception".
+	Reason string
+
+	// HitBreakpoints contains the IDs of the breakpoints that caused the
+	// pause.
+	HitBreakpoints []debugger.BreakpointID
+
+	// Exception is the thrown value if the thread paused on an exception.
+	Exception *runtime.RemoteObject
+
+	// Frames is the call stack, top frame first.
+	Frames []*Frame
+}
+
+/*
+Top returns the top call frame.
+*/
+func (pause *Pause) Top() *Frame {
+	if 0 == len(pause.Frames) {
+		return nil
+	}
+	return pause.Frames[0]
+}
+
+/*
+Frame is a call frame of a pause. Frames can only be inspected while the
+thread is paused.
+*/
+type Frame struct {
+	// ID is the call frame ID.
+	ID debugger.CallFrameID
+
+	// Function is the function name, empty for anonymous functions.
+	Function string
+
+	// ScriptID is the ID of the script of the frame.
+	ScriptID runtime.ScriptID
+
+	// URL is the script URL.
+	URL string
+
+	// Line is the current line.
+	Line int
+
+	// Column is the current column.
+	Column int
+
+	// Scopes is the scope chain, innermost scope first.
+	Scopes []*Scope
+
+	// This is the `this` object of the frame.
+	This *runtime.RemoteObject
+
+	// ReturnValue is the value being returned, if the frame is at a return
+	// point.
+	ReturnValue *runtime.RemoteObject
+
+	debugger *Debugger
+}
+
+/*
+Scope is a scope of a call frame.
+*/
+type Scope struct {
+	// Type is the scope type, e.g. "local", "closure", "block" or "global".
+	Type string
+
+	// Name is the scope name, if any.
+	Name string
+
+	// Object is the object enumerating the scope variables.
+	Object *runtime.RemoteObject
+
+	debugger *Debugger
+}
+
+/*
+Variable is a named value of a scope or an object property.
+*/
+type Variable struct {
+	Name  string
+	Value *runtime.RemoteObject
+}
+
+/*
+Enable enables the Debugger domain and starts tracking scripts and pauses.
+*/
+func (dbg *Debugger) Enable() error {
+	dbg.mux.Lock()
+	if dbg.enabled {
+		dbg.mux.Unlock()
+		return nil
+	}
+	dbg.enabled = true
+	dbg.scriptHandler = socket.NewOrderedEventHandler("Debugger.scriptParsed", dbg.onScriptParsed)
+	dbg.handlers = []socket.EventHandler{
+		dbg.scriptHandler,
+		socket.NewOrderedEventHandler("Debugger.paused", dbg.onPaused),
+		socket.NewEventHandler("Debugger.breakpointResolved", dbg.onBreakpointResolved),
+	}
+	dbg.mux.Unlock()
+
+	for _, handler := range dbg.handlers {
+		dbg.tab.AddEventHandler(handler)
+	}
+	if result := <-dbg.tab.Debugger().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.DebuggerFailed, "Debugger.enable failed")
+	}
+	return nil
+}
+
+/*
+Disable disables the Debugger domain, which removes all breakpoints and
+logpoints and resumes a paused thread.
+*/
+func (dbg *Debugger) Disable() error {
+	dbg.mux.Lock()
+	if !dbg.enabled {
+		dbg.mux.Unlock()
+		return nil
+	}
+	dbg.enabled = false
+	handlers := dbg.handlers
+	dbg.handlers = nil
+	dbg.breakpoints = map[debugger.BreakpointID]*Breakpoint{}
+	dbg.logpoints = map[string]*Logpoint{}
+	dbg.asyncStacks = false
+	dbg.consoleEnabled = false
+	dbg.resumed()
+	dbg.mux.Unlock()
+
+	for _, handler := range handlers {
+		dbg.tab.RemoveEventHandler(handler)
+	}
+	if result := <-dbg.tab.Debugger().Disable(); nil != result.Err {
+		return wrapError(result.Err, codes.DebuggerFailed, "Debugger.disable failed")
+	}
+	return nil
+}
+
+func (dbg *Debugger) onScriptParsed(response *socket.Response) {
+	event := &debugger.ScriptParsedEvent{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+	dbg.mux.Lock()
+	defer dbg.mux.Unlock()
+	if _, ok := dbg.scripts[event.ScriptID]; !ok {
+		dbg.scriptOrder = append(dbg.scriptOrder, event.ScriptID)
+	}
+	dbg.scripts[event.ScriptID] = event
+}
+
+func (dbg *Debugger) onBreakpointResolved(response *socket.Response) {
+	event := &debugger.BreakpointResolvedEvent{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+	dbg.mux.Lock()
+	breakpoint, ok := dbg.breakpoints[event.BreakpointID]
+	dbg.mux.Unlock()
+	if ok {
+		breakpoint.mux.Lock()
+		breakpoint.locations = append(breakpoint.locations, event.Location)
+		breakpoint.mux.Unlock()
+	}
+}
+
+/*
+onPaused stores the pause. Debugger.resumed events are not tracked, events
+are delivered concurrently and a late resumed event would clear the next
+pause. The pause is cleared when the session resumes the thread instead.
+*/
+func (dbg *Debugger) onPaused(response *socket.Response) {
+	// debugger.PausedEvent rejects scope types it does not know, e.g.
+	// "wasm-expression-stack", and the pause data, which is not a string
+	// map for exceptions.
+	event := &struct {
+		Reason         string          `json:"reason"`
+		Data           json.RawMessage `json:"data"`
+		HitBreakpoints []string        `json:"hitBreakpoints"`
+		CallFrames     []struct {
+			CallFrameID  debugger.CallFrameID `json:"callFrameId"`
+			FunctionName string               `json:"functionName"`
+			Location     *debugger.Location   `json:"location"`
+			URL          string               `json:"url"`
+			ScopeChain   []struct {
+				Type   string                `json:"type"`
+				Name   string                `json:"name"`
+				Object *runtime.RemoteObject `json:"object"`
+			} `json:"scopeChain"`
+			This        *runtime.RemoteObject `json:"this"`
+			ReturnValue *runtime.RemoteObject `json:"returnValue"`
+		} `json:"callFrames"`
+	}{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+	pause := &Pause{Reason: event.Reason}
+	if ("exception" == event.Reason || "promiseRejection" == event.Reason) && 0 != len(event.Data) {
+		pause.Exception = &runtime.RemoteObject{}
+		if err := json.Unmarshal(event.Data, pause.Exception); nil != err {
+			pause.Exception = nil
+		}
+	}
+	for _, id := range event.HitBreakpoints {
+		pause.HitBreakpoints = append(pause.HitBreakpoints, debugger.BreakpointID(id))
+	}
+	for _, callFrame := range event.CallFrames {
+		frame := &Frame{
+			ID:          callFrame.CallFrameID,
+			Function:    callFrame.FunctionName,
+			URL:         callFrame.URL,
+			This:        callFrame.This,
+			ReturnValue: callFrame.ReturnValue,
+			debugger:    dbg,
+		}
+		if nil != callFrame.Location {
+			frame.ScriptID = callFrame.Location.ScriptID
+			frame.Line = int(callFrame.Location.LineNumber) + 1
+			frame.Column = int(callFrame.Location.ColumnNumber) + 1
+		}
+		for _, scope := range callFrame.ScopeChain {
+			frame.Scopes = append(frame.Scopes, &Scope{
+				Type:     scope.Type,
+				Name:     scope.Name,
+				Object:   scope.Object,
+				debugger: dbg,
+			})
+		}
+		pause.Frames = append(pause.Frames, frame)
+	}
+
+	dbg.mux.Lock()
+	if nil == dbg.paused {
+		close(dbg.pauseChan)
+	}
+	dbg.paused = pause
+	handlers := dbg.pauseHandlers
+	dbg.mux.Unlock()
+
+	for _, handler := range handlers {
+		handler(pause)
+	}
+}
+
+/*
+OnPause adds a callback that is called whenever the thread pauses. Callbacks
+are called in the order of the pauses.
+*/
+func (dbg *Debugger) OnPause(callback func(pause *Pause)) {
+	dbg.mux.Lock()
+	dbg.pauseHandlers = append(dbg.pauseHandlers, callback)
+	dbg.mux.Unlock()
+}
+
+/*
+resumed clears the current pause. The caller must hold the lock.
+*/
+func (dbg *Debugger) resumed() {
+	if nil != dbg.paused {
+		dbg.paused = nil
+		dbg.pauseChan = make(chan bool)
+	}
+}
+
+/*
+Scripts returns the parsed scripts in the order they were parsed.
+*/
+func (dbg *Debugger) Scripts() []*debugger.ScriptParsedEvent {
+	dbg.mux.Lock()
+	handler := dbg.scriptHandler
+	dbg.mux.Unlock()
+	if nil != handler {
+		handler.Wait()
+	}
+
+	dbg.mux.Lock()
+	defer dbg.mux.Unlock()
+	scripts := make([]*debugger.ScriptParsedEvent, 0, len(dbg.scriptOrder))
+	for _, id := range dbg.scriptOrder {
+		scripts = append(scripts, dbg.scripts[id])
+	}
+	return scripts
+}
+
+/*
+FindScripts returns the parsed scripts matching a file. A file matches the
+script URL if it is the complete URL or a trailing part of the URL path, e.g.
+"app.js" or "js/app.js" for "https://example.com/js/app.js?v=2".
+*/
+func (dbg *Debugger) FindScripts(file string) []*debugger.ScriptParsedEvent {
+	pattern := regexp.MustCompile(fileURLRegex(file))
+	scripts := []*debugger.ScriptParsedEvent{}
+	for _, script := range dbg.Scripts() {
+		if pattern.MatchString(script.URL) {
+			scripts = append(scripts, script)
+		}
+	}
+	return scripts
+}
+
+/*
+fileURLRegex returns the regular expression matching the URLs of a file.
+*/
+func fileURLRegex(file string) string {
+	return `(^|/)` + regexp.QuoteMeta(strings.TrimPrefix(file, "/")) + `([?#].*)?$`
+}
+
+/*
+ScriptSource returns the source of a parsed script.
+*/
+func (dbg *Debugger) ScriptSource(scriptID runtime.ScriptID) (string, error) {
+	result := <-dbg.tab.Debugger().GetScriptSource(&debugger.GetScriptSourceParams{ScriptID: scriptID})
+	if nil != result.Err {
+		return "", wrapError(result.Err, codes.DebuggerFailed, "Debugger.getScriptSource failed")
+	}
+	return result.ScriptSource, nil
+}
+
+/*
+SetBreakpoint sets a breakpoint at a line of a file, see FindScripts() for
+the file matching rules. If the file is already parsed the breakpoint is moved
+to the first possible break location at or after the line, otherwise it is
+set at the start of the line. The breakpoint also applies to scripts parsed
+later, e.g. after a reload.
+
+The breakpoint only pauses if the condition evaluates to true, an empty
+condition always pauses.
+*/
+func (dbg *Debugger) SetBreakpoint(file string, line int, condition string) (*Breakpoint, error) {
+	if line < 1 {
+		return nil, errs.New(codes.BreakpointFailed, fmt.Sprintf("invalid line %d", line))
+	}
+	lineNumber := int64(line - 1)
+	columnNumber := int64(0)
+	for _, script := range dbg.FindScripts(file) {
+		result := <-dbg.tab.Debugger().GetPossibleBreakpoints(&debugger.GetPossibleBreakpointsParams{
+			Start: &debugger.Location{ScriptID: script.ScriptID, LineNumber: lineNumber},
+		})
+		if nil != result.Err {
+			return nil, wrapError(result.Err, codes.BreakpointFailed, "Debugger.getPossibleBreakpoints failed")
+		}
+		if len(result.Locations) > 0 {
+			lineNumber = int64(result.Locations[0].LineNumber)
+			columnNumber = int64(result.Locations[0].ColumnNumber)
+			break
+		}
+	}
+
+	result := <-dbg.tab.Debugger().SetBreakpointByURL(&debugger.SetBreakpointByURLParams{
+		LineNumber:   lineNumber,
+		URLRegex:     fileURLRegex(file),
+		ColumnNumber: columnNumber,
+		Condition:    condition,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.BreakpointFailed, "Debugger.setBreakpointByUrl failed")
+	}
+	breakpoint := &Breakpoint{
+		ID:        result.BreakpointID,
+		File:      file,
+		Line:      int(lineNumber) + 1,
+		Column:    int(columnNumber) + 1,
+		Condition: condition,
+		locations: result.Locations,
+		mux:       &sync.Mutex{},
+	}
+	dbg.mux.Lock()
+	dbg.breakpoints[breakpoint.ID] = breakpoint
+	dbg.mux.Unlock()
+	return breakpoint, nil
+}
+
+/*
+Breakpoints returns the breakpoints of the session.
+*/
+func (dbg *Debugger) Breakpoints() []*Breakpoint {
+	dbg.mux.Lock()
+	defer dbg.mux.Unlock()
+	breakpoints := make([]*Breakpoint, 0, len(dbg.breakpoints))
+	for _, breakpoint := range dbg.breakpoints {
+		breakpoints = append(breakpoints, breakpoint)
+	}
+	return breakpoints
+}
+
+/*
+RemoveBreakpoint removes a breakpoint.
+*/
+func (dbg *Debugger) RemoveBreakpoint(breakpoint *Breakpoint) error {
+	if result := <-dbg.tab.Debugger().RemoveBreakpoint(&debugger.RemoveBreakpointParams{
+		BreakpointID: breakpoint.ID,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.BreakpointFailed, "Debugger.removeBreakpoint failed")
+	}
+	dbg.mux.Lock()
+	delete(dbg.breakpoints, breakpoint.ID)
+	dbg.mux.Unlock()
+	return nil
+}
+
+/*
+Paused returns the current pause, if the thread is paused.
+*/
+func (dbg *Debugger) Paused() (*Pause, bool) {
+	dbg.mux.Lock()
+	defer dbg.mux.Unlock()
+	return dbg.paused, nil != dbg.paused
+}
+
+/*
+WaitForPause blocks until the thread is paused and returns the pause. It
+returns immediately if the thread is already paused.
+*/
+func (dbg *Debugger) WaitForPause(ctx context.Context) (*Pause, error) {
+	dbg.mux.Lock()
+	pauseChan := dbg.pauseChan
+	dbg.mux.Unlock()
+	select {
+	case <-pauseChan:
+		dbg.mux.Lock()
+		defer dbg.mux.Unlock()
+		if nil == dbg.paused {
+			// Resumed by another goroutine in the meantime.
+			return nil, errs.New(codes.DebuggerFailed, "the thread was resumed")
+		}
+		return dbg.paused, nil
+	case <-ctx.Done():
+		return nil, wrapError(ctx.Err(), codes.DebuggerFailed, "the thread did not pause")
+	}
+}
+
+/*
+PauseOnExceptions defines which exceptions pause the thread: "none",
+"uncaught" or "all".
+*/
+func (dbg *Debugger) PauseOnExceptions(state string) error {
+	var stateEnum debugger.StateEnum
+	if err := json.Unmarshal([]byte(`"`+state+`"`), &stateEnum); nil != err {
+		return wrapError(err, codes.DebuggerFailed, "invalid pause on exceptions state")
+	}
+	if result := <-dbg.tab.Debugger().SetPauseOnExceptions(&debugger.SetPauseOnExceptionsParams{
+		State: stateEnum,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.DebuggerFailed, "Debugger.setPauseOnExceptions failed")
+	}
+	return nil
+}
+
+/*
+Pause requests the thread to pause at the next statement.
+*/
+func (dbg *Debugger) Pause() error {
+	if result := <-dbg.tab.Debugger().Pause(); nil != result.Err {
+		return wrapError(result.Err, codes.DebuggerFailed, "Debugger.pause failed")
+	}
+	return nil
+}
+
+/*
+Continue resumes the paused thread.
+*/
+func (dbg *Debugger) Continue() error {
+	return dbg.resume("Debugger.resume", func() error {
+		return (<-dbg.tab.Debugger().Resume()).Err
+	})
+}
+
+/*
+StepOver steps over the current statement. Use WaitForPause() to wait until
+the step completes.
+*/
+func (dbg *Debugger) StepOver() error {
+	return dbg.resume("Debugger.stepOver", func() error {
+		return (<-dbg.tab.Debugger().StepOver()).Err
+	})
+}
+
+/*
+StepInto steps into the function call of the current statement.
+*/
+func (dbg *Debugger) StepInto() error {
+	return dbg.resume("Debugger.stepInto", func() error {
+		return (<-dbg.tab.Debugger().StepInto(&debugger.StepIntoParams{})).Err
+	})
+}
+
+/*
+StepOut steps out of the current function.
+*/
+func (dbg *Debugger) StepOut() error {
+	return dbg.resume("Debugger.stepOut", func() error {
+		return (<-dbg.tab.Debugger().StepOut()).Err
+	})
+}
+
+/*
+resume clears the current pause and sends a command that resumes the thread.
+The pause is restored if the command fails.
+*/
+func (dbg *Debugger) resume(method string, command func() error) error {
+	dbg.mux.Lock()
+	pause := dbg.paused
+	if nil == pause {
+		dbg.mux.Unlock()
+		return errs.New(codes.DebuggerFailed, "the thread is not paused")
+	}
+	dbg.resumed()
+	dbg.mux.Unlock()
+
+	if err := command(); nil != err {
+		dbg.mux.Lock()
+		if nil == dbg.paused {
+			dbg.paused = pause
+			close(dbg.pauseChan)
+		}
+		dbg.mux.Unlock()
+		return wrapError(err, codes.DebuggerFailed, method+" failed")
+	}
+	return nil
+}
+
+/*
+Properties returns the own properties of an object. Primitive values have no
+properties.
+*/
+func (dbg *Debugger) Properties(object *runtime.RemoteObject) ([]*Variable, error) {
+	if nil == object || "" == object.ObjectID {
+		return []*Variable{}, nil
+	}
+	result := <-dbg.tab.Runtime().GetProperties(&runtime.GetPropertiesParams{
+		ObjectID:      object.ObjectID,
+		OwnProperties: true,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.EvaluateFailed, "Runtime.getProperties failed")
+	}
+	if nil != result.ExceptionDetails {
+		return nil, exceptionError(result.ExceptionDetails)
+	}
+	variables := make([]*Variable, 0, len(result.Result))
+	for _, property := range result.Result {
+		if nil != property.Value {
+			variables = append(variables, &Variable{Name: property.Name, Value: property.Value})
+		}
+	}
+	return variables, nil
+}
+
+/*
+Evaluate evaluates an expression in the global scope of the page.
+*/
+func (dbg *Debugger) Evaluate(expression string) (*runtime.RemoteObject, error) {
+	result := <-dbg.tab.Runtime().Evaluate(&runtime.EvaluateParams{
+		Expression: expression,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.EvaluateFailed, "Runtime.evaluate failed")
+	}
+	if nil != result.ExceptionDetails {
+		return nil, exceptionError(result.ExceptionDetails)
+	}
+	return result.Result, nil
+}
+
+/*
+exceptionError returns the error for an exception thrown by an evaluation.
+*/
+func exceptionError(details *runtime.ExceptionDetails) error {
+	message := details.Text
+	if nil != details.Exception && "" != details.Exception.Description {
+		message = details.Exception.Description
+	}
+	return errs.New(codes.EvaluateFailed, message)
+}
+
+/*
+Variables returns the variables of the scope.
+*/
+func (scope *Scope) Variables() ([]*Variable, error) {
+	return scope.debugger.Properties(scope.Object)
+}
+
+/*
+Variables returns the variables visible in the frame, excluding the
+properties of the global object. Variables of inner scopes shadow variables
+of outer scopes.
+*/
+func (frame *Frame) Variables() ([]*Variable, error) {
+	seen := map[string]bool{}
+	variables := []*Variable{}
+	for _, scope := range frame.Scopes {
+		if debugger.ScopeType.Global.String() == scope.Type {
+			continue
+		}
+		scopeVariables, err := scope.Variables()
+		if nil != err {
+			return nil, err
+		}
+		for _, variable := range scopeVariables {
+			if !seen[variable.Name] {
+				seen[variable.Name] = true
+				variables = append(variables, variable)
+			}
+		}
+	}
+	return variables, nil
+}
+
+/*
+Variable returns the value of a variable visible in the frame.
+*/
+func (frame *Frame) Variable(name string) (*runtime.RemoteObject, error) {
+	variables, err := frame.Variables()
+	if nil != err {
+		return nil, err
+	}
+	for _, variable := range variables {
+		if name == variable.Name {
+			return variable.Value, nil
+		}
+	}
+	return nil, errs.New(codes.EvaluateFailed, fmt.Sprintf("%s is not defined", name))
+}
+
+/*
+Evaluate evaluates an expression in the context of the frame. An exception
+thrown by the expression is returned as an error.
+*/
+func (frame *Frame) Evaluate(expression string) (*runtime.RemoteObject, error) {
+	return frame.evaluate(expression, false)
+}
+
+/*
+Value evaluates an expression in the context of the frame and returns the
+JSON value of the result, e.g. float64 for numbers and map[string]interface{}
+for objects. Undefined is returned as nil.
+*/
+func (frame *Frame) Value(expression string) (interface{}, error) {
+	result, err := frame.evaluate(expression, true)
+	if nil != err {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+func (frame *Frame) evaluate(expression string, byValue bool) (*runtime.RemoteObject, error) {
+	result := <-frame.debugger.tab.Debugger().EvaluateOnCallFrame(&debugger.EvaluateOnCallFrameParams{
+		CallFrameID:   frame.ID,
+		Expression:    expression,
+		ReturnByValue: byValue,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.EvaluateFailed, "Debugger.evaluateOnCallFrame failed")
+	}
+	if nil != result.ExceptionDetails {
+		return nil, exceptionError(result.ExceptionDetails)
+	}
+	return result.Result, nil
+}
@@ -0,0 +1,234 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func testPausedEvent(line int64) *debugger.PausedEvent {
+	return &debugger.PausedEvent{
+		Reason:         "other",
+		HitBreakpoints: []string{"bp-1"},
+		CallFrames: []*debugger.CallFrame{{
+			CallFrameID:  "frame-1",
+			FunctionName: "add",
+			URL:          "https://example.com/js/app.js",
+			Location:     &debugger.Location{ScriptID: "7", LineNumber: line, ColumnNumber: 2},
+			ScopeChain: []*debugger.Scope{
+				{Type: debugger.ScopeType.Local, Object: &runtime.RemoteObject{Type: runtime.ObjectType.Object, ObjectID: "local-1"}},
+				{Type: debugger.ScopeType.Closure, Object: &runtime.RemoteObject{Type: runtime.ObjectType.Object, ObjectID: "closure-1"}},
+				{Type: debugger.ScopeType.Global, Object: &runtime.RemoteObject{Type: runtime.ObjectType.Object, ObjectID: "global-1"}},
+			},
+			This: &runtime.RemoteObject{Type: runtime.ObjectType.Undefined},
+		}},
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestDebugger")
+	mockSocket.Respond("Debugger.enable", func(params json.RawMessage) (interface{}, error) {
+		mockSocket.Fire("Debugger.scriptParsed", &debugger.ScriptParsedEvent{ScriptID: "6", URL: "https://example.com/vendor.js"})
+		mockSocket.Fire("Debugger.scriptParsed", &debugger.ScriptParsedEvent{ScriptID: "7", URL: "https://example.com/js/app.js?v=2"})
+		return &debugger.EnableResult{}, nil
+	})
+	mockSocket.Respond("Debugger.getPossibleBreakpoints", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.GetPossibleBreakpointsParams{}
+		json.Unmarshal(params, request)
+		if "7" != request.Start.ScriptID || 9 != request.Start.LineNumber {
+			t.Errorf("Unexpected start location %v", request.Start)
+		}
+		// Line 10 is blank, the next statement is on line 11.
+		return &debugger.GetPossibleBreakpointsResult{Locations: []*debugger.BreakLocation{
+			{ScriptID: "7", LineNumber: 10, ColumnNumber: 2},
+			{ScriptID: "7", LineNumber: 11, ColumnNumber: 2},
+		}}, nil
+	})
+	mockSocket.Respond("Debugger.setBreakpointByUrl", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.SetBreakpointByURLParams{}
+		json.Unmarshal(params, request)
+		if 10 != request.LineNumber || 2 != request.ColumnNumber || "a > 1" != request.Condition {
+			t.Errorf("Unexpected breakpoint %v", request)
+		}
+		return &debugger.SetBreakpointByURLResult{
+			BreakpointID: "bp-1",
+			Locations:    []*debugger.Location{{ScriptID: "7", LineNumber: 10, ColumnNumber: 2}},
+		}, nil
+	})
+	mockSocket.Respond("Debugger.stepOver", func(params json.RawMessage) (interface{}, error) {
+		go mockSocket.Fire("Debugger.paused", testPausedEvent(11))
+		return &debugger.StepOverResult{}, nil
+	})
+	mockSocket.Respond("Debugger.resume", func(params json.RawMessage) (interface{}, error) {
+		return nil, fmt.Errorf("resume failed")
+	})
+	mockSocket.Respond("Runtime.getProperties", func(params json.RawMessage) (interface{}, error) {
+		request := &runtime.GetPropertiesParams{}
+		json.Unmarshal(params, request)
+		properties := map[runtime.RemoteObjectID][]*runtime.PropertyDescriptor{
+			"local-1": {
+				{Name: "a", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 2}},
+				{Name: "b", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 3}},
+			},
+			"closure-1": {
+				{Name: "a", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 1}},
+				{Name: "total", Value: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 10}},
+			},
+		}
+		if "global-1" == request.ObjectID {
+			t.Errorf("Expected the global scope to be skipped")
+		}
+		return &runtime.GetPropertiesResult{Result: properties[request.ObjectID]}, nil
+	})
+	mockSocket.Respond("Debugger.evaluateOnCallFrame", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.EvaluateOnCallFrameParams{}
+		json.Unmarshal(params, request)
+		if "frame-1" != request.CallFrameID {
+			t.Errorf("Unexpected call frame %s", request.CallFrameID)
+		}
+		if "missing" == request.Expression {
+			return &debugger.EvaluateOnCallFrameResult{
+				Result: &runtime.RemoteObject{Type: runtime.ObjectType.Object},
+				ExceptionDetails: &runtime.ExceptionDetails{
+					Text:      "Uncaught",
+					Exception: &runtime.RemoteObject{Type: runtime.ObjectType.Object, Description: "ReferenceError: missing is not defined"},
+				},
+			}, nil
+		}
+		return &debugger.EvaluateOnCallFrameResult{
+			Result: &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 5},
+		}, nil
+	})
+
+	dbg := NewDebugger(tab)
+	if err := dbg.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+	if scripts := dbg.FindScripts("app.js"); 1 != len(scripts) || "7" != scripts[0].ScriptID {
+		t.Errorf("Expected app.js to be found, received %v", scripts)
+	}
+	if scripts := dbg.FindScripts("pp.js"); 0 != len(scripts) {
+		t.Errorf("Expected a partial file name not to match, received %v", scripts)
+	}
+
+	breakpoint, err := dbg.SetBreakpoint("js/app.js", 10, "a > 1")
+	if nil != err {
+		t.Fatalf("SetBreakpoint failed: %s", err)
+	}
+	if 11 != breakpoint.Line || 3 != breakpoint.Column || 1 != len(breakpoint.Locations()) {
+		t.Errorf("Unexpected breakpoint %v", breakpoint)
+	}
+	mockSocket.Fire("Debugger.breakpointResolved", &debugger.BreakpointResolvedEvent{
+		BreakpointID: "bp-1",
+		Location:     &debugger.Location{ScriptID: "8", LineNumber: 10},
+	})
+	if 2 != len(breakpoint.Locations()) {
+		t.Errorf("Expected the resolved location to be added, received %v", breakpoint.Locations())
+	}
+
+	if err := dbg.Continue(); nil == err {
+		t.Errorf("Expected an error when not paused")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	if _, err := dbg.WaitForPause(ctx); nil == err {
+		t.Errorf("Expected WaitForPause to time out")
+	}
+	cancel()
+
+	mockSocket.Fire("Debugger.paused", testPausedEvent(10))
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pause, err := dbg.WaitForPause(ctx)
+	if nil != err {
+		t.Fatalf("WaitForPause failed: %s", err)
+	}
+	frame := pause.Top()
+	if "add" != frame.Function || 11 != frame.Line || 3 != frame.Column || "bp-1" != pause.HitBreakpoints[0] {
+		t.Errorf("Unexpected pause %v %v", pause, frame)
+	}
+
+	variables, err := frame.Variables()
+	if nil != err {
+		t.Fatalf("Variables failed: %s", err)
+	}
+	if 3 != len(variables) || "a" != variables[0].Name || 2.0 != variables[0].Value.Value || "total" != variables[2].Name {
+		t.Errorf("Expected the local a to shadow the closure a, received %v", variables)
+	}
+	if total, err := frame.Variable("total"); nil != err || 10.0 != total.Value {
+		t.Errorf("Unexpected total %v: %v", total, err)
+	}
+	if _, err := frame.Variable("missing"); nil == err {
+		t.Errorf("Expected an error for an undefined variable")
+	}
+	if value, err := frame.Value("a + b"); nil != err || 5.0 != value {
+		t.Errorf("Unexpected value %v: %v", value, err)
+	}
+	if _, err := frame.Evaluate("missing"); nil == err || "ReferenceError: missing is not defined" != err.Error() {
+		t.Errorf("Expected a ReferenceError, received %v", err)
+	}
+
+	if err := dbg.Continue(); nil == err {
+		t.Errorf("Expected the resume error to be returned")
+	}
+	if _, ok := dbg.Paused(); !ok {
+		t.Errorf("Expected the pause to be restored after a failed resume")
+	}
+
+	if err := dbg.StepOver(); nil != err {
+		t.Fatalf("StepOver failed: %s", err)
+	}
+	pause, err = dbg.WaitForPause(ctx)
+	if nil != err {
+		t.Fatalf("WaitForPause failed: %s", err)
+	}
+	if 12 != pause.Top().Line {
+		t.Errorf("Expected to pause on the next line, received %d", pause.Top().Line)
+	}
+}
+
+func TestDebuggerPausedUnknownScope(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestDebuggerPausedUnknownScope")
+	mockSocket.Respond("Debugger.enable", func(params json.RawMessage) (interface{}, error) {
+		return &debugger.EnableResult{}, nil
+	})
+	dbg := NewDebugger(tab)
+	if err := dbg.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+
+	mockSocket.Fire("Debugger.paused", map[string]interface{}{
+		"reason": "exception",
+		"data":   map[string]interface{}{"type": "object", "subtype": "error", "description": "Error: boom"},
+		"callFrames": []map[string]interface{}{{
+			"callFrameId":  "frame-1",
+			"functionName": "run",
+			"url":          "https://example.com/app.wasm",
+			"location":     map[string]interface{}{"scriptId": "9", "lineNumber": 0, "columnNumber": 4},
+			"scopeChain": []map[string]interface{}{
+				{"type": "wasm-expression-stack", "object": map[string]interface{}{"type": "object", "objectId": "stack-1"}},
+				{"type": "local", "object": map[string]interface{}{"type": "object", "objectId": "local-1"}},
+			},
+		}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pause, err := dbg.WaitForPause(ctx)
+	if nil != err {
+		t.Fatalf("WaitForPause failed: %s", err)
+	}
+	frame := pause.Top()
+	if nil == frame || "run" != frame.Function || 1 != frame.Line || 5 != frame.Column {
+		t.Fatalf("Unexpected frame %v", frame)
+	}
+	if 2 != len(frame.Scopes) || "wasm-expression-stack" != frame.Scopes[0].Type || "local" != frame.Scopes[1].Type {
+		t.Errorf("Unexpected scopes %v", frame.Scopes)
+	}
+	if nil == pause.Exception || "Error: boom" != pause.Exception.Description {
+		t.Errorf("Unexpected exception %v", pause.Exception)
+	}
+}
@@ -0,0 +1,74 @@
+package chrome
+
+import (
+	"encoding/json"
+
+	"github.com/mkenney/go-chrome/tot/dom"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+domNode is a DOM node as returned by DOM.getDocument. It is decoded into a
+local type, unknown pseudo element and shadow root types of newer Chrome
+versions would fail the enum types of the dom package.
+*/
+type domNode struct {
+	NodeID          dom.NodeID        `json:"nodeId"`
+	BackendNodeID   dom.BackendNodeID `json:"backendNodeId"`
+	NodeType        int               `json:"nodeType"`
+	NodeName        string            `json:"nodeName"`
+	LocalName       string            `json:"localName"`
+	NodeValue       string            `json:"nodeValue"`
+	Children        []*domNode        `json:"children"`
+	Attributes      []string          `json:"attributes"`
+	DocumentURL     string            `json:"documentURL"`
+	BaseURL         string            `json:"baseURL"`
+	PublicID        string            `json:"publicId"`
+	SystemID        string            `json:"systemId"`
+	ShadowRootType  string            `json:"shadowRootType"`
+	FrameID         string            `json:"frameId"`
+	ContentDocument *domNode          `json:"contentDocument"`
+	ShadowRoots     []*domNode        `json:"shadowRoots"`
+	TemplateContent *domNode          `json:"templateContent"`
+}
+
+/*
+Attribute returns the value of an attribute of an element node.
+*/
+func (node *domNode) Attribute(name string) (string, bool) {
+	for a := 0; a+1 < len(node.Attributes); a += 2 {
+		if name == node.Attributes[a] {
+			return node.Attributes[a+1], true
+		}
+	}
+	return "", false
+}
+
+/*
+domDocument returns the complete DOM tree of the page, including the
+documents of same-process frames and shadow roots.
+*/
+func (tab *Tab) domDocument() (*domNode, error) {
+	result := &struct {
+		Root *domNode `json:"root"`
+	}{}
+	if err := tab.sendCommand("DOM.getDocument", &dom.GetDocumentParams{Depth: -1, Pierce: true}, result); nil != err {
+		return nil, err
+	}
+	if nil == result.Root {
+		return &domNode{NodeType: 9}, nil
+	}
+	return result.Root, nil
+}
+
+/*
+sendCommand sends a command and decodes the result into a local type, for
+results the protocol types can not decode.
+*/
+func (tab *Tab) sendCommand(method string, params interface{}, result interface{}) error {
+	response := <-tab.SendCommand(socket.NewCommand(tab.Socket(), method, params))
+	if nil != response.Error && 0 != response.Error.Code {
+		return response.Error
+	}
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,475 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+Download states.
+*/
+const (
+	// DownloadInProgress is the state of a running download.
+	DownloadInProgress = "inProgress"
+
+	// DownloadCompleted is the state of a finished download.
+	DownloadCompleted = "completed"
+
+	// DownloadCanceled is the state of a canceled or denied download.
+	DownloadCanceled = "canceled"
+)
+
+/*
+DefaultDownloadTimeout is the default time Download.Wait() waits for a
+download to complete.
+*/
+var DefaultDownloadTimeout = 60 * time.Second
+
+/*
+NewDownloads returns the download manager of the tab. Downloads are saved in
+dir, a temporary directory is created if dir is empty. Downloads are not
+handled until the manager is enabled.
+*/
+func NewDownloads(tab *Tab, dir string) *Downloads {
+	return &Downloads{
+		Timeout:   DefaultDownloadTimeout,
+		changed:   make(chan struct{}),
+		dir:       dir,
+		downloads: map[string]*Download{},
+		mux:       &sync.Mutex{},
+		tab:       tab,
+	}
+}
+
+/*
+Downloads saves the downloads of a tab, each in its own folder named after
+the download GUID, and reports their start and completion, so that tests do
+not need to poll the file system:
+
+	downloads := chrome.NewDownloads(tab, "")
+	downloads.Enable()
+	download, err := downloads.WaitForDownload(ctx, func() error {
+		return exportButton.Click(ctx)
+	})
+	report, _ := ioutil.ReadFile(download.Path)
+*/
+type Downloads struct {
+	// Allow is called when a download begins, the download is denied if it
+	// returns false. All downloads are allowed if Allow is nil.
+	Allow func(url, filename string) bool
+
+	// Timeout is the time Download.Wait() waits for a download to complete,
+	// DefaultDownloadTimeout by default.
+	Timeout time.Duration
+
+	changed   chan struct{}
+	dir       string
+	downloads map[string]*Download
+	handlers  []*socket.OrderedHandler
+	list      []*Download
+	mux       *sync.Mutex
+	tab       *Tab
+}
+
+/*
+Download is a download of the tab. Path, Size and MimeType are set once the
+download completed.
+*/
+type Download struct {
+	// GUID identifies the download.
+	GUID string
+
+	// URL is the URL of the download.
+	URL string
+
+	// SuggestedFilename is the file name suggested by the page or the
+	// server.
+	SuggestedFilename string
+
+	// Path is the path of the downloaded file.
+	Path string
+
+	// Size is the size of the downloaded file in bytes.
+	Size int64
+
+	// MimeType is the MIME type of the file, guessed from the file name
+	// extension or from the content.
+	MimeType string
+
+	begun     bool
+	denied    bool
+	done      chan struct{}
+	downloads *Downloads
+	err       error
+	finished  bool
+	mux       sync.Mutex
+	received  int64
+	state     string
+	total     int64
+}
+
+/*
+Enable makes Chrome save downloads in the download directory and starts
+tracking them.
+*/
+func (downloads *Downloads) Enable() error {
+	downloads.mux.Lock()
+	if nil != downloads.handlers {
+		downloads.mux.Unlock()
+		return nil
+	}
+	if "" == downloads.dir {
+		dir, err := ioutil.TempDir("", "go-chrome-downloads")
+		if nil != err {
+			downloads.mux.Unlock()
+			return wrapError(err, codes.DownloadFailed, "could not create the download directory")
+		}
+		downloads.dir = dir
+	}
+	dir, err := filepath.Abs(downloads.dir)
+	if nil != err {
+		downloads.mux.Unlock()
+		return wrapError(err, codes.DownloadFailed, fmt.Sprintf("invalid download directory '%s'", downloads.dir))
+	}
+	downloads.dir = dir
+	// The events share one queue, so that a download begins before its
+	// progress is handled.
+	downloads.handlers = socket.NewOrderedEventHandlers(
+		downloads.onEvent,
+		"Browser.downloadWillBegin",
+		"Browser.downloadProgress",
+	)
+	handlers := downloads.handlers
+	downloads.mux.Unlock()
+	for _, handler := range handlers {
+		downloads.tab.AddEventHandler(handler)
+	}
+
+	if err := os.MkdirAll(dir, 0700); nil != err {
+		return wrapError(err, codes.DownloadFailed, "could not create the download directory")
+	}
+	// Files are saved with the GUID as name and moved to their folder when
+	// they complete.
+	if err := downloads.tab.sendCommand("Browser.setDownloadBehavior", map[string]interface{}{
+		"behavior":      "allowAndName",
+		"downloadPath":  dir,
+		"eventsEnabled": true,
+	}, &struct{}{}); nil != err {
+		return wrapError(err, codes.DownloadFailed, "Browser.setDownloadBehavior failed")
+	}
+	return nil
+}
+
+/*
+Disable restores the default download behavior of Chrome and stops tracking
+downloads. The downloaded files are kept.
+*/
+func (downloads *Downloads) Disable() error {
+	downloads.mux.Lock()
+	handlers := downloads.handlers
+	downloads.handlers = nil
+	downloads.mux.Unlock()
+
+	for _, handler := range handlers {
+		downloads.tab.RemoveEventHandler(handler)
+	}
+	if err := downloads.tab.sendCommand("Browser.setDownloadBehavior", map[string]interface{}{
+		"behavior": "default",
+	}, &struct{}{}); nil != err {
+		return wrapError(err, codes.DownloadFailed, "Browser.setDownloadBehavior failed")
+	}
+	return nil
+}
+
+/*
+Dir returns the download directory.
+*/
+func (downloads *Downloads) Dir() string {
+	downloads.mux.Lock()
+	defer downloads.mux.Unlock()
+	return downloads.dir
+}
+
+/*
+List returns the downloads in the order they began.
+*/
+func (downloads *Downloads) List() []*Download {
+	downloads.mux.Lock()
+	defer downloads.mux.Unlock()
+	return append([]*Download{}, downloads.list...)
+}
+
+/*
+WaitForDownload calls trigger, e.g. a click on a download link, and waits for
+the next download to begin and to complete, see Download.Wait(). The
+download is returned with the error of a failed download.
+*/
+func (downloads *Downloads) WaitForDownload(ctx context.Context, trigger func() error) (*Download, error) {
+	downloads.mux.Lock()
+	begun := len(downloads.list)
+	downloads.mux.Unlock()
+
+	if nil != trigger {
+		if err := trigger(); nil != err {
+			return nil, wrapError(err, codes.DownloadFailed, "the download trigger failed")
+		}
+	}
+	for {
+		downloads.mux.Lock()
+		var download *Download
+		if len(downloads.list) > begun {
+			download = downloads.list[begun]
+		}
+		changed := downloads.changed
+		downloads.mux.Unlock()
+		if nil != download {
+			return download, download.Wait(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.DownloadTimeout, "waiting for a download to begin")
+		case <-changed:
+		}
+	}
+}
+
+/*
+Wait waits until the download completed, was canceled or denied, or until
+the Timeout of the manager. A download that times out is canceled.
+*/
+func (download *Download) Wait(ctx context.Context) error {
+	timeout := download.downloads.Timeout
+	if 0 == timeout {
+		timeout = DefaultDownloadTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	select {
+	case <-download.done:
+		return download.err
+	case <-ctx.Done():
+		return wrapError(ctx.Err(), codes.DownloadTimeout, fmt.Sprintf("waiting for download '%s'", download.SuggestedFilename))
+	case <-deadline.C:
+		download.downloads.cancel(download.GUID)
+		return errs.New(codes.DownloadTimeout, fmt.Sprintf("download '%s' did not complete within %s", download.SuggestedFilename, timeout))
+	}
+}
+
+/*
+State returns the state of the download: DownloadInProgress,
+DownloadCompleted or DownloadCanceled.
+*/
+func (download *Download) State() string {
+	download.mux.Lock()
+	defer download.mux.Unlock()
+	return download.state
+}
+
+/*
+Progress returns the received and the total bytes of the download. The total
+is 0 if the size is unknown.
+*/
+func (download *Download) Progress() (received, total int64) {
+	download.mux.Lock()
+	defer download.mux.Unlock()
+	return download.received, download.total
+}
+
+/*
+download returns the download with a GUID.
+*/
+func (downloads *Downloads) download(guid string) *Download {
+	downloads.mux.Lock()
+	defer downloads.mux.Unlock()
+	download, ok := downloads.downloads[guid]
+	if !ok {
+		download = &Download{
+			GUID:      guid,
+			done:      make(chan struct{}),
+			downloads: downloads,
+			state:     DownloadInProgress,
+		}
+		downloads.downloads[guid] = download
+	}
+	return download
+}
+
+/*
+onEvent handles the download events.
+*/
+func (downloads *Downloads) onEvent(response *socket.Response) {
+	if "Browser.downloadWillBegin" == response.Method {
+		downloads.onWillBegin(response)
+	} else {
+		downloads.onProgress(response)
+	}
+}
+
+func (downloads *Downloads) onWillBegin(response *socket.Response) {
+	event := &struct {
+		GUID              string `json:"guid"`
+		URL               string `json:"url"`
+		SuggestedFilename string `json:"suggestedFilename"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	download := downloads.download(event.GUID)
+	download.mux.Lock()
+	download.URL = event.URL
+	download.SuggestedFilename = event.SuggestedFilename
+	download.begun = true
+	download.mux.Unlock()
+
+	downloads.mux.Lock()
+	allow := downloads.Allow
+	downloads.list = append(downloads.list, download)
+	downloads.notify()
+	downloads.mux.Unlock()
+
+	if nil != allow && !allow(event.URL, event.SuggestedFilename) {
+		download.mux.Lock()
+		download.denied = true
+		download.mux.Unlock()
+		go downloads.cancel(event.GUID)
+	}
+	downloads.finish(download)
+}
+
+func (downloads *Downloads) onProgress(response *socket.Response) {
+	// The byte counts are missing from some events, e.g. of a canceled
+	// download, the previous counts are kept.
+	event := &struct {
+		GUID          string   `json:"guid"`
+		TotalBytes    *float64 `json:"totalBytes"`
+		ReceivedBytes *float64 `json:"receivedBytes"`
+		State         string   `json:"state"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	download := downloads.download(event.GUID)
+	download.mux.Lock()
+	if nil != event.ReceivedBytes {
+		download.received = int64(*event.ReceivedBytes)
+	}
+	if nil != event.TotalBytes {
+		download.total = int64(*event.TotalBytes)
+	}
+	if DownloadInProgress == download.state {
+		download.state = event.State
+	}
+	download.mux.Unlock()
+	downloads.finish(download)
+}
+
+/*
+finish completes a download that began and ended: a completed file is moved
+to the folder of the download, the partial file of a denied download is
+removed.
+*/
+func (downloads *Downloads) finish(download *Download) {
+	dir := downloads.Dir()
+	download.mux.Lock()
+	defer download.mux.Unlock()
+	if !download.begun || download.finished || DownloadInProgress == download.state {
+		return
+	}
+	download.finished = true
+	defer close(download.done)
+
+	switch {
+	case DownloadCanceled == download.state && download.denied:
+		download.err = errs.New(codes.DownloadDenied, fmt.Sprintf("download '%s' from %s was denied", download.SuggestedFilename, download.URL))
+		if err := os.RemoveAll(filepath.Join(dir, download.GUID)); nil != err {
+			log.WithFields(log.Fields{"error": err, "guid": download.GUID}).
+				Warn("could not remove the denied download")
+		}
+	case DownloadCompleted != download.state:
+		download.err = errs.New(codes.DownloadFailed, fmt.Sprintf("download '%s' was %s", download.SuggestedFilename, download.state))
+	default:
+		download.err = download.move(dir)
+	}
+}
+
+/*
+move moves the downloaded file, which is named after the GUID, to the folder
+of the download and sets its path, size and MIME type.
+*/
+func (download *Download) move(dir string) error {
+	name := filepath.Base(download.SuggestedFilename)
+	if "." == name || string(filepath.Separator) == name {
+		name = "download"
+	}
+	saved := filepath.Join(dir, download.GUID)
+	partial := saved + ".download"
+	path := filepath.Join(saved, name)
+	if err := os.Rename(saved, partial); nil != err {
+		return wrapError(err, codes.DownloadFailed, fmt.Sprintf("download '%s' not found", download.SuggestedFilename))
+	}
+	if err := os.MkdirAll(saved, 0700); nil != err {
+		return wrapError(err, codes.DownloadFailed, "could not create the download folder")
+	}
+	if err := os.Rename(partial, path); nil != err {
+		return wrapError(err, codes.DownloadFailed, fmt.Sprintf("could not move download '%s'", download.SuggestedFilename))
+	}
+	info, err := os.Stat(path)
+	if nil != err {
+		return wrapError(err, codes.DownloadFailed, fmt.Sprintf("download '%s' not found", download.SuggestedFilename))
+	}
+	download.Path = path
+	download.Size = info.Size()
+	download.MimeType = mime.TypeByExtension(filepath.Ext(name))
+	if "" == download.MimeType {
+		download.MimeType = sniffMimeType(path)
+	}
+	return nil
+}
+
+/*
+cancel cancels a download.
+*/
+func (downloads *Downloads) cancel(guid string) {
+	if err := downloads.tab.sendCommand("Browser.cancelDownload", map[string]string{
+		"guid": guid,
+	}, &struct{}{}); nil != err {
+		log.WithFields(log.Fields{"error": err, "guid": guid}).
+			Warn("could not cancel the download")
+	}
+}
+
+/*
+notify wakes up the waits for downloads.
+*/
+func (downloads *Downloads) notify() {
+	close(downloads.changed)
+	downloads.changed = make(chan struct{})
+}
+
+/*
+sniffMimeType returns the MIME type of the content of a file.
+*/
+func sniffMimeType(path string) string {
+	file, err := os.Open(path)
+	if nil != err {
+		return "application/octet-stream"
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, _ := file.Read(head)
+	return http.DetectContentType(head[:n])
+}
@@ -0,0 +1,119 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+)
+
+func TestDownloads(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestDownloads")
+	dir, err := ioutil.TempDir("", "TestDownloads")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mux sync.Mutex
+	commands := []string{}
+	for _, method := range []string{"Browser.setDownloadBehavior", "Browser.cancelDownload"} {
+		method := method
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			mux.Lock()
+			defer mux.Unlock()
+			commands = append(commands, method+" "+string(params))
+			if "Browser.cancelDownload" == method {
+				request := struct{ GUID string }{}
+				json.Unmarshal(params, &request)
+				go mockSocket.Fire("Browser.downloadProgress", map[string]interface{}{"guid": request.GUID, "state": "canceled"})
+			}
+			return map[string]interface{}{}, nil
+		})
+	}
+
+	downloads := NewDownloads(tab, dir)
+	downloads.Allow = func(url, filename string) bool {
+		return !strings.Contains(url, "blocked")
+	}
+	if err := downloads.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	download, err := downloads.WaitForDownload(ctx, func() error {
+		ioutil.WriteFile(filepath.Join(dir, "G1"), []byte(`{"total": 3}`), 0600)
+		mockSocket.Fire("Browser.downloadWillBegin", map[string]string{"frameId": "M", "guid": "G1", "url": "https://example.com/export", "suggestedFilename": "report.json"})
+		mockSocket.Fire("Browser.downloadProgress", map[string]interface{}{"guid": "G1", "totalBytes": 12, "receivedBytes": 12, "state": "completed"})
+		return nil
+	})
+	expected := filepath.Join(dir, "G1", "report.json")
+	if nil != err || expected != download.Path || 12 != download.Size || "application/json" != download.MimeType || DownloadCompleted != download.State() {
+		t.Fatalf("Unexpected download %+v (%v)", download, err)
+	}
+	if data, err := ioutil.ReadFile(download.Path); nil != err || `{"total": 3}` != string(data) {
+		t.Errorf("Unexpected download content %q (%v)", data, err)
+	}
+
+	// Denied downloads are canceled and their partial file is removed.
+	download, err = downloads.WaitForDownload(ctx, func() error {
+		ioutil.WriteFile(filepath.Join(dir, "G2"), []byte("MZ"), 0600)
+		mockSocket.Fire("Browser.downloadWillBegin", map[string]string{"guid": "G2", "url": "https://example.com/blocked.exe", "suggestedFilename": "blocked.exe"})
+		return nil
+	})
+	if nil == err || codes.DownloadDenied != err.(errs.Err).Code() || DownloadCanceled != download.State() {
+		t.Errorf("Expected DownloadDenied, received %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "G2")); !os.IsNotExist(err) {
+		t.Errorf("The denied download was not removed: %v", err)
+	}
+
+	// Downloads that do not complete in time are canceled.
+	downloads.Timeout = 20 * time.Millisecond
+	download, err = downloads.WaitForDownload(ctx, func() error {
+		mockSocket.Fire("Browser.downloadWillBegin", map[string]string{"guid": "G3", "url": "https://example.com/slow", "suggestedFilename": "slow.bin"})
+		mockSocket.Fire("Browser.downloadProgress", map[string]interface{}{"guid": "G3", "totalBytes": 100, "receivedBytes": 10, "state": "inProgress"})
+		return nil
+	})
+	if nil == err || codes.DownloadTimeout != err.(errs.Err).Code() {
+		t.Errorf("Expected DownloadTimeout, received %v", err)
+	}
+	// The canceled event has no byte counts, the progress is kept.
+	for deadline := time.Now().Add(time.Second); DownloadCanceled != download.State(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the download to be canceled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if received, total := download.Progress(); 10 != received || 100 != total {
+		t.Errorf("Unexpected progress %d/%d", received, total)
+	}
+	if 3 != len(downloads.List()) {
+		t.Errorf("Unexpected downloads %v", downloads.List())
+	}
+
+	if err := downloads.Disable(); nil != err {
+		t.Errorf("Disable failed: %s", err)
+	}
+	mux.Lock()
+	expectedCommands := []string{
+		fmt.Sprintf(`Browser.setDownloadBehavior {"behavior":"allowAndName","downloadPath":%q,"eventsEnabled":true}`, dir),
+		`Browser.cancelDownload {"guid":"G2"}`,
+		`Browser.cancelDownload {"guid":"G3"}`,
+		`Browser.setDownloadBehavior {"behavior":"default"}`,
+	}
+	if fmt.Sprint(expectedCommands) != fmt.Sprint(commands) {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+	mux.Unlock()
+}
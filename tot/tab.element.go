@@ -0,0 +1,105 @@
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+/*
+DefaultActionTimeout is the default time actions wait for an element to
+become actionable.
+*/
+var DefaultActionTimeout = 30 * time.Second
+
+/*
+Element is a handle of a DOM element found by a selector. The backend node ID
+stays valid while the element is in the document, unlike DOM node IDs.
+*/
+type Element struct {
+	// BackendNodeID identifies the element in the DOM domain.
+	BackendNodeID dom.BackendNodeID
+
+	// Selector is the selector the element was found with.
+	Selector string
+
+	// Timeout is the time actions wait for the element to become
+	// actionable, DefaultActionTimeout by default.
+	Timeout time.Duration
+
+	tab *Tab
+}
+
+/*
+newElement returns the handle of an element.
+*/
+func (tab *Tab) newElement(backendNodeID dom.BackendNodeID, selector string) *Element {
+	return &Element{
+		BackendNodeID: backendNodeID,
+		Selector:      selector,
+		Timeout:       DefaultActionTimeout,
+		tab:           tab,
+	}
+}
+
+/*
+callFunction calls a JavaScript function with the element as this and
+decodes the returned value into result. Promises are awaited.
+*/
+func (element *Element) callFunction(declaration string, result interface{}, args ...interface{}) error {
+	resolved := &struct {
+		Object struct {
+			ObjectID string `json:"objectId"`
+		} `json:"object"`
+	}{}
+	if err := element.tab.sendCommand("DOM.resolveNode", &dom.ResolveNodeParams{BackendNodeID: element.BackendNodeID}, resolved); nil != err {
+		return errElementDetached{err}
+	}
+	defer element.tab.sendCommand("Runtime.releaseObject", map[string]string{"objectId": resolved.Object.ObjectID}, &struct{}{})
+
+	arguments := []map[string]interface{}{}
+	for _, arg := range args {
+		arguments = append(arguments, map[string]interface{}{"value": arg})
+	}
+	called := &struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception *struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}{}
+	err := element.tab.sendCommand("Runtime.callFunctionOn", map[string]interface{}{
+		"functionDeclaration": declaration,
+		"objectId":            resolved.Object.ObjectID,
+		"arguments":           arguments,
+		"returnByValue":       true,
+		"awaitPromise":        true,
+	}, called)
+	if nil != err {
+		return err
+	}
+	if nil != called.ExceptionDetails {
+		if nil != called.ExceptionDetails.Exception && "" != called.ExceptionDetails.Exception.Description {
+			return errors.New(called.ExceptionDetails.Exception.Description)
+		}
+		return errors.New(called.ExceptionDetails.Text)
+	}
+	if nil == result || 0 == len(called.Result.Value) {
+		return nil
+	}
+	return json.Unmarshal(called.Result.Value, result)
+}
+
+/*
+errElementDetached is the error of calls on elements that were removed from
+the document.
+*/
+type errElementDetached struct {
+	error
+}
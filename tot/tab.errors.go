@@ -0,0 +1,29 @@
+package chrome
+
+import (
+	"fmt"
+	"strings"
+
+	errs "github.com/bdlm/errors"
+	std "github.com/bdlm/std/error"
+)
+
+/*
+wrapError wraps err with an error code and a message. Err.Error() returns the
+last message of the chain only, so the message of err is appended once, here,
+e.g. the exception of a script or the context deadline.
+*/
+func wrapError(err error, code std.Code, message string) errs.Err {
+	if nil == err {
+		return errs.New(code, escapeVerbs(message))
+	}
+	return errs.Wrap(err, code, escapeVerbs(fmt.Sprintf("%s: %s", message, err)))
+}
+
+/*
+escapeVerbs escapes the formatting verbs in a message, errs.New() and
+errs.Wrap() format their message.
+*/
+func escapeVerbs(message string) string {
+	return strings.Replace(message, "%", "%%", -1)
+}
@@ -0,0 +1,27 @@
+package chrome
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkenney/go-chrome/codes"
+)
+
+func TestWrapError(t *testing.T) {
+	err := wrapError(context.DeadlineExceeded, codes.WaitFailed, "waiting for 'a'")
+	if "waiting for 'a': context deadline exceeded" != err.Error() || codes.WaitFailed != err.Code() {
+		t.Errorf("Unexpected error %q (%d)", err.Error(), err.Code())
+	}
+	if 2 != len(err) {
+		t.Errorf("Expected the cause in the chain, received %d errors", len(err))
+	}
+
+	err = wrapError(errors.New(`No option "50%"`), codes.ActionFailed, "could not select")
+	if `could not select: No option "50%"` != err.Error() {
+		t.Errorf("Unexpected error %q", err.Error())
+	}
+	if err = wrapError(nil, codes.ActionFailed, "100% failed"); "100% failed" != err.Error() {
+		t.Errorf("Unexpected error %q", err.Error())
+	}
+}
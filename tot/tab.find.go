@@ -0,0 +1,374 @@
+package chrome
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+/*
+findPageSize is the number of search results fetched at once.
+*/
+const findPageSize = 100
+
+/*
+FindOptions are the options of Tab.Find().
+*/
+type FindOptions struct {
+	// IncludeUserAgentShadowDOM includes the shadow DOM of built-in
+	// elements, e.g. of <input> and <video> elements.
+	IncludeUserAgentShadowDOM bool
+}
+
+/*
+Find returns the elements matching a selector in document order, including
+the elements of shadow roots and same-process frames. The selector type is
+set by a prefix:
+
+	css=form > button       CSS selector, the default
+	xpath=//button[@type]   XPath expression, the default for selectors
+	                        starting with / or (
+	text=Sign in            elements containing the text, ignoring case
+	text="Sign in"          elements with exactly the text
+	role=button[name=Save]  accessibility selectors, see Tab.QueryAll()
+	label=Email
+
+XPath and text selectors use the search of the DOM domain. The search also
+matches the query as plain text in node names and attributes, XPath results
+are the found elements and the parent elements of found text and attribute
+nodes. Searches are always discarded.
+*/
+func (tab *Tab) Find(ctx context.Context, selector string, options ...*FindOptions) ([]*Element, error) {
+	return tab.find(ctx, selector, nil, options)
+}
+
+/*
+find returns the elements matching a selector, only the elements of frame if
+it is not nil.
+*/
+func (tab *Tab) find(ctx context.Context, selector string, frame *PageFrame, options []*FindOptions) ([]*Element, error) {
+	kind, query := "css", strings.TrimSpace(selector)
+	if index := strings.Index(query, "="); index > 0 {
+		switch prefix := query[:index]; prefix {
+		case "css", "xpath", "text":
+			kind, query = prefix, strings.TrimSpace(query[index+1:])
+		case "role", "label":
+			elements, err := tab.QueryAll(selector)
+			if nil != err || nil == frame {
+				return elements, err
+			}
+			return tab.frameElements(elements, frame)
+		}
+	}
+	if "css" == kind && (strings.HasPrefix(query, "/") || strings.HasPrefix(query, "(")) {
+		kind = "xpath"
+	}
+	exact := false
+	if "text" == kind {
+		query, exact = unquoteText(query)
+	}
+	if "" == query {
+		return nil, errs.New(codes.SelectorInvalid, fmt.Sprintf("empty selector '%s'", selector))
+	}
+	finder := &elementFinder{
+		ctx:      ctx,
+		exact:    exact,
+		frames:   map[*domNode]string{},
+		kind:     kind,
+		nodes:    map[dom.NodeID]*domNode{},
+		order:    map[*domNode]int{},
+		parents:  map[*domNode]*domNode{},
+		query:    query,
+		selector: selector,
+		tab:      tab,
+	}
+	if nil != frame {
+		finder.frame = string(frame.ID)
+		finder.topFrame = string(frame.rootID())
+	}
+	for _, option := range options {
+		if nil != option && option.IncludeUserAgentShadowDOM {
+			finder.userAgent = true
+		}
+	}
+	return finder.find()
+}
+
+/*
+elementFinder is the state of a Find() call.
+*/
+type elementFinder struct {
+	ctx       context.Context
+	exact     bool
+	frame     string
+	frames    map[*domNode]string
+	kind      string
+	nodes     map[dom.NodeID]*domNode
+	order     map[*domNode]int
+	parents   map[*domNode]*domNode
+	query     string
+	roots     []*domNode
+	selector  string
+	tab       *Tab
+	topFrame  string
+	userAgent bool
+}
+
+func (finder *elementFinder) find() ([]*Element, error) {
+	if err := finder.ctx.Err(); nil != err {
+		return nil, err
+	}
+	// The node IDs of the search results are only known after the document
+	// was requested, requesting it again would invalidate them.
+	document, err := finder.tab.domDocument()
+	if nil != err {
+		return nil, wrapError(err, codes.ElementNotFound, "could not get the document")
+	}
+	finder.index(document, nil, finder.topFrame)
+
+	var nodeIDs []dom.NodeID
+	if "css" == finder.kind {
+		nodeIDs, err = finder.querySelectorAll()
+	} else {
+		nodeIDs, err = finder.search()
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	found := map[*domNode]bool{}
+	for _, nodeID := range nodeIDs {
+		node, ok := finder.nodes[nodeID]
+		if !ok {
+			if node, err = finder.describe(nodeID); nil != err {
+				return nil, err
+			}
+		}
+		if element := finder.element(node); nil != element && finder.inFrame(element) {
+			found[element] = true
+		}
+	}
+	nodes := make([]*domNode, 0, len(found))
+	for node := range found {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(a, b int) bool {
+		return finder.order[nodes[a]] < finder.order[nodes[b]]
+	})
+	elements := make([]*Element, 0, len(nodes))
+	for _, node := range nodes {
+		elements = append(elements, finder.tab.newElement(node.BackendNodeID, finder.selector))
+	}
+	return elements, nil
+}
+
+/*
+index records the nodes of the document in document order with the ID of
+their frame, and the roots that CSS selectors are matched in: documents and
+shadow roots.
+*/
+func (finder *elementFinder) index(node, parent *domNode, frame string) {
+	if 9 == node.NodeType && "" != node.FrameID {
+		frame = node.FrameID
+	}
+	finder.order[node] = len(finder.order) + 1
+	finder.parents[node] = parent
+	finder.frames[node] = frame
+	if 0 != node.NodeID {
+		finder.nodes[node.NodeID] = node
+	}
+	if (9 == node.NodeType || 11 == node.NodeType) && finder.inFrame(node) {
+		finder.roots = append(finder.roots, node)
+	}
+	for _, root := range node.ShadowRoots {
+		if "user-agent" != root.ShadowRootType || finder.userAgent {
+			finder.index(root, node, frame)
+		}
+	}
+	if nil != node.ContentDocument {
+		finder.index(node.ContentDocument, node, node.FrameID)
+	}
+	for _, child := range node.Children {
+		finder.index(child, node, frame)
+	}
+}
+
+/*
+inFrame returns whether an indexed node is part of the frame the elements
+are found in. Nodes that are not part of the document tree are only found in
+all frames.
+*/
+func (finder *elementFinder) inFrame(node *domNode) bool {
+	if "" == finder.frame {
+		return true
+	}
+	frame, ok := finder.frames[node]
+	return ok && finder.frame == frame
+}
+
+/*
+frameElements returns the elements that are part of a frame.
+*/
+func (tab *Tab) frameElements(elements []*Element, frame *PageFrame) ([]*Element, error) {
+	document, err := tab.domDocument()
+	if nil != err {
+		return nil, wrapError(err, codes.ElementNotFound, "could not get the document")
+	}
+	finder := &elementFinder{
+		frame:    string(frame.ID),
+		frames:   map[*domNode]string{},
+		nodes:    map[dom.NodeID]*domNode{},
+		order:    map[*domNode]int{},
+		parents:  map[*domNode]*domNode{},
+		topFrame: string(frame.rootID()),
+	}
+	finder.index(document, nil, finder.topFrame)
+	inFrame := map[dom.BackendNodeID]bool{}
+	for node := range finder.frames {
+		if finder.inFrame(node) {
+			inFrame[node.BackendNodeID] = true
+		}
+	}
+	found := []*Element{}
+	for _, element := range elements {
+		if inFrame[element.BackendNodeID] {
+			found = append(found, element)
+		}
+	}
+	return found, nil
+}
+
+/*
+element returns the element of a search result: the node if it is an element
+or the parent element of a text or attribute node. Text results are only
+text nodes containing the query, or with exactly the query as text if it was
+quoted.
+*/
+func (finder *elementFinder) element(node *domNode) *domNode {
+	if "text" == finder.kind {
+		if 3 != node.NodeType {
+			return nil
+		}
+		if finder.exact && finder.query != strings.Join(strings.Fields(node.NodeValue), " ") {
+			return nil
+		}
+		if !finder.exact && !strings.Contains(strings.ToLower(node.NodeValue), strings.ToLower(finder.query)) {
+			return nil
+		}
+	}
+	for ; nil != node; node = finder.parents[node] {
+		switch node.NodeType {
+		case 1:
+			if "text" == finder.kind && noTextElements[strings.ToLower(node.LocalName)] {
+				return nil
+			}
+			return node
+		case 9, 11:
+			return nil
+		}
+	}
+	return nil
+}
+
+/*
+unquoteText returns the text of a quoted text selector and true, or the text
+as is and false if it is not quoted.
+*/
+func unquoteText(text string) (string, bool) {
+	if len(text) < 2 || ('"' != text[0] && '\'' != text[0]) || text[0] != text[len(text)-1] {
+		return text, false
+	}
+	unquoted := []byte{}
+	for a := 1; a < len(text)-1; a++ {
+		if '\\' == text[a] && a+1 < len(text)-1 {
+			a++
+		}
+		unquoted = append(unquoted, text[a])
+	}
+	return strings.Join(strings.Fields(string(unquoted)), " "), true
+}
+
+/*
+querySelectorAll matches a CSS selector in the documents and shadow roots.
+*/
+func (finder *elementFinder) querySelectorAll() ([]dom.NodeID, error) {
+	nodeIDs := []dom.NodeID{}
+	for _, root := range finder.roots {
+		if err := finder.ctx.Err(); nil != err {
+			return nil, err
+		}
+		result := <-finder.tab.DOM().QuerySelectorAll(&dom.QuerySelectorAllParams{
+			NodeID:   root.NodeID,
+			Selector: finder.query,
+		})
+		if nil != result.Err {
+			return nil, wrapError(result.Err, codes.SelectorInvalid, fmt.Sprintf("could not query '%s'", finder.selector))
+		}
+		nodeIDs = append(nodeIDs, result.NodeIDs...)
+	}
+	return nodeIDs, nil
+}
+
+/*
+search runs a DOM search and returns all results. The search is discarded
+in any case.
+*/
+func (finder *elementFinder) search() ([]dom.NodeID, error) {
+	search := <-finder.tab.DOM().PerformSearch(&dom.PerformSearchParams{
+		Query:                     finder.query,
+		IncludeUserAgentShadowDOM: finder.userAgent,
+	})
+	if nil != search.Err {
+		return nil, wrapError(search.Err, codes.SelectorInvalid, fmt.Sprintf("could not search '%s'", finder.selector))
+	}
+	defer finder.discard(search.SearchID)
+
+	nodeIDs := []dom.NodeID{}
+	for from := 0; from < search.ResultCount; from += findPageSize {
+		if err := finder.ctx.Err(); nil != err {
+			return nil, err
+		}
+		to := from + findPageSize
+		if to > search.ResultCount {
+			to = search.ResultCount
+		}
+		result := <-finder.tab.DOM().GetSearchResults(&dom.GetSearchResultsParams{
+			SearchID:  search.SearchID,
+			FromIndex: int64(from),
+			ToIndex:   int64(to),
+		})
+		if nil != result.Err {
+			return nil, wrapError(result.Err, codes.ElementNotFound, fmt.Sprintf("could not get the results of '%s'", finder.selector))
+		}
+		nodeIDs = append(nodeIDs, result.NodeIDs...)
+	}
+	return nodeIDs, nil
+}
+
+/*
+discard discards a search.
+*/
+func (finder *elementFinder) discard(searchID string) {
+	<-finder.tab.DOM().DiscardSearchResults(&dom.DiscardSearchResultsParams{SearchID: searchID})
+}
+
+/*
+describe returns a search result that is not part of the document tree.
+*/
+func (finder *elementFinder) describe(nodeID dom.NodeID) (*domNode, error) {
+	result := &struct {
+		Node *domNode `json:"node"`
+	}{}
+	if err := finder.tab.sendCommand("DOM.describeNode", &dom.DescribeNodeParams{NodeID: nodeID}, result); nil != err {
+		return nil, wrapError(err, codes.ElementNotFound, fmt.Sprintf("could not describe node %d", nodeID))
+	}
+	if nil == result.Node {
+		result.Node = &domNode{}
+	}
+	return result.Node, nil
+}
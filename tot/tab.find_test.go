@@ -0,0 +1,172 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+func TestFind(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestFind")
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testAXDocument), nil
+	})
+	selectors := map[int][]int{}
+	mockSocket.Respond("DOM.querySelectorAll", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			NodeID   int
+			Selector string
+		}{}
+		json.Unmarshal(params, &request)
+		if "!" == request.Selector {
+			return nil, errors.New("DOM Error while querying")
+		}
+		return map[string]interface{}{"nodeIds": selectors[request.NodeID]}, nil
+	})
+
+	searches := []string{}
+	discarded := []string{}
+	pages := []string{}
+	results := []int{}
+	mockSocket.Respond("DOM.performSearch", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			Query                     string
+			IncludeUserAgentShadowDOM bool
+		}{}
+		json.Unmarshal(params, &request)
+		searches = append(searches, fmt.Sprintf("%s %v", request.Query, request.IncludeUserAgentShadowDOM))
+		return map[string]interface{}{"searchId": fmt.Sprintf("S%d", len(searches)), "resultCount": len(results)}, nil
+	})
+	mockSocket.Respond("DOM.getSearchResults", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			SearchID           string
+			FromIndex, ToIndex int
+		}{}
+		json.Unmarshal(params, &request)
+		pages = append(pages, fmt.Sprintf("%s:%d-%d", request.SearchID, request.FromIndex, request.ToIndex))
+		if request.ToIndex > len(results) {
+			return nil, errors.New("Invalid search result range")
+		}
+		return map[string]interface{}{"nodeIds": results[request.FromIndex:request.ToIndex]}, nil
+	})
+	mockSocket.Respond("DOM.discardSearchResults", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ SearchID string }{}
+		json.Unmarshal(params, &request)
+		discarded = append(discarded, request.SearchID)
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("DOM.describeNode", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(`{"node": {"nodeType": 1, "nodeName": "DIV", "localName": "div", "backendNodeId": 99}}`), nil
+	})
+
+	find := func(selector string, options ...*FindOptions) string {
+		elements, err := tab.Find(context.Background(), selector, options...)
+		if nil != err {
+			return err.Error()
+		}
+		ids := []dom.BackendNodeID{}
+		for _, element := range elements {
+			if selector != element.Selector {
+				t.Errorf("Unexpected selector %s", element.Selector)
+			}
+			ids = append(ids, element.BackendNodeID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	selectors[1] = []int{10, 9}
+	selectors[30] = []int{15}
+	if ids := find("css=input, span"); "[9 10 15]" != ids {
+		t.Errorf("Unexpected CSS results %s", ids)
+	}
+	if ids := find("input"); "[9 10 15]" != ids {
+		t.Errorf("Unexpected default CSS results %s", ids)
+	}
+	if 0 != len(searches) {
+		t.Errorf("Unexpected searches %v", searches)
+	}
+
+	results = []int{20}
+	if ids := find("xpath=//a"); "[20]" != ids {
+		t.Errorf("Unexpected XPath results %s", ids)
+	}
+	if ids := find("//a", &FindOptions{IncludeUserAgentShadowDOM: true}); "[20]" != ids {
+		t.Errorf("Unexpected default XPath results %s", ids)
+	}
+	results = []int{43, 13, 40}
+	if ids := find("text=shadow"); "[15]" != ids {
+		t.Errorf("Unexpected text results %s", ids)
+	}
+	results = []int{40}
+	if ids := find("text=Test"); "[]" != ids {
+		t.Errorf("Unexpected title results %s", ids)
+	}
+	results = []int{777}
+	if ids := find("xpath=//div"); "[99]" != ids {
+		t.Errorf("Unexpected described results %s", ids)
+	}
+
+	results = []int{}
+	for a := 0; a < 149; a++ {
+		results = append(results, 41)
+	}
+	results = append(results, 43)
+	pages = []string{}
+	if ids := find("text=O"); "[12 15]" != ids {
+		t.Errorf("Unexpected paged results %s", ids)
+	}
+	if "[S6:0-100 S6:100-150]" != fmt.Sprint(pages) {
+		t.Errorf("Unexpected pages %v", pages)
+	}
+	if "[//a false //a true shadow false Test false //div false O false]" != fmt.Sprint(searches) {
+		t.Errorf("Unexpected searches %v", searches)
+	}
+	if "[S1 S2 S3 S4 S5 S6]" != fmt.Sprint(discarded) {
+		t.Errorf("Searches not discarded %v", discarded)
+	}
+
+	// Failed searches and canceled contexts discard the search too.
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSocket.Respond("DOM.getSearchResults", func(params json.RawMessage) (interface{}, error) {
+		cancel()
+		return map[string]interface{}{"nodeIds": []int{}}, nil
+	})
+	if _, err := tab.Find(ctx, "text=i"); context.Canceled != err {
+		t.Errorf("Expected the context error, received %v", err)
+	}
+	mockSocket.Respond("DOM.getSearchResults", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("No search session with given id found")
+	})
+	if _, err := tab.Find(context.Background(), "text=i"); nil == err {
+		t.Errorf("Expected an error for failed search results")
+	}
+	if "[S1 S2 S3 S4 S5 S6 S7 S8]" != fmt.Sprint(discarded) {
+		t.Errorf("Searches not discarded %v", discarded)
+	}
+	if _, err := tab.Find(context.Background(), "css=!"); nil == err {
+		t.Errorf("Expected an error for an invalid selector")
+	}
+	if _, err := tab.Find(context.Background(), "text="); nil == err {
+		t.Errorf("Expected an error for an empty selector")
+	}
+
+	// Quoted text is matched exactly.
+	mockSocket.Respond("DOM.getSearchResults", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"nodeIds": []int{41, 43}}, nil
+	})
+	results = []int{41, 43}
+	searches = []string{}
+	if ids := find(`text="low  contrast"`); "[12]" != ids {
+		t.Errorf("Unexpected exact text results %s", ids)
+	}
+	if ids := find(`text='Low contrast'`); "[]" != ids {
+		t.Errorf("Unexpected exact text results %s", ids)
+	}
+	if "[low contrast false Low contrast false]" != fmt.Sprint(searches) {
+		t.Errorf("Expected unquoted searches, received %v", searches)
+	}
+}
@@ -0,0 +1,143 @@
+package chrome
+
+import (
+	"encoding/json"
+
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+/*
+AttachOutOfProcessFrames makes out-of-process frames part of the tree. With
+site isolation cross-origin frames are rendered by their own targets, the
+DOM and Runtime domains of the page can not reach them.
+
+The tree enables auto-attach with waitForDebuggerOnStart, so new frame
+targets are paused before their first script runs. setup is called with the
+tab of each frame session while it is paused, to repeat the domain setup of
+the page: enabling domains, request interception or emulation. The frames of
+the session are then loaded and the target resumed with
+Runtime.runIfWaitingForDebugger. Setup failures are logged, the target is
+resumed anyway. setup may be nil.
+
+Other targets attached to the page, e.g. workers, are resumed without setup.
+*/
+func (tree *FrameTree) AttachOutOfProcessFrames(setup func(session *Tab) error) error {
+	if err := tree.Enable(); nil != err {
+		return err
+	}
+	tree.mux.Lock()
+	tree.setup = setup
+	tree.mux.Unlock()
+	return tree.autoAttach(tree.tab)
+}
+
+/*
+autoAttach attaches to the targets of the page or of a frame session.
+*/
+func (tree *FrameTree) autoAttach(tab *Tab) error {
+	tree.addHandlers(tab,
+		socket.NewOrderedEventHandler("Target.attachedToTarget", func(response *socket.Response) {
+			tree.onAttachedToTarget(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Target.detachedFromTarget", func(response *socket.Response) {
+			tree.onDetachedFromTarget(response)
+		}),
+	)
+	if result := <-tab.Target().SetAutoAttach(&target.SetAutoAttachParams{
+		AutoAttach:             true,
+		WaitForDebuggerOnStart: true,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.FrameFailed, "Target.setAutoAttach failed")
+	}
+	return nil
+}
+
+func (tree *FrameTree) onAttachedToTarget(parent *Tab, response *socket.Response) {
+	event := &target.AttachedToTargetEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err || nil == event.Info {
+		return
+	}
+	tab := newSessionTab(parent, event.SessionID, event.Info)
+
+	if "iframe" != event.Info.Type {
+		if event.WaitingForDebugger {
+			go tree.resume(tab)
+		}
+		return
+	}
+	tree.mux.Lock()
+	tree.sessions[event.SessionID] = tab
+	// Frames detached from the process of their parent without a reason
+	// were swapped into this session.
+	delete(tree.detached, page.FrameID(event.Info.ID))
+	setup := tree.setup
+	tree.mux.Unlock()
+
+	// The session is set up on its own routine, its responses are events
+	// of the parent session.
+	go func() {
+		if nil != setup {
+			if err := setup(tab); nil != err {
+				log.WithFields(log.Fields{"error": err, "targetID": event.Info.ID, "url": event.Info.URL}).
+					Warn("out-of-process frame setup failed")
+			}
+		}
+		if err := tree.enableSession(tab); nil != err {
+			log.WithFields(log.Fields{"error": err, "targetID": event.Info.ID, "url": event.Info.URL}).
+				Warn("could not load out-of-process frame")
+		}
+		// Frames nested in the frame are attached to its session.
+		if err := tree.autoAttach(tab); nil != err {
+			log.WithFields(log.Fields{"error": err, "targetID": event.Info.ID, "url": event.Info.URL}).
+				Warn("could not attach the targets of out-of-process frame")
+		}
+		if event.WaitingForDebugger {
+			tree.resume(tab)
+		}
+	}()
+}
+
+/*
+onDetachedFromTarget removes the frames of a detached session.
+*/
+func (tree *FrameTree) onDetachedFromTarget(response *socket.Response) {
+	event := &target.DetachedFromTargetEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	tab, ok := tree.sessions[event.SessionID]
+	if !ok {
+		return
+	}
+	delete(tree.sessions, event.SessionID)
+	delete(tree.handlers, tab)
+	for _, frame := range tree.frames {
+		if tab == frame.tab && !frame.detached {
+			tree.detach(frame)
+			// The process of the parent may render the frame again.
+			delete(tree.detached, frame.ID)
+		}
+	}
+	for key := range tree.contexts {
+		if tab == key.tab {
+			delete(tree.contexts, key)
+		}
+	}
+	tree.notify()
+}
+
+/*
+resume runs a target that waits for the debugger.
+*/
+func (tree *FrameTree) resume(tab *Tab) {
+	if result := <-tab.Runtime().RunIfWaitingForDebugger(); nil != result.Err {
+		log.WithFields(log.Fields{"error": result.Err, "targetID": tab.data.ID}).
+			Warn("could not resume target")
+	}
+}
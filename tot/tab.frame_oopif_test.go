@@ -0,0 +1,150 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+)
+
+func TestAttachOutOfProcessFrames(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestAttachOutOfProcessFrames")
+	for _, method := range []string{"Page.enable", "Runtime.enable", "Target.setAutoAttach"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("Page.getFrameTree", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(`{"frameTree": {"frame": {"id": "M", "url": "https://example.com/"}}}`), nil
+	})
+	mockSocket.Respond("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"value": "page"}}, nil
+	})
+
+	// Messages to sessions are answered by the session responders through
+	// Target.receivedMessageFromTarget events.
+	var mux sync.Mutex
+	sessionMethods := map[string][]string{}
+	sessionResults := map[string]string{
+		"Page.getFrameTree": `{"frameTree": {"frame": {"id": "F9", "parentId": "M", "url": "https://widget.example/"}}}`,
+		"Runtime.evaluate":  `{"result": {"value": "widget"}}`,
+		"DOM.getDocument": `{"root": {"nodeId": 1, "backendNodeId": 1, "nodeType": 9, "nodeName": "#document", "children": [
+			{"nodeId": 2, "backendNodeId": 52, "nodeType": 1, "nodeName": "A", "localName": "a"}
+		]}}`,
+		"DOM.querySelectorAll": `{"nodeIds": [2]}`,
+	}
+	receive := func(sessionID string, message interface{}) {
+		data, _ := json.Marshal(message)
+		mockSocket.Fire("Target.receivedMessageFromTarget", map[string]string{"sessionId": sessionID, "message": string(data)})
+	}
+	mockSocket.Respond("Target.sendMessageToTarget", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			Message   string
+			SessionID string
+		}{}
+		json.Unmarshal(params, &request)
+		command := struct {
+			ID     int
+			Method string
+			Params map[string]interface{}
+		}{}
+		json.Unmarshal([]byte(request.Message), &command)
+		mux.Lock()
+		sessionMethods[request.SessionID] = append(sessionMethods[request.SessionID], command.Method)
+		mux.Unlock()
+
+		result, ok := sessionResults[command.Method]
+		if !ok {
+			result = "{}"
+		}
+		go func() {
+			receive(request.SessionID, map[string]interface{}{"id": command.ID, "result": json.RawMessage(result)})
+			if "Runtime.enable" == command.Method {
+				receive(request.SessionID, map[string]interface{}{
+					"method": "Runtime.executionContextCreated",
+					"params": testFrameContext(1, "F9", "", true),
+				})
+			}
+		}()
+		return map[string]interface{}{}, nil
+	})
+
+	frames := NewFrameTree(tab)
+	setups := make(chan *Tab, 1)
+	err := frames.AttachOutOfProcessFrames(func(session *Tab) error {
+		setups <- session
+		return session.sendCommand("Network.enable", map[string]interface{}{}, &struct{}{})
+	})
+	if nil != err {
+		t.Fatalf("AttachOutOfProcessFrames failed: %s", err)
+	}
+	mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(1, "M", "", true))
+	mockSocket.Fire("Target.attachedToTarget", map[string]interface{}{
+		"sessionId":          "S2",
+		"targetInfo":         map[string]string{"targetId": "W1", "type": "worker", "url": "https://example.com/worker.js"},
+		"waitingForDebugger": true,
+	})
+	mockSocket.Fire("Target.attachedToTarget", map[string]interface{}{
+		"sessionId":          "S1",
+		"targetInfo":         map[string]string{"targetId": "F9", "type": "iframe", "url": "https://widget.example/"},
+		"waitingForDebugger": true,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	widget, err := frames.WaitForFrame(ctx, func(frame *PageFrame) bool {
+		return "https://widget.example/" == frame.URL()
+	})
+	if nil != err || "M" != widget.Parent().ID || 2 != len(frames.Frames()) {
+		t.Fatalf("Unexpected frame %v (%v)", widget, err)
+	}
+	if session := <-setups; "F9" != session.Data().ID || "iframe" != session.Data().Type {
+		t.Errorf("Unexpected session %v", session.Data())
+	}
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		mux.Lock()
+		resumed := len(sessionMethods["S2"]) > 0 && len(sessionMethods["S1"]) > 5
+		mux.Unlock()
+		if resumed {
+			break
+		}
+	}
+	mux.Lock()
+	if "[Network.enable Page.enable Runtime.enable Page.getFrameTree Target.setAutoAttach Runtime.runIfWaitingForDebugger]" != fmt.Sprint(sessionMethods["S1"]) {
+		t.Errorf("Unexpected session setup %v", sessionMethods["S1"])
+	}
+	if "[Runtime.runIfWaitingForDebugger]" != fmt.Sprint(sessionMethods["S2"]) {
+		t.Errorf("Unexpected worker setup %v", sessionMethods["S2"])
+	}
+	mux.Unlock()
+
+	// Context IDs of the page and the session overlap.
+	if value, err := widget.Evaluate(ctx, "document.title"); nil != err || `"widget"` != string(value) {
+		t.Errorf("Unexpected frame value %s (%v)", value, err)
+	}
+	if value, err := frames.Main().Evaluate(ctx, "document.title"); nil != err || `"page"` != string(value) {
+		t.Errorf("Unexpected page value %s (%v)", value, err)
+	}
+	elements, err := widget.Find(ctx, "css=a")
+	if nil != err || 1 != len(elements) || 52 != elements[0].BackendNodeID {
+		t.Errorf("Unexpected elements %v (%v)", elements, err)
+	}
+
+	mockSocket.Fire("Target.detachedFromTarget", map[string]string{"sessionId": "S1"})
+	for !widget.Detached() {
+		time.Sleep(time.Millisecond)
+	}
+	if 1 != len(frames.Frames()) {
+		t.Errorf("Unexpected frames after detaching %v", frames.Frames())
+	}
+	if _, err := widget.Evaluate(ctx, "1"); nil == err || codes.FrameDetached != err.(errs.Err).Code() {
+		t.Errorf("Expected FrameDetached, received %v", err)
+	}
+	frames.Disable()
+}
@@ -0,0 +1,835 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+/*
+NewFrameTree returns the frame tree of the tab. The tree is empty until it is
+enabled.
+*/
+func NewFrameTree(tab *Tab) *FrameTree {
+	return &FrameTree{
+		changed:  make(chan struct{}),
+		contexts: map[contextKey]*frameContext{},
+		detached: map[page.FrameID]bool{},
+		frames:   map[page.FrameID]*PageFrame{},
+		handlers: map[*Tab][]socket.EventHandler{},
+		mux:      &sync.Mutex{},
+		parents:  map[page.FrameID]page.FrameID{},
+		sessions: map[target.SessionID]*Tab{},
+		tab:      tab,
+	}
+}
+
+/*
+FrameTree tracks the frames of a page and the JavaScript execution contexts
+of each frame, so that scripts can be evaluated and elements found in any
+frame instead of the main world of the top frame:
+
+	frames := chrome.NewFrameTree(tab)
+	frames.Enable()
+	checkout, _ := frames.WaitForFrame(ctx, func(frame *chrome.PageFrame) bool {
+		return "checkout" == frame.Name()
+	})
+	total, _ := checkout.Evaluate(ctx, "document.querySelector('#total').textContent")
+	helpers, _ := checkout.CreateIsolatedWorld(ctx, "helpers")
+	helpers.Evaluate(ctx, "window.helper = 1") // invisible to the page
+
+Only frames rendered in the process of the page are part of the tree unless
+out-of-process frames are attached, see AttachOutOfProcessFrames().
+*/
+type FrameTree struct {
+	changed  chan struct{}
+	contexts map[contextKey]*frameContext
+	detached map[page.FrameID]bool
+	enabled  bool
+	frames   map[page.FrameID]*PageFrame
+	handlers map[*Tab][]socket.EventHandler
+	main     *PageFrame
+	mux      *sync.Mutex
+	parents  map[page.FrameID]page.FrameID
+	sessions map[target.SessionID]*Tab
+	setup    func(session *Tab) error
+	tab      *Tab
+}
+
+/*
+contextKey identifies an execution context, context IDs are only unique in
+the session of a target.
+*/
+type contextKey struct {
+	id  runtime.ExecutionContextID
+	tab *Tab
+}
+
+/*
+frameContext is an execution context of a frame.
+*/
+type frameContext struct {
+	frameID   page.FrameID
+	isDefault bool
+	name      string
+}
+
+/*
+PageFrame is a frame of the page. The name and URL of a frame change when it
+navigates, its ID does not.
+*/
+type PageFrame struct {
+	// ID is the frame ID.
+	ID page.FrameID
+
+	// ParentID is the ID of the parent frame, empty for the main frame.
+	ParentID page.FrameID
+
+	children []*PageFrame
+	detached bool
+	name     string
+	tab      *Tab
+	tree     *FrameTree
+	url      string
+	worlds   map[string]*World
+}
+
+/*
+World is a JavaScript world of a frame: the main world shared with the page
+or an isolated world. Isolated worlds share the DOM with the page but not
+the globals, so helper scripts can not clash with page scripts.
+*/
+type World struct {
+	// Name is the name of an isolated world, empty for the main world.
+	Name string
+
+	frame *PageFrame
+	mux   sync.Mutex
+}
+
+/*
+Enable enables the Page and Runtime domains, loads the frame tree and starts
+tracking frames and execution contexts.
+*/
+func (tree *FrameTree) Enable() error {
+	tree.mux.Lock()
+	if tree.enabled {
+		tree.mux.Unlock()
+		return nil
+	}
+	tree.enabled = true
+	tree.mux.Unlock()
+	return tree.enableSession(tree.tab)
+}
+
+/*
+enableSession tracks the frames and execution contexts of the page or of an
+out-of-process frame.
+*/
+func (tree *FrameTree) enableSession(tab *Tab) error {
+	tree.addHandlers(tab,
+		socket.NewOrderedEventHandler("Page.frameAttached", func(response *socket.Response) {
+			tree.onFrameAttached(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Page.frameNavigated", func(response *socket.Response) {
+			tree.onFrameNavigated(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Page.frameDetached", func(response *socket.Response) {
+			tree.onFrameDetached(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Runtime.executionContextCreated", func(response *socket.Response) {
+			tree.onContextCreated(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Runtime.executionContextDestroyed", func(response *socket.Response) {
+			tree.onContextDestroyed(tab, response)
+		}),
+		socket.NewOrderedEventHandler("Runtime.executionContextsCleared", func(response *socket.Response) {
+			tree.onContextsCleared(tab, response)
+		}),
+	)
+	if result := <-tab.Page().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.FrameFailed, "Page.enable failed")
+	}
+	if result := <-tab.Runtime().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.FrameFailed, "Runtime.enable failed")
+	}
+	result := <-tab.Page().GetFrameTree()
+	if nil != result.Err {
+		return wrapError(result.Err, codes.FrameFailed, "Page.getFrameTree failed")
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	tree.load(result.FrameTree, tab)
+	tree.notify()
+	return nil
+}
+
+/*
+addHandlers adds event handlers to the tab of a session.
+*/
+func (tree *FrameTree) addHandlers(tab *Tab, handlers ...socket.EventHandler) {
+	tree.mux.Lock()
+	tree.handlers[tab] = append(tree.handlers[tab], handlers...)
+	tree.mux.Unlock()
+	for _, handler := range handlers {
+		tab.AddEventHandler(handler)
+	}
+}
+
+/*
+Disable stops tracking frames. The Page and Runtime domains stay enabled,
+other features of the tab may depend on them.
+*/
+func (tree *FrameTree) Disable() {
+	tree.mux.Lock()
+	handlers := tree.handlers
+	tree.enabled = false
+	tree.handlers = map[*Tab][]socket.EventHandler{}
+	tree.mux.Unlock()
+
+	for tab, tabHandlers := range handlers {
+		for _, handler := range tabHandlers {
+			tab.RemoveEventHandler(handler)
+		}
+	}
+}
+
+/*
+Main returns the main frame, nil before the tree is loaded.
+*/
+func (tree *FrameTree) Main() *PageFrame {
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	return tree.main
+}
+
+/*
+Frame returns the frame with an ID, nil if the frame is not attached.
+*/
+func (tree *FrameTree) Frame(id page.FrameID) *PageFrame {
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	return tree.frames[id]
+}
+
+/*
+Frames returns the attached frames, parents before their children.
+*/
+func (tree *FrameTree) Frames() []*PageFrame {
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	return tree.list()
+}
+
+/*
+FrameByName returns the first frame with a name, nil if there is none.
+*/
+func (tree *FrameTree) FrameByName(name string) *PageFrame {
+	return tree.find(func(frame *PageFrame) bool {
+		return name == frame.name
+	})
+}
+
+/*
+FrameByURL returns the first frame whose URL contains a string, nil if there
+is none.
+*/
+func (tree *FrameTree) FrameByURL(url string) *PageFrame {
+	return tree.find(func(frame *PageFrame) bool {
+		return strings.Contains(frame.url, url)
+	})
+}
+
+/*
+WaitForFrame waits for a frame matching the predicate, which is called for
+each attached frame whenever the tree changes.
+*/
+func (tree *FrameTree) WaitForFrame(ctx context.Context, predicate func(frame *PageFrame) bool) (*PageFrame, error) {
+	for {
+		tree.mux.Lock()
+		frames := tree.list()
+		changed := tree.changed
+		tree.mux.Unlock()
+		for _, frame := range frames {
+			if predicate(frame) {
+				return frame, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.FrameFailed, "waiting for a frame")
+		case <-changed:
+		}
+	}
+}
+
+/*
+find returns the first frame matching a predicate, which is called with the
+tree locked.
+*/
+func (tree *FrameTree) find(predicate func(frame *PageFrame) bool) *PageFrame {
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	for _, frame := range tree.list() {
+		if predicate(frame) {
+			return frame
+		}
+	}
+	return nil
+}
+
+/*
+list returns the attached frames in tree order.
+*/
+func (tree *FrameTree) list() []*PageFrame {
+	frames := []*PageFrame{}
+	var walk func(frame *PageFrame)
+	walk = func(frame *PageFrame) {
+		frames = append(frames, frame)
+		for _, child := range frame.children {
+			walk(child)
+		}
+	}
+	if nil != tree.main {
+		walk(tree.main)
+	}
+	return frames
+}
+
+/*
+notify wakes up the waits for changes of the tree.
+*/
+func (tree *FrameTree) notify() {
+	close(tree.changed)
+	tree.changed = make(chan struct{})
+}
+
+/*
+load adds the frames of Page.getFrameTree of a session. Frames that are
+already known were updated by events, which are more recent, but they are
+rendered by the session now.
+*/
+func (tree *FrameTree) load(node *page.FrameTree, tab *Tab) {
+	if nil == node || nil == node.Frame {
+		return
+	}
+	if frame, ok := tree.frames[page.FrameID(node.Frame.ID)]; ok {
+		frame.tab = tab
+	} else {
+		tree.navigated(node.Frame, tab)
+	}
+	for _, child := range node.ChildFrames {
+		tree.load(child, tab)
+	}
+}
+
+/*
+attach adds a frame rendered by the session of a tab to the tree. Only the
+page can have the main frame, out-of-process frames without a parent keep
+the parent they had in the process of their parent.
+*/
+func (tree *FrameTree) attach(id, parentID page.FrameID, tab *Tab) *PageFrame {
+	if frame, ok := tree.frames[id]; ok {
+		frame.tab = tab
+		return frame
+	}
+	if "" == parentID {
+		parentID = tree.parents[id]
+	}
+	if "" != parentID {
+		tree.parents[id] = parentID
+	}
+	frame := &PageFrame{
+		ID:       id,
+		ParentID: parentID,
+		tab:      tab,
+		tree:     tree,
+		worlds:   map[string]*World{},
+	}
+	tree.frames[id] = frame
+	if parent, ok := tree.frames[parentID]; ok {
+		parent.children = append(parent.children, frame)
+	} else if "" == parentID && tree.tab == tab {
+		if nil != tree.main {
+			tree.detach(tree.main)
+		}
+		tree.main = frame
+	}
+	// Children attached before their parent was known.
+	for _, child := range tree.frames {
+		if frame.ID == child.ParentID && child != frame && !frame.hasChild(child) {
+			frame.children = append(frame.children, child)
+		}
+	}
+	return frame
+}
+
+/*
+navigated updates a frame from a navigation, adding it if it is unknown.
+*/
+func (tree *FrameTree) navigated(data *page.Frame, tab *Tab) {
+	id := page.FrameID(data.ID)
+	if tree.detached[id] {
+		return
+	}
+	frame := tree.attach(id, page.FrameID(data.ParentID), tab)
+	frame.name = data.Name
+	frame.url = data.URL
+}
+
+/*
+detach removes a frame and its children from the tree.
+*/
+func (tree *FrameTree) detach(frame *PageFrame) {
+	for _, child := range frame.children {
+		tree.detach(child)
+	}
+	frame.children = nil
+	frame.detached = true
+	tree.detached[frame.ID] = true
+	delete(tree.frames, frame.ID)
+	if parent, ok := tree.frames[frame.ParentID]; ok {
+		for a, child := range parent.children {
+			if child == frame {
+				parent.children = append(parent.children[:a:a], parent.children[a+1:]...)
+				break
+			}
+		}
+	}
+	if tree.main == frame {
+		tree.main = nil
+	}
+}
+
+func (tree *FrameTree) onFrameAttached(tab *Tab, response *socket.Response) {
+	event := &struct {
+		FrameID       page.FrameID `json:"frameId"`
+		ParentFrameID page.FrameID `json:"parentFrameId"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	if !tree.detached[event.FrameID] {
+		tree.attach(event.FrameID, event.ParentFrameID, tab)
+		tree.notify()
+	}
+}
+
+func (tree *FrameTree) onFrameNavigated(tab *Tab, response *socket.Response) {
+	event := &struct {
+		Frame *page.Frame `json:"frame"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err || nil == event.Frame {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	tree.navigated(event.Frame, tab)
+	tree.notify()
+}
+
+/*
+onFrameDetached removes a frame, unless it was swapped into another process:
+the frame is rendered by the session of an out-of-process frame now.
+*/
+func (tree *FrameTree) onFrameDetached(tab *Tab, response *socket.Response) {
+	event := &struct {
+		FrameID page.FrameID `json:"frameId"`
+		Reason  string       `json:"reason"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	frame, ok := tree.frames[event.FrameID]
+	if ok && tab != frame.tab {
+		// A late event of the session that rendered the frame before.
+		return
+	}
+	if "swap" == event.Reason {
+		return
+	}
+	if ok {
+		tree.detach(frame)
+	}
+	tree.detached[event.FrameID] = true
+	tree.notify()
+}
+
+/*
+onContextCreated records the frame of an execution context. The auxiliary
+data of frame contexts is {"frameId": "...", "isDefault": true, "type":
+"default"}, runtime.ExecutionContextDescription can not decode the boolean.
+*/
+func (tree *FrameTree) onContextCreated(tab *Tab, response *socket.Response) {
+	event := &struct {
+		Context struct {
+			ID      runtime.ExecutionContextID `json:"id"`
+			Name    string                     `json:"name"`
+			AuxData struct {
+				FrameID   page.FrameID `json:"frameId"`
+				IsDefault bool         `json:"isDefault"`
+			} `json:"auxData"`
+		} `json:"context"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err || "" == event.Context.AuxData.FrameID {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	tree.contexts[contextKey{event.Context.ID, tab}] = &frameContext{
+		frameID:   event.Context.AuxData.FrameID,
+		isDefault: event.Context.AuxData.IsDefault,
+		name:      event.Context.Name,
+	}
+	tree.notify()
+}
+
+func (tree *FrameTree) onContextDestroyed(tab *Tab, response *socket.Response) {
+	event := &runtime.ExecutionContextDestroyedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	delete(tree.contexts, contextKey{event.ExecutionContextID, tab})
+	tree.notify()
+}
+
+func (tree *FrameTree) onContextsCleared(tab *Tab, response *socket.Response) {
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	for key := range tree.contexts {
+		if tab == key.tab {
+			delete(tree.contexts, key)
+		}
+	}
+	tree.notify()
+}
+
+/*
+contextID returns the newest execution context of a world in the session
+rendering the frame, 0 if there is none.
+*/
+func (tree *FrameTree) contextID(frame *PageFrame, world string) runtime.ExecutionContextID {
+	var found runtime.ExecutionContextID
+	for key, context := range tree.contexts {
+		if frame.tab != key.tab || frame.ID != context.frameID || key.id < found {
+			continue
+		}
+		if ("" == world && context.isDefault) || ("" != world && !context.isDefault && world == context.name) {
+			found = key.id
+		}
+	}
+	return found
+}
+
+/*
+rootID returns the ID of the top frame of the document tree the frame is
+part of: the main frame or an out-of-process frame.
+*/
+func (frame *PageFrame) rootID() page.FrameID {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	root := frame
+	for {
+		parent, ok := frame.tree.frames[root.ParentID]
+		if !ok || parent.tab != root.tab {
+			return root.ID
+		}
+		root = parent
+	}
+}
+
+/*
+session returns the tab of the session rendering the frame.
+*/
+func (frame *PageFrame) session() *Tab {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return frame.tab
+}
+
+/*
+hasChild returns whether a frame is a child of the frame.
+*/
+func (frame *PageFrame) hasChild(child *PageFrame) bool {
+	for _, known := range frame.children {
+		if known == child {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Name returns the name of the frame.
+*/
+func (frame *PageFrame) Name() string {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return frame.name
+}
+
+/*
+URL returns the URL of the frame document.
+*/
+func (frame *PageFrame) URL() string {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return frame.url
+}
+
+/*
+Parent returns the parent frame, nil for the main frame.
+*/
+func (frame *PageFrame) Parent() *PageFrame {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return frame.tree.frames[frame.ParentID]
+}
+
+/*
+Children returns the child frames.
+*/
+func (frame *PageFrame) Children() []*PageFrame {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return append([]*PageFrame{}, frame.children...)
+}
+
+/*
+Detached returns whether the frame was removed from the page.
+*/
+func (frame *PageFrame) Detached() bool {
+	frame.tree.mux.Lock()
+	defer frame.tree.mux.Unlock()
+	return frame.detached
+}
+
+/*
+MainWorld returns the main world of the frame.
+*/
+func (frame *PageFrame) MainWorld() *World {
+	return &World{frame: frame}
+}
+
+/*
+CreateIsolatedWorld creates an isolated world in the frame. Worlds are
+identified by their name, creating a world twice returns the same world.
+Isolated worlds do not survive navigations, they are created again when
+they are used after a navigation.
+*/
+func (frame *PageFrame) CreateIsolatedWorld(ctx context.Context, name string) (*World, error) {
+	if "" == name {
+		return nil, errs.New(codes.FrameFailed, "isolated worlds need a name")
+	}
+	frame.tree.mux.Lock()
+	world, ok := frame.worlds[name]
+	if !ok {
+		world = &World{Name: name, frame: frame}
+		frame.worlds[name] = world
+	}
+	frame.tree.mux.Unlock()
+
+	if _, err := world.ExecutionContext(ctx); nil != err {
+		return nil, err
+	}
+	return world, nil
+}
+
+/*
+Evaluate evaluates an expression in the main world of the frame, see
+World.Evaluate().
+*/
+func (frame *PageFrame) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	return frame.MainWorld().Evaluate(ctx, expression)
+}
+
+/*
+WaitForFunction waits until an expression is truthy in the main world of the
+frame, see Tab.WaitForFunction().
+*/
+func (frame *PageFrame) WaitForFunction(ctx context.Context, js, polling string) (json.RawMessage, error) {
+	return frame.MainWorld().WaitForFunction(ctx, js, polling)
+}
+
+/*
+Find returns the elements of the frame matching a selector, see Tab.Find().
+Elements of child frames are not included.
+*/
+func (frame *PageFrame) Find(ctx context.Context, selector string, options ...*FindOptions) ([]*Element, error) {
+	if frame.Detached() {
+		return nil, errs.New(codes.FrameDetached, fmt.Sprintf("frame %s was detached", frame.ID))
+	}
+	return frame.session().find(ctx, selector, frame, options)
+}
+
+/*
+WaitForSelector waits until the elements of the frame matching a selector
+are in a state, see Tab.WaitForSelector().
+*/
+func (frame *PageFrame) WaitForSelector(ctx context.Context, selector, state string) (*Element, error) {
+	return frame.session().waitForSelector(ctx, selector, state, func() ([]*Element, error) {
+		return frame.Find(ctx, selector)
+	})
+}
+
+/*
+Frame returns the frame of the world.
+*/
+func (world *World) Frame() *PageFrame {
+	return world.frame
+}
+
+/*
+ExecutionContext returns the current execution context of the world. It
+waits for the main world of a navigating frame and creates isolated worlds
+that do not exist.
+*/
+func (world *World) ExecutionContext(ctx context.Context) (runtime.ExecutionContextID, error) {
+	_, id, err := world.context(ctx)
+	return id, err
+}
+
+/*
+context returns the current execution context of the world and the tab of
+the session it belongs to.
+*/
+func (world *World) context(ctx context.Context) (*Tab, runtime.ExecutionContextID, error) {
+	tree := world.frame.tree
+	for {
+		tree.mux.Lock()
+		detached := world.frame.detached
+		id := tree.contextID(world.frame, world.Name)
+		tab := world.frame.tab
+		changed := tree.changed
+		tree.mux.Unlock()
+
+		switch {
+		case detached:
+			return nil, 0, errs.New(codes.FrameDetached, fmt.Sprintf("frame %s was detached", world.frame.ID))
+		case 0 != id:
+			return tab, id, nil
+		case "" != world.Name:
+			id, err := world.create(tab)
+			return tab, id, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, 0, wrapError(ctx.Err(), codes.FrameFailed, fmt.Sprintf("waiting for the execution context of frame %s", world.frame.ID))
+		case <-changed:
+		}
+	}
+}
+
+/*
+create creates the isolated world in the session of a tab.
+*/
+func (world *World) create(tab *Tab) (runtime.ExecutionContextID, error) {
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	tree := world.frame.tree
+	tree.mux.Lock()
+	id := tree.contextID(world.frame, world.Name)
+	tree.mux.Unlock()
+	if 0 != id {
+		return id, nil
+	}
+
+	result := <-tab.Page().CreateIsolatedWorld(&page.CreateIsolatedWorldParams{
+		FrameID:   world.frame.ID,
+		WorldName: world.Name,
+	})
+	if nil != result.Err {
+		return 0, wrapError(result.Err, codes.FrameFailed, fmt.Sprintf("could not create the isolated world '%s' in frame %s", world.Name, world.frame.ID))
+	}
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	tree.contexts[contextKey{result.ExecutionContextID, tab}] = &frameContext{
+		frameID: world.frame.ID,
+		name:    world.Name,
+	}
+	tree.notify()
+	return result.ExecutionContextID, nil
+}
+
+/*
+Evaluate evaluates an expression in the world and returns its value as JSON.
+Promises are awaited. The evaluation is repeated if the frame navigates
+while it runs.
+*/
+func (world *World) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	for {
+		tab, contextID, err := world.context(ctx)
+		if nil != err {
+			return nil, err
+		}
+		results := make(chan *evaluateResult, 1)
+		go func() {
+			results <- tab.evaluate(contextID, expression)
+		}()
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.FrameFailed, fmt.Sprintf("evaluating in frame %s", world.frame.ID))
+		case result := <-results:
+			if nil == result.err {
+				return result.value, nil
+			}
+			if !contextDestroyed(result.err) {
+				return nil, wrapError(result.err, codes.FrameFailed, fmt.Sprintf("evaluating in frame %s", world.frame.ID))
+			}
+			world.forget(tab, contextID)
+		}
+	}
+}
+
+/*
+WaitForFunction waits until an expression is truthy in the world, see
+Tab.WaitForFunction().
+*/
+func (world *World) WaitForFunction(ctx context.Context, js, polling string) (json.RawMessage, error) {
+	var tab *Tab
+	var contextID runtime.ExecutionContextID
+	return world.frame.tree.tab.waitForFunction(ctx, js, polling, func(ctx context.Context) (*Tab, runtime.ExecutionContextID, error) {
+		// The previous context was destroyed.
+		if nil != tab {
+			world.forget(tab, contextID)
+		}
+		var err error
+		tab, contextID, err = world.context(ctx)
+		return tab, contextID, err
+	})
+}
+
+/*
+forget removes a destroyed execution context whose event may not have been
+handled yet.
+*/
+func (world *World) forget(tab *Tab, contextID runtime.ExecutionContextID) {
+	tree := world.frame.tree
+	tree.mux.Lock()
+	defer tree.mux.Unlock()
+	delete(tree.contexts, contextKey{contextID, tab})
+}
+
+/*
+contextDestroyed returns whether an evaluation failed because its execution
+context was destroyed, e.g. by a navigation.
+*/
+func contextDestroyed(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "context was destroyed") || strings.Contains(message, "Cannot find context")
+}
@@ -0,0 +1,166 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+func testFrameContext(id int, frameID, name string, isDefault bool) map[string]interface{} {
+	return map[string]interface{}{"context": map[string]interface{}{
+		"id":      id,
+		"name":    name,
+		"origin":  "https://example.com",
+		"auxData": map[string]interface{}{"frameId": frameID, "isDefault": isDefault, "type": "default"},
+	}}
+}
+
+func TestFrameTree(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestFrameTree")
+	for _, method := range []string{"Page.enable", "Runtime.enable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("Page.getFrameTree", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(`{"frameTree": {
+			"frame": {"id": "M", "loaderId": "L1", "url": "https://example.com/", "securityOrigin": "https://example.com", "mimeType": "text/html"},
+			"childFrames": [{"frame": {"id": "F2", "parentId": "M", "loaderId": "L2", "name": "checkout", "url": "https://pay.example.com/", "securityOrigin": "https://pay.example.com", "mimeType": "text/html"}}]
+		}}`), nil
+	})
+	var mux sync.Mutex
+	worlds := []string{}
+	evaluated := []string{}
+	mockSocket.Respond("Page.createIsolatedWorld", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ FrameID, WorldName string }{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		defer mux.Unlock()
+		worlds = append(worlds, request.FrameID+"/"+request.WorldName)
+		return map[string]int{"executionContextId": 9 + len(worlds)}, nil
+	})
+	mockSocket.Respond("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			Expression string
+			ContextID  int
+		}{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		defer mux.Unlock()
+		evaluated = append(evaluated, fmt.Sprintf("%d:%s", request.ContextID, request.Expression))
+		return map[string]interface{}{"result": map[string]interface{}{"value": request.ContextID}}, nil
+	})
+
+	frames := NewFrameTree(tab)
+	mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(1, "M", "", true))
+	if err := frames.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+	mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(1, "M", "", true))
+	mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(2, "F2", "", true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	checkout, err := frames.WaitForFrame(ctx, func(frame *PageFrame) bool {
+		return "checkout" == frame.Name()
+	})
+	if nil != err || "F2" != checkout.ID || "M" != checkout.Parent().ID || checkout != frames.FrameByURL("pay.example") {
+		t.Fatalf("Unexpected frame %v (%v)", checkout, err)
+	}
+	if main := frames.Main(); 2 != len(frames.Frames()) || nil != main.Parent() || checkout != main.Children()[0] {
+		t.Errorf("Unexpected frames %v", frames.Frames())
+	}
+
+	if value, err := checkout.Evaluate(ctx, "document.title"); nil != err || "2" != string(value) {
+		t.Errorf("Unexpected value %s (%v)", value, err)
+	}
+	helpers, err := checkout.CreateIsolatedWorld(ctx, "helpers")
+	if nil != err || helpers.Frame() != checkout {
+		t.Fatalf("CreateIsolatedWorld failed: %v", err)
+	}
+	mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(10, "F2", "helpers", false))
+	if value, err := helpers.Evaluate(ctx, "window.helper = 1"); nil != err || "10" != string(value) {
+		t.Errorf("Unexpected isolated value %s (%v)", value, err)
+	}
+	if same, _ := checkout.CreateIsolatedWorld(ctx, "helpers"); same != helpers || 1 != len(worlds) {
+		t.Errorf("The world was created again %v", worlds)
+	}
+
+	// A navigation destroys the contexts, the main world is waited for and
+	// the isolated world is created again.
+	mockSocket.Fire("Runtime.executionContextDestroyed", map[string]int{"executionContextId": 2})
+	mockSocket.Fire("Runtime.executionContextDestroyed", map[string]int{"executionContextId": 10})
+	mockSocket.Fire("Page.frameNavigated", map[string]interface{}{"frame": map[string]string{"id": "F2", "parentId": "M", "name": "checkout", "url": "https://pay.example.com/done"}})
+	for "https://pay.example.com/done" != checkout.URL() {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		mockSocket.Fire("Runtime.executionContextCreated", testFrameContext(3, "F2", "", true))
+	}()
+	if value, err := checkout.Evaluate(ctx, "location.href"); nil != err || "3" != string(value) {
+		t.Errorf("Unexpected value after navigation %s (%v)", value, err)
+	}
+	if value, err := helpers.Evaluate(ctx, "window.helper"); nil != err || "11" != string(value) || "[F2/helpers F2/helpers]" != fmt.Sprint(worlds) {
+		t.Errorf("Unexpected isolated value after navigation %s (%v) %v", value, err, worlds)
+	}
+	if value, err := frames.Main().Evaluate(ctx, "1"); nil != err || "1" != string(value) {
+		t.Errorf("Unexpected main frame value %s (%v)", value, err)
+	}
+
+	// Elements are found in the document of their frame only.
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testAXDocument), nil
+	})
+	mockSocket.Respond("DOM.querySelectorAll", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ NodeID int }{}
+		json.Unmarshal(params, &request)
+		return map[string]interface{}{"nodeIds": map[int][]int{1: {9}, 17: {20}, 30: {15}}[request.NodeID]}, nil
+	})
+	for frame, expected := range map[*PageFrame]string{frames.Main(): "[9 15]", checkout: "[20]"} {
+		elements, err := frame.Find(ctx, "css=a, input, span")
+		ids := []dom.BackendNodeID{}
+		for _, element := range elements {
+			ids = append(ids, element.BackendNodeID)
+		}
+		if nil != err || expected != fmt.Sprint(ids) {
+			t.Errorf("Unexpected elements of frame %s: %v (%v)", frame.ID, ids, err)
+		}
+	}
+	if elements, err := tab.Find(ctx, "css=a, input, span"); nil != err || 3 != len(elements) {
+		t.Errorf("Unexpected elements of the tab %v (%v)", elements, err)
+	}
+
+	// Detaching a frame detaches its children.
+	mockSocket.Fire("Page.frameAttached", map[string]string{"frameId": "F3", "parentFrameId": "F2"})
+	nested, err := frames.WaitForFrame(ctx, func(frame *PageFrame) bool {
+		return "F3" == frame.ID
+	})
+	if nil != err || checkout != nested.Parent() {
+		t.Fatalf("Unexpected nested frame %v (%v)", nested, err)
+	}
+	mockSocket.Fire("Page.frameDetached", map[string]string{"frameId": "F2"})
+	for !nested.Detached() {
+		time.Sleep(time.Millisecond)
+	}
+	if !checkout.Detached() || 1 != len(frames.Frames()) || nil != frames.Frame("F3") {
+		t.Errorf("Unexpected frames after detaching %v", frames.Frames())
+	}
+	if _, err := checkout.Evaluate(ctx, "1"); nil == err || codes.FrameDetached != err.(errs.Err).Code() {
+		t.Errorf("Expected FrameDetached, received %v", err)
+	}
+	if _, err := checkout.WaitForSelector(ctx, "css=a", SelectorAttached); nil == err || codes.FrameDetached != err.(errs.Err).Code() {
+		t.Errorf("Expected FrameDetached, received %v", err)
+	}
+	if _, err := frames.Main().CreateIsolatedWorld(ctx, ""); nil == err {
+		t.Errorf("Expected an error for an unnamed world")
+	}
+	frames.Disable()
+}
@@ -0,0 +1,130 @@
+package chrome
+
+import (
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/pprof"
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+)
+
+/*
+NewHeapSampler returns a sampling heap profiler for the tab. interval is the
+average sampling interval in bytes, 0 uses the Chrome default of 32KB.
+*/
+func NewHeapSampler(tab *Tab, interval int) *HeapSampler {
+	if interval <= 0 {
+		interval = pprof.DefaultHeapSamplingInterval
+	}
+	return &HeapSampler{
+		interval: interval,
+		mux:      &sync.Mutex{},
+		tab:      tab,
+	}
+}
+
+/*
+HeapSampler collects a sampling heap profile over a scenario and converts it
+to pprof inuse_objects and inuse_space profiles. A baseline can be taken
+before the scenario to get the allocations the scenario retained:
+
+	sampler := chrome.NewHeapSampler(tab, 0)
+	sampler.Start()
+	sampler.Baseline()
+	// run the scenario
+	diff, _ := sampler.Diff()
+	diff.Write(file) // go tool pprof -http=: file
+*/
+type HeapSampler struct {
+	baseline *pprof.Profile
+	interval int
+	mux      *sync.Mutex
+	tab      *Tab
+}
+
+/*
+Start enables the HeapProfiler domain and starts sampling.
+*/
+func (sampler *HeapSampler) Start() error {
+	if result := <-sampler.tab.HeapProfiler().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.HeapSamplingFailed, "HeapProfiler.enable failed")
+	}
+	if result := <-sampler.tab.HeapProfiler().StartSampling(&heap.StartSamplingParams{
+		SamplingInterval: sampler.interval,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.HeapSamplingFailed, "HeapProfiler.startSampling failed")
+	}
+	return nil
+}
+
+/*
+Profile collects garbage and returns the current in-use heap profile.
+*/
+func (sampler *HeapSampler) Profile() (*pprof.Profile, error) {
+	if result := <-sampler.tab.HeapProfiler().CollectGarbage(); nil != result.Err {
+		return nil, wrapError(result.Err, codes.HeapSamplingFailed, "HeapProfiler.collectGarbage failed")
+	}
+	result := <-sampler.tab.HeapProfiler().GetSamplingProfile(&heap.GetSamplingProfileParams{})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.HeapSamplingFailed, "HeapProfiler.getSamplingProfile failed")
+	}
+	return sampler.convert(result.Profile)
+}
+
+/*
+Baseline stores the current profile as the baseline for Diff().
+*/
+func (sampler *HeapSampler) Baseline() error {
+	profile, err := sampler.Profile()
+	if nil != err {
+		return err
+	}
+	sampler.mux.Lock()
+	sampler.baseline = profile
+	sampler.mux.Unlock()
+	return nil
+}
+
+/*
+Diff returns the current profile minus the baseline.
+*/
+func (sampler *HeapSampler) Diff() (*pprof.Profile, error) {
+	sampler.mux.Lock()
+	baseline := sampler.baseline
+	sampler.mux.Unlock()
+	if nil == baseline {
+		return nil, errs.New(codes.HeapSamplingFailed, "no baseline")
+	}
+	profile, err := sampler.Profile()
+	if nil != err {
+		return nil, err
+	}
+	diff, err := pprof.Diff(baseline, profile)
+	if nil != err {
+		return nil, wrapError(err, codes.HeapSamplingFailed, "could not diff heap profiles")
+	}
+	return diff, nil
+}
+
+/*
+Stop stops sampling and returns the final profile.
+*/
+func (sampler *HeapSampler) Stop() (*pprof.Profile, error) {
+	result := <-sampler.tab.HeapProfiler().StopSampling(&heap.StopSamplingParams{})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.HeapSamplingFailed, "HeapProfiler.stopSampling failed")
+	}
+	return sampler.convert(result.Profile)
+}
+
+/*
+convert converts a sampling heap profile to pprof.
+*/
+func (sampler *HeapSampler) convert(profile *heap.SamplingHeapProfile) (*pprof.Profile, error) {
+	converted, err := pprof.FromHeapProfile(profile, sampler.interval)
+	if nil != err {
+		return nil, wrapError(err, codes.HeapSamplingFailed, "invalid sampling heap profile")
+	}
+	return converted, nil
+}
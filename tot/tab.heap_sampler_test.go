@@ -0,0 +1,159 @@
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/pprof"
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+	"github.com/mkenney/go-chrome/tot/memory"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func testSamplingHeapProfile(leak int) *heap.SamplingHeapProfile {
+	return &heap.SamplingHeapProfile{
+		Head: &heap.SamplingHeapProfileNode{
+			ID:        1,
+			CallFrame: &runtime.CallFrame{FunctionName: "(root)"},
+			Children: []*heap.SamplingHeapProfileNode{{
+				ID:        2,
+				CallFrame: &runtime.CallFrame{FunctionName: "render", URL: "https://example.com/app.js", LineNumber: 4},
+				SelfSize:  1024,
+				Children: []*heap.SamplingHeapProfileNode{{
+					ID:        3,
+					CallFrame: &runtime.CallFrame{FunctionName: "cache", URL: "https://example.com/app.js", LineNumber: 20},
+					SelfSize:  leak,
+				}},
+			}},
+		},
+	}
+}
+
+func TestHeapSampler(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestHeapSampler")
+	interval := 0
+	collected := 0
+	leak := 1024
+	mockSocket.Respond("HeapProfiler.enable", func(params json.RawMessage) (interface{}, error) {
+		return &heap.EnableResult{}, nil
+	})
+	mockSocket.Respond("HeapProfiler.startSampling", func(params json.RawMessage) (interface{}, error) {
+		request := &heap.StartSamplingParams{}
+		json.Unmarshal(params, request)
+		interval = request.SamplingInterval
+		return &heap.StartSamplingResult{}, nil
+	})
+	mockSocket.Respond("HeapProfiler.collectGarbage", func(params json.RawMessage) (interface{}, error) {
+		collected++
+		return &heap.CollectGarbageResult{}, nil
+	})
+	mockSocket.Respond("HeapProfiler.getSamplingProfile", func(params json.RawMessage) (interface{}, error) {
+		if "{}" != string(params) {
+			t.Errorf("Expected no deprecated profile parameter, received %s", params)
+		}
+		profile := testSamplingHeapProfile(leak)
+		leak = 4096
+		return &heap.GetSamplingProfileResult{Profile: profile}, nil
+	})
+	mockSocket.Respond("HeapProfiler.stopSampling", func(params json.RawMessage) (interface{}, error) {
+		if "{}" != string(params) {
+			t.Errorf("Expected no deprecated profile parameter, received %s", params)
+		}
+		return &heap.StopSamplingResult{Profile: testSamplingHeapProfile(8192)}, nil
+	})
+
+	sampler := NewHeapSampler(tab, 0)
+	if _, err := sampler.Diff(); nil == err || codes.HeapSamplingFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected an error without a baseline, received %v", err)
+	}
+	if err := sampler.Start(); nil != err {
+		t.Fatalf("Start failed: %s", err)
+	}
+	if pprof.DefaultHeapSamplingInterval != interval {
+		t.Errorf("Expected the default sampling interval, received %d", interval)
+	}
+	if err := sampler.Baseline(); nil != err {
+		t.Fatalf("Baseline failed: %s", err)
+	}
+	diff, err := sampler.Diff()
+	if nil != err {
+		t.Fatalf("Diff failed: %s", err)
+	}
+	if 2 != collected {
+		t.Errorf("Expected garbage to be collected before each profile, received %d collections", collected)
+	}
+	if 1 != len(diff.Sample) || 3072 != diff.Sample[0].Value[1] || "cache" != diff.Sample[0].Location[0].Line[0].Function.Name {
+		t.Errorf("Expected the retained cache allocations, received %+v", diff.Sample)
+	}
+
+	profile, err := sampler.Stop()
+	if nil != err {
+		t.Fatalf("Stop failed: %s", err)
+	}
+	if 2 != len(profile.Sample) || 8192 != profile.Sample[1].Value[1] {
+		t.Errorf("Unexpected final profile %+v", profile.Sample)
+	}
+}
+
+func TestHeapSamplerErrors(t *testing.T) {
+	methods := []string{
+		"HeapProfiler.enable",
+		"HeapProfiler.startSampling",
+		"HeapProfiler.collectGarbage",
+		"HeapProfiler.getSamplingProfile",
+		"HeapProfiler.stopSampling",
+	}
+	for _, failing := range methods {
+		tab, mockSocket := NewMockTab("https://TestHeapSamplerErrors")
+		for _, method := range methods {
+			method := method
+			mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+				if failing == method {
+					return nil, errors.New("Sampling profiler is not running")
+				}
+				return &heap.StopSamplingResult{Profile: testSamplingHeapProfile(0)}, nil
+			})
+		}
+		sampler := NewHeapSampler(tab, 1024)
+		err := sampler.Start()
+		if nil == err {
+			_, err = sampler.Profile()
+		}
+		if nil == err {
+			_, err = sampler.Stop()
+		}
+		if nil == err || codes.HeapSamplingFailed != err.(errs.Err).Code() {
+			t.Errorf("Expected HeapSamplingFailed for %s, received %v", failing, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), failing+" failed") || !strings.Contains(err.Error(), "Sampling profiler is not running") {
+			t.Errorf("Expected the cause of %s, received %s", failing, err.Error())
+		}
+	}
+
+	tab, mockSocket := NewMockTab("https://TestHeapSamplerErrors")
+	mockSocket.Respond("HeapProfiler.stopSampling", func(params json.RawMessage) (interface{}, error) {
+		return &heap.StopSamplingResult{}, nil
+	})
+	if _, err := NewHeapSampler(tab, 0).Stop(); nil == err || codes.HeapSamplingFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected HeapSamplingFailed for an empty profile, received %v", err)
+	}
+}
+
+func TestGetDOMCounters(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestGetDOMCounters")
+	mockSocket.Respond("Memory.getDOMCounters", func(params json.RawMessage) (interface{}, error) {
+		if "{}" != string(params) {
+			t.Errorf("Expected no deprecated counter parameters, received %s", params)
+		}
+		return &memory.GetDOMCountersResult{Documents: 2, Nodes: 120, JsEventListeners: 7}, nil
+	})
+	result := <-tab.Memory().GetDOMCounters(&memory.GetDOMCountersParams{})
+	if nil != result.Err || 2 != result.Documents || 120 != result.Nodes || 7 != result.JsEventListeners {
+		t.Errorf("Unexpected DOM counters %+v", result)
+	}
+}
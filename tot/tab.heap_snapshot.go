@@ -0,0 +1,78 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	stdio "io"
+	"sync"
+
+	"github.com/mkenney/go-chrome/codes"
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+TakeHeapSnapshot takes a heap snapshot of the tab and streams it to w in the
+.heapsnapshot format, which can be loaded in the DevTools Memory panel or
+analysed with the heapsnapshot package. Snapshots of large heaps are hundreds
+of megabytes, chunks are written as they arrive instead of being buffered.
+
+If progress is not nil it is called with the number of nodes processed while
+Chrome builds the snapshot.
+*/
+func (tab *Tab) TakeHeapSnapshot(
+	ctx context.Context,
+	w stdio.Writer,
+	progress func(done, total int),
+) error {
+	var writeErr error
+	mux := &sync.Mutex{}
+	chunks := socket.NewOrderedEventHandler("HeapProfiler.addHeapSnapshotChunk", func(response *socket.Response) {
+		event := &heap.AddHeapSnapshotChunkEvent{}
+		if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		if nil == writeErr {
+			_, writeErr = stdio.WriteString(w, event.Chunk)
+		}
+	})
+	reports := socket.NewOrderedEventHandler("HeapProfiler.reportHeapSnapshotProgress", func(response *socket.Response) {
+		event := &heap.ReportHeapSnapshotProgressEvent{}
+		if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+			return
+		}
+		progress(event.Done, event.Total)
+	})
+	tab.AddEventHandler(chunks)
+	defer tab.RemoveEventHandler(chunks)
+	if nil != progress {
+		tab.AddEventHandler(reports)
+		defer tab.RemoveEventHandler(reports)
+	}
+
+	if result := <-tab.HeapProfiler().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.HeapSnapshotFailed, "HeapProfiler.enable failed")
+	}
+	select {
+	case result := <-tab.HeapProfiler().TakeHeapSnapshot(&heap.TakeHeapSnapshotParams{
+		ReportProgress: nil != progress,
+	}):
+		if nil != result.Err {
+			return wrapError(result.Err, codes.HeapSnapshotFailed, "HeapProfiler.takeHeapSnapshot failed")
+		}
+	case <-ctx.Done():
+		return wrapError(ctx.Err(), codes.HeapSnapshotFailed, "heap snapshot did not complete")
+	}
+
+	// All chunks are sent before the command result.
+	chunks.Wait()
+	reports.Wait()
+	mux.Lock()
+	defer mux.Unlock()
+	if nil != writeErr {
+		return wrapError(writeErr, codes.HeapSnapshotFailed, "could not write heap snapshot")
+	}
+	return nil
+}
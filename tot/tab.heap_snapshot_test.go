@@ -0,0 +1,89 @@
+package chrome
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(data []byte) (int, error) {
+	return 0, fmt.Errorf("disk full")
+}
+
+func TestTabTakeHeapSnapshot(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestTabTakeHeapSnapshot")
+	mockSocket.Respond("HeapProfiler.enable", func(params json.RawMessage) (interface{}, error) {
+		return &heap.EnableResult{}, nil
+	})
+	mockSocket.Respond("HeapProfiler.takeHeapSnapshot", func(params json.RawMessage) (interface{}, error) {
+		take := &heap.TakeHeapSnapshotParams{}
+		json.Unmarshal(params, take)
+		if take.ReportProgress {
+			for done := 0; done <= 100; done += 50 {
+				mockSocket.Fire("HeapProfiler.reportHeapSnapshotProgress", &heap.ReportHeapSnapshotProgressEvent{
+					Done:     done,
+					Total:    100,
+					Finished: 100 == done,
+				})
+			}
+		}
+		for a := 0; a < 100; a++ {
+			mockSocket.Fire("HeapProfiler.addHeapSnapshotChunk", &heap.AddHeapSnapshotChunkEvent{
+				Chunk: fmt.Sprintf("%d,", a),
+			})
+		}
+		return &heap.TakeHeapSnapshotResult{}, nil
+	})
+
+	expected := ""
+	for a := 0; a < 100; a++ {
+		expected += fmt.Sprintf("%d,", a)
+	}
+	buf := &bytes.Buffer{}
+	reports := []int{}
+	err := tab.TakeHeapSnapshot(context.Background(), buf, func(done, total int) {
+		if 100 != total {
+			t.Errorf("Expected a total of 100, received %d", total)
+		}
+		reports = append(reports, done)
+	})
+	if nil != err {
+		t.Fatalf("TakeHeapSnapshot failed: %s", err)
+	}
+	if expected != buf.String() {
+		t.Errorf("Expected the chunks in order, received %s", buf.String())
+	}
+	if 3 != len(reports) || 100 != reports[2] {
+		t.Errorf("Expected 3 progress reports, received %v", reports)
+	}
+
+	buf.Reset()
+	if err := tab.TakeHeapSnapshot(context.Background(), buf, nil); nil != err {
+		t.Fatalf("TakeHeapSnapshot failed: %s", err)
+	}
+	if expected != buf.String() {
+		t.Errorf("Expected the chunks of the second snapshot only, received %s", buf.String())
+	}
+
+	if err := tab.TakeHeapSnapshot(context.Background(), failingWriter{}, nil); nil == err {
+		t.Errorf("Expected the write error to be returned")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	release := make(chan bool)
+	defer close(release)
+	mockSocket.Respond("HeapProfiler.takeHeapSnapshot", func(params json.RawMessage) (interface{}, error) {
+		<-release
+		return &heap.TakeHeapSnapshotResult{}, nil
+	})
+	if err := tab.TakeHeapSnapshot(ctx, buf, nil); nil == err {
+		t.Errorf("Expected a cancelled snapshot to fail")
+	}
+}
@@ -0,0 +1,559 @@
+package chrome
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+NewHotReloader returns a hot reloader for the tab.
+*/
+func NewHotReloader(tab *Tab) *HotReloader {
+	return &HotReloader{
+		Interval: 500 * time.Millisecond,
+		Reload:   true,
+		contexts: map[runtime.ExecutionContextID]bool{},
+		debugger: NewDebugger(tab),
+		files:    map[string]os.FileInfo{},
+		mux:      &sync.Mutex{},
+		tab:      tab,
+		watchMux: &sync.Mutex{},
+	}
+}
+
+/*
+HotReloader pushes local edits of the page scripts into the running page.
+Script URLs are mapped to local files by URL prefix rules, when a file
+changes the new source is applied with Debugger.setScriptSource:
+
+	reloader := chrome.NewHotReloader(tab)
+	reloader.AddRule("http://localhost:8080/static/", "./web/static")
+	reloader.OnReload(func(reload *chrome.HotReload) {
+		if nil != reload.Err {
+			log.Error(reload.Err)
+		}
+	})
+	reloader.Start()
+	defer reloader.Stop()
+
+Live edits replace function bodies, code that already ran is not run again.
+Edits of the top-level code of a script and edits that change the call stack
+of a paused page reload the page instead, unless Reload is false.
+*/
+type HotReloader struct {
+	// Interval is the interval of the file checks.
+	Interval time.Duration
+
+	// Reload enables reloading the page when an edit can not be applied
+	// live.
+	Reload bool
+
+	callbacks []func(reload *HotReload)
+	contexts  map[runtime.ExecutionContextID]bool
+	debugger  *Debugger
+	files     map[string]os.FileInfo
+	handlers  []socket.EventHandler
+	mux       *sync.Mutex
+	rules     []*hotReloadRule
+	started   bool
+	stop      chan bool
+	tab       *Tab
+	watchMux  *sync.Mutex
+}
+
+/*
+hotReloadRule maps the script URLs starting with a prefix to a directory.
+*/
+type hotReloadRule struct {
+	prefix string
+	dir    string
+}
+
+/*
+HotReload is the result of pushing a file into the page.
+*/
+type HotReload struct {
+	// Path is the local path of the file.
+	Path string
+
+	// URL is the URL of the edited scripts.
+	URL string
+
+	// Scripts are the IDs of the edited scripts.
+	Scripts []runtime.ScriptID
+
+	// Time is the time of the update.
+	Time time.Time
+
+	// Applied is true if the source was applied live.
+	Applied bool
+
+	// TopLevel is true if the top-level code of the script changed.
+	TopLevel bool
+
+	// StackChanged is true if the live edit changed the call stack of the
+	// paused page.
+	StackChanged bool
+
+	// Reloaded is true if the page was reloaded.
+	Reloaded bool
+
+	// CompileError is the compile error of the new source, if any.
+	CompileError *runtime.ExceptionDetails
+
+	// Err is the error of the update, if any.
+	Err error
+}
+
+/*
+AddRule maps the script URLs starting with a prefix to a local directory, the
+rest of the URL path is the path of the file in the directory. The longest
+matching prefix is used.
+*/
+func (reloader *HotReloader) AddRule(prefix, dir string) {
+	reloader.mux.Lock()
+	defer reloader.mux.Unlock()
+	reloader.rules = append(reloader.rules, &hotReloadRule{prefix: prefix, dir: dir})
+	sort.SliceStable(reloader.rules, func(a, b int) bool {
+		return len(reloader.rules[a].prefix) > len(reloader.rules[b].prefix)
+	})
+}
+
+/*
+Path returns the local path of a script URL.
+*/
+func (reloader *HotReloader) Path(scriptURL string) (string, bool) {
+	if index := strings.IndexAny(scriptURL, "?#"); index >= 0 {
+		scriptURL = scriptURL[:index]
+	}
+	reloader.mux.Lock()
+	rules := reloader.rules
+	reloader.mux.Unlock()
+	for _, rule := range rules {
+		if !strings.HasPrefix(scriptURL, rule.prefix) {
+			continue
+		}
+		file, err := url.PathUnescape(strings.TrimPrefix(scriptURL, rule.prefix))
+		if nil != err || "" == file || strings.HasSuffix(file, "/") {
+			return "", false
+		}
+		path := filepath.Join(rule.dir, filepath.FromSlash(file))
+		// The URL path must not leave the directory.
+		if rel, err := filepath.Rel(rule.dir, path); nil != err || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		return path, true
+	}
+	return "", false
+}
+
+/*
+OnReload adds a callback that is called for each file change detected by the
+watcher.
+*/
+func (reloader *HotReloader) OnReload(callback func(reload *HotReload)) {
+	reloader.mux.Lock()
+	reloader.callbacks = append(reloader.callbacks, callback)
+	reloader.mux.Unlock()
+}
+
+/*
+Start enables the debugger and starts watching the files of the parsed
+scripts.
+*/
+func (reloader *HotReloader) Start() error {
+	reloader.mux.Lock()
+	if reloader.started {
+		reloader.mux.Unlock()
+		return nil
+	}
+	reloader.started = true
+	reloader.stop = make(chan bool)
+	reloader.handlers = []socket.EventHandler{
+		socket.NewOrderedEventHandler("Runtime.executionContextCreated", reloader.onContext),
+		socket.NewOrderedEventHandler("Runtime.executionContextDestroyed", reloader.onContext),
+		socket.NewOrderedEventHandler("Runtime.executionContextsCleared", reloader.onContext),
+	}
+	stop := reloader.stop
+	interval := reloader.Interval
+	reloader.mux.Unlock()
+
+	for _, handler := range reloader.handlers {
+		reloader.tab.AddEventHandler(handler)
+	}
+	if err := reloader.debugger.Enable(); nil != err {
+		return wrapError(err, codes.HotReloadFailed, "could not enable the debugger")
+	}
+	if result := <-reloader.tab.Runtime().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.HotReloadFailed, "Runtime.enable failed")
+	}
+	reloader.poll(false)
+	go reloader.watch(stop, interval)
+	return nil
+}
+
+/*
+Stop stops watching the files and disables the debugger.
+*/
+func (reloader *HotReloader) Stop() error {
+	reloader.mux.Lock()
+	if !reloader.started {
+		reloader.mux.Unlock()
+		return nil
+	}
+	reloader.started = false
+	close(reloader.stop)
+	handlers := reloader.handlers
+	reloader.handlers = nil
+	reloader.mux.Unlock()
+
+	for _, handler := range handlers {
+		reloader.tab.RemoveEventHandler(handler)
+	}
+	if err := reloader.debugger.Disable(); nil != err {
+		return wrapError(err, codes.HotReloadFailed, "could not disable the debugger")
+	}
+	return nil
+}
+
+func (reloader *HotReloader) onContext(response *socket.Response) {
+	// Only the context IDs are decoded.
+	event := &struct {
+		Context *struct {
+			ID runtime.ExecutionContextID `json:"id"`
+		} `json:"context"`
+		ExecutionContextID runtime.ExecutionContextID `json:"executionContextId"`
+	}{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+	reloader.mux.Lock()
+	defer reloader.mux.Unlock()
+	switch response.Method {
+	case "Runtime.executionContextCreated":
+		if nil != event.Context {
+			reloader.contexts[event.Context.ID] = true
+		}
+	case "Runtime.executionContextDestroyed":
+		delete(reloader.contexts, event.ExecutionContextID)
+	case "Runtime.executionContextsCleared":
+		reloader.contexts = map[runtime.ExecutionContextID]bool{}
+	}
+}
+
+/*
+scripts returns the scripts of the live execution contexts by local path.
+Scripts of destroyed contexts, e.g. from before a navigation, can not be
+edited.
+*/
+func (reloader *HotReloader) scripts() map[string][]*debugger.ScriptParsedEvent {
+	reloader.mux.Lock()
+	handlers := reloader.handlers
+	reloader.mux.Unlock()
+	for _, handler := range handlers {
+		handler.(*socket.OrderedHandler).Wait()
+	}
+
+	live := []*debugger.ScriptParsedEvent{}
+	parsed := reloader.debugger.Scripts()
+	reloader.mux.Lock()
+	for _, script := range parsed {
+		if reloader.contexts[script.ExecutionContextID] {
+			live = append(live, script)
+		}
+	}
+	reloader.mux.Unlock()
+
+	scripts := map[string][]*debugger.ScriptParsedEvent{}
+	for _, script := range live {
+		if path, ok := reloader.Path(script.URL); ok {
+			scripts[path] = append(scripts[path], script)
+		}
+	}
+	return scripts
+}
+
+func (reloader *HotReloader) watch(stop chan bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloader.poll(true)
+		}
+	}
+}
+
+/*
+poll checks the files of the parsed scripts for changes. Files seen for the
+first time are recorded without an update.
+*/
+func (reloader *HotReloader) poll(notify bool) {
+	reloader.watchMux.Lock()
+	defer reloader.watchMux.Unlock()
+	for path, scripts := range reloader.scripts() {
+		info, err := os.Stat(path)
+		if nil != err {
+			delete(reloader.files, path)
+			continue
+		}
+		previous, ok := reloader.files[path]
+		reloader.files[path] = info
+		if !ok || !notify || (previous.ModTime().Equal(info.ModTime()) && previous.Size() == info.Size()) {
+			continue
+		}
+		reload := reloader.update(path, scripts)
+		reloader.mux.Lock()
+		callbacks := reloader.callbacks
+		reloader.mux.Unlock()
+		for _, callback := range callbacks {
+			callback(reload)
+		}
+	}
+}
+
+/*
+Update pushes the current source of a local file into the page, regardless
+of whether it changed. The path is compared to the paths mapped by the rules.
+*/
+func (reloader *HotReloader) Update(path string) *HotReload {
+	path = filepath.Clean(path)
+	// The watcher and Update push one file at a time.
+	reloader.watchMux.Lock()
+	defer reloader.watchMux.Unlock()
+	scripts, ok := reloader.scripts()[path]
+	if !ok {
+		return &HotReload{
+			Path: path,
+			Time: time.Now(),
+			Err:  errs.New(codes.HotReloadFailed, fmt.Sprintf("no script is loaded from '%s'", path)),
+		}
+	}
+	return reloader.update(path, scripts)
+}
+
+func (reloader *HotReloader) update(path string, scripts []*debugger.ScriptParsedEvent) *HotReload {
+	reload := &HotReload{
+		Path: path,
+		URL:  scripts[0].URL,
+		Time: time.Now(),
+	}
+	for _, script := range scripts {
+		reload.Scripts = append(reload.Scripts, script.ScriptID)
+	}
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		reload.Err = wrapError(err, codes.HotReloadFailed, "could not read the script")
+		return reload
+	}
+	source := string(data)
+	current, err := reloader.debugger.ScriptSource(scripts[0].ScriptID)
+	if nil != err {
+		reload.Err = wrapError(err, codes.HotReloadFailed, "could not get the script source")
+		return reload
+	}
+	if current == source {
+		return reload
+	}
+	if topLevelSource(current) != topLevelSource(source) {
+		reload.TopLevel = true
+		return reloader.reload(reload, nil)
+	}
+
+	// A dry run first, so that a compile error does not leave some of the
+	// scripts edited. The scripts share the source, one is checked.
+	for pass, targets := range [][]*debugger.ScriptParsedEvent{scripts[:1], scripts} {
+		dryRun := 0 == pass
+		for _, script := range targets {
+			result := <-reloader.tab.Debugger().SetScriptSource(&debugger.SetScriptSourceParams{
+				ScriptID:     script.ScriptID,
+				ScriptSource: source,
+				DryRun:       dryRun,
+			})
+			if nil != result.Err {
+				return reloader.reload(reload, wrapError(result.Err, codes.HotReloadFailed, "Debugger.setScriptSource failed"))
+			}
+			if nil != result.ExceptionDetails {
+				reload.CompileError = result.ExceptionDetails
+				reload.Err = errs.New(codes.HotReloadFailed, fmt.Sprintf(
+					"%s:%d:%d: %s",
+					path,
+					result.ExceptionDetails.LineNumber+1,
+					result.ExceptionDetails.ColumnNumber+1,
+					result.ExceptionDetails.Text,
+				))
+				return reload
+			}
+			if !dryRun {
+				reload.StackChanged = reload.StackChanged || result.StackChanged
+			}
+		}
+	}
+	reload.Applied = true
+	if reload.StackChanged {
+		return reloader.reload(reload, nil)
+	}
+	return reload
+}
+
+/*
+reload reloads the page for an edit that can not be applied live. cause is
+the error of the live edit, if any.
+*/
+func (reloader *HotReloader) reload(reload *HotReload, cause error) *HotReload {
+	if !reloader.Reload {
+		reload.Err = cause
+		if nil == reload.Err {
+			reload.Err = errs.New(codes.HotReloadFailed, fmt.Sprintf("the edit of '%s' requires a reload", reload.Path))
+		}
+		return reload
+	}
+	if result := <-reloader.tab.Page().Reload(&page.ReloadParams{IgnoreCache: true}); nil != result.Err {
+		reload.Err = wrapError(result.Err, codes.HotReloadFailed, "Page.reload failed")
+		return reload
+	}
+	reload.Reloaded = true
+	return reload
+}
+
+/*
+controlKeywords are the keywords followed by a parenthesized expression and
+a block that is not a function body.
+*/
+var controlKeywords = map[string]bool{
+	"catch":  true,
+	"for":    true,
+	"if":     true,
+	"switch": true,
+	"while":  true,
+	"with":   true,
+}
+
+/*
+topLevelSource returns the top-level code of a script without comments,
+whitespace and function bodies, which is the code a live edit can not
+update. A brace opens a function body if it follows an arrow or the
+parameter list of a function or method. It is a heuristic, regular
+expression literals containing quotes are not recognized.
+*/
+func topLevelSource(source string) string {
+	skeleton := &bytes.Buffer{}
+	depth := 0
+	space := false
+	var last, prev byte
+	word, closed := "", ""
+	parens := []string{}
+	for a := 0; a < len(source); a++ {
+		c := source[a]
+		switch {
+		case '/' == c && a+1 < len(source) && '/' == source[a+1]:
+			for a < len(source) && '\n' != source[a] {
+				a++
+			}
+			space = true
+			continue
+		case '/' == c && a+1 < len(source) && '*' == source[a+1]:
+			end := strings.Index(source[a+2:], "*/")
+			if end < 0 {
+				a = len(source)
+			} else {
+				a += end + 3
+			}
+			space = true
+			continue
+		case ' ' == c || '\t' == c || '\n' == c || '\r' == c:
+			space = true
+			continue
+		case '"' == c || '\'' == c || '`' == c:
+			end := a + 1
+			for end < len(source) && c != source[end] {
+				if '\\' == source[end] {
+					end++
+				}
+				end++
+			}
+			if end >= len(source) {
+				end = len(source) - 1
+			}
+			if 0 == depth {
+				skeleton.WriteString(source[a : end+1])
+			}
+			a = end
+			prev, last, word, space = last, c, "", false
+			continue
+		}
+
+		if depth > 0 {
+			if '{' == c {
+				depth++
+			} else if '}' == c {
+				depth--
+			}
+			if 0 == depth {
+				prev, last, word = last, c, ""
+			}
+			space = false
+			continue
+		}
+
+		switch c {
+		case '(':
+			name := ""
+			if isIdentByte(last) {
+				name = word
+			}
+			parens = append(parens, name)
+		case ')':
+			closed = ""
+			if len(parens) > 0 {
+				closed = parens[len(parens)-1]
+				parens = parens[:len(parens)-1]
+			}
+		case '{':
+			if (')' == last && !controlKeywords[closed]) || ('>' == last && '=' == prev) {
+				skeleton.WriteString("{}")
+				depth = 1
+				space = false
+				continue
+			}
+		}
+		if isIdentByte(c) {
+			if !isIdentByte(last) || space {
+				word = ""
+			}
+			word += string(c)
+		}
+		if space && isIdentByte(last) && isIdentByte(c) {
+			skeleton.WriteByte(' ')
+		}
+		skeleton.WriteByte(c)
+		prev, last, space = last, c, false
+	}
+	return skeleton.String()
+}
+
+/*
+isIdentByte returns whether a byte can be part of a JavaScript identifier.
+*/
+func isIdentByte(c byte) bool {
+	return '_' == c || '$' == c || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,189 @@
+package chrome
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/page"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func TestHotReloader(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestHotReloader")
+	mux := &sync.Mutex{}
+	source := "function tick() { return 1 }\nvar x = 1;\n"
+	edits := []*debugger.SetScriptSourceParams{}
+	reloads := 0
+	for _, method := range []string{"Runtime.enable", "Debugger.enable", "Debugger.disable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("Debugger.getScriptSource", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		return &debugger.GetScriptSourceResult{ScriptSource: source}, nil
+	})
+	mockSocket.Respond("Debugger.setScriptSource", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.SetScriptSourceParams{}
+		json.Unmarshal(params, request)
+		mux.Lock()
+		defer mux.Unlock()
+		edits = append(edits, request)
+		if strings.Contains(request.ScriptSource, "return (") {
+			return &debugger.SetScriptSourceResult{ExceptionDetails: &runtime.ExceptionDetails{
+				Text: "SyntaxError: Unexpected token }", LineNumber: 0, ColumnNumber: 26,
+			}}, nil
+		}
+		if !request.DryRun {
+			source = request.ScriptSource
+		}
+		return &debugger.SetScriptSourceResult{StackChanged: strings.Contains(request.ScriptSource, "return 3")}, nil
+	})
+	mockSocket.Respond("Page.reload", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		reloads++
+		mux.Unlock()
+		return &page.ReloadResult{}, nil
+	})
+
+	dir, err := ioutil.TempDir("", "hot-reload")
+	if nil != err {
+		t.Fatalf("TempDir failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "js"), 0755)
+	path := filepath.Join(dir, "js", "app.js")
+	write := func(text string) {
+		if err := ioutil.WriteFile(path, []byte(text), 0644); nil != err {
+			t.Fatalf("WriteFile failed: %s", err)
+		}
+	}
+	write(source)
+
+	reloader := NewHotReloader(tab)
+	// The test polls the files itself.
+	reloader.Interval = time.Hour
+	reloader.AddRule("https://example.com/", "/srv/root")
+	reloader.AddRule("https://example.com/static/", dir)
+	if p, ok := reloader.Path("https://example.com/static/js/app.js?v=2"); !ok || path != p {
+		t.Errorf("Unexpected path %s", p)
+	}
+	for _, scriptURL := range []string{"https://other.com/static/js/app.js", "https://example.com/static/", "https://example.com/static/%2e%2e/%2e%2e/etc/passwd"} {
+		if p, ok := reloader.Path(scriptURL); ok {
+			t.Errorf("Expected no path for %s, received %s", scriptURL, p)
+		}
+	}
+	reports := make(chan *HotReload, 1)
+	reloader.OnReload(func(reload *HotReload) {
+		reports <- reload
+	})
+	if err := reloader.Start(); nil != err {
+		t.Fatalf("Start failed: %s", err)
+	}
+	defer reloader.Stop()
+
+	mockSocket.Fire("Runtime.executionContextCreated", map[string]interface{}{
+		"context": map[string]interface{}{"id": 1, "origin": "https://example.com", "name": ""},
+	})
+	for id, context := range map[string]int{"1": 1, "2": 2} {
+		mockSocket.Fire("Debugger.scriptParsed", map[string]interface{}{
+			"scriptId":           id,
+			"url":                "https://example.com/static/js/app.js?v=2",
+			"executionContextId": context,
+		})
+	}
+
+	// Function body edits are applied live, the scripts of destroyed
+	// contexts are ignored.
+	write("function tick() { return 2 }\nvar x = 1;\n")
+	reload := reloader.Update(path)
+	if nil != reload.Err || !reload.Applied || reload.Reloaded || 1 != len(reload.Scripts) || "1" != reload.Scripts[0] {
+		t.Errorf("Expected a live edit, received %v", reload)
+	}
+	mux.Lock()
+	if 2 != len(edits) || !edits[0].DryRun || edits[1].DryRun {
+		t.Errorf("Expected a dry run and an edit, received %d edits", len(edits))
+	}
+	mux.Unlock()
+
+	// Compile errors are reported without reloading.
+	write("function tick() { return ( }\nvar x = 1;\n")
+	reload = reloader.Update(path)
+	if nil == reload.Err || nil == reload.CompileError || reload.Applied || reload.Reloaded ||
+		!strings.Contains(reload.Err.Error(), "app.js:1:27: SyntaxError") {
+		t.Errorf("Expected a compile error, received %v", reload)
+	}
+
+	// Edits of the top-level code and stack changes reload the page.
+	write("function tick() { return 2 }\nvar x = 2;\n")
+	reload = reloader.Update(path)
+	if nil != reload.Err || !reload.TopLevel || reload.Applied || !reload.Reloaded {
+		t.Errorf("Expected a reload for a top-level edit, received %v", reload)
+	}
+	reloadCount := func() int {
+		mux.Lock()
+		defer mux.Unlock()
+		return reloads
+	}
+	write("function tick() { return 3 }\nvar x = 1;\n")
+	reload = reloader.Update(path)
+	if nil != reload.Err || !reload.StackChanged || !reload.Applied || !reload.Reloaded || 2 != reloadCount() {
+		t.Errorf("Expected a reload for a stack change, received %v", reload)
+	}
+	reloader.Reload = false
+	write("function tick() { return 3 }\nvar x = 2;\n")
+	if reload = reloader.Update(path); nil == reload.Err || reload.Reloaded || 2 != reloadCount() {
+		t.Errorf("Expected an error without reloads, received %v", reload)
+	}
+	reloader.Reload = true
+
+	// The watcher detects file changes once it has seen the file.
+	reloader.poll(true)
+	write("function tick() { return 4 }\nvar x = 1;\n")
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+	reloader.poll(true)
+	select {
+	case reload = <-reports:
+		if nil != reload.Err || !reload.Applied || path != reload.Path {
+			t.Errorf("Unexpected watcher update %v", reload)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the watcher")
+	}
+
+	mockSocket.Fire("Runtime.executionContextsCleared", map[string]interface{}{})
+	if reload = reloader.Update(path); nil == reload.Err || 0 != len(reload.Scripts) {
+		t.Errorf("Expected an error after the contexts were cleared, received %v", reload)
+	}
+}
+
+func TestTopLevelSource(t *testing.T) {
+	for _, test := range []struct {
+		old, new string
+		same     bool
+	}{
+		{"function a() { return 1 }", "function a() {\n  // comment\n  return 2\n}", true},
+		{"var a = 1", "var  a = 1 // one", true},
+		{"var a = 1", "var a = 2", false},
+		{"var f = (x) => { return x }", "var f = (x) => { return x + 1 }", true},
+		{"(function () { var a = '}'; })()", "(function () { var a = \"{\"; })()", true},
+		{"class A { b() { return 1 } }", "class A { b() { return 2 } }", true},
+		{"class A { b() { return 1 } }", "class A { c() { return 1 } }", false},
+		{"if (a) { b() }", "if (a) { c() }", false},
+		{"var s = 'a'", "var s = 'b'", false},
+		{"var s = `a${b}`", "var s = `a${c}`", false},
+	} {
+		if same := topLevelSource(test.old) == topLevelSource(test.new); test.same != same {
+			t.Errorf("Expected %v for %q and %q: %q %q", test.same, test.old, test.new, topLevelSource(test.old), topLevelSource(test.new))
+		}
+	}
+}
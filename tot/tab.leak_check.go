@@ -0,0 +1,353 @@
+package chrome
+
+import (
+	"context"
+	"fmt"
+	stdio "io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/heapsnapshot"
+	"github.com/mkenney/go-chrome/tot/memory"
+)
+
+/*
+NewLeakCheck returns a leak check harness that runs a user flow the specified
+number of times.
+*/
+func NewLeakCheck(tab *Tab, iterations int) *LeakCheck {
+	if iterations < 2 {
+		iterations = 2
+	}
+	return &LeakCheck{
+		Ignore:     []string{"(system)", "(compiled code)"},
+		Paths:      3,
+		Warmup:     1,
+		iterations: iterations,
+		tab:        tab,
+	}
+}
+
+/*
+LeakCheck detects memory leaks in a repeated user flow, e.g. opening and
+closing a dialog. The flow is run a number of times with a garbage collection
+after each run. Heap objects and DOM counters that grow by the same amount in
+every iteration are reported as leaks:
+
+	check := chrome.NewLeakCheck(tab, 5)
+	report, err := check.Run(ctx, func() error {
+		// open and close the dialog
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	report.Assert(t)
+*/
+type LeakCheck struct {
+	// Ignore contains class names that are never reported. By default the
+	// internal V8 classes (system) and (compiled code) are ignored.
+	Ignore []string
+
+	// Paths is the maximum number of retainer paths reported per class.
+	Paths int
+
+	// Warmup is the number of runs before the first snapshot, so that
+	// caches and lazily initialized objects are not reported.
+	Warmup int
+
+	iterations int
+	tab        *Tab
+}
+
+/*
+DOMCounters contains the result of Memory.getDOMCounters.
+*/
+type DOMCounters struct {
+	Documents        int
+	Nodes            int
+	JSEventListeners int
+}
+
+/*
+LeakReport is the result of a leak check.
+*/
+type LeakReport struct {
+	// Iterations is the number of measured runs.
+	Iterations int
+
+	// Prepared is true if Memory.prepareForLeakDetection was available.
+	Prepared bool
+
+	// Counters contains the DOM counters before the first and after each
+	// measured run.
+	Counters []DOMCounters
+
+	// Start is the heap snapshot before the first measured run.
+	Start *heapsnapshot.Snapshot
+
+	// End is the heap snapshot after the last run.
+	End *heapsnapshot.Snapshot
+
+	// Objects contains the classes of the leaked heap objects.
+	Objects []*LeakedClass
+
+	// DOM contains the leaked DOM counters.
+	DOM []*LeakedCounter
+}
+
+/*
+LeakedClass describes the heap objects of a class that were retained by every
+run.
+*/
+type LeakedClass struct {
+	// Name is the class name.
+	Name string
+
+	// PerIteration is the number of objects retained by each run.
+	PerIteration int
+
+	// Size is the total self size of the retained objects.
+	Size int64
+
+	// Paths contains retainer paths of the retained objects.
+	Paths []string
+}
+
+/*
+LeakedCounter describes a DOM counter that grew by the same amount in every
+run.
+*/
+type LeakedCounter struct {
+	// Name is the counter name: documents, nodes or jsEventListeners.
+	Name string
+
+	// Start is the value before the first measured run.
+	Start int
+
+	// End is the value after the last run.
+	End int
+
+	// PerIteration is the growth per run.
+	PerIteration int
+}
+
+/*
+Run runs the flow and reports the leaks.
+*/
+func (check *LeakCheck) Run(ctx context.Context, action func() error) (*LeakReport, error) {
+	report := &LeakReport{
+		Iterations: check.iterations,
+		Prepared:   true,
+	}
+	for a := 0; a < check.Warmup; a++ {
+		if err := action(); nil != err {
+			return nil, wrapError(err, codes.LeakCheckFailed, fmt.Sprintf("warmup run %d failed", a+1))
+		}
+	}
+
+	counters, err := check.measure(report)
+	if nil != err {
+		return nil, err
+	}
+	report.Counters = append(report.Counters, counters)
+	if report.Start, err = check.snapshot(ctx); nil != err {
+		return nil, err
+	}
+
+	for a := 0; a < check.iterations; a++ {
+		if nil != ctx.Err() {
+			return nil, wrapError(ctx.Err(), codes.LeakCheckFailed, "leak check did not complete")
+		}
+		if err := action(); nil != err {
+			return nil, wrapError(err, codes.LeakCheckFailed, fmt.Sprintf("run %d failed", a+1))
+		}
+		counters, err := check.measure(report)
+		if nil != err {
+			return nil, err
+		}
+		report.Counters = append(report.Counters, counters)
+	}
+	if report.End, err = check.snapshot(ctx); nil != err {
+		return nil, err
+	}
+
+	report.DOM = check.leakedCounters(report.Counters)
+	report.Objects = check.leakedClasses(report.Start, report.End)
+	return report, nil
+}
+
+/*
+measure collects garbage and reads the DOM counters. Memory.prepareForLeakDetection
+also clears caches that would otherwise retain DOM nodes, it is skipped once
+it failed.
+*/
+func (check *LeakCheck) measure(report *LeakReport) (DOMCounters, error) {
+	if report.Prepared {
+		if result := <-check.tab.Memory().PrepareForLeakDetection(); nil != result.Err {
+			report.Prepared = false
+		}
+	}
+	if result := <-check.tab.HeapProfiler().CollectGarbage(); nil != result.Err {
+		return DOMCounters{}, wrapError(result.Err, codes.LeakCheckFailed, "HeapProfiler.collectGarbage failed")
+	}
+	result := <-check.tab.Memory().GetDOMCounters(&memory.GetDOMCountersParams{})
+	if nil != result.Err {
+		return DOMCounters{}, wrapError(result.Err, codes.LeakCheckFailed, "Memory.getDOMCounters failed")
+	}
+	return DOMCounters{
+		Documents:        result.Documents,
+		Nodes:            result.Nodes,
+		JSEventListeners: result.JsEventListeners,
+	}, nil
+}
+
+/*
+snapshot takes a heap snapshot and parses it while it is streamed.
+*/
+func (check *LeakCheck) snapshot(ctx context.Context) (*heapsnapshot.Snapshot, error) {
+	reader, writer := stdio.Pipe()
+	type parsed struct {
+		snapshot *heapsnapshot.Snapshot
+		err      error
+	}
+	parsedChan := make(chan parsed)
+	go func() {
+		snapshot, err := heapsnapshot.Parse(reader)
+		// Drain the remaining chunks if parsing failed.
+		stdio.Copy(ioutil.Discard, reader)
+		parsedChan <- parsed{snapshot, err}
+	}()
+
+	err := check.tab.TakeHeapSnapshot(ctx, writer, nil)
+	writer.CloseWithError(err)
+	result := <-parsedChan
+	if nil != err {
+		return nil, wrapError(err, codes.LeakCheckFailed, "could not take heap snapshot")
+	}
+	if nil != result.err {
+		return nil, wrapError(result.err, codes.LeakCheckFailed, "could not parse heap snapshot")
+	}
+	return result.snapshot, nil
+}
+
+/*
+leakedCounters returns the counters that grew by the same amount in every run.
+*/
+func (check *LeakCheck) leakedCounters(counters []DOMCounters) []*LeakedCounter {
+	leaked := []*LeakedCounter{}
+	for _, counter := range []struct {
+		name  string
+		value func(counters DOMCounters) int
+	}{
+		{"documents", func(counters DOMCounters) int { return counters.Documents }},
+		{"nodes", func(counters DOMCounters) int { return counters.Nodes }},
+		{"jsEventListeners", func(counters DOMCounters) int { return counters.JSEventListeners }},
+	} {
+		growth := counter.value(counters[1]) - counter.value(counters[0])
+		if growth <= 0 {
+			continue
+		}
+		constant := true
+		for a := 2; a < len(counters); a++ {
+			if growth != counter.value(counters[a])-counter.value(counters[a-1]) {
+				constant = false
+				break
+			}
+		}
+		if constant {
+			leaked = append(leaked, &LeakedCounter{
+				Name:         counter.name,
+				Start:        counter.value(counters[0]),
+				End:          counter.value(counters[len(counters)-1]),
+				PerIteration: growth,
+			})
+		}
+	}
+	return leaked
+}
+
+/*
+leakedClasses returns the classes with a number of new objects that is a
+multiple of the number of runs.
+*/
+func (check *LeakCheck) leakedClasses(start, end *heapsnapshot.Snapshot) []*LeakedClass {
+	ignore := map[string]bool{}
+	for _, name := range check.Ignore {
+		ignore[name] = true
+	}
+	leaked := []*LeakedClass{}
+	for _, delta := range heapsnapshot.Compare(start, end) {
+		count := delta.Count()
+		if ignore[delta.Name] || count < check.iterations || 0 != count%check.iterations {
+			continue
+		}
+		class := &LeakedClass{
+			Name:         delta.Name,
+			PerIteration: count / check.iterations,
+			Size:         delta.SizeDelta,
+		}
+		seen := map[string]bool{}
+		for _, node := range delta.New {
+			if len(class.Paths) >= check.Paths {
+				break
+			}
+			path := node.RetainerPath().String()
+			if "" != path && !seen[path] {
+				seen[path] = true
+				class.Paths = append(class.Paths, path)
+			}
+		}
+		leaked = append(leaked, class)
+	}
+	return leaked
+}
+
+/*
+Leaked checks if any leaks were detected.
+*/
+func (report *LeakReport) Leaked() bool {
+	return len(report.Objects) > 0 || len(report.DOM) > 0
+}
+
+/*
+String implements Stringer.
+*/
+func (report *LeakReport) String() string {
+	if !report.Leaked() {
+		return fmt.Sprintf("no leaks detected in %d iterations", report.Iterations)
+	}
+	lines := []string{fmt.Sprintf("leaks detected in %d iterations:", report.Iterations)}
+	for _, counter := range report.DOM {
+		lines = append(lines, fmt.Sprintf("  %s: %d -> %d (+%d per iteration)",
+			counter.Name, counter.Start, counter.End, counter.PerIteration))
+	}
+	for _, class := range report.Objects {
+		lines = append(lines, fmt.Sprintf("  %s: +%d per iteration, %d bytes",
+			class.Name, class.PerIteration, class.Size))
+		for _, path := range class.Paths {
+			lines = append(lines, "    retained by "+path)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+TestingT is the part of testing.TB used by LeakReport.Assert(), it keeps the
+testing package out of non-test binaries.
+*/
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+/*
+Assert fails the test if any leaks were detected.
+*/
+func (report *LeakReport) Assert(t TestingT) {
+	t.Helper()
+	if report.Leaked() {
+		t.Errorf("%s", report)
+	}
+}
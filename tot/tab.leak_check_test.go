@@ -0,0 +1,155 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	heap "github.com/mkenney/go-chrome/tot/heap/profiler"
+	"github.com/mkenney/go-chrome/tot/memory"
+)
+
+/*
+leakSnapshot returns a heap snapshot with a number of Listener objects
+retained by an array of the window.
+*/
+func leakSnapshot(listeners int) string {
+	nodes := []int{
+		9, 0, 1, 0, 1,
+		3, 1, 3, 100, 1,
+		1, 2, 5, 16, listeners,
+	}
+	edges := []int{
+		1, 1, 5,
+		2, 4, 10,
+	}
+	for a := 0; a < listeners; a++ {
+		nodes = append(nodes, 3, 3, 101+2*a, 24, 0)
+		edges = append(edges, 1, a, 15+5*a)
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"snapshot": map[string]interface{}{
+			"meta": map[string]interface{}{
+				"node_fields": []string{"type", "name", "id", "self_size", "edge_count"},
+				"node_types": []interface{}{
+					[]string{"hidden", "array", "string", "object", "code", "closure", "regexp", "number", "native", "synthetic"},
+					"string", "number", "number", "number",
+				},
+				"edge_fields": []string{"type", "name_or_index", "to_node"},
+				"edge_types": []interface{}{
+					[]string{"context", "element", "property", "internal", "hidden", "shortcut", "weak"},
+					"string_or_number", "node",
+				},
+			},
+			"node_count": len(nodes) / 5,
+			"edge_count": len(edges) / 3,
+		},
+		"nodes":   nodes,
+		"edges":   edges,
+		"strings": []string{"", "Window", "Array", "Listener", "handlers"},
+	})
+	return string(data)
+}
+
+type leakTestRecorder struct {
+	errors []string
+}
+
+func (recorder *leakTestRecorder) Helper() {}
+
+func (recorder *leakTestRecorder) Errorf(format string, args ...interface{}) {
+	recorder.errors = append(recorder.errors, fmt.Sprintf(format, args...))
+}
+
+func TestLeakCheck(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestLeakCheck")
+	mux := &sync.Mutex{}
+	runs := 0
+	measurements := 0
+	collected := 0
+	mockSocket.Respond("Memory.prepareForLeakDetection", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("'Memory.prepareForLeakDetection' wasn't found")
+	})
+	mockSocket.Respond("HeapProfiler.collectGarbage", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		collected++
+		mux.Unlock()
+		return &heap.CollectGarbageResult{}, nil
+	})
+	mockSocket.Respond("Memory.getDOMCounters", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		measurements++
+		return &memory.GetDOMCountersResult{
+			Documents:        1,
+			Nodes:            100 + 10*runs,
+			JsEventListeners: []int{5, 6, 6, 8}[measurements-1],
+		}, nil
+	})
+	mockSocket.Respond("HeapProfiler.enable", func(params json.RawMessage) (interface{}, error) {
+		return &heap.EnableResult{}, nil
+	})
+	mockSocket.Respond("HeapProfiler.takeHeapSnapshot", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		snapshot := leakSnapshot(2 * runs)
+		mux.Unlock()
+		for len(snapshot) > 0 {
+			size := 16
+			if size > len(snapshot) {
+				size = len(snapshot)
+			}
+			mockSocket.Fire("HeapProfiler.addHeapSnapshotChunk", &heap.AddHeapSnapshotChunkEvent{Chunk: snapshot[:size]})
+			snapshot = snapshot[size:]
+		}
+		return &heap.TakeHeapSnapshotResult{}, nil
+	})
+
+	report, err := NewLeakCheck(tab, 3).Run(context.Background(), func() error {
+		mux.Lock()
+		runs++
+		mux.Unlock()
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if 4 != runs || 4 != measurements || 4 != collected {
+		t.Errorf("Expected 4 runs and measurements, received %d, %d and %d", runs, measurements, collected)
+	}
+	if report.Prepared {
+		t.Errorf("Expected Memory.prepareForLeakDetection to be unavailable")
+	}
+
+	if 1 != len(report.DOM) || "nodes" != report.DOM[0].Name || 10 != report.DOM[0].PerIteration ||
+		110 != report.DOM[0].Start || 140 != report.DOM[0].End {
+		t.Errorf("Expected the nodes counter to leak, received %v", report.DOM)
+	}
+	if 1 != len(report.Objects) {
+		t.Fatalf("Expected 1 leaked class, received %d", len(report.Objects))
+	}
+	leaked := report.Objects[0]
+	if "Listener" != leaked.Name || 2 != leaked.PerIteration || 6*24 != leaked.Size {
+		t.Errorf("Unexpected leaked class %v", leaked)
+	}
+	if 3 != len(leaked.Paths) || "Window.handlers[2]" != leaked.Paths[0] {
+		t.Errorf("Unexpected retainer paths %v", leaked.Paths)
+	}
+
+	recorder := &leakTestRecorder{}
+	report.Assert(recorder)
+	if 1 != len(recorder.errors) || !strings.Contains(recorder.errors[0], "retained by Window.handlers[2]") {
+		t.Errorf("Expected the test to fail with the retainer paths, received %v", recorder.errors)
+	}
+
+	report.DOM = nil
+	report.Objects = nil
+	recorder = &leakTestRecorder{}
+	report.Assert(recorder)
+	if 0 != len(recorder.errors) {
+		t.Errorf("Expected no failures, received %v", recorder.errors)
+	}
+}
@@ -0,0 +1,284 @@
+package chrome
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+logpointMarker is the first console argument of logpoint messages, it
+distinguishes them from the console messages of the page.
+*/
+const logpointMarker = "go-chrome:logpoint"
+
+/*
+asyncStackDepth is the maximum depth of the async call stacks captured by
+tracepoints.
+*/
+const asyncStackDepth = 32
+
+/*
+Logpoint is a breakpoint that logs instead of pausing. The breakpoint
+condition logs the value of an expression with console.debug() and evaluates
+to false, so the page never stops.
+*/
+type Logpoint struct {
+	// ID identifies the logpoint, e.g. for RemoveLogpoint().
+	ID string
+
+	// Expression is the logged expression, empty for tracepoints that only
+	// log the stack.
+	Expression string
+
+	// Trace is true for tracepoints, which capture the call stack.
+	Trace bool
+
+	// Breakpoint is the breakpoint of the logpoint.
+	Breakpoint *Breakpoint
+
+	hits int
+	mux  *sync.Mutex
+}
+
+/*
+Hits returns the number of times the logpoint was hit.
+*/
+func (logpoint *Logpoint) Hits() int {
+	logpoint.mux.Lock()
+	defer logpoint.mux.Unlock()
+	return logpoint.hits
+}
+
+/*
+LogpointHit is a record of a logpoint hit.
+*/
+type LogpointHit struct {
+	// Logpoint is the logpoint that was hit.
+	Logpoint *Logpoint
+
+	// Hit is the hit count of the logpoint, starting at 1.
+	Hit int
+
+	// Timestamp is the time of the hit.
+	Timestamp runtime.Timestamp
+
+	// Value is the value of the expression. Objects are referenced by ID
+	// and can be inspected with Debugger.Properties().
+	Value *runtime.RemoteObject
+
+	// Exception is the value thrown by the expression, if any.
+	Exception *runtime.RemoteObject
+
+	// Stack is the call stack of a tracepoint hit, including the async
+	// parent stacks.
+	Stack *runtime.StackTrace
+}
+
+/*
+SetLogpoint sets a logpoint that logs the value of an expression each time a
+line is reached, see SetBreakpoint() for the location rules. Hits are passed
+to the OnLogpoint() callbacks.
+*/
+func (dbg *Debugger) SetLogpoint(file string, line int, expression string) (*Logpoint, error) {
+	if "" == expression {
+		return nil, errs.New(codes.LogpointFailed, "empty logpoint expression")
+	}
+	return dbg.setLogpoint(file, line, expression, false)
+}
+
+/*
+SetTracepoint sets a tracepoint that captures the call stack each time a line
+is reached, without stopping the page. Hits are passed to the OnLogpoint()
+callbacks.
+*/
+func (dbg *Debugger) SetTracepoint(file string, line int) (*Logpoint, error) {
+	return dbg.setLogpoint(file, line, "", true)
+}
+
+func (dbg *Debugger) setLogpoint(file string, line int, expression string, trace bool) (*Logpoint, error) {
+	if err := dbg.enableConsole(trace); nil != err {
+		return nil, err
+	}
+
+	dbg.mux.Lock()
+	dbg.logpointID++
+	logpoint := &Logpoint{
+		ID:         fmt.Sprintf("logpoint-%d", dbg.logpointID),
+		Expression: expression,
+		Trace:      trace,
+		mux:        &sync.Mutex{},
+	}
+	dbg.mux.Unlock()
+
+	breakpoint, err := dbg.SetBreakpoint(file, line, logpointCondition(logpoint.ID, expression))
+	if nil != err {
+		return nil, wrapError(err, codes.LogpointFailed, "could not set the logpoint breakpoint")
+	}
+	logpoint.Breakpoint = breakpoint
+	dbg.mux.Lock()
+	dbg.logpoints[logpoint.ID] = logpoint
+	dbg.mux.Unlock()
+	return logpoint, nil
+}
+
+/*
+logpointCondition returns the breakpoint condition of a logpoint. The
+condition is evaluated as a program, its completion value false keeps the
+page running. Exceptions thrown by the expression are logged as well.
+*/
+func logpointCondition(id, expression string) string {
+	if "" == expression {
+		return fmt.Sprintf(`console.debug(%q, %q, "trace"); false`, logpointMarker, id)
+	}
+	return fmt.Sprintf(
+		"try { console.debug(%q, %q, \"value\", (\n%s\n)); } catch (e) { console.debug(%q, %q, \"exception\", e); } false",
+		logpointMarker, id, expression, logpointMarker, id,
+	)
+}
+
+/*
+enableConsole starts listening to console messages and, for tracepoints,
+enables async call stacks.
+*/
+func (dbg *Debugger) enableConsole(trace bool) error {
+	dbg.mux.Lock()
+	enable := !dbg.consoleEnabled
+	asyncStacks := trace && !dbg.asyncStacks
+	var handler socket.EventHandler
+	if enable {
+		dbg.consoleEnabled = true
+		handler = socket.NewOrderedEventHandler("Runtime.consoleAPICalled", dbg.onConsoleAPICalled)
+		dbg.handlers = append(dbg.handlers, handler)
+	}
+	dbg.asyncStacks = dbg.asyncStacks || trace
+	dbg.mux.Unlock()
+
+	if enable {
+		dbg.tab.AddEventHandler(handler)
+		if result := <-dbg.tab.Runtime().Enable(); nil != result.Err {
+			return wrapError(result.Err, codes.LogpointFailed, "Runtime.enable failed")
+		}
+	}
+	if asyncStacks {
+		if result := <-dbg.tab.Debugger().SetAsyncCallStackDepth(&debugger.SetAsyncCallStackDepthParams{
+			MaxDepth: asyncStackDepth,
+		}); nil != result.Err {
+			return wrapError(result.Err, codes.LogpointFailed, "Debugger.setAsyncCallStackDepth failed")
+		}
+	}
+	return nil
+}
+
+/*
+Logpoints returns the logpoints and tracepoints of the session.
+*/
+func (dbg *Debugger) Logpoints() []*Logpoint {
+	dbg.mux.Lock()
+	defer dbg.mux.Unlock()
+	logpoints := make([]*Logpoint, 0, len(dbg.logpoints))
+	for _, logpoint := range dbg.logpoints {
+		logpoints = append(logpoints, logpoint)
+	}
+	return logpoints
+}
+
+/*
+RemoveLogpoint removes a logpoint or tracepoint by ID.
+*/
+func (dbg *Debugger) RemoveLogpoint(id string) error {
+	dbg.mux.Lock()
+	logpoint, ok := dbg.logpoints[id]
+	dbg.mux.Unlock()
+	if !ok {
+		return errs.New(codes.LogpointFailed, fmt.Sprintf("logpoint '%s' not found", id))
+	}
+	if err := dbg.RemoveBreakpoint(logpoint.Breakpoint); nil != err {
+		return wrapError(err, codes.LogpointFailed, "could not remove the logpoint breakpoint")
+	}
+	dbg.mux.Lock()
+	delete(dbg.logpoints, id)
+	dbg.mux.Unlock()
+	return nil
+}
+
+/*
+OnLogpoint adds a callback that is called for each logpoint and tracepoint
+hit. Callbacks are called in the order of the hits.
+*/
+func (dbg *Debugger) OnLogpoint(callback func(hit *LogpointHit)) {
+	dbg.mux.Lock()
+	dbg.logpointHandlers = append(dbg.logpointHandlers, callback)
+	dbg.mux.Unlock()
+}
+
+func (dbg *Debugger) onConsoleAPICalled(response *socket.Response) {
+	event := &runtime.ConsoleAPICalledEvent{}
+	if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+		return
+	}
+	if len(event.Args) < 3 || logpointMarker != event.Args[0].Value {
+		return
+	}
+	id, _ := event.Args[1].Value.(string)
+	dbg.mux.Lock()
+	logpoint, ok := dbg.logpoints[id]
+	handlers := dbg.logpointHandlers
+	dbg.mux.Unlock()
+	if !ok {
+		// The logpoint was removed.
+		return
+	}
+
+	logpoint.mux.Lock()
+	logpoint.hits++
+	hit := &LogpointHit{
+		Logpoint:  logpoint,
+		Hit:       logpoint.hits,
+		Timestamp: event.Timestamp,
+	}
+	logpoint.mux.Unlock()
+	if len(event.Args) > 3 {
+		if "exception" == event.Args[2].Value {
+			hit.Exception = event.Args[3]
+		} else {
+			hit.Value = event.Args[3]
+		}
+	}
+	if logpoint.Trace && nil != event.StackTrace {
+		hit.Stack = dbg.asyncStack(event.StackTrace)
+	}
+
+	for _, handler := range handlers {
+		handler(hit)
+	}
+}
+
+/*
+asyncStack resolves the async parents of a stack trace that are only
+referenced by ID. The frames of the breakpoint condition, which have no URL,
+are removed from the top of the stack.
+*/
+func (dbg *Debugger) asyncStack(stack *runtime.StackTrace) *runtime.StackTrace {
+	for len(stack.CallFrames) > 1 && "" == stack.CallFrames[0].URL {
+		stack.CallFrames = stack.CallFrames[1:]
+	}
+	for trace := stack; nil != trace; trace = trace.Parent {
+		if nil != trace.Parent || nil == trace.ParentID {
+			continue
+		}
+		result := <-dbg.tab.Debugger().GetStackTrace(&debugger.GetStackTraceParams{
+			StackTraceID: *trace.ParentID,
+		})
+		if nil == result.Err {
+			trace.Parent = result.StackTrace
+		}
+	}
+	return stack
+}
@@ -0,0 +1,150 @@
+package chrome
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/debugger"
+	"github.com/mkenney/go-chrome/tot/runtime"
+)
+
+func logpointEvent(id, kind string, value *runtime.RemoteObject) *runtime.ConsoleAPICalledEvent {
+	args := []*runtime.RemoteObject{
+		{Type: runtime.ObjectType.String, Value: logpointMarker},
+		{Type: runtime.ObjectType.String, Value: id},
+		{Type: runtime.ObjectType.String, Value: kind},
+	}
+	if nil != value {
+		args = append(args, value)
+	}
+	return &runtime.ConsoleAPICalledEvent{
+		Type:      runtime.CallType.Debug,
+		Args:      args,
+		Timestamp: 1000,
+		StackTrace: &runtime.StackTrace{
+			CallFrames: []*runtime.CallFrame{
+				{FunctionName: "", URL: ""},
+				{FunctionName: "tick", URL: "https://example.com/js/app.js", LineNumber: 9},
+			},
+			ParentID: &runtime.StackTraceID{ID: "async-1"},
+		},
+	}
+}
+
+func TestLogpoint(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestLogpoint")
+	conditions := []string{}
+	removed := []string{}
+	asyncDepth := 0
+	mockSocket.Respond("Runtime.enable", func(params json.RawMessage) (interface{}, error) {
+		return &runtime.EnableResult{}, nil
+	})
+	mockSocket.Respond("Debugger.setAsyncCallStackDepth", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.SetAsyncCallStackDepthParams{}
+		json.Unmarshal(params, request)
+		asyncDepth = request.MaxDepth
+		return &debugger.SetAsyncCallStackDepthResult{}, nil
+	})
+	mockSocket.Respond("Debugger.setBreakpointByUrl", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.SetBreakpointByURLParams{}
+		json.Unmarshal(params, request)
+		conditions = append(conditions, request.Condition)
+		return &debugger.SetBreakpointByURLResult{
+			BreakpointID: debugger.BreakpointID(fmt.Sprintf("bp-%d", len(conditions))),
+		}, nil
+	})
+	mockSocket.Respond("Debugger.removeBreakpoint", func(params json.RawMessage) (interface{}, error) {
+		request := &debugger.RemoveBreakpointParams{}
+		json.Unmarshal(params, request)
+		removed = append(removed, string(request.BreakpointID))
+		return &debugger.RemoveBreakpointResult{}, nil
+	})
+	mockSocket.Respond("Debugger.getStackTrace", func(params json.RawMessage) (interface{}, error) {
+		return &debugger.GetStackTraceResult{StackTrace: &runtime.StackTrace{
+			Description: "setTimeout",
+			CallFrames:  []*runtime.CallFrame{{FunctionName: "start", URL: "https://example.com/js/app.js"}},
+		}}, nil
+	})
+
+	dbg := NewDebugger(tab)
+	hits := make(chan *LogpointHit, 10)
+	dbg.OnLogpoint(func(hit *LogpointHit) {
+		hits <- hit
+	})
+	if _, err := dbg.SetLogpoint("app.js", 10, ""); nil == err {
+		t.Errorf("Expected an error for an empty expression")
+	}
+	logpoint, err := dbg.SetLogpoint("app.js", 10, "state.count")
+	if nil != err {
+		t.Fatalf("SetLogpoint failed: %s", err)
+	}
+	if !strings.Contains(conditions[0], "(\nstate.count\n)") || !strings.HasSuffix(conditions[0], "false") ||
+		!strings.Contains(conditions[0], `"`+logpoint.ID+`"`) {
+		t.Errorf("Unexpected condition %s", conditions[0])
+	}
+	if 0 != asyncDepth {
+		t.Errorf("Expected async stacks to be enabled by tracepoints only")
+	}
+	tracepoint, err := dbg.SetTracepoint("app.js", 12)
+	if nil != err {
+		t.Fatalf("SetTracepoint failed: %s", err)
+	}
+	if asyncStackDepth != asyncDepth || 2 != len(dbg.Logpoints()) {
+		t.Errorf("Expected async stacks to be enabled, received depth %d", asyncDepth)
+	}
+
+	receive := func() *LogpointHit {
+		select {
+		case hit := <-hits:
+			return hit
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a logpoint hit")
+		}
+		return nil
+	}
+	mockSocket.Fire("Runtime.consoleAPICalled", &runtime.ConsoleAPICalledEvent{
+		Type: runtime.CallType.Log,
+		Args: []*runtime.RemoteObject{{Type: runtime.ObjectType.String, Value: "page message"}},
+	})
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent(logpoint.ID, "value", &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 3}))
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent(logpoint.ID, "exception", &runtime.RemoteObject{Type: runtime.ObjectType.Object, Description: "TypeError"}))
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent(tracepoint.ID, "trace", nil))
+
+	hit := receive()
+	if logpoint != hit.Logpoint || 1 != hit.Hit || 3.0 != hit.Value.Value || nil != hit.Stack {
+		t.Errorf("Unexpected hit %v", hit)
+	}
+	hit = receive()
+	if 2 != hit.Hit || nil != hit.Value || "TypeError" != hit.Exception.Description {
+		t.Errorf("Expected the exception to be recorded, received %v", hit)
+	}
+	hit = receive()
+	if tracepoint != hit.Logpoint || nil == hit.Stack {
+		t.Fatalf("Expected a stack for the tracepoint hit, received %v", hit)
+	}
+	if 1 != len(hit.Stack.CallFrames) || "tick" != hit.Stack.CallFrames[0].FunctionName ||
+		nil == hit.Stack.Parent || "setTimeout" != hit.Stack.Parent.Description {
+		t.Errorf("Unexpected stack %v", hit.Stack)
+	}
+	if 2 != logpoint.Hits() || 1 != tracepoint.Hits() {
+		t.Errorf("Unexpected hit counts %d and %d", logpoint.Hits(), tracepoint.Hits())
+	}
+
+	if err := dbg.RemoveLogpoint(logpoint.ID); nil != err {
+		t.Fatalf("RemoveLogpoint failed: %s", err)
+	}
+	if 1 != len(removed) || "bp-1" != removed[0] || 1 != len(dbg.Logpoints()) {
+		t.Errorf("Expected the logpoint breakpoint to be removed, received %v", removed)
+	}
+	if err := dbg.RemoveLogpoint(logpoint.ID); nil == err {
+		t.Errorf("Expected an error for a removed logpoint")
+	}
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent(logpoint.ID, "value", &runtime.RemoteObject{Type: runtime.ObjectType.Number, Value: 4}))
+	mockSocket.Fire("Runtime.consoleAPICalled", logpointEvent(tracepoint.ID, "trace", nil))
+	if hit := receive(); tracepoint != hit.Logpoint || 2 != hit.Hit {
+		t.Errorf("Expected hits of removed logpoints to be ignored, received %v", hit)
+	}
+}
@@ -0,0 +1,378 @@
+package chrome
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	stdio "io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	errs "github.com/bdlm/errors"
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+)
+
+/*
+SoakMetrics lists the Performance domain metrics that usually reveal leaks
+and runaway work in long running scenarios.
+*/
+var SoakMetrics = []string{
+	"JSHeapUsedSize",
+	"JSHeapTotalSize",
+	"Nodes",
+	"Documents",
+	"JSEventListeners",
+	"LayoutCount",
+	"RecalcStyleCount",
+	"LayoutDuration",
+	"RecalcStyleDuration",
+	"ScriptDuration",
+	"TaskDuration",
+}
+
+/*
+MetricsSample is a snapshot of the Performance domain metrics.
+*/
+type MetricsSample struct {
+	// Time the sample was taken.
+	Time time.Time `json:"time"`
+
+	// Values contains the metric values by name.
+	Values map[string]float64 `json:"values"`
+
+	// Deltas contains the change of each metric since the previous sample.
+	Deltas map[string]float64 `json:"deltas"`
+
+	// Marks contains the user marks added since the previous sample.
+	Marks []string `json:"marks,omitempty"`
+}
+
+/*
+NewMetricsSampler returns a sampler that polls Performance.getMetrics on the
+tab at the specified interval.
+*/
+func NewMetricsSampler(tab *Tab, interval time.Duration) *MetricsSampler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &MetricsSampler{
+		interval: interval,
+		mux:      &sync.Mutex{},
+		tab:      tab,
+	}
+}
+
+/*
+MetricsSampler collects a time series of runtime performance metrics.
+*/
+type MetricsSampler struct {
+	done     chan bool
+	err      error
+	interval time.Duration
+	marks    []string
+	mux      *sync.Mutex
+	samples  []*MetricsSample
+	stop     chan bool
+	tab      *Tab
+}
+
+/*
+Start enables the Performance domain, takes the first sample and polls for
+more samples until Stop() is called or the context is done.
+*/
+func (sampler *MetricsSampler) Start(ctx context.Context) error {
+	sampler.mux.Lock()
+	if nil != sampler.stop {
+		sampler.mux.Unlock()
+		return errs.New(codes.MetricsFailed, "sampler already started")
+	}
+	sampler.stop = make(chan bool)
+	sampler.done = make(chan bool)
+	sampler.mux.Unlock()
+
+	if result := <-sampler.tab.Performance().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.MetricsFailed, "Performance.enable failed")
+	}
+	if _, err := sampler.Sample(); nil != err {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(sampler.interval)
+		defer ticker.Stop()
+		defer close(sampler.done)
+		for {
+			select {
+			case <-sampler.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := sampler.Sample(); nil != err {
+					log.WithFields(log.Fields{"error": err}).Warn("metrics sample failed")
+					sampler.mux.Lock()
+					sampler.err = err
+					sampler.mux.Unlock()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+/*
+Stop stops polling, takes a final sample and disables the Performance domain.
+The last sampling error, if any, is returned.
+*/
+func (sampler *MetricsSampler) Stop() error {
+	sampler.mux.Lock()
+	stop, done := sampler.stop, sampler.done
+	sampler.mux.Unlock()
+	if nil == stop {
+		return errs.New(codes.MetricsFailed, "sampler not started")
+	}
+	select {
+	case <-done:
+	default:
+		close(stop)
+		<-done
+	}
+
+	_, err := sampler.Sample()
+	if result := <-sampler.tab.Performance().Disable(); nil != result.Err && nil == err {
+		err = wrapError(result.Err, codes.MetricsFailed, "Performance.disable failed")
+	}
+
+	sampler.mux.Lock()
+	defer sampler.mux.Unlock()
+	if nil == err {
+		err = sampler.err
+	}
+	return err
+}
+
+/*
+Mark attaches a user mark to the next sample.
+*/
+func (sampler *MetricsSampler) Mark(label string) {
+	sampler.mux.Lock()
+	sampler.marks = append(sampler.marks, label)
+	sampler.mux.Unlock()
+}
+
+/*
+Sample takes a sample immediately and adds it to the series.
+*/
+func (sampler *MetricsSampler) Sample() (*MetricsSample, error) {
+	result := <-sampler.tab.Performance().GetMetrics()
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.MetricsFailed, "Performance.getMetrics failed")
+	}
+
+	sample := &MetricsSample{
+		Time:   time.Now(),
+		Values: map[string]float64{},
+		Deltas: map[string]float64{},
+	}
+	for _, metric := range result.Metrics {
+		sample.Values[metric.Name] = metric.Value
+	}
+
+	sampler.mux.Lock()
+	defer sampler.mux.Unlock()
+	if len(sampler.samples) > 0 {
+		previous := sampler.samples[len(sampler.samples)-1]
+		for name, value := range sample.Values {
+			sample.Deltas[name] = value - previous.Values[name]
+		}
+	}
+	sample.Marks = sampler.marks
+	sampler.marks = nil
+	sampler.samples = append(sampler.samples, sample)
+	return sample, nil
+}
+
+/*
+Samples returns the collected samples.
+*/
+func (sampler *MetricsSampler) Samples() []*MetricsSample {
+	sampler.mux.Lock()
+	defer sampler.mux.Unlock()
+	return append([]*MetricsSample{}, sampler.samples...)
+}
+
+/*
+Growth returns the change of a metric between the first and the last sample.
+*/
+func (sampler *MetricsSampler) Growth(name string) float64 {
+	samples := sampler.Samples()
+	if len(samples) < 2 {
+		return 0
+	}
+	return samples[len(samples)-1].Values[name] - samples[0].Values[name]
+}
+
+/*
+Slope returns the least-squares trend of a metric in units per second. A
+steadily positive slope for JSHeapUsedSize or Nodes over a long run indicates
+a leak.
+*/
+func (sampler *MetricsSampler) Slope(name string) float64 {
+	samples := sampler.Samples()
+	if len(samples) < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := sample.Time.Sub(samples[0].Time).Seconds()
+		y := sample.Values[name]
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if 0 == denominator {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+/*
+metricNames returns the sorted names of all sampled metrics.
+*/
+func (sampler *MetricsSampler) metricNames(samples []*MetricsSample) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, sample := range samples {
+		for name := range sample.Values {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+WriteCSV writes the samples as CSV. Each metric has a value and a delta
+column, marks are joined with '|'.
+*/
+func (sampler *MetricsSampler) WriteCSV(w stdio.Writer) error {
+	samples := sampler.Samples()
+	names := sampler.metricNames(samples)
+
+	writer := csv.NewWriter(w)
+	header := []string{"time", "elapsed", "marks"}
+	for _, name := range names {
+		header = append(header, name, name+".delta")
+	}
+	if err := writer.Write(header); nil != err {
+		return wrapError(err, codes.MetricsExportFailed, "could not write CSV header")
+	}
+
+	for _, sample := range samples {
+		row := []string{
+			sample.Time.Format(time.RFC3339Nano),
+			strconv.FormatFloat(sample.Time.Sub(samples[0].Time).Seconds(), 'f', 3, 64),
+			strings.Join(sample.Marks, "|"),
+		}
+		for _, name := range names {
+			row = append(row,
+				strconv.FormatFloat(sample.Values[name], 'f', -1, 64),
+				strconv.FormatFloat(sample.Deltas[name], 'f', -1, 64),
+			)
+		}
+		if err := writer.Write(row); nil != err {
+			return wrapError(err, codes.MetricsExportFailed, "could not write CSV row")
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); nil != err {
+		return wrapError(err, codes.MetricsExportFailed, "could not write CSV")
+	}
+	return nil
+}
+
+/*
+WriteJSON writes the samples as a JSON document.
+*/
+func (sampler *MetricsSampler) WriteJSON(w stdio.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(struct {
+		Interval string           `json:"interval"`
+		Samples  []*MetricsSample `json:"samples"`
+	}{
+		Interval: sampler.interval.String(),
+		Samples:  sampler.Samples(),
+	})
+	if nil != err {
+		return wrapError(err, codes.MetricsExportFailed, "could not write JSON")
+	}
+	return nil
+}
+
+/*
+WritePrometheus writes the latest sample in the Prometheus text exposition
+format. Metric names are converted to snake case and prefixed with 'chrome_',
+e.g. JSHeapUsedSize is exported as chrome_js_heap_used_size. Samples carry
+their timestamp so a series can be backfilled from successive exports.
+*/
+func (sampler *MetricsSampler) WritePrometheus(w stdio.Writer) error {
+	samples := sampler.Samples()
+	if 0 == len(samples) {
+		return nil
+	}
+	sample := samples[len(samples)-1]
+
+	labels := ""
+	if nil != sampler.tab.Data() && "" != sampler.tab.Data().ID {
+		labels = fmt.Sprintf(`{tab="%s"}`, sampler.tab.Data().ID)
+	}
+	for _, name := range sampler.metricNames(samples) {
+		metric := "chrome_" + snakeCase(name)
+		_, err := fmt.Fprintf(w,
+			"# HELP %s Chrome Performance metric %s.\n# TYPE %s gauge\n%s%s %s %d\n",
+			metric, name,
+			metric,
+			metric, labels, strconv.FormatFloat(sample.Values[name], 'g', -1, 64), sample.Time.UnixNano()/int64(time.Millisecond),
+		)
+		if nil != err {
+			return wrapError(err, codes.MetricsExportFailed, "could not write Prometheus metrics")
+		}
+	}
+	return nil
+}
+
+/*
+snakeCase converts CamelCase metric names to snake_case. Runs of upper case
+letters are treated as acronyms, e.g. JSHeapUsedSize becomes
+js_heap_used_size.
+*/
+func snakeCase(name string) string {
+	runes := []rune(name)
+	result := []rune{}
+	for a, char := range runes {
+		if unicode.IsUpper(char) {
+			if a > 0 && (unicode.IsLower(runes[a-1]) ||
+				(a+1 < len(runes) && unicode.IsLower(runes[a+1]) && unicode.IsUpper(runes[a-1]))) {
+				result = append(result, '_')
+			}
+			char = unicode.ToLower(char)
+		}
+		result = append(result, char)
+	}
+	return string(result)
+}
@@ -0,0 +1,118 @@
+package chrome
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/performance"
+)
+
+func TestMetricsSampler(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestMetricsSampler")
+	mux := &sync.Mutex{}
+	heap := 1000.0
+	enabled := false
+	mockSocket.Respond("Performance.enable", func(params json.RawMessage) (interface{}, error) {
+		enabled = true
+		return &performance.EnableResult{}, nil
+	})
+	mockSocket.Respond("Performance.disable", func(params json.RawMessage) (interface{}, error) {
+		enabled = false
+		return &performance.DisableResult{}, nil
+	})
+	mockSocket.Respond("Performance.getMetrics", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		heap += 500
+		return &performance.GetMetricsResult{Metrics: []*performance.Metric{
+			{Name: "JSHeapUsedSize", Value: heap},
+			{Name: "ScriptDuration", Value: 0.25},
+		}}, nil
+	})
+
+	sampler := NewMetricsSampler(tab, time.Hour)
+	if err := sampler.Start(context.Background()); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if !enabled {
+		t.Errorf("Expected the Performance domain to be enabled")
+	}
+	sampler.Mark("login")
+	if err := sampler.Stop(); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if enabled {
+		t.Errorf("Expected the Performance domain to be disabled")
+	}
+
+	samples := sampler.Samples()
+	if 2 != len(samples) {
+		t.Fatalf("Expected 2 samples, received %d", len(samples))
+	}
+	if 500 != samples[1].Deltas["JSHeapUsedSize"] {
+		t.Errorf("Expected a delta of 500, received %f", samples[1].Deltas["JSHeapUsedSize"])
+	}
+	if 1 != len(samples[1].Marks) || "login" != samples[1].Marks[0] {
+		t.Errorf("Expected the mark on the second sample, received %v", samples[1].Marks)
+	}
+	if 500 != sampler.Growth("JSHeapUsedSize") {
+		t.Errorf("Expected a growth of 500, received %f", sampler.Growth("JSHeapUsedSize"))
+	}
+	if sampler.Slope("JSHeapUsedSize") <= 0 {
+		t.Errorf("Expected a positive slope, received %f", sampler.Slope("JSHeapUsedSize"))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := sampler.WriteCSV(buf); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 3 != len(rows) || "JSHeapUsedSize" != rows[0][3] || "2000" != rows[2][3] || "500" != rows[2][4] {
+		t.Errorf("Unexpected CSV: %v", rows)
+	}
+
+	buf.Reset()
+	if err := sampler.WriteJSON(buf); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	data := struct {
+		Samples []*MetricsSample `json:"samples"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &data); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 2 != len(data.Samples) {
+		t.Errorf("Expected 2 samples, received %d", len(data.Samples))
+	}
+
+	buf.Reset()
+	if err := sampler.WritePrometheus(buf); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "# TYPE chrome_js_heap_used_size gauge\nchrome_js_heap_used_size") ||
+		!strings.Contains(buf.String(), " 2000 ") {
+		t.Errorf("Unexpected Prometheus output:\n%s", buf.String())
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"JSHeapUsedSize":   "js_heap_used_size",
+		"Nodes":            "nodes",
+		"JSEventListeners": "js_event_listeners",
+		"LayoutDuration":   "layout_duration",
+	} {
+		if result := snakeCase(name); expected != result {
+			t.Errorf("Expected '%s', received '%s'", expected, result)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package chrome
+
+import (
+	"fmt"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/a11y"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+/*
+QueryAll returns the elements matching a role=, label= or text= selector in
+document order, see a11y.Selector. The selector is resolved against the
+accessibility tree, including shadow roots and same-process frames.
+*/
+func (tab *Tab) QueryAll(selector string) ([]*Element, error) {
+	parsed, err := a11y.ParseSelector(selector)
+	if nil != err {
+		return nil, wrapError(err, codes.SelectorInvalid, fmt.Sprintf("invalid selector '%s'", selector))
+	}
+	tree, err := tab.accessibilityTree(false)
+	if nil != err {
+		return nil, wrapError(err, codes.ElementNotFound, fmt.Sprintf("could not resolve '%s'", selector))
+	}
+	elements := []*Element{}
+	for _, element := range tree.Query(parsed) {
+		elements = append(elements, tab.newElement(dom.BackendNodeID(element.BackendNodeID), selector))
+	}
+	return elements, nil
+}
+
+/*
+Query returns the first element matching a role=, label= or text= selector.
+*/
+func (tab *Tab) Query(selector string) (*Element, error) {
+	elements, err := tab.QueryAll(selector)
+	if nil != err {
+		return nil, err
+	}
+	if 0 == len(elements) {
+		return nil, errs.New(codes.ElementNotFound, fmt.Sprintf("no element matches '%s'", selector))
+	}
+	return elements[0], nil
+}
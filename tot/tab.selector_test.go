@@ -0,0 +1,79 @@
+package chrome
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+func TestQuery(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestQuery")
+	for _, method := range []string{"DOM.enable", "CSS.enable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testAXDocument), nil
+	})
+	mockSocket.Respond("Accessibility.getPartialAXTree", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ NodeID int }{}
+		json.Unmarshal(params, &request)
+		nodes, ok := testAXNodes[request.NodeID]
+		if !ok {
+			nodes = "[]"
+		}
+		return json.RawMessage(`{"nodes": ` + nodes + `}`), nil
+	})
+
+	for selector, expected := range map[string][]dom.BackendNodeID{
+		`role=textbox`:                 {9, 10},
+		`role=textbox[name="Query"]`:   {10},
+		`label=query`:                  {10},
+		`text=Big`:                     {13},
+		`role=generic`:                 {5, 14, 15},
+		`role=link`:                    {20},
+		`role=button[focusable=true]`:  {8},
+		`role=button[focusable=false]`: {},
+	} {
+		elements, err := tab.QueryAll(selector)
+		if nil != err {
+			t.Errorf("QueryAll(%s) failed: %s", selector, err)
+			continue
+		}
+		ids := []dom.BackendNodeID{}
+		for _, element := range elements {
+			if selector != element.Selector || tab != element.tab {
+				t.Errorf("Unexpected element %v", element)
+			}
+			ids = append(ids, element.BackendNodeID)
+		}
+		if len(expected) != len(ids) {
+			t.Errorf("Expected %v for %s, received %v", expected, selector, ids)
+			continue
+		}
+		for a := range ids {
+			if expected[a] != ids[a] {
+				t.Errorf("Expected %v for %s, received %v", expected, selector, ids)
+			}
+		}
+	}
+
+	element, err := tab.Query(`role=link[name=""]`)
+	if nil != err || 20 != element.BackendNodeID {
+		t.Errorf("Unexpected element %v (%v)", element, err)
+	}
+	if _, err := tab.Query(`role=dialog`); nil == err || codes.ElementNotFound != err.(errs.Err).Code() {
+		t.Errorf("Expected ElementNotFound, received %v", err)
+	}
+	if _, err := tab.Query(`button`); nil == err || codes.SelectorInvalid != err.(errs.Err).Code() {
+		t.Errorf("Expected SelectorInvalid, received %v", err)
+	}
+	if _, err := tab.QueryAll(`role=`); nil == err || !strings.Contains(err.Error(), "invalid role") {
+		t.Errorf("Expected the parser reason, received %v", err)
+	}
+}
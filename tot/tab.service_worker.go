@@ -0,0 +1,511 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	worker "github.com/mkenney/go-chrome/tot/service/worker"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/storage"
+)
+
+/*
+NewServiceWorkers returns the service worker registry of the tab. The registry
+is empty until it is enabled.
+*/
+func NewServiceWorkers(tab *Tab) *ServiceWorkers {
+	return &ServiceWorkers{
+		changed:       make(chan struct{}),
+		mux:           &sync.Mutex{},
+		registrations: map[string]*worker.Registration{},
+		tab:           tab,
+		versions:      map[string]*worker.Version{},
+	}
+}
+
+/*
+ServiceWorkers tracks the service worker registrations of the browser and
+their versions, and controls their lifecycle, so that service worker logic can
+be tested. Together with the worker sessions of NewWorkers() the outcome of
+push and sync events can be checked in the worker:
+
+	registry := chrome.NewServiceWorkers(tab)
+	registry.Enable()
+	registry.Reset(ctx, "https://example.com")
+	tab.Page().Navigate(&page.NavigateParams{URL: "https://example.com/"})
+	version, _ := registry.WaitForVersion(ctx, "https://example.com/", worker.VersionStatus.Activated, 0)
+	sw, _ := workers.WaitForWorker(ctx, func(w *chrome.Worker) bool {
+		return version.TargetID == w.ID
+	})
+	err := registry.DispatchSync(ctx, "https://example.com/", "outbox", false, func(ctx context.Context) (bool, error) {
+		pending, err := sw.Evaluate(ctx, "outbox.length")
+		return "0" == string(pending), err
+	})
+
+Versions and registrations are snapshots, they are replaced when Chrome
+reports an update.
+*/
+type ServiceWorkers struct {
+	changed       chan struct{}
+	enabled       bool
+	errors        []*worker.ErrorMessage
+	handlers      []socket.EventHandler
+	mux           *sync.Mutex
+	registrations map[string]*worker.Registration
+	tab           *Tab
+	versions      map[string]*worker.Version
+}
+
+/*
+Enable enables the ServiceWorker domain and starts tracking registrations,
+versions and reported errors.
+*/
+func (registry *ServiceWorkers) Enable() error {
+	registry.mux.Lock()
+	if registry.enabled {
+		registry.mux.Unlock()
+		return nil
+	}
+	registry.enabled = true
+	registry.handlers = []socket.EventHandler{
+		socket.NewOrderedEventHandler("ServiceWorker.workerRegistrationUpdated", registry.onRegistrationUpdated),
+		socket.NewOrderedEventHandler("ServiceWorker.workerVersionUpdated", registry.onVersionUpdated),
+		socket.NewOrderedEventHandler("ServiceWorker.workerErrorReported", registry.onErrorReported),
+	}
+	handlers := registry.handlers
+	registry.mux.Unlock()
+	for _, handler := range handlers {
+		registry.tab.AddEventHandler(handler)
+	}
+
+	// The current registrations and versions are reported after enabling.
+	if result := <-registry.tab.ServiceWorker().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.enable failed")
+	}
+	return nil
+}
+
+/*
+Disable stops tracking service workers. The ServiceWorker domain stays
+enabled, other features of the tab may depend on it.
+*/
+func (registry *ServiceWorkers) Disable() {
+	registry.mux.Lock()
+	handlers := registry.handlers
+	registry.enabled = false
+	registry.handlers = nil
+	registry.mux.Unlock()
+
+	for _, handler := range handlers {
+		registry.tab.RemoveEventHandler(handler)
+	}
+}
+
+/*
+Registrations returns the registrations that are not deleted, ordered by
+scope URL.
+*/
+func (registry *ServiceWorkers) Registrations() []*worker.Registration {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registrations := []*worker.Registration{}
+	for _, registration := range registry.registrations {
+		if !registration.IsDeleted {
+			registrations = append(registrations, registration)
+		}
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].ScopeURL < registrations[j].ScopeURL
+	})
+	return registrations
+}
+
+/*
+Registration returns the registration of a scope URL, nil if there is none.
+*/
+func (registry *ServiceWorkers) Registration(scopeURL string) *worker.Registration {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	return registry.registration(scopeURL)
+}
+
+/*
+Versions returns the versions of a registration, oldest first.
+*/
+func (registry *ServiceWorkers) Versions(registrationID string) []*worker.Version {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	return registry.versionsOf(registrationID)
+}
+
+/*
+Errors returns the errors reported by the versions of a registration.
+*/
+func (registry *ServiceWorkers) Errors(registrationID string) []*worker.ErrorMessage {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	errors := []*worker.ErrorMessage{}
+	for _, message := range registry.errors {
+		if registrationID == message.RegistrationID {
+			errors = append(errors, message)
+		}
+	}
+	return errors
+}
+
+/*
+WaitForVersion waits for a version of the registration of a scope URL to
+reach a status and a running status. A zero status or running status matches
+any value. The newest matching version is returned.
+*/
+func (registry *ServiceWorkers) WaitForVersion(
+	ctx context.Context,
+	scopeURL string,
+	status worker.VersionStatusEnum,
+	runningStatus worker.VersionRunningStatusEnum,
+) (*worker.Version, error) {
+	for {
+		registry.mux.Lock()
+		var found *worker.Version
+		if registration := registry.registration(scopeURL); nil != registration {
+			for _, version := range registry.versionsOf(registration.RegistrationID) {
+				if (0 == status || status == version.Status) &&
+					(0 == runningStatus || runningStatus == version.RunningStatus) {
+					found = version
+				}
+			}
+		}
+		changed := registry.changed
+		registry.mux.Unlock()
+		if nil != found {
+			return found, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.ServiceWorkerFailed, fmt.Sprintf("waiting for a version of '%s'", scopeURL))
+		case <-changed:
+		}
+	}
+}
+
+/*
+Start starts the service worker of a scope URL.
+*/
+func (registry *ServiceWorkers) Start(scopeURL string) error {
+	if result := <-registry.tab.ServiceWorker().StartWorker(&worker.StartWorkerParams{
+		ScopeURL: scopeURL,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.startWorker failed")
+	}
+	return nil
+}
+
+/*
+Stop stops the running versions of the registration of a scope URL.
+*/
+func (registry *ServiceWorkers) Stop(scopeURL string) error {
+	registration := registry.Registration(scopeURL)
+	if nil == registration {
+		return errs.New(codes.ServiceWorkerFailed, fmt.Sprintf("no registration for scope '%s'", scopeURL))
+	}
+	for _, version := range registry.Versions(registration.RegistrationID) {
+		if worker.VersionRunningStatus.Stopped == version.RunningStatus {
+			continue
+		}
+		if result := <-registry.tab.ServiceWorker().StopWorker(&worker.StopWorkerParams{
+			VersionID: version.VersionID,
+		}); nil != result.Err {
+			return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.stopWorker failed")
+		}
+	}
+	return nil
+}
+
+/*
+SkipWaiting activates the waiting version of a scope URL.
+*/
+func (registry *ServiceWorkers) SkipWaiting(scopeURL string) error {
+	if result := <-registry.tab.ServiceWorker().SkipWaiting(&worker.SkipWaitingParams{
+		ScopeURL: scopeURL,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.skipWaiting failed")
+	}
+	return nil
+}
+
+/*
+Update checks the registration of a scope URL for a new version.
+*/
+func (registry *ServiceWorkers) Update(scopeURL string) error {
+	if result := <-registry.tab.ServiceWorker().UpdateRegistration(&worker.UpdateRegistrationParams{
+		ScopeURL: scopeURL,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.updateRegistration failed")
+	}
+	return nil
+}
+
+/*
+Unregister unregisters the registration of a scope URL.
+*/
+func (registry *ServiceWorkers) Unregister(scopeURL string) error {
+	if result := <-registry.tab.ServiceWorker().Unregister(&worker.UnregisterParams{
+		ScopeURL: scopeURL,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "ServiceWorker.unregister failed")
+	}
+	return nil
+}
+
+/*
+DeliverPush delivers a push message to the registration of a scope URL, see
+dispatch() for the outcome.
+*/
+func (registry *ServiceWorkers) DeliverPush(
+	ctx context.Context,
+	scopeURL string,
+	data string,
+	outcome func(ctx context.Context) (bool, error),
+) error {
+	return registry.dispatch(ctx, scopeURL, outcome, func(origin string, registration *worker.Registration) error {
+		if result := <-registry.tab.ServiceWorker().DeliverPushMessage(&worker.DeliverPushMessageParams{
+			Origin:         origin,
+			RegistrationID: registration.RegistrationID,
+			Data:           data,
+		}); nil != result.Err {
+			return wrapError(result.Err, codes.ServiceWorkerEventFailed, "ServiceWorker.deliverPushMessage failed")
+		}
+		return nil
+	})
+}
+
+/*
+DispatchSync dispatches a background sync event with a tag to the
+registration of a scope URL, see dispatch() for the outcome. lastChance tells
+the worker that the sync is not retried if it fails.
+*/
+func (registry *ServiceWorkers) DispatchSync(
+	ctx context.Context,
+	scopeURL string,
+	tag string,
+	lastChance bool,
+	outcome func(ctx context.Context) (bool, error),
+) error {
+	return registry.dispatch(ctx, scopeURL, outcome, func(origin string, registration *worker.Registration) error {
+		if result := <-registry.tab.ServiceWorker().DispatchSyncEvent(&worker.DispatchSyncEventParams{
+			Origin:         origin,
+			RegistrationID: registration.RegistrationID,
+			Tag:            tag,
+			LastChance:     lastChance,
+		}); nil != result.Err {
+			return wrapError(result.Err, codes.ServiceWorkerEventFailed, "ServiceWorker.dispatchSyncEvent failed")
+		}
+		return nil
+	})
+}
+
+/*
+dispatch sends an event to the registration of a scope URL. Chrome does not
+report when the worker has handled the event, so the outcome is polled every
+WaitPollInterval until it returns true. The event fails if the outcome fails
+or the worker reports an error before. Without an outcome dispatch returns
+once the event is sent.
+*/
+func (registry *ServiceWorkers) dispatch(
+	ctx context.Context,
+	scopeURL string,
+	outcome func(ctx context.Context) (bool, error),
+	send func(origin string, registration *worker.Registration) error,
+) error {
+	registry.mux.Lock()
+	registration := registry.registration(scopeURL)
+	reported := len(registry.errors)
+	registry.mux.Unlock()
+	if nil == registration {
+		return errs.New(codes.ServiceWorkerFailed, fmt.Sprintf("no registration for scope '%s'", scopeURL))
+	}
+	if err := send(urlOrigin(scopeURL), registration); nil != err {
+		return err
+	}
+	if nil == outcome {
+		return nil
+	}
+
+	for {
+		registry.mux.Lock()
+		for _, message := range registry.errors[reported:] {
+			if registration.RegistrationID == message.RegistrationID {
+				registry.mux.Unlock()
+				return errs.New(codes.ServiceWorkerEventFailed, fmt.Sprintf(
+					"%s (%s:%d:%d)", message.ErrorMessage, message.SourceURL, message.LineNumber, message.ColumnNumber,
+				))
+			}
+		}
+		reported = len(registry.errors)
+		changed := registry.changed
+		registry.mux.Unlock()
+
+		done, err := outcome(ctx)
+		if nil != err {
+			return wrapError(err, codes.ServiceWorkerEventFailed, "the outcome of the event failed")
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return wrapError(ctx.Err(), codes.ServiceWorkerEventFailed, fmt.Sprintf("waiting for the outcome of the event of '%s'", scopeURL))
+		case <-changed:
+		case <-time.After(WaitPollInterval):
+		}
+	}
+}
+
+/*
+Reset unregisters the service workers of an origin, e.g.
+"https://example.com", and clears their storage. It waits until the
+registrations of the origin are deleted.
+*/
+func (registry *ServiceWorkers) Reset(ctx context.Context, origin string) error {
+	origin = urlOrigin(origin)
+	for _, registration := range registry.Registrations() {
+		if origin != urlOrigin(registration.ScopeURL) {
+			continue
+		}
+		if err := registry.Unregister(registration.ScopeURL); nil != err {
+			return err
+		}
+	}
+	// Registrations that were not reported yet are removed with the storage.
+	if result := <-registry.tab.Storage().ClearDataForOrigin(&storage.ClearDataForOriginParams{
+		Origin: origin,
+		Types:  storage.Type.ServiceWorkers.String(),
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.ServiceWorkerFailed, "Storage.clearDataForOrigin failed")
+	}
+
+	for {
+		registry.mux.Lock()
+		remaining := 0
+		for _, registration := range registry.registrations {
+			if !registration.IsDeleted && origin == urlOrigin(registration.ScopeURL) {
+				remaining++
+			}
+		}
+		changed := registry.changed
+		registry.mux.Unlock()
+		if 0 == remaining {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return wrapError(ctx.Err(), codes.ServiceWorkerFailed, fmt.Sprintf("waiting for %d registrations of '%s' to be deleted", remaining, origin))
+		case <-changed:
+		}
+	}
+}
+
+/*
+registration returns the registration of a scope URL, which is called with the
+registry locked.
+*/
+func (registry *ServiceWorkers) registration(scopeURL string) *worker.Registration {
+	for _, registration := range registry.registrations {
+		if !registration.IsDeleted && scopeURL == registration.ScopeURL {
+			return registration
+		}
+	}
+	return nil
+}
+
+/*
+versionsOf returns the versions of a registration ordered by version ID, which
+is called with the registry locked.
+*/
+func (registry *ServiceWorkers) versionsOf(registrationID string) []*worker.Version {
+	versions := []*worker.Version{}
+	for _, version := range registry.versions {
+		if registrationID == version.RegistrationID {
+			versions = append(versions, version)
+		}
+	}
+	// Version IDs are increasing numbers.
+	sort.Slice(versions, func(i, j int) bool {
+		if len(versions[i].VersionID) != len(versions[j].VersionID) {
+			return len(versions[i].VersionID) < len(versions[j].VersionID)
+		}
+		return versions[i].VersionID < versions[j].VersionID
+	})
+	return versions
+}
+
+/*
+notify wakes up the waits for changes of the registry.
+*/
+func (registry *ServiceWorkers) notify() {
+	close(registry.changed)
+	registry.changed = make(chan struct{})
+}
+
+func (registry *ServiceWorkers) onRegistrationUpdated(response *socket.Response) {
+	event := &worker.RegistrationUpdatedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	for _, registration := range event.Registrations {
+		registry.registrations[registration.RegistrationID] = registration
+		if registration.IsDeleted {
+			for id, version := range registry.versions {
+				if registration.RegistrationID == version.RegistrationID {
+					delete(registry.versions, id)
+				}
+			}
+		}
+	}
+	registry.notify()
+}
+
+func (registry *ServiceWorkers) onVersionUpdated(response *socket.Response) {
+	event := &worker.VersionUpdatedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	for _, version := range event.Versions {
+		registry.versions[version.VersionID] = version
+	}
+	registry.notify()
+}
+
+func (registry *ServiceWorkers) onErrorReported(response *socket.Response) {
+	event := &worker.ErrorReportedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err || nil == event.ErrorMessage {
+		return
+	}
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registry.errors = append(registry.errors, event.ErrorMessage)
+	registry.notify()
+}
+
+/*
+urlOrigin returns the origin of a URL, e.g. "https://example.com" for
+"https://example.com/app/".
+*/
+func urlOrigin(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if nil != err || "" == parsed.Host {
+		return rawURL
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
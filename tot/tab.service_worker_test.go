@@ -0,0 +1,143 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	worker "github.com/mkenney/go-chrome/tot/service/worker"
+)
+
+func testVersion(id, registrationID, status, runningStatus string) map[string]string {
+	return map[string]string{
+		"versionId":      id,
+		"registrationId": registrationID,
+		"scriptURL":      "https://example.com/sw.js",
+		"status":         status,
+		"runningStatus":  runningStatus,
+		"targetId":       "SW" + id,
+	}
+}
+
+func TestServiceWorkers(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestServiceWorkers")
+	var mux sync.Mutex
+	commands := []string{}
+	record := func(method string, params json.RawMessage) {
+		mux.Lock()
+		defer mux.Unlock()
+		commands = append(commands, method+" "+string(params))
+	}
+	mockSocket.Respond("ServiceWorker.enable", func(params json.RawMessage) (interface{}, error) {
+		go func() {
+			mockSocket.Fire("ServiceWorker.workerRegistrationUpdated", map[string]interface{}{"registrations": []map[string]interface{}{
+				{"registrationId": "1", "scopeURL": "https://example.com/", "isDeleted": false},
+				{"registrationId": "2", "scopeURL": "https://other.example/", "isDeleted": false},
+			}})
+			mockSocket.Fire("ServiceWorker.workerVersionUpdated", map[string]interface{}{"versions": []map[string]string{
+				testVersion("1", "1", "installing", "starting"),
+			}})
+		}()
+		return map[string]interface{}{}, nil
+	})
+
+	registry := NewServiceWorkers(tab)
+	if err := registry.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockSocket.Fire("ServiceWorker.workerVersionUpdated", map[string]interface{}{"versions": []map[string]string{
+			testVersion("1", "1", "activated", "running"),
+		}})
+	}()
+	version, err := registry.WaitForVersion(ctx, "https://example.com/", worker.VersionStatus.Activated, worker.VersionRunningStatus.Running)
+	if nil != err || "1" != version.VersionID || "SW1" != version.TargetID {
+		t.Fatalf("Unexpected version %v (%v)", version, err)
+	}
+	if registrations := registry.Registrations(); 2 != len(registrations) || "https://example.com/" != registrations[0].ScopeURL {
+		t.Errorf("Unexpected registrations %v", registrations)
+	}
+
+	// The sync event is handled once the outcome is true.
+	mockSocket.Respond("ServiceWorker.dispatchSyncEvent", func(params json.RawMessage) (interface{}, error) {
+		record("ServiceWorker.dispatchSyncEvent", params)
+		return map[string]interface{}{}, nil
+	})
+	interval := WaitPollInterval
+	WaitPollInterval = 5 * time.Millisecond
+	defer func() { WaitPollInterval = interval }()
+	checks := 0
+	err = registry.DispatchSync(ctx, "https://example.com/", "outbox", true, func(ctx context.Context) (bool, error) {
+		checks++
+		return checks > 2, nil
+	})
+	if nil != err || 3 != checks {
+		t.Errorf("DispatchSync failed after %d checks: %v", checks, err)
+	}
+
+	// Errors reported by the worker fail the push event.
+	mockSocket.Respond("ServiceWorker.deliverPushMessage", func(params json.RawMessage) (interface{}, error) {
+		record("ServiceWorker.deliverPushMessage", params)
+		go mockSocket.Fire("ServiceWorker.workerErrorReported", map[string]interface{}{"errorMessage": map[string]interface{}{
+			"errorMessage":   "Uncaught TypeError: payload.json is not a function",
+			"registrationId": "1",
+			"versionId":      "1",
+			"sourceURL":      "https://example.com/sw.js",
+			"lineNumber":     12,
+			"columnNumber":   3,
+		}})
+		return map[string]interface{}{}, nil
+	})
+	err = registry.DeliverPush(ctx, "https://example.com/", `{"title": "hi"}`, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	if nil == err || codes.ServiceWorkerEventFailed != err.(errs.Err).Code() || !strings.Contains(err.Error(), "sw.js:12:3") {
+		t.Errorf("Expected ServiceWorkerEventFailed, received %v", err)
+	}
+	if 1 != len(registry.Errors("1")) {
+		t.Errorf("Unexpected errors %v", registry.Errors("1"))
+	}
+	if err := registry.DeliverPush(ctx, "https://unknown.example/", "", nil); nil == err {
+		t.Errorf("Expected an error for an unknown scope")
+	}
+
+	// Reset unregisters the registrations of the origin only.
+	mockSocket.Respond("ServiceWorker.unregister", func(params json.RawMessage) (interface{}, error) {
+		record("ServiceWorker.unregister", params)
+		go mockSocket.Fire("ServiceWorker.workerRegistrationUpdated", map[string]interface{}{"registrations": []map[string]interface{}{
+			{"registrationId": "1", "scopeURL": "https://example.com/", "isDeleted": true},
+		}})
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("Storage.clearDataForOrigin", func(params json.RawMessage) (interface{}, error) {
+		record("Storage.clearDataForOrigin", params)
+		return map[string]interface{}{}, nil
+	})
+	if err := registry.Reset(ctx, "https://example.com/app/"); nil != err {
+		t.Fatalf("Reset failed: %s", err)
+	}
+	if registrations := registry.Registrations(); 1 != len(registrations) || nil != registry.Registration("https://example.com/") || 0 != len(registry.Versions("1")) {
+		t.Errorf("Unexpected registrations after reset %v", registrations)
+	}
+	mux.Lock()
+	expected := []string{
+		`ServiceWorker.dispatchSyncEvent {"origin":"https://example.com","registrationId":"1","tag":"outbox","lastChance":true}`,
+		`ServiceWorker.deliverPushMessage {"origin":"https://example.com","registrationId":"1","data":"{\"title\": \"hi\"}"}`,
+		`ServiceWorker.unregister {"scopeURL":"https://example.com/"}`,
+		`Storage.clearDataForOrigin {"origin":"https://example.com","storageTypes":"service_workers"}`,
+	}
+	if fmt.Sprint(expected) != fmt.Sprint(commands) {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+	mux.Unlock()
+	registry.Disable()
+}
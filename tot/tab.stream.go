@@ -0,0 +1,51 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	stdio "io"
+
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/io"
+)
+
+/*
+ReadStream copies the contents of a DevTools IO stream to w and closes the
+stream. Base64 encoded chunks are decoded before they are written.
+*/
+func (tab *Tab) ReadStream(ctx context.Context, handle io.StreamHandle, w stdio.Writer) error {
+	defer func() {
+		<-tab.IO().Close(&io.CloseParams{Handle: handle})
+	}()
+
+	for {
+		if err := ctx.Err(); nil != err {
+			return err
+		}
+
+		result := <-tab.IO().Read(&io.ReadParams{
+			Handle: handle,
+			Size:   1 << 20,
+		})
+		if nil != result.Err {
+			return wrapError(result.Err, codes.StreamReadFailed, fmt.Sprintf("IO.read failed for stream '%s'", handle))
+		}
+
+		data := []byte(result.Data)
+		if result.Base64Encoded {
+			decoded, err := base64.StdEncoding.DecodeString(result.Data)
+			if nil != err {
+				return wrapError(err, codes.StreamReadFailed, fmt.Sprintf("invalid base64 data in stream '%s'", handle))
+			}
+			data = decoded
+		}
+		if _, err := w.Write(data); nil != err {
+			return wrapError(err, codes.StreamReadFailed, fmt.Sprintf("could not write stream '%s'", handle))
+		}
+
+		if result.EOF {
+			return nil
+		}
+	}
+}
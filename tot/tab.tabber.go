@@ -8,6 +8,7 @@ import (
 	"github.com/bdlm/log"
 	"github.com/mkenney/go-chrome/codes"
 	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/target"
 )
 
 /*
@@ -52,6 +53,66 @@ func (chrome *Chrome) NewTab(uri string) (*Tab, error) {
 	return tab, nil
 }
 
+/*
+AttachTab connects to an existing target, e.g. a tab that was opened by a user
+or another client, and returns a reference to it.
+*/
+func (chrome *Chrome) AttachTab(tabID string) (*Tab, error) {
+	targets := []*TabData{}
+	if _, err := chrome.Query("/json/list", url.Values{}, &targets); nil != err {
+		return nil, errs.Wrap(err, codes.TabQueryFailed, "/json/list query failed")
+	}
+	tab := &Tab{
+		chrome: chrome,
+	}
+	for _, data := range targets {
+		if tabID == data.ID {
+			tab.data = data
+			break
+		}
+	}
+	if nil == tab.data {
+		return nil, errs.New(codes.ChromeTabNotFound, fmt.Sprintf("target '%s' not found", tabID))
+	}
+
+	targetURL, err := url.Parse(tab.Data().URL)
+	if nil != err {
+		return nil, errs.Wrap(err, codes.TabURLInvalid, "invalid URL")
+	}
+	tab.url = targetURL
+	websocketURL, err := url.Parse(tab.Data().WebSocketDebuggerURL)
+	if nil != err {
+		return nil, errs.Wrap(err, codes.TabWebsocketURLInvalid, fmt.Sprintf("invalid websocket URL '%s'", tab.Data().WebSocketDebuggerURL))
+	}
+
+	socket := socket.New(websocketURL)
+	tab.socket = socket
+	tab.protocol = socket
+	chrome.tabs = append(chrome.tabs, tab)
+
+	return tab, nil
+}
+
+/*
+newSessionTab returns a tab for a target session that is attached through the
+socket of another tab, e.g. an out-of-process frame or a worker.
+*/
+func newSessionTab(parent *Tab, sessionID target.SessionID, info *target.Info) *Tab {
+	session := socket.NewSession(parent.Socket(), sessionID)
+	return &Tab{
+		chrome: parent.chrome,
+		data: &TabData{
+			ID:    string(info.ID),
+			Title: info.Title,
+			Type:  info.Type,
+			URL:   info.URL,
+		},
+		protocol: session,
+		socket:   session,
+		url:      session.URL(),
+	}
+}
+
 /*
 Tab is a struct representing an individual Chrome tab
 */
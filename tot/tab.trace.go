@@ -0,0 +1,234 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	stdio "io"
+	"strings"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/tracing"
+)
+
+/*
+TraceCategories is a list of trace categories. Categories prefixed with '-'
+are excluded from the trace.
+*/
+type TraceCategories []string
+
+/*
+TraceDevtoolsTimeline records the categories used by the DevTools Performance
+panel.
+*/
+var TraceDevtoolsTimeline = TraceCategories{
+	"-*",
+	"devtools.timeline",
+	"v8.execute",
+	"disabled-by-default-devtools.timeline",
+	"disabled-by-default-devtools.timeline.frame",
+	"disabled-by-default-devtools.timeline.stack",
+	"disabled-by-default-v8.cpu_profiler",
+	"disabled-by-default-v8.cpu_profiler.hires",
+	"toplevel",
+	"loading",
+	"latencyInfo",
+	"blink.console",
+	"blink.user_timing",
+}
+
+/*
+TraceRendering records compositor, paint and GPU activity.
+*/
+var TraceRendering = TraceCategories{
+	"-*",
+	"devtools.timeline",
+	"disabled-by-default-devtools.timeline.frame",
+	"disabled-by-default-devtools.timeline.layers",
+	"disabled-by-default-devtools.timeline.picture",
+	"benchmark",
+	"blink",
+	"cc",
+	"gpu",
+	"rail",
+	"toplevel",
+	"viz",
+}
+
+/*
+TraceMemoryInfra records memory-infra dumps. A dump is requested when the
+trace stops, more can be requested with TraceRecorder.MemoryDump().
+*/
+var TraceMemoryInfra = TraceCategories{
+	"-*",
+	"disabled-by-default-memory-infra",
+	"toplevel",
+}
+
+/*
+Merge returns the union of the category sets.
+*/
+func (categories TraceCategories) Merge(sets ...TraceCategories) TraceCategories {
+	seen := map[string]bool{}
+	merged := TraceCategories{}
+	for _, set := range append([]TraceCategories{categories}, sets...) {
+		for _, category := range set {
+			if !seen[category] {
+				seen[category] = true
+				merged = append(merged, category)
+			}
+		}
+	}
+	return merged
+}
+
+/*
+Config returns a trace config including and excluding the listed categories.
+*/
+func (categories TraceCategories) Config() *tracing.TraceConfig {
+	config := &tracing.TraceConfig{
+		RecordMode: tracing.RecordMode.RecordAsMuchAsPossible,
+	}
+	for _, category := range categories {
+		if strings.HasPrefix(category, "-") {
+			config.ExcludedCategories = append(config.ExcludedCategories, category[1:])
+		} else {
+			config.IncludedCategories = append(config.IncludedCategories, category)
+		}
+	}
+	return config
+}
+
+/*
+memoryInfra returns true if memory-infra dumps are recorded.
+*/
+func (categories TraceCategories) memoryInfra() bool {
+	for _, category := range categories {
+		if "disabled-by-default-memory-infra" == category {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Trace starts recording a performance trace. Trace data is kept by the browser
+until TraceRecorder.Stop() is called, the trace is then streamed into w as a
+trace-event JSON document that can be loaded in chrome://tracing or Perfetto.
+*/
+func (tab *Tab) Trace(
+	ctx context.Context,
+	categories TraceCategories,
+	w stdio.Writer,
+) (*TraceRecorder, error) {
+	if 0 == len(categories) {
+		categories = TraceDevtoolsTimeline
+	}
+	recorder := &TraceRecorder{
+		categories: categories,
+		complete:   make(chan *tracing.CompleteEvent, 1),
+		ctx:        ctx,
+		mux:        &sync.Mutex{},
+		tab:        tab,
+		w:          w,
+	}
+
+	// Listen for completion before starting so the event can't be missed.
+	recorder.handler = socket.NewEventHandler(
+		"Tracing.tracingComplete",
+		func(response *socket.Response) {
+			event := &tracing.CompleteEvent{}
+			if err := json.Unmarshal([]byte(response.Params), event); nil != err {
+				event.Err = err
+			}
+			select {
+			case recorder.complete <- event:
+			default:
+			}
+		},
+	)
+	tab.AddEventHandler(recorder.handler)
+
+	result := <-tab.Tracing().Start(&tracing.StartParams{
+		TransferMode: tracing.TransferMode.ReturnAsStream,
+		TraceConfig:  categories.Config(),
+	})
+	if nil != result.Err {
+		tab.RemoveEventHandler(recorder.handler)
+		return nil, wrapError(result.Err, codes.TraceStartFailed, "Tracing.start failed")
+	}
+	log.WithFields(log.Fields{"categories": categories}).Debug("trace started")
+
+	return recorder, nil
+}
+
+/*
+TraceRecorder streams a running trace to a writer.
+*/
+type TraceRecorder struct {
+	categories TraceCategories
+	complete   chan *tracing.CompleteEvent
+	ctx        context.Context
+	handler    socket.EventHandler
+	mux        *sync.Mutex
+	stopped    bool
+	tab        *Tab
+	w          stdio.Writer
+}
+
+/*
+MemoryDump requests a global memory dump and returns its GUID. The trace must
+include the memory-infra category.
+*/
+func (recorder *TraceRecorder) MemoryDump() (string, error) {
+	result := <-recorder.tab.Tracing().RequestMemoryDump()
+	if nil != result.Err {
+		return "", wrapError(result.Err, codes.TraceEndFailed, "Tracing.requestMemoryDump failed")
+	}
+	if !result.Success {
+		return result.DumpGUID, errs.New(codes.TraceEndFailed, "memory dump was not successful")
+	}
+	return result.DumpGUID, nil
+}
+
+/*
+Stop ends the trace and writes the trace data. Stop blocks until the whole
+trace has been written or the context is done.
+*/
+func (recorder *TraceRecorder) Stop() error {
+	recorder.mux.Lock()
+	defer recorder.mux.Unlock()
+	if recorder.stopped {
+		return errs.New(codes.TraceEndFailed, "trace already stopped")
+	}
+	recorder.stopped = true
+	defer recorder.tab.RemoveEventHandler(recorder.handler)
+
+	if recorder.categories.memoryInfra() {
+		if _, err := recorder.MemoryDump(); nil != err {
+			log.WithFields(log.Fields{"error": err}).Warn("final memory dump failed")
+		}
+	}
+
+	if result := <-recorder.tab.Tracing().End(); nil != result.Err {
+		return wrapError(result.Err, codes.TraceEndFailed, "Tracing.end failed")
+	}
+
+	var event *tracing.CompleteEvent
+	select {
+	case event = <-recorder.complete:
+	case <-recorder.ctx.Done():
+		return wrapError(recorder.ctx.Err(), codes.TraceEndFailed, "trace did not complete")
+	}
+	if nil != event.Err {
+		return wrapError(event.Err, codes.TraceEndFailed, "invalid tracingComplete event")
+	}
+	if "" == event.Stream {
+		return errs.New(codes.TraceEndFailed, "trace completed without a stream")
+	}
+
+	return recorder.tab.ReadStream(recorder.ctx, event.Stream, recorder.w)
+}
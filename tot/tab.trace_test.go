@@ -0,0 +1,80 @@
+package chrome
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mkenney/go-chrome/tot/io"
+	"github.com/mkenney/go-chrome/tot/tracing"
+)
+
+func TestTraceCategoriesConfig(t *testing.T) {
+	config := TraceDevtoolsTimeline.Merge(TraceMemoryInfra).Config()
+	if 1 != len(config.ExcludedCategories) || "*" != config.ExcludedCategories[0] {
+		t.Errorf("Expected '*' to be excluded, received %v", config.ExcludedCategories)
+	}
+	found := false
+	for _, category := range config.IncludedCategories {
+		if "disabled-by-default-memory-infra" == category {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected memory-infra to be included, received %v", config.IncludedCategories)
+	}
+	if tracing.RecordMode.RecordAsMuchAsPossible != config.RecordMode {
+		t.Errorf("Expected recordAsMuchAsPossible, received %s", config.RecordMode)
+	}
+}
+
+func TestTabTrace(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestTabTrace")
+	mockSocket.Respond("Tracing.start", func(params json.RawMessage) (interface{}, error) {
+		start := &tracing.StartParams{}
+		json.Unmarshal(params, start)
+		if tracing.TransferMode.ReturnAsStream != start.TransferMode {
+			t.Errorf("Expected ReturnAsStream, received %s", start.TransferMode)
+		}
+		return &tracing.StartResult{}, nil
+	})
+	mockSocket.Respond("Tracing.end", func(params json.RawMessage) (interface{}, error) {
+		go mockSocket.Fire("Tracing.tracingComplete", &tracing.CompleteEvent{Stream: "stream-1"})
+		return &tracing.EndResult{}, nil
+	})
+	chunks := []*io.ReadResult{
+		{Data: `{"traceEvents":[`},
+		{Data: base64.StdEncoding.EncodeToString([]byte(`]}`)), Base64Encoded: true, EOF: true},
+	}
+	mockSocket.Respond("IO.read", func(params json.RawMessage) (interface{}, error) {
+		chunk := chunks[0]
+		chunks = chunks[1:]
+		return chunk, nil
+	})
+	closed := make(chan bool, 1)
+	mockSocket.Respond("IO.close", func(params json.RawMessage) (interface{}, error) {
+		closed <- true
+		return &io.CloseResult{}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	buf := &bytes.Buffer{}
+	recorder, err := tab.Trace(ctx, TraceRendering, buf)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if err = recorder.Stop(); nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if `{"traceEvents":[]}` != buf.String() {
+		t.Errorf("Expected trace data, received '%s'", buf.String())
+	}
+	<-closed
+	if err = recorder.Stop(); nil == err {
+		t.Errorf("Expected error, received nil")
+	}
+}
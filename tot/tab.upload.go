@@ -0,0 +1,125 @@
+package chrome
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/dom"
+)
+
+/*
+UploadFile is an in-memory file for Element.SetFileData().
+*/
+type UploadFile struct {
+	// Name is the file name the page sees.
+	Name string
+
+	// Data is the file content.
+	Data []byte
+}
+
+/*
+fileInputCheck checks that the element is a file input that accepts the
+number of files.
+*/
+const fileInputCheck = `function(count) {
+	if (!this.isConnected) {
+		return 'attached';
+	}
+	if (!(this instanceof HTMLInputElement) || 'file' !== this.type) {
+		throw new Error('Element is not an <input type="file"> element');
+	}
+	if (count > 1 && !this.multiple) {
+		throw new Error('Element does not accept multiple files');
+	}
+	if (this.disabled) {
+		return 'enabled';
+	}
+	return '';
+}`
+
+/*
+SetFiles sets the files of an <input type="file"> element, which fires its
+input and change events. Relative paths are resolved against the working
+directory, the files must exist. Without paths the selection is cleared.
+
+File inputs are often hidden behind a styled button, so the element does not
+need to be visible.
+*/
+func (element *Element) SetFiles(paths ...string) error {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		absolute, err := filepath.Abs(path)
+		if nil != err {
+			return wrapError(err, codes.ActionFailed, fmt.Sprintf("invalid file path '%s'", path))
+		}
+		if info, err := os.Stat(absolute); nil != err {
+			return wrapError(err, codes.ActionFailed, fmt.Sprintf("could not read file '%s'", path))
+		} else if info.IsDir() {
+			return errs.New(codes.ActionFailed, fmt.Sprintf("'%s' is a directory", path))
+		}
+		files = append(files, absolute)
+	}
+
+	failed := ""
+	err := element.callFunction(fileInputCheck, &failed, len(files))
+	if _, ok := err.(errElementDetached); ok || "attached" == failed {
+		return errs.New(codes.ElementNotAttached, fmt.Sprintf("could not set the files of '%s': element is not attached to the document", element.Selector))
+	} else if nil != err {
+		return wrapError(err, codes.ActionFailed, fmt.Sprintf("could not set the files of '%s'", element.Selector))
+	} else if "enabled" == failed {
+		return errs.New(codes.ElementNotEnabled, fmt.Sprintf("could not set the files of '%s': element is not enabled", element.Selector))
+	}
+
+	if err := element.tab.sendCommand("DOM.setFileInputFiles", &dom.SetFileInputFilesParams{
+		Files:         files,
+		BackendNodeID: element.BackendNodeID,
+	}, &struct{}{}); nil != err {
+		return wrapError(err, codes.ActionFailed, fmt.Sprintf("could not set the files of '%s'", element.Selector))
+	}
+	return nil
+}
+
+/*
+SetFileData writes in-memory files to a temporary directory and sets them as
+the files of an <input type="file"> element, see Element.SetFiles(). The page
+reads the files when it uploads them, so they are kept until remove is
+called.
+*/
+func (element *Element) SetFileData(files ...*UploadFile) (remove func() error, err error) {
+	dir, err := ioutil.TempDir("", "go-chrome-upload")
+	if nil != err {
+		return nil, wrapError(err, codes.ActionFailed, "could not create the upload directory")
+	}
+	remove = func() error {
+		return os.RemoveAll(dir)
+	}
+
+	paths := make([]string, 0, len(files))
+	for i, file := range files {
+		// Each file has its own directory, so that names may repeat.
+		name := filepath.Base(file.Name)
+		if "." == name || string(filepath.Separator) == name {
+			name = "file"
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d", i), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); nil != err {
+			remove()
+			return nil, wrapError(err, codes.ActionFailed, "could not create the upload directory")
+		}
+		if err := ioutil.WriteFile(path, file.Data, 0600); nil != err {
+			remove()
+			return nil, wrapError(err, codes.ActionFailed, fmt.Sprintf("could not write the upload file '%s'", file.Name))
+		}
+		paths = append(paths, path)
+	}
+	if err := element.SetFiles(paths...); nil != err {
+		remove()
+		return nil, err
+	}
+	return remove, nil
+}
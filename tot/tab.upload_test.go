@@ -0,0 +1,95 @@
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+)
+
+func TestElementSetFiles(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestElementSetFiles")
+	mockSocket.Respond("DOM.resolveNode", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ BackendNodeID int }{}
+		json.Unmarshal(params, &request)
+		if 10 != request.BackendNodeID {
+			return nil, errors.New("No node with given id found")
+		}
+		return map[string]interface{}{"object": map[string]string{"type": "object", "objectId": "obj-10"}}, nil
+	})
+	mockSocket.Respond("Runtime.releaseObject", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("Runtime.callFunctionOn", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ Arguments []struct{ Value int } }{}
+		json.Unmarshal(params, &request)
+		if request.Arguments[0].Value > 1 {
+			return map[string]interface{}{
+				"result":           map[string]interface{}{"type": "object"},
+				"exceptionDetails": map[string]interface{}{"text": "Uncaught", "exception": map[string]string{"description": "Error: Element does not accept multiple files"}},
+			}, nil
+		}
+		return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": ""}}, nil
+	})
+	var mux sync.Mutex
+	var files [][]string
+	mockSocket.Respond("DOM.setFileInputFiles", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			Files         []string
+			BackendNodeID int
+		}{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		defer mux.Unlock()
+		files = append(files, request.Files)
+		return map[string]interface{}{}, nil
+	})
+
+	dir, err := ioutil.TempDir("", "TestElementSetFiles")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "avatar.png")
+	ioutil.WriteFile(path, []byte("png"), 0600)
+
+	input := tab.newElement(10, "css=input[type=file]")
+	if err := input.SetFiles(path); nil != err {
+		t.Fatalf("SetFiles failed: %s", err)
+	}
+	if err := input.SetFiles(path, path); nil == err || codes.ActionFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected ActionFailed for multiple files, received %v", err)
+	}
+	if err := input.SetFiles(filepath.Join(dir, "missing.png")); nil == err || codes.ActionFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected ActionFailed for a missing file, received %v", err)
+	}
+	if err := tab.newElement(11, "css=#gone").SetFiles(path); nil == err || codes.ElementNotAttached != err.(errs.Err).Code() {
+		t.Errorf("Expected ElementNotAttached, received %v", err)
+	}
+
+	remove, err := input.SetFileData(&UploadFile{Name: "report.csv", Data: []byte("a,b\n1,2\n")})
+	if nil != err {
+		t.Fatalf("SetFileData failed: %s", err)
+	}
+	mux.Lock()
+	if 2 != len(files) || path != files[0][0] || 1 != len(files[1]) || "report.csv" != filepath.Base(files[1][0]) {
+		t.Errorf("Unexpected files %v", files)
+	}
+	uploaded := files[1][0]
+	mux.Unlock()
+	if data, err := ioutil.ReadFile(uploaded); nil != err || "a,b\n1,2\n" != string(data) {
+		t.Errorf("Unexpected upload file %q (%v)", data, err)
+	}
+	if err := remove(); nil != err {
+		t.Errorf("remove failed: %s", err)
+	}
+	if _, err := os.Stat(uploaded); !os.IsNotExist(err) {
+		t.Errorf("The upload file was not removed: %v", err)
+	}
+}
@@ -0,0 +1,536 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/network"
+	"github.com/mkenney/go-chrome/tot/runtime"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+/*
+Selector states of Tab.WaitForSelector().
+*/
+const (
+	// SelectorAttached waits for an element matching the selector.
+	SelectorAttached = "attached"
+
+	// SelectorDetached waits until no element matches the selector.
+	SelectorDetached = "detached"
+
+	// SelectorVisible waits for a visible element matching the selector.
+	SelectorVisible = "visible"
+
+	// SelectorHidden waits until no visible element matches the selector.
+	SelectorHidden = "hidden"
+)
+
+/*
+Polling modes of Tab.WaitForFunction().
+*/
+const (
+	// PollRAF checks before every animation frame.
+	PollRAF = "raf"
+
+	// PollMutation checks after every DOM mutation.
+	PollMutation = "mutation"
+
+	// PollInterval checks every WaitPollInterval.
+	PollInterval = "interval"
+)
+
+/*
+WaitPollInterval is the interval of Tab.WaitForSelector() and of
+Tab.WaitForFunction() with PollInterval.
+*/
+var WaitPollInterval = 100 * time.Millisecond
+
+/*
+visibleCheck returns whether an element is rendered.
+*/
+const visibleCheck = `function() {
+	const element = Node.ELEMENT_NODE === this.nodeType ? this : this.parentElement;
+	if (!this.isConnected || !element || 'hidden' === getComputedStyle(element).visibility) {
+		return false;
+	}
+	const rect = element.getBoundingClientRect();
+	return rect.width > 0 && rect.height > 0;
+}`
+
+/*
+WaitForEvent waits for an event of a protocol method, e.g.
+"Page.loadEventFired", that matches the predicate. A nil predicate matches
+every event.
+
+The event handler is added before trigger is called, so events caused by
+trigger can not be missed. trigger may be nil. The predicate may be called
+concurrently.
+*/
+func (tab *Tab) WaitForEvent(
+	ctx context.Context,
+	method string,
+	predicate func(response *socket.Response) bool,
+	trigger func() error,
+) (*socket.Response, error) {
+	matched := make(chan *socket.Response, 1)
+	handler := socket.NewEventHandler(method, func(response *socket.Response) {
+		if nil == predicate || predicate(response) {
+			select {
+			case matched <- response:
+			default:
+			}
+		}
+	})
+	tab.AddEventHandler(handler)
+	defer tab.RemoveEventHandler(handler)
+
+	if nil != trigger {
+		if err := trigger(); nil != err {
+			return nil, wrapError(err, codes.WaitFailed, fmt.Sprintf("trigger of the wait for %s failed", method))
+		}
+	}
+	select {
+	case response := <-matched:
+		return response, nil
+	case <-ctx.Done():
+		return nil, wrapError(ctx.Err(), codes.WaitFailed, fmt.Sprintf("waiting for %s", method))
+	}
+}
+
+/*
+WaitForRequest enables the Network domain and waits for a request matching
+the predicate. A nil predicate matches every request. The post data of the
+request is fetched if Chrome did not include it in the event.
+
+See WaitForEvent() for trigger.
+*/
+func (tab *Tab) WaitForRequest(
+	ctx context.Context,
+	predicate func(request *network.Request) bool,
+	trigger func() error,
+) (*network.Request, error) {
+	if err := tab.enableNetwork(); nil != err {
+		return nil, err
+	}
+	var mux sync.Mutex
+	var request *network.Request
+	var requestID network.RequestID
+	var hasPostData bool
+	_, err := tab.WaitForEvent(ctx, "Network.requestWillBeSent", func(response *socket.Response) bool {
+		event := &struct {
+			RequestID network.RequestID `json:"requestId"`
+			Request   json.RawMessage   `json:"request"`
+		}{}
+		if err := json.Unmarshal(response.Params, event); nil != err {
+			return false
+		}
+		candidate := &network.Request{}
+		if err := decodeProtocolObject(event.Request, candidate, "initialPriority", "mixedContentType", "referrerPolicy"); nil != err {
+			return false
+		}
+		if nil != predicate && !predicate(candidate) {
+			return false
+		}
+		postData := &struct {
+			HasPostData bool `json:"hasPostData"`
+		}{}
+		json.Unmarshal(event.Request, postData)
+
+		mux.Lock()
+		defer mux.Unlock()
+		if nil == request {
+			request, requestID, hasPostData = candidate, event.RequestID, postData.HasPostData
+		}
+		return true
+	}, trigger)
+	if nil != err {
+		return nil, err
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if hasPostData && "" == request.PostData {
+		result := &struct {
+			PostData string `json:"postData"`
+		}{}
+		if err := tab.sendCommand("Network.getRequestPostData", map[string]interface{}{"requestId": requestID}, result); nil != err {
+			return request, wrapError(err, codes.WaitFailed, fmt.Sprintf("could not get the post data of %s", request.URL))
+		}
+		request.PostData = result.PostData
+	}
+	return request, nil
+}
+
+/*
+WaitForResponse enables the Network domain and waits for a response matching
+the predicate and for its body to be loaded. A nil predicate matches every
+response. The body is returned decoded. If the body is not available, e.g.
+for redirects, the response is returned with the error.
+
+See WaitForEvent() for trigger.
+*/
+func (tab *Tab) WaitForResponse(
+	ctx context.Context,
+	predicate func(response *network.Response) bool,
+	trigger func() error,
+) (*network.Response, []byte, error) {
+	if err := tab.enableNetwork(); nil != err {
+		return nil, nil, err
+	}
+
+	var mux sync.Mutex
+	var matched *network.Response
+	var matchedID network.RequestID
+	loaded := map[network.RequestID]string{}
+	done := make(chan string, 1)
+	finish := func() {
+		// Called with mux locked.
+		if nil == matched {
+			return
+		}
+		if failure, ok := loaded[matchedID]; ok {
+			select {
+			case done <- failure:
+			default:
+			}
+		}
+	}
+	loadingHandler := func(failed bool) func(*socket.Response) {
+		return func(response *socket.Response) {
+			event := &struct {
+				RequestID network.RequestID `json:"requestId"`
+				ErrorText string            `json:"errorText"`
+				Canceled  bool              `json:"canceled"`
+			}{}
+			if err := json.Unmarshal(response.Params, event); nil != err {
+				return
+			}
+			failure := ""
+			if failed {
+				failure = "loading failed: " + event.ErrorText
+				if event.Canceled {
+					failure = "loading canceled"
+				}
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			loaded[event.RequestID] = failure
+			finish()
+		}
+	}
+	handlers := []socket.EventHandler{
+		socket.NewEventHandler("Network.loadingFinished", loadingHandler(false)),
+		socket.NewEventHandler("Network.loadingFailed", loadingHandler(true)),
+	}
+	for _, handler := range handlers {
+		tab.AddEventHandler(handler)
+		defer tab.RemoveEventHandler(handler)
+	}
+
+	_, err := tab.WaitForEvent(ctx, "Network.responseReceived", func(response *socket.Response) bool {
+		event := &struct {
+			RequestID network.RequestID `json:"requestId"`
+			Response  json.RawMessage   `json:"response"`
+		}{}
+		if err := json.Unmarshal(response.Params, event); nil != err {
+			return false
+		}
+		candidate := &network.Response{}
+		if err := decodeProtocolObject(event.Response, candidate, "securityState", "securityDetails"); nil != err {
+			return false
+		}
+		if nil != predicate && !predicate(candidate) {
+			return false
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		if nil == matched {
+			matched, matchedID = candidate, event.RequestID
+			finish()
+		}
+		return true
+	}, trigger)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	mux.Lock()
+	response, requestID := matched, matchedID
+	mux.Unlock()
+	select {
+	case failure := <-done:
+		if "" != failure {
+			return response, nil, errs.New(codes.WaitFailed, fmt.Sprintf("%s: %s", response.URL, failure))
+		}
+	case <-ctx.Done():
+		return response, nil, wrapError(ctx.Err(), codes.WaitFailed, fmt.Sprintf("waiting for the body of %s", response.URL))
+	}
+
+	result := &struct {
+		Body          string `json:"body"`
+		Base64Encoded bool   `json:"base64Encoded"`
+	}{}
+	if err := tab.sendCommand("Network.getResponseBody", map[string]interface{}{"requestId": requestID}, result); nil != err {
+		return response, nil, wrapError(err, codes.WaitFailed, fmt.Sprintf("could not get the body of %s", response.URL))
+	}
+	if !result.Base64Encoded {
+		return response, []byte(result.Body), nil
+	}
+	body, err := base64.StdEncoding.DecodeString(result.Body)
+	if nil != err {
+		return response, nil, wrapError(err, codes.WaitFailed, fmt.Sprintf("invalid body of %s", response.URL))
+	}
+	return response, body, nil
+}
+
+/*
+WaitForSelector waits until the elements matching a Find() selector are in
+a state: SelectorAttached, SelectorDetached, SelectorVisible or
+SelectorHidden. It returns the first matching element for the attached and
+visible states, and nil otherwise.
+*/
+func (tab *Tab) WaitForSelector(ctx context.Context, selector, state string) (*Element, error) {
+	return tab.waitForSelector(ctx, selector, state, func() ([]*Element, error) {
+		return tab.Find(ctx, selector)
+	})
+}
+
+/*
+waitForSelector polls find until the found elements are in a state.
+*/
+func (tab *Tab) waitForSelector(ctx context.Context, selector, state string, find func() ([]*Element, error)) (*Element, error) {
+	switch state {
+	case SelectorAttached, SelectorDetached, SelectorVisible, SelectorHidden:
+	default:
+		return nil, errs.New(codes.WaitFailed, fmt.Sprintf("unknown selector state '%s'", state))
+	}
+	for {
+		elements, err := find()
+		if nil != err {
+			if code, ok := err.(errs.Err); ok && (codes.SelectorInvalid == code.Code() || codes.FrameDetached == code.Code()) {
+				return nil, err
+			}
+			if nil != ctx.Err() {
+				return nil, wrapError(ctx.Err(), codes.WaitFailed, fmt.Sprintf("waiting for '%s' to be %s", selector, state))
+			}
+			// The document may be replaced by a navigation.
+			elements = nil
+		}
+
+		switch state {
+		case SelectorAttached:
+			if len(elements) > 0 {
+				return elements[0], nil
+			}
+		case SelectorDetached:
+			if nil == err && 0 == len(elements) {
+				return nil, nil
+			}
+		default:
+			var visible *Element
+			for _, element := range elements {
+				isVisible := false
+				if err := element.callFunction(visibleCheck, &isVisible); nil == err && isVisible {
+					visible = element
+					break
+				}
+			}
+			if SelectorVisible == state && nil != visible {
+				return visible, nil
+			}
+			if SelectorHidden == state && nil == err && nil == visible {
+				return nil, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.WaitFailed, fmt.Sprintf("waiting for '%s' to be %s", selector, state))
+		case <-time.After(WaitPollInterval):
+		}
+	}
+}
+
+/*
+WaitForFunction waits until a JavaScript expression is truthy in the page
+and returns its value as JSON. If the expression is a function, the function
+is called instead. polling is PollRAF, PollMutation or PollInterval.
+
+The expression is polled in the page, a navigation restarts the wait in the
+new document. An exception thrown by the expression ends the wait.
+*/
+func (tab *Tab) WaitForFunction(ctx context.Context, js, polling string) (json.RawMessage, error) {
+	return tab.waitForFunction(ctx, js, polling, nil)
+}
+
+/*
+waitForFunction polls a JavaScript expression in the execution context
+returned by executionContext with the tab of its session, which is called
+again after the context was destroyed. A nil executionContext selects the
+main world of the top frame of the tab.
+*/
+func (tab *Tab) waitForFunction(
+	ctx context.Context,
+	js, polling string,
+	executionContext func(ctx context.Context) (*Tab, runtime.ExecutionContextID, error),
+) (json.RawMessage, error) {
+	switch polling {
+	case PollRAF, PollMutation, PollInterval:
+	default:
+		return nil, errs.New(codes.WaitFailed, fmt.Sprintf("unknown polling mode '%s'", polling))
+	}
+	waitID := fmt.Sprintf("wait%d", time.Now().UnixNano())
+	expression := fmt.Sprintf(`new Promise((resolve, reject) => {
+	const waits = window.__goChromeWaits = window.__goChromeWaits || {};
+	let predicate = () => (%s);
+	let done = false;
+	let observer, timer;
+	const finish = (value, error) => {
+		done = true;
+		delete waits[%q];
+		if (observer) observer.disconnect();
+		if (timer) clearInterval(timer);
+		error ? reject(error) : resolve(value);
+	};
+	const check = () => {
+		if (done) return;
+		try {
+			let value = predicate();
+			if ('function' === typeof value) {
+				predicate = value;
+				value = predicate();
+			}
+			if (value) finish(value);
+		} catch (error) {
+			finish(undefined, error);
+		}
+	};
+	waits[%q] = () => finish(undefined);
+	check();
+	if (done) return;
+	switch (%q) {
+	case 'raf':
+		const frame = () => { check(); if (!done) requestAnimationFrame(frame); };
+		requestAnimationFrame(frame);
+		break;
+	case 'mutation':
+		observer = new MutationObserver(check);
+		observer.observe(document, {attributes: true, characterData: true, childList: true, subtree: true});
+		break;
+	default:
+		timer = setInterval(check, %d);
+	}
+})`, js, waitID, waitID, polling, WaitPollInterval/time.Millisecond)
+
+	for {
+		session, contextID := tab, runtime.ExecutionContextID(0)
+		if nil != executionContext {
+			var err error
+			if session, contextID, err = executionContext(ctx); nil != err {
+				return nil, wrapError(err, codes.WaitFailed, "waiting for function")
+			}
+		}
+		results := make(chan *evaluateResult, 1)
+		go func() {
+			results <- session.evaluate(contextID, expression)
+		}()
+		select {
+		case <-ctx.Done():
+			session.evaluate(contextID, fmt.Sprintf(`window.__goChromeWaits && window.__goChromeWaits[%q] && window.__goChromeWaits[%q]()`, waitID, waitID))
+			return nil, wrapError(ctx.Err(), codes.WaitFailed, "waiting for function")
+		case result := <-results:
+			if nil == result.err {
+				return result.value, nil
+			}
+			if !contextDestroyed(result.err) {
+				return nil, wrapError(result.err, codes.WaitFailed, "waiting for function")
+			}
+		}
+	}
+}
+
+/*
+evaluateResult is the result of Tab.evaluate().
+*/
+type evaluateResult struct {
+	err   error
+	value json.RawMessage
+}
+
+/*
+evaluate evaluates an expression by value and awaits promises. A zero
+contextID selects the main world of the top frame.
+*/
+func (tab *Tab) evaluate(contextID runtime.ExecutionContextID, expression string) *evaluateResult {
+	evaluated := &struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception *struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}{}
+	params := map[string]interface{}{
+		"expression":    expression,
+		"returnByValue": true,
+		"awaitPromise":  true,
+	}
+	if 0 != contextID {
+		params["contextId"] = contextID
+	}
+	err := tab.sendCommand("Runtime.evaluate", params, evaluated)
+	if nil != err {
+		return &evaluateResult{err: err}
+	}
+	if details := evaluated.ExceptionDetails; nil != details {
+		if nil != details.Exception && "" != details.Exception.Description {
+			return &evaluateResult{err: errors.New(details.Exception.Description)}
+		}
+		return &evaluateResult{err: errors.New(details.Text)}
+	}
+	return &evaluateResult{value: evaluated.Result.Value}
+}
+
+/*
+enableNetwork enables the Network domain.
+*/
+func (tab *Tab) enableNetwork() error {
+	if result := <-tab.Network().Enable(&network.EnableParams{}); nil != result.Err {
+		return wrapError(result.Err, codes.WaitFailed, "could not enable the Network domain")
+	}
+	return nil
+}
+
+/*
+decodeProtocolObject decodes a protocol object. Enum values unknown to the
+protocol packages fail to decode, if decoding fails the enum fields are
+dropped and the object is decoded again.
+*/
+func decodeProtocolObject(data json.RawMessage, object interface{}, enumFields ...string) error {
+	if err := json.Unmarshal(data, object); nil == err {
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); nil != err {
+		return err
+	}
+	for _, field := range enumFields {
+		delete(fields, field)
+	}
+	stripped, err := json.Marshal(fields)
+	if nil != err {
+		return err
+	}
+	return json.Unmarshal(stripped, object)
+}
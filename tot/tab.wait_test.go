@@ -0,0 +1,243 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/network"
+	"github.com/mkenney/go-chrome/tot/socket"
+)
+
+func TestWaitForEvent(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestWaitForEvent")
+
+	// Events fired by the trigger are not missed.
+	response, err := tab.WaitForEvent(context.Background(), "Page.frameNavigated", func(response *socket.Response) bool {
+		return strings.Contains(string(response.Params), "second")
+	}, func() error {
+		mockSocket.Fire("Page.frameNavigated", map[string]string{"name": "first"})
+		mockSocket.Fire("Page.frameNavigated", map[string]string{"name": "second"})
+		return nil
+	})
+	if nil != err || `{"name":"second"}` != string(response.Params) {
+		t.Errorf("Unexpected event %v (%v)", response, err)
+	}
+
+	if _, err := tab.WaitForEvent(context.Background(), "Page.loadEventFired", nil, func() error {
+		return errors.New("navigation failed")
+	}); nil == err || codes.WaitFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected the trigger error, received %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tab.WaitForEvent(ctx, "Page.loadEventFired", nil, nil); nil == err || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Expected the context error, received %v", err)
+	}
+}
+
+func TestWaitForNetwork(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestWaitForNetwork")
+	mockSocket.Respond("Network.enable", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("Network.getRequestPostData", func(params json.RawMessage) (interface{}, error) {
+		return map[string]string{"postData": "a=1"}, nil
+	})
+	mockSocket.Respond("Network.getResponseBody", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ RequestID string }{}
+		json.Unmarshal(params, &request)
+		if "R2" == request.RequestID {
+			return map[string]interface{}{"body": "eyJvayI6dHJ1ZX0=", "base64Encoded": true}, nil
+		}
+		return map[string]interface{}{"body": "plain"}, nil
+	})
+
+	request, err := tab.WaitForRequest(context.Background(), func(request *network.Request) bool {
+		return "POST" == request.Method
+	}, func() error {
+		mockSocket.Fire("Network.requestWillBeSent", map[string]interface{}{
+			"requestId": "R1",
+			"request":   map[string]interface{}{"url": "https://example.com/", "method": "GET"},
+		})
+		mockSocket.Fire("Network.requestWillBeSent", map[string]interface{}{
+			"requestId": "R2",
+			"request": map[string]interface{}{
+				"url":            "https://example.com/api",
+				"method":         "POST",
+				"hasPostData":    true,
+				"referrerPolicy": "some-future-policy",
+			},
+		})
+		return nil
+	})
+	if nil != err || "https://example.com/api" != request.URL || "a=1" != request.PostData {
+		t.Errorf("Unexpected request %v (%v)", request, err)
+	}
+
+	// The body finishes loading before the response is matched and the
+	// other request fails, neither is missed.
+	var wg sync.WaitGroup
+	response, body, err := tab.WaitForResponse(context.Background(), func(response *network.Response) bool {
+		return strings.HasSuffix(response.URL, "/api")
+	}, func() error {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mockSocket.Fire("Network.loadingFinished", map[string]interface{}{"requestId": "R2"})
+			mockSocket.Fire("Network.loadingFailed", map[string]interface{}{"requestId": "R1", "errorText": "net::ERR_FAILED"})
+		}()
+		mockSocket.Fire("Network.responseReceived", map[string]interface{}{
+			"requestId": "R1",
+			"response":  map[string]interface{}{"url": "https://example.com/", "status": 200},
+		})
+		mockSocket.Fire("Network.responseReceived", map[string]interface{}{
+			"requestId": "R2",
+			"response":  map[string]interface{}{"url": "https://example.com/api", "status": 201, "securityState": "unknown-state"},
+		})
+		return nil
+	})
+	wg.Wait()
+	if nil != err || 201 != response.Status || `{"ok":true}` != string(body) {
+		t.Errorf("Unexpected response %v %s (%v)", response, body, err)
+	}
+
+	response, _, err = tab.WaitForResponse(context.Background(), nil, func() error {
+		mockSocket.Fire("Network.responseReceived", map[string]interface{}{
+			"requestId": "R3",
+			"response":  map[string]interface{}{"url": "https://example.com/gone", "status": 200},
+		})
+		mockSocket.Fire("Network.loadingFailed", map[string]interface{}{"requestId": "R3", "canceled": true})
+		return nil
+	})
+	if nil == err || nil == response || !strings.Contains(err.Error(), "https://example.com/gone: loading canceled") {
+		t.Errorf("Expected a canceled load, received %v", err)
+	}
+}
+
+func TestWaitForSelector(t *testing.T) {
+	interval := WaitPollInterval
+	WaitPollInterval = 5 * time.Millisecond
+	defer func() { WaitPollInterval = interval }()
+
+	tab, mockSocket := NewMockTab("https://TestWaitForSelector")
+	var mux sync.Mutex
+	queries := 0
+	visible := false
+	mockSocket.Respond("DOM.getDocument", func(params json.RawMessage) (interface{}, error) {
+		return json.RawMessage(testAXDocument), nil
+	})
+	mockSocket.Respond("DOM.querySelectorAll", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		queries++
+		if queries < 3 || queries > 6 {
+			return map[string]interface{}{"nodeIds": []int{}}, nil
+		}
+		return map[string]interface{}{"nodeIds": []int{10}}, nil
+	})
+	mockSocket.Respond("DOM.resolveNode", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"object": map[string]string{"objectId": "obj-10"}}, nil
+	})
+	mockSocket.Respond("Runtime.releaseObject", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	})
+	mockSocket.Respond("Runtime.callFunctionOn", func(params json.RawMessage) (interface{}, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		return map[string]interface{}{"result": map[string]interface{}{"value": visible}}, nil
+	})
+
+	// Queries 1-2 find nothing, 3-6 find the element, later queries find
+	// nothing again.
+	element, err := tab.WaitForSelector(context.Background(), "css=input", SelectorAttached)
+	if nil != err || 10 != element.BackendNodeID || 3 != queries {
+		t.Fatalf("Unexpected element %v after %d queries (%v)", element, queries, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := tab.WaitForSelector(ctx, "css=input", SelectorVisible); nil == err || codes.WaitFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected a timeout, received %v", err)
+	}
+	if element, err := tab.WaitForSelector(context.Background(), "css=input", SelectorHidden); nil != err || nil != element {
+		t.Errorf("Unexpected hidden result %v (%v)", element, err)
+	}
+	if _, err := tab.WaitForSelector(context.Background(), "css=input", SelectorDetached); nil != err {
+		t.Errorf("Unexpected detached error %v", err)
+	}
+
+	mux.Lock()
+	queries, visible = 2, true
+	mux.Unlock()
+	if element, err := tab.WaitForSelector(context.Background(), "css=input", SelectorVisible); nil != err || nil == element {
+		t.Errorf("Unexpected visible result %v (%v)", element, err)
+	}
+
+	if _, err := tab.WaitForSelector(context.Background(), "css=input", "gone"); nil == err {
+		t.Errorf("Expected an error for an unknown state")
+	}
+	if _, err := tab.WaitForSelector(context.Background(), "text=", SelectorAttached); nil == err || codes.SelectorInvalid != err.(errs.Err).Code() {
+		t.Errorf("Expected an invalid selector error, received %v", err)
+	}
+}
+
+func TestWaitForFunction(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestWaitForFunction")
+	var mux sync.Mutex
+	expressions := []string{}
+	mockSocket.Respond("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ Expression string }{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		expressions = append(expressions, request.Expression)
+		count := len(expressions)
+		mux.Unlock()
+		switch {
+		case strings.Contains(request.Expression, "throw"):
+			return map[string]interface{}{
+				"result":           map[string]interface{}{"type": "object"},
+				"exceptionDetails": map[string]interface{}{"text": "Uncaught", "exception": map[string]string{"description": "Error: failed"}},
+			}, nil
+		case strings.Contains(request.Expression, "window.never"):
+			select {}
+		case 1 == count:
+			return nil, errors.New("Execution context was destroyed.")
+		}
+		return map[string]interface{}{"result": map[string]interface{}{"value": map[string]int{"count": 2}}}, nil
+	})
+
+	value, err := tab.WaitForFunction(context.Background(), "document.querySelectorAll('li').length > 1 && {count: 2}", PollMutation)
+	if nil != err || `{"count":2}` != string(value) {
+		t.Errorf("Unexpected value %s (%v)", value, err)
+	}
+	if 2 != len(expressions) || !strings.Contains(expressions[1], `switch ("mutation")`) || !strings.Contains(expressions[1], "document.querySelectorAll('li')") {
+		t.Errorf("Unexpected expressions %v", expressions)
+	}
+
+	if _, err := tab.WaitForFunction(context.Background(), "(() => { throw new Error() })()", PollRAF); nil == err || !strings.Contains(err.Error(), "Error: failed") {
+		t.Errorf("Expected the exception, received %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tab.WaitForFunction(ctx, "window.never", PollInterval); nil == err || codes.WaitFailed != err.(errs.Err).Code() {
+		t.Errorf("Expected a timeout, received %v", err)
+	}
+	mux.Lock()
+	last := expressions[len(expressions)-1]
+	mux.Unlock()
+	if !strings.HasPrefix(last, "window.__goChromeWaits") {
+		t.Errorf("The wait was not canceled in the page: %s", last)
+	}
+
+	if _, err := tab.WaitForFunction(context.Background(), "true", "sometimes"); nil == err {
+		t.Errorf("Expected an error for an unknown polling mode")
+	}
+}
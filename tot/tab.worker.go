@@ -0,0 +1,479 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	errs "github.com/bdlm/errors"
+	"github.com/bdlm/log"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/socket"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+/*
+Worker target types.
+*/
+const (
+	// WorkerDedicated is a dedicated worker of a page.
+	WorkerDedicated = "worker"
+
+	// WorkerShared is a shared worker.
+	WorkerShared = "shared_worker"
+
+	// WorkerService is a service worker.
+	WorkerService = "service_worker"
+)
+
+/*
+NewWorkers returns the worker sessions of the tab. No worker is attached until
+the workers are enabled.
+*/
+func NewWorkers(tab *Tab) *Workers {
+	return &Workers{
+		changed: make(chan struct{}),
+		mux:     &sync.Mutex{},
+		pending: map[target.ID]bool{},
+		tab:     tab,
+	}
+}
+
+/*
+Workers attaches to dedicated, shared and service workers and exposes each of
+them as a session with its own Runtime, Debugger, Network and Profiler
+domains:
+
+	workers := chrome.NewWorkers(tab)
+	workers.Enable()
+	sw, _ := workers.WaitForWorker(ctx, func(worker *chrome.Worker) bool {
+		return chrome.WorkerService == worker.Type
+	})
+	pending, _ := sw.Evaluate(ctx, "syncQueue.length")
+
+Workers are discovered through Target.targetCreated and through the versions
+reported by ServiceWorker.workerVersionUpdated, so that a service worker that
+is started for a push or sync event is attached as well. Filter limits the
+attached workers, e.g. to the workers of an origin.
+*/
+type Workers struct {
+	// Filter is called with each discovered worker target, the target is only
+	// attached if it returns true. All workers are attached if Filter is nil.
+	Filter func(info *target.Info) bool
+
+	changed  chan struct{}
+	console  []func(worker *Worker, record *ConsoleRecord)
+	enabled  bool
+	handlers []socket.EventHandler
+	list     []*Worker
+	mux      *sync.Mutex
+	pending  map[target.ID]bool
+	tab      *Tab
+}
+
+/*
+Worker is an attached worker target.
+*/
+type Worker struct {
+	// ID is the target ID.
+	ID target.ID
+
+	// SessionID is the ID of the session attached to the worker.
+	SessionID target.SessionID
+
+	// Type is the worker type: WorkerDedicated, WorkerShared or
+	// WorkerService.
+	Type string
+
+	// URL is the script URL.
+	URL string
+
+	closed  bool
+	console []*ConsoleRecord
+	handler socket.EventHandler
+	mux     sync.Mutex
+	tab     *Tab
+	workers *Workers
+}
+
+/*
+Enable starts discovering workers and attaches to the running workers.
+*/
+func (workers *Workers) Enable() error {
+	workers.mux.Lock()
+	if workers.enabled {
+		workers.mux.Unlock()
+		return nil
+	}
+	workers.enabled = true
+	workers.handlers = []socket.EventHandler{
+		socket.NewOrderedEventHandler("Target.targetCreated", workers.onTargetCreated),
+		socket.NewOrderedEventHandler("Target.targetDestroyed", workers.onTargetDestroyed),
+		socket.NewOrderedEventHandler("Target.detachedFromTarget", workers.onDetachedFromTarget),
+		socket.NewOrderedEventHandler("ServiceWorker.workerVersionUpdated", workers.onVersionUpdated),
+	}
+	handlers := workers.handlers
+	workers.mux.Unlock()
+	for _, handler := range handlers {
+		workers.tab.AddEventHandler(handler)
+	}
+
+	// Targets that exist already are reported by Target.targetCreated events.
+	if result := <-workers.tab.Target().SetDiscoverTargets(&target.SetDiscoverTargetsParams{
+		Discover: true,
+	}); nil != result.Err {
+		return wrapError(result.Err, codes.WorkerFailed, "Target.setDiscoverTargets failed")
+	}
+	if result := <-workers.tab.ServiceWorker().Enable(); nil != result.Err {
+		return wrapError(result.Err, codes.WorkerFailed, "ServiceWorker.enable failed")
+	}
+	return nil
+}
+
+/*
+Disable stops discovering workers and detaches from the attached workers.
+*/
+func (workers *Workers) Disable() error {
+	workers.mux.Lock()
+	handlers := workers.handlers
+	list := workers.list
+	workers.enabled = false
+	workers.handlers = nil
+	workers.list = nil
+	workers.pending = map[target.ID]bool{}
+	workers.mux.Unlock()
+
+	for _, handler := range handlers {
+		workers.tab.RemoveEventHandler(handler)
+	}
+	var err error
+	for _, worker := range list {
+		worker.close()
+		if result := <-workers.tab.Target().DetachFromTarget(&target.DetachFromTargetParams{
+			SessionID: worker.SessionID,
+		}); nil != result.Err && nil == err {
+			err = wrapError(result.Err, codes.WorkerFailed, fmt.Sprintf("could not detach from worker %s", worker.ID))
+		}
+	}
+	return err
+}
+
+/*
+List returns the attached workers in the order they were attached.
+*/
+func (workers *Workers) List() []*Worker {
+	workers.mux.Lock()
+	defer workers.mux.Unlock()
+	return append([]*Worker{}, workers.list...)
+}
+
+/*
+Worker returns the attached worker with a target ID, nil if there is none.
+*/
+func (workers *Workers) Worker(id target.ID) *Worker {
+	workers.mux.Lock()
+	defer workers.mux.Unlock()
+	for _, worker := range workers.list {
+		if id == worker.ID {
+			return worker
+		}
+	}
+	return nil
+}
+
+/*
+WaitForWorker waits for an attached worker matching the predicate, which is
+called for each attached worker whenever a worker is attached.
+*/
+func (workers *Workers) WaitForWorker(ctx context.Context, predicate func(worker *Worker) bool) (*Worker, error) {
+	for {
+		workers.mux.Lock()
+		list := workers.list
+		changed := workers.changed
+		workers.mux.Unlock()
+		for _, worker := range list {
+			if predicate(worker) {
+				return worker, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, wrapError(ctx.Err(), codes.WorkerFailed, "waiting for a worker")
+		case <-changed:
+		}
+	}
+}
+
+/*
+OnConsole adds a callback that is called for each console message of the
+attached workers. Messages that were logged before a worker was attached are
+reported when it is attached.
+*/
+func (workers *Workers) OnConsole(callback func(worker *Worker, record *ConsoleRecord)) {
+	workers.mux.Lock()
+	workers.console = append(workers.console, callback)
+	workers.mux.Unlock()
+}
+
+/*
+notify wakes up the waits for workers.
+*/
+func (workers *Workers) notify() {
+	close(workers.changed)
+	workers.changed = make(chan struct{})
+}
+
+func (workers *Workers) onTargetCreated(response *socket.Response) {
+	event := &target.CreatedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err || nil == event.Info {
+		return
+	}
+	switch event.Info.Type {
+	case WorkerDedicated, WorkerShared, WorkerService:
+		// Commands of the session are answered by events of the tab.
+		go workers.attach(event.Info)
+	}
+}
+
+func (workers *Workers) onVersionUpdated(response *socket.Response) {
+	// Only the required fields are decoded, the status enums of the worker
+	// package fail on unknown values.
+	event := &struct {
+		Versions []struct {
+			RunningStatus string    `json:"runningStatus"`
+			ScriptURL     string    `json:"scriptURL"`
+			TargetID      target.ID `json:"targetId"`
+		} `json:"versions"`
+	}{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	for _, version := range event.Versions {
+		if "" == version.TargetID || "stopped" == version.RunningStatus || "stopping" == version.RunningStatus {
+			continue
+		}
+		go workers.attach(&target.Info{
+			ID:   version.TargetID,
+			Type: WorkerService,
+			URL:  version.ScriptURL,
+		})
+	}
+}
+
+func (workers *Workers) onTargetDestroyed(response *socket.Response) {
+	event := &target.DestroyedEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	workers.remove(func(worker *Worker) bool {
+		return event.ID == worker.ID
+	})
+	workers.mux.Lock()
+	delete(workers.pending, event.ID)
+	workers.mux.Unlock()
+}
+
+func (workers *Workers) onDetachedFromTarget(response *socket.Response) {
+	event := &target.DetachedFromTargetEvent{}
+	if err := json.Unmarshal(response.Params, event); nil != err {
+		return
+	}
+	workers.remove(func(worker *Worker) bool {
+		return event.SessionID == worker.SessionID
+	})
+}
+
+/*
+remove closes and removes the attached workers matching a predicate.
+*/
+func (workers *Workers) remove(predicate func(worker *Worker) bool) {
+	workers.mux.Lock()
+	defer workers.mux.Unlock()
+	list := workers.list[:0:0]
+	for _, worker := range workers.list {
+		if predicate(worker) {
+			worker.close()
+			delete(workers.pending, worker.ID)
+			continue
+		}
+		list = append(list, worker)
+	}
+	workers.list = list
+	workers.notify()
+}
+
+/*
+attach attaches to a worker target unless it is attached already or filtered.
+*/
+func (workers *Workers) attach(info *target.Info) {
+	workers.mux.Lock()
+	filter := workers.Filter
+	if !workers.enabled || workers.pending[info.ID] || (nil != filter && !filter(info)) {
+		workers.mux.Unlock()
+		return
+	}
+	workers.pending[info.ID] = true
+	workers.mux.Unlock()
+
+	worker, err := workers.open(info)
+	if nil != err {
+		log.WithFields(log.Fields{"error": err, "targetID": info.ID, "url": info.URL}).
+			Warn("could not attach to worker")
+		workers.mux.Lock()
+		delete(workers.pending, info.ID)
+		workers.mux.Unlock()
+		return
+	}
+
+	workers.mux.Lock()
+	defer workers.mux.Unlock()
+	if !workers.pending[info.ID] {
+		// The worker was destroyed or the workers disabled while attaching.
+		worker.close()
+		go workers.tab.Target().DetachFromTarget(&target.DetachFromTargetParams{
+			SessionID: worker.SessionID,
+		})
+		return
+	}
+	workers.list = append(workers.list, worker)
+	workers.notify()
+}
+
+/*
+open attaches a session to a worker target and enables its Runtime domain to
+capture console messages.
+*/
+func (workers *Workers) open(info *target.Info) (*Worker, error) {
+	result := <-workers.tab.Target().AttachToTarget(&target.AttachToTargetParams{
+		ID: info.ID,
+	})
+	if nil != result.Err {
+		return nil, wrapError(result.Err, codes.WorkerFailed, "Target.attachToTarget failed")
+	}
+	worker := &Worker{
+		ID:        info.ID,
+		SessionID: result.SessionID,
+		Type:      info.Type,
+		URL:       info.URL,
+		tab:       newSessionTab(workers.tab, result.SessionID, info),
+		workers:   workers,
+	}
+	worker.handler = socket.NewOrderedEventHandler("Runtime.consoleAPICalled", worker.onConsole)
+	worker.tab.AddEventHandler(worker.handler)
+	if result := <-worker.tab.Runtime().Enable(); nil != result.Err {
+		worker.close()
+		return nil, wrapError(result.Err, codes.WorkerFailed, "Runtime.enable failed")
+	}
+	return worker, nil
+}
+
+/*
+Tab returns the tab of the worker session. Domains that the worker does not
+support, e.g. DOM or Page, fail.
+*/
+func (worker *Worker) Tab() *Tab {
+	return worker.tab
+}
+
+/*
+Runtime returns the Runtime domain of the worker.
+*/
+func (worker *Worker) Runtime() *socket.RuntimeProtocol {
+	return worker.tab.Runtime()
+}
+
+/*
+Debugger returns the Debugger domain of the worker.
+*/
+func (worker *Worker) Debugger() *socket.DebuggerProtocol {
+	return worker.tab.Debugger()
+}
+
+/*
+Network returns the Network domain of the worker.
+*/
+func (worker *Worker) Network() *socket.NetworkProtocol {
+	return worker.tab.Network()
+}
+
+/*
+Profiler returns the Profiler domain of the worker.
+*/
+func (worker *Worker) Profiler() *socket.ProfilerProtocol {
+	return worker.tab.Profiler()
+}
+
+/*
+Closed returns whether the worker was destroyed or detached.
+*/
+func (worker *Worker) Closed() bool {
+	worker.mux.Lock()
+	defer worker.mux.Unlock()
+	return worker.closed
+}
+
+/*
+Console returns the console messages of the worker since it was attached.
+*/
+func (worker *Worker) Console() []*ConsoleRecord {
+	worker.mux.Lock()
+	defer worker.mux.Unlock()
+	return append([]*ConsoleRecord{}, worker.console...)
+}
+
+/*
+Evaluate evaluates an expression in the global scope of the worker and
+returns its JSON value. Promises are awaited.
+*/
+func (worker *Worker) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	if worker.Closed() {
+		return nil, errs.New(codes.WorkerClosed, fmt.Sprintf("worker %s was closed", worker.ID))
+	}
+	results := make(chan *evaluateResult, 1)
+	go func() {
+		results <- worker.tab.evaluate(0, expression)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, wrapError(ctx.Err(), codes.WorkerFailed, fmt.Sprintf("evaluating in worker %s", worker.ID))
+	case result := <-results:
+		if nil == result.err {
+			return result.value, nil
+		}
+		if worker.Closed() {
+			return nil, wrapError(result.err, codes.WorkerClosed, fmt.Sprintf("worker %s was closed", worker.ID))
+		}
+		return nil, wrapError(result.err, codes.WorkerFailed, fmt.Sprintf("evaluating in worker %s", worker.ID))
+	}
+}
+
+func (worker *Worker) onConsole(response *socket.Response) {
+	record := consoleRecord(response.Params)
+	if nil == record {
+		return
+	}
+	worker.mux.Lock()
+	worker.console = append(worker.console, record)
+	worker.mux.Unlock()
+
+	worker.workers.mux.Lock()
+	callbacks := worker.workers.console
+	worker.workers.mux.Unlock()
+	for _, callback := range callbacks {
+		callback(worker, record)
+	}
+}
+
+/*
+close marks the worker as closed and stops listening to its console.
+*/
+func (worker *Worker) close() {
+	worker.mux.Lock()
+	closed := worker.closed
+	worker.closed = true
+	worker.mux.Unlock()
+	if !closed {
+		worker.tab.RemoveEventHandler(worker.handler)
+	}
+}
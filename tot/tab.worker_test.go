@@ -0,0 +1,163 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errs "github.com/bdlm/errors"
+	"github.com/mkenney/go-chrome/codes"
+	"github.com/mkenney/go-chrome/tot/target"
+)
+
+func TestWorkers(t *testing.T) {
+	tab, mockSocket := NewMockTab("https://TestWorkers")
+	for _, method := range []string{"Target.setDiscoverTargets", "ServiceWorker.enable"} {
+		mockSocket.Respond(method, func(params json.RawMessage) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		})
+	}
+	var mux sync.Mutex
+	attached := []string{}
+	detached := []string{}
+	mockSocket.Respond("Target.attachToTarget", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ TargetID string }{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		defer mux.Unlock()
+		attached = append(attached, request.TargetID)
+		return map[string]string{"sessionId": "S-" + request.TargetID}, nil
+	})
+	mockSocket.Respond("Target.detachFromTarget", func(params json.RawMessage) (interface{}, error) {
+		request := struct{ SessionID string }{}
+		json.Unmarshal(params, &request)
+		mux.Lock()
+		defer mux.Unlock()
+		detached = append(detached, request.SessionID)
+		return map[string]interface{}{}, nil
+	})
+
+	// Worker sessions answer through Target.receivedMessageFromTarget events,
+	// the service worker logged a message before it was attached.
+	receive := func(sessionID string, message interface{}) {
+		data, _ := json.Marshal(message)
+		mockSocket.Fire("Target.receivedMessageFromTarget", map[string]string{"sessionId": sessionID, "message": string(data)})
+	}
+	mockSocket.Respond("Target.sendMessageToTarget", func(params json.RawMessage) (interface{}, error) {
+		request := struct {
+			Message   string
+			SessionID string
+		}{}
+		json.Unmarshal(params, &request)
+		command := struct {
+			ID     int
+			Method string
+		}{}
+		json.Unmarshal([]byte(request.Message), &command)
+		result := `{}`
+		if "Runtime.evaluate" == command.Method {
+			result = fmt.Sprintf(`{"result": {"value": %q}}`, request.SessionID)
+		}
+		go func() {
+			if "Runtime.enable" == command.Method && "S-SW1" == request.SessionID {
+				receive(request.SessionID, map[string]interface{}{
+					"method": "Runtime.consoleAPICalled",
+					"params": map[string]interface{}{
+						"type":      "log",
+						"args":      []map[string]string{{"type": "string", "value": "synced"}, {"type": "number", "value": "2", "description": "2"}},
+						"timestamp": 1500000000000,
+					},
+				})
+			}
+			receive(request.SessionID, map[string]interface{}{"id": command.ID, "result": json.RawMessage(result)})
+		}()
+		return map[string]interface{}{}, nil
+	})
+
+	workers := NewWorkers(tab)
+	workers.Filter = func(info *target.Info) bool {
+		return !strings.Contains(info.URL, "other.example")
+	}
+	consoleMux := sync.Mutex{}
+	console := []string{}
+	workers.OnConsole(func(worker *Worker, record *ConsoleRecord) {
+		consoleMux.Lock()
+		defer consoleMux.Unlock()
+		console = append(console, string(worker.ID)+": "+record.Text)
+	})
+	if err := workers.Enable(); nil != err {
+		t.Fatalf("Enable failed: %s", err)
+	}
+	mockSocket.Fire("Target.targetCreated", map[string]interface{}{
+		"targetInfo": map[string]string{"targetId": "P1", "type": "page", "url": "https://example.com/"},
+	})
+	mockSocket.Fire("Target.targetCreated", map[string]interface{}{
+		"targetInfo": map[string]string{"targetId": "X1", "type": "shared_worker", "url": "https://other.example/shared.js"},
+	})
+	mockSocket.Fire("Target.targetCreated", map[string]interface{}{
+		"targetInfo": map[string]string{"targetId": "W1", "type": "worker", "url": "https://example.com/worker.js"},
+	})
+	mockSocket.Fire("ServiceWorker.workerVersionUpdated", map[string]interface{}{"versions": []map[string]string{
+		{"versionId": "0", "registrationId": "1", "scriptURL": "https://example.com/old-sw.js", "runningStatus": "stopped", "status": "redundant", "targetId": "SW0"},
+		{"versionId": "1", "registrationId": "1", "scriptURL": "https://example.com/sw.js", "runningStatus": "running", "status": "activated", "targetId": "SW1"},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sw, err := workers.WaitForWorker(ctx, func(worker *Worker) bool {
+		return WorkerService == worker.Type
+	})
+	if nil != err || "SW1" != sw.ID || "https://example.com/sw.js" != sw.URL {
+		t.Fatalf("Unexpected service worker %v (%v)", sw, err)
+	}
+	dedicated, err := workers.WaitForWorker(ctx, func(worker *Worker) bool {
+		return WorkerDedicated == worker.Type
+	})
+	if nil != err || dedicated != workers.Worker("W1") || 2 != len(workers.List()) {
+		t.Fatalf("Unexpected worker %v (%v)", dedicated, err)
+	}
+	mux.Lock()
+	if 2 != len(attached) {
+		t.Errorf("Unexpected attached targets %v", attached)
+	}
+	mux.Unlock()
+
+	if value, err := sw.Evaluate(ctx, "syncQueue.length"); nil != err || `"S-SW1"` != string(value) {
+		t.Errorf("Unexpected service worker value %s (%v)", value, err)
+	}
+	if value, err := dedicated.Evaluate(ctx, "self.name"); nil != err || `"S-W1"` != string(value) {
+		t.Errorf("Unexpected worker value %s (%v)", value, err)
+	}
+	if records := sw.Console(); 1 != len(records) || "synced 2" != records[0].Text {
+		t.Errorf("Unexpected console %v", records)
+	}
+	consoleMux.Lock()
+	if "[SW1: synced 2]" != fmt.Sprint(console) {
+		t.Errorf("Unexpected console callbacks %v", console)
+	}
+	consoleMux.Unlock()
+
+	mockSocket.Fire("Target.targetDestroyed", map[string]string{"targetId": "W1"})
+	for !dedicated.Closed() {
+		time.Sleep(time.Millisecond)
+	}
+	if 1 != len(workers.List()) {
+		t.Errorf("Unexpected workers after closing %v", workers.List())
+	}
+	if _, err := dedicated.Evaluate(ctx, "1"); nil == err || codes.WorkerClosed != err.(errs.Err).Code() {
+		t.Errorf("Expected WorkerClosed, received %v", err)
+	}
+
+	if err := workers.Disable(); nil != err {
+		t.Errorf("Disable failed: %s", err)
+	}
+	mux.Lock()
+	if "[S-SW1]" != fmt.Sprint(detached) || !sw.Closed() {
+		t.Errorf("Unexpected detached sessions %v", detached)
+	}
+	mux.Unlock()
+}
@@ -0,0 +1,204 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+Phase is the type of a trace event.
+*/
+type Phase string
+
+const (
+	// PhaseBegin marks the beginning of a duration event.
+	PhaseBegin Phase = "B"
+	// PhaseEnd marks the end of a duration event.
+	PhaseEnd Phase = "E"
+	// PhaseComplete is a duration event with an explicit duration.
+	PhaseComplete Phase = "X"
+	// PhaseInstant is an instant event.
+	PhaseInstant Phase = "I"
+	// PhaseInstantLegacy is the deprecated instant event phase.
+	PhaseInstantLegacy Phase = "i"
+	// PhaseCounter is a counter event.
+	PhaseCounter Phase = "C"
+	// PhaseAsyncBegin begins a nestable async event.
+	PhaseAsyncBegin Phase = "b"
+	// PhaseAsyncEnd ends a nestable async event.
+	PhaseAsyncEnd Phase = "e"
+	// PhaseAsyncInstant is a nestable async instant event.
+	PhaseAsyncInstant Phase = "n"
+	// PhaseAsyncStart begins a legacy async event.
+	PhaseAsyncStart Phase = "S"
+	// PhaseAsyncStep is a step of a legacy async event.
+	PhaseAsyncStep Phase = "T"
+	// PhaseAsyncFinish ends a legacy async event.
+	PhaseAsyncFinish Phase = "F"
+	// PhaseMetadata is a metadata event.
+	PhaseMetadata Phase = "M"
+	// PhaseMark is a navigation timing or user timing mark.
+	PhaseMark Phase = "R"
+	// PhaseSample is a sample event.
+	PhaseSample Phase = "P"
+)
+
+/*
+ID is a trace event ID. IDs may be encoded as strings or numbers.
+*/
+type ID string
+
+/*
+UnmarshalJSON implements json.Unmarshaler
+*/
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); nil != err {
+		return err
+	}
+	switch value.(type) {
+	case nil:
+		*id = ""
+	case string:
+		*id = ID(value.(string))
+	case float64:
+		*id = ID(strings.TrimSpace(string(data)))
+	default:
+		return fmt.Errorf("trace: invalid id %s", data)
+	}
+	return nil
+}
+
+/*
+ID2 is a scoped trace event ID.
+*/
+type ID2 struct {
+	// Global IDs are unique across processes.
+	Global ID `json:"global,omitempty"`
+
+	// Local IDs are unique within a process.
+	Local ID `json:"local,omitempty"`
+}
+
+/*
+Event is a single trace event.
+*/
+type Event struct {
+	// Name of the event.
+	Name string `json:"name"`
+
+	// Cat is a comma separated list of categories.
+	Cat string `json:"cat"`
+
+	// Phase of the event.
+	Phase Phase `json:"ph"`
+
+	// Timestamp in microseconds.
+	Timestamp float64 `json:"ts"`
+
+	// Duration in microseconds, complete events only.
+	Duration float64 `json:"dur,omitempty"`
+
+	// ThreadTimestamp is the thread clock timestamp in microseconds.
+	ThreadTimestamp float64 `json:"tts,omitempty"`
+
+	// ThreadDuration is the thread clock duration in microseconds.
+	ThreadDuration float64 `json:"tdur,omitempty"`
+
+	// PID is the process ID.
+	PID int `json:"pid"`
+
+	// TID is the thread ID.
+	TID int `json:"tid"`
+
+	// ID of async and object events.
+	ID ID `json:"id,omitempty"`
+
+	// ID2 is the scoped ID of async events.
+	ID2 *ID2 `json:"id2,omitempty"`
+
+	// Scope of the event ID.
+	Scope string `json:"scope,omitempty"`
+
+	// InstantScope is the scope of instant events: g, p or t.
+	InstantScope string `json:"s,omitempty"`
+
+	// Args contains the event arguments.
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+/*
+End returns the end timestamp of complete events.
+*/
+func (event *Event) End() float64 {
+	return event.Timestamp + event.Duration
+}
+
+/*
+HasCategory checks if the event belongs to the category.
+*/
+func (event *Event) HasCategory(category string) bool {
+	for _, cat := range strings.Split(event.Cat, ",") {
+		if category == strings.TrimSpace(cat) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Arg returns a nested argument value, e.g. Arg("data", "url"), or nil if it
+does not exist.
+*/
+func (event *Event) Arg(path ...string) interface{} {
+	var value interface{} = event.Args
+	for _, key := range path {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = values[key]
+	}
+	return value
+}
+
+/*
+ArgString returns a nested string argument or an empty string.
+*/
+func (event *Event) ArgString(path ...string) string {
+	value, _ := event.Arg(path...).(string)
+	return value
+}
+
+/*
+ArgFloat returns a nested numeric argument or 0.
+*/
+func (event *Event) ArgFloat(path ...string) float64 {
+	value, _ := event.Arg(path...).(float64)
+	return value
+}
+
+/*
+ArgBool returns a nested boolean argument or false.
+*/
+func (event *Event) ArgBool(path ...string) bool {
+	value, _ := event.Arg(path...).(bool)
+	return value
+}
+
+/*
+AsyncID returns the key used to match async events. Local IDs are scoped to
+their process.
+*/
+func (event *Event) AsyncID() string {
+	id := string(event.ID)
+	if nil != event.ID2 {
+		if "" != event.ID2.Global {
+			id = string(event.ID2.Global)
+		} else {
+			id = fmt.Sprintf("%d:%s", event.PID, event.ID2.Local)
+		}
+	}
+	return fmt.Sprintf("%s:%s:%s", event.Cat, event.Scope, id)
+}
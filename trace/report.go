@@ -0,0 +1,366 @@
+package trace
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+LongTaskThreshold is the duration above which a main thread task is a long
+task. Time above the threshold counts towards Total Blocking Time.
+*/
+const LongTaskThreshold = 50 * time.Millisecond
+
+/*
+Activity is a category of main thread work.
+*/
+type Activity string
+
+const (
+	// ActivityScript is script compilation and execution.
+	ActivityScript Activity = "script"
+	// ActivityStyle is style recalculation.
+	ActivityStyle Activity = "style"
+	// ActivityLayout is layout.
+	ActivityLayout Activity = "layout"
+	// ActivityPaint is painting, compositing and image decoding.
+	ActivityPaint Activity = "paint"
+	// ActivityParse is HTML and CSS parsing.
+	ActivityParse Activity = "parse"
+	// ActivityGC is garbage collection.
+	ActivityGC Activity = "gc"
+	// ActivityOther is any other main thread work.
+	ActivityOther Activity = "other"
+)
+
+/*
+activities maps trace event names to main thread activities.
+*/
+var activities = map[string]Activity{
+	"EvaluateScript":             ActivityScript,
+	"v8.evaluateModule":          ActivityScript,
+	"v8.compile":                 ActivityScript,
+	"v8.compileModule":           ActivityScript,
+	"v8.produceCache":            ActivityScript,
+	"v8.run":                     ActivityScript,
+	"V8.Execute":                 ActivityScript,
+	"FunctionCall":               ActivityScript,
+	"TimerFire":                  ActivityScript,
+	"EventDispatch":              ActivityScript,
+	"FireAnimationFrame":         ActivityScript,
+	"FireIdleCallback":           ActivityScript,
+	"RunMicrotasks":              ActivityScript,
+	"XHRReadyStateChange":        ActivityScript,
+	"XHRLoad":                    ActivityScript,
+	"ParseHTML":                  ActivityParse,
+	"ParseAuthorStyleSheet":      ActivityParse,
+	"UpdateLayoutTree":           ActivityStyle,
+	"RecalculateStyles":          ActivityStyle,
+	"Layout":                     ActivityLayout,
+	"UpdateLayerTree":            ActivityLayout,
+	"Paint":                      ActivityPaint,
+	"PaintImage":                 ActivityPaint,
+	"PrePaint":                   ActivityPaint,
+	"PaintSetup":                 ActivityPaint,
+	"Rasterize":                  ActivityPaint,
+	"RasterTask":                 ActivityPaint,
+	"CompositeLayers":            ActivityPaint,
+	"Layerize":                   ActivityPaint,
+	"UpdateLayer":                ActivityPaint,
+	"Decode Image":               ActivityPaint,
+	"ImageDecodeTask":            ActivityPaint,
+	"MinorGC":                    ActivityGC,
+	"MajorGC":                    ActivityGC,
+	"V8.GCScavenger":             ActivityGC,
+	"V8.GCIncrementalMarking":    ActivityGC,
+	"V8.GCFinalizeMC":            ActivityGC,
+	"V8.GCCompactor":             ActivityGC,
+	"BlinkGC.AtomicPhase":        ActivityGC,
+	"BlinkGC.CompleteSweep":      ActivityGC,
+	"BlinkGC.LazySweepInIdle":    ActivityGC,
+	"ThreadState::performGC":     ActivityGC,
+	"GCEvent":                    ActivityGC,
+	"BlinkGCMarking":             ActivityGC,
+	"ThreadState::completeSweep": ActivityGC,
+}
+
+/*
+Task is a top-level main thread task.
+*/
+type Task struct {
+	// Name of the task event.
+	Name string
+
+	// Start is the task start relative to navigation start.
+	Start time.Duration
+
+	// Duration of the task.
+	Duration time.Duration
+}
+
+/*
+Report contains page timings computed from a trace. Timings are relative to
+navigation start, a zero value means the metric was not found in the trace.
+*/
+type Report struct {
+	// URL of the main frame, if known.
+	URL string
+
+	// PID and TID of the renderer main thread that was analysed.
+	PID int
+	TID int
+
+	// NavigationStart is the trace timestamp of the navigation in
+	// microseconds. If no navigation was recorded this is the trace start.
+	NavigationStart float64
+
+	// FirstContentfulPaint is the time of the first contentful paint.
+	FirstContentfulPaint time.Duration
+
+	// LargestContentfulPaint is the time of the largest contentful paint.
+	LargestContentfulPaint time.Duration
+
+	// CumulativeLayoutShift is the largest session window of layout shifts
+	// without recent input.
+	CumulativeLayoutShift float64
+
+	// TotalBlockingTime is the sum of the time above LongTaskThreshold of all
+	// main thread tasks after first contentful paint.
+	TotalBlockingTime time.Duration
+
+	// LongTasks contains the main thread tasks longer than
+	// LongTaskThreshold.
+	LongTasks []*Task
+
+	// MainThread contains the main thread self time per activity after
+	// navigation start.
+	MainThread map[Activity]time.Duration
+}
+
+/*
+Analyze computes page timings for the main frame of a trace.
+*/
+func Analyze(trace *Trace) (*Report, error) {
+	timeline := trace.Timeline()
+	report := &Report{
+		NavigationStart: timeline.Start,
+		MainThread:      map[Activity]time.Duration{},
+	}
+
+	frame, thread := mainThread(trace, timeline, report)
+	if nil == thread {
+		return nil, fmt.Errorf("trace: no renderer main thread found")
+	}
+	report.PID, report.TID = thread.PID, thread.TID
+
+	inFrame := func(event *Event) bool {
+		if "" == frame {
+			return event.PID == thread.PID
+		}
+		return frame == event.ArgString("frame") || frame == event.ArgString("data", "frame")
+	}
+
+	var fcp *Event
+	navigations := []*Event{}
+	candidates := []*Event{}
+	invalidations := []*Event{}
+	shifts := []*Event{}
+	for _, event := range trace.Events {
+		if !inFrame(event) {
+			continue
+		}
+		switch event.Name {
+		case "navigationStart":
+			if nil == event.Arg("data", "isLoadingMainFrame") || event.ArgBool("data", "isLoadingMainFrame") {
+				navigations = append(navigations, event)
+			}
+		case "firstContentfulPaint":
+			if nil == fcp || event.Timestamp < fcp.Timestamp {
+				fcp = event
+			}
+		case "largestContentfulPaint::Candidate":
+			candidates = append(candidates, event)
+		case "largestContentfulPaint::Invalidate":
+			invalidations = append(invalidations, event)
+		case "LayoutShift":
+			shifts = append(shifts, event)
+		}
+	}
+	sort.SliceStable(navigations, func(a, b int) bool {
+		return navigations[a].Timestamp < navigations[b].Timestamp
+	})
+
+	// Use the navigation that produced the first contentful paint.
+	var navigation *Event
+	for _, event := range navigations {
+		if nil != fcp && event.Timestamp > fcp.Timestamp {
+			break
+		}
+		if nil != fcp && "" != fcp.ArgString("data", "navigationId") &&
+			fcp.ArgString("data", "navigationId") != event.ArgString("data", "navigationId") {
+			continue
+		}
+		navigation = event
+	}
+	if nil != navigation {
+		report.NavigationStart = navigation.Timestamp
+		if url := navigation.ArgString("data", "documentLoaderURL"); "" != url {
+			report.URL = url
+		}
+	}
+	since := func(ts float64) time.Duration {
+		return Micros(ts - report.NavigationStart)
+	}
+
+	blockingStart := report.NavigationStart
+	if nil != fcp {
+		report.FirstContentfulPaint = since(fcp.Timestamp)
+		blockingStart = fcp.Timestamp
+	}
+	report.LargestContentfulPaint = largestContentfulPaint(candidates, invalidations, report.NavigationStart)
+	report.CumulativeLayoutShift = cumulativeLayoutShift(shifts, report.NavigationStart)
+
+	for _, slice := range thread.Slices {
+		if slice.End <= report.NavigationStart {
+			continue
+		}
+		if slice.Duration() > LongTaskThreshold {
+			report.LongTasks = append(report.LongTasks, &Task{
+				Name:     slice.Name,
+				Start:    since(slice.Start),
+				Duration: slice.Duration(),
+			})
+		}
+		blocking := Micros(slice.End-math.Max(slice.Start, blockingStart)) - LongTaskThreshold
+		if slice.Duration() > LongTaskThreshold && blocking > 0 {
+			report.TotalBlockingTime += blocking
+		}
+	}
+
+	thread.Walk(func(slice *Slice) {
+		if slice.Start < report.NavigationStart {
+			return
+		}
+		activity, ok := activities[slice.Name]
+		if !ok {
+			activity = ActivityOther
+		}
+		report.MainThread[activity] += slice.SelfTime()
+	})
+
+	return report, nil
+}
+
+/*
+mainThread finds the main frame ID and the renderer main thread of the
+traced page.
+*/
+func mainThread(trace *Trace, timeline *Timeline, report *Report) (string, *Thread) {
+	frameID := ""
+	for _, event := range trace.Events {
+		switch event.Name {
+		case "TracingStartedInBrowser":
+			frames, _ := event.Arg("data", "frames").([]interface{})
+			for _, data := range frames {
+				frame, ok := data.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if _, hasParent := frame["parent"]; hasParent {
+					continue
+				}
+				id, _ := frame["frame"].(string)
+				pid, _ := frame["processId"].(float64)
+				report.URL, _ = frame["url"].(string)
+				if process, ok := timeline.Processes[int(pid)]; ok {
+					for _, thread := range process.Threads {
+						if "CrRendererMain" == thread.Name {
+							return id, thread
+						}
+					}
+				}
+				frameID = id
+			}
+
+		case "TracingStartedInPage":
+			if process, ok := timeline.Processes[event.PID]; ok {
+				if thread, ok := process.Threads[event.TID]; ok {
+					return event.ArgString("data", "page"), thread
+				}
+			}
+		}
+	}
+
+	// Fall back to the busiest renderer main thread.
+	var busiest *Thread
+	for _, thread := range timeline.Threads("CrRendererMain") {
+		if nil == busiest || len(thread.Slices) > len(busiest.Slices) {
+			busiest = thread
+		}
+	}
+	return frameID, busiest
+}
+
+/*
+largestContentfulPaint returns the timestamp of the last LCP candidate
+relative to navigation start, or 0 if the candidate was invalidated. Events
+of previous navigations are ignored.
+*/
+func largestContentfulPaint(candidates, invalidations []*Event, navigationStart float64) time.Duration {
+	var last *Event
+	for _, event := range candidates {
+		if event.Timestamp < navigationStart {
+			continue
+		}
+		if nil == last ||
+			event.ArgFloat("data", "candidateIndex") > last.ArgFloat("data", "candidateIndex") ||
+			(event.ArgFloat("data", "candidateIndex") == last.ArgFloat("data", "candidateIndex") && event.Timestamp > last.Timestamp) {
+			last = event
+		}
+	}
+	if nil == last {
+		return 0
+	}
+	for _, event := range invalidations {
+		if event.Timestamp < navigationStart {
+			continue
+		}
+		if event.Timestamp > last.Timestamp {
+			return 0
+		}
+	}
+	return Micros(last.Timestamp - navigationStart)
+}
+
+/*
+cumulativeLayoutShift returns the largest session window score of the layout
+shifts. Session windows end after a 1 second gap or after 5 seconds.
+*/
+func cumulativeLayoutShift(shifts []*Event, navigationStart float64) float64 {
+	sort.SliceStable(shifts, func(a, b int) bool {
+		return shifts[a].Timestamp < shifts[b].Timestamp
+	})
+
+	var max, score, windowStart, last float64
+	for _, event := range shifts {
+		if event.Timestamp < navigationStart || event.ArgBool("data", "had_recent_input") {
+			continue
+		}
+		value := event.ArgFloat("data", "weighted_score_delta")
+		if nil == event.Arg("data", "weighted_score_delta") {
+			value = event.ArgFloat("data", "score")
+		}
+		if 0 == score ||
+			event.Timestamp-last > float64(time.Second/time.Microsecond) ||
+			event.Timestamp-windowStart > float64(5*time.Second/time.Microsecond) {
+			score = 0
+			windowStart = event.Timestamp
+		}
+		score += value
+		last = event.Timestamp
+		max = math.Max(max, score)
+	}
+	return max
+}
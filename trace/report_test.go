@@ -0,0 +1,110 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var reportTrace = `{"traceEvents": [
+	{"name": "thread_name", "ph": "M", "pid": 10, "tid": 1, "args": {"name": "CrRendererMain"}},
+	{"name": "thread_name", "ph": "M", "pid": 20, "tid": 1, "args": {"name": "CrRendererMain"}},
+	{"name": "TracingStartedInBrowser", "cat": "disabled-by-default-devtools.timeline", "ph": "I", "ts": 1000, "pid": 1, "tid": 1,
+		"args": {"data": {"frames": [
+			{"frame": "MAIN", "url": "https://example.com/", "processId": 10},
+			{"frame": "CHILD", "url": "https://ads.example.com/", "processId": 20, "parent": "MAIN"}
+		]}}},
+	{"name": "largestContentfulPaint::Invalidate", "cat": "loading", "ph": "R", "ts": 1500, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {}}},
+	{"name": "navigationStart", "cat": "blink.user_timing", "ph": "R", "ts": 2000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"documentLoaderURL": "https://example.com/", "isLoadingMainFrame": true, "navigationId": "NAV"}}},
+	{"name": "firstContentfulPaint", "cat": "loading", "ph": "R", "ts": 302000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"navigationId": "NAV"}}},
+	{"name": "firstContentfulPaint", "cat": "loading", "ph": "R", "ts": 102000, "pid": 20, "tid": 1,
+		"args": {"frame": "CHILD"}},
+	{"name": "largestContentfulPaint::Candidate", "cat": "loading", "ph": "R", "ts": 402000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"candidateIndex": 1, "size": 100}}},
+	{"name": "largestContentfulPaint::Candidate", "cat": "loading", "ph": "R", "ts": 802000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"candidateIndex": 2, "size": 1000}}},
+	{"name": "LayoutShift", "cat": "loading", "ph": "I", "ts": 500000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"score": 0.1, "weighted_score_delta": 0.1, "had_recent_input": false}}},
+	{"name": "LayoutShift", "cat": "loading", "ph": "I", "ts": 900000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"score": 0.05, "weighted_score_delta": 0.05, "had_recent_input": false}}},
+	{"name": "LayoutShift", "cat": "loading", "ph": "I", "ts": 950000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"score": 0.5, "weighted_score_delta": 0.5, "had_recent_input": true}}},
+	{"name": "LayoutShift", "cat": "loading", "ph": "I", "ts": 3000000, "pid": 10, "tid": 1,
+		"args": {"frame": "MAIN", "data": {"score": 0.12, "weighted_score_delta": 0.12, "had_recent_input": false}}},
+	{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 10000, "dur": 120000, "pid": 10, "tid": 1},
+	{"name": "EvaluateScript", "cat": "devtools.timeline", "ph": "X", "ts": 10000, "dur": 100000, "pid": 10, "tid": 1},
+	{"name": "Layout", "cat": "devtools.timeline", "ph": "X", "ts": 110000, "dur": 10000, "pid": 10, "tid": 1},
+	{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 282000, "dur": 70000, "pid": 10, "tid": 1},
+	{"name": "UpdateLayoutTree", "cat": "devtools.timeline", "ph": "X", "ts": 282000, "dur": 20000, "pid": 10, "tid": 1},
+	{"name": "Paint", "cat": "devtools.timeline", "ph": "X", "ts": 302000, "dur": 10000, "pid": 10, "tid": 1},
+	{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 402000, "dur": 30000, "pid": 10, "tid": 1},
+	{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 502000, "dur": 150000, "pid": 10, "tid": 1},
+	{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 502000, "dur": 150000, "pid": 20, "tid": 1}
+]}`
+
+func TestAnalyze(t *testing.T) {
+	trace, err := Parse(strings.NewReader(reportTrace))
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	report, err := Analyze(trace)
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+
+	if 10 != report.PID || 1 != report.TID {
+		t.Errorf("Expected main thread 10:1, received %d:%d", report.PID, report.TID)
+	}
+	if "https://example.com/" != report.URL {
+		t.Errorf("Expected 'https://example.com/', received '%s'", report.URL)
+	}
+	if 2000 != report.NavigationStart {
+		t.Errorf("Expected navigation start 2000, received %f", report.NavigationStart)
+	}
+	if 300*time.Millisecond != report.FirstContentfulPaint {
+		t.Errorf("Expected FCP 300ms, received %s", report.FirstContentfulPaint)
+	}
+	if 800*time.Millisecond != report.LargestContentfulPaint {
+		t.Errorf("Expected LCP 800ms, received %s", report.LargestContentfulPaint)
+	}
+	if 0.15 != float64(int(report.CumulativeLayoutShift*1000+0.5))/1000 {
+		t.Errorf("Expected CLS 0.15, received %f", report.CumulativeLayoutShift)
+	}
+
+	// Tasks: 120ms (before FCP), 70ms (straddles FCP by 50ms), 30ms, 150ms.
+	if 3 != len(report.LongTasks) {
+		t.Fatalf("Expected 3 long tasks, received %d", len(report.LongTasks))
+	}
+	if 500*time.Millisecond != report.LongTasks[2].Start {
+		t.Errorf("Expected task at 500ms, received %s", report.LongTasks[2].Start)
+	}
+	if 100*time.Millisecond != report.TotalBlockingTime {
+		t.Errorf("Expected TBT 100ms, received %s", report.TotalBlockingTime)
+	}
+
+	if 100*time.Millisecond != report.MainThread[ActivityScript] {
+		t.Errorf("Expected 100ms script, received %s", report.MainThread[ActivityScript])
+	}
+	if 10*time.Millisecond != report.MainThread[ActivityLayout] {
+		t.Errorf("Expected 10ms layout, received %s", report.MainThread[ActivityLayout])
+	}
+	if 20*time.Millisecond != report.MainThread[ActivityStyle] {
+		t.Errorf("Expected 20ms style, received %s", report.MainThread[ActivityStyle])
+	}
+	if 10*time.Millisecond != report.MainThread[ActivityPaint] {
+		t.Errorf("Expected 10ms paint, received %s", report.MainThread[ActivityPaint])
+	}
+	if 230*time.Millisecond != report.MainThread[ActivityOther] {
+		t.Errorf("Expected 230ms other, received %s", report.MainThread[ActivityOther])
+	}
+}
+
+func TestAnalyzeWithoutRenderer(t *testing.T) {
+	trace, _ := Parse(strings.NewReader(`[{"name": "a", "ph": "X", "ts": 1, "dur": 1, "pid": 1, "tid": 1}]`))
+	if _, err := Analyze(trace); nil == err {
+		t.Errorf("Expected error, received nil")
+	}
+}
@@ -0,0 +1,425 @@
+package trace
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+/*
+Timeline is the per-process and per-thread view of a trace.
+*/
+type Timeline struct {
+	// Start is the timestamp of the first event in microseconds.
+	Start float64
+
+	// End is the timestamp of the end of the last event in microseconds.
+	End float64
+
+	// Processes contains the traced processes by PID.
+	Processes map[int]*Process
+
+	// Async contains the async slices, sorted by start time.
+	Async []*AsyncSlice
+}
+
+/*
+Process is a traced process.
+*/
+type Process struct {
+	// PID is the process ID.
+	PID int
+
+	// Name is the process name from process_name metadata.
+	Name string
+
+	// Labels is the process label from process_labels metadata.
+	Labels string
+
+	// Threads contains the threads of the process by TID.
+	Threads map[int]*Thread
+
+	// Counters contains the counters of the process by name.
+	Counters map[string]*Counter
+}
+
+/*
+Thread is a traced thread.
+*/
+type Thread struct {
+	// PID is the process ID.
+	PID int
+
+	// TID is the thread ID.
+	TID int
+
+	// Name is the thread name from thread_name metadata.
+	Name string
+
+	// Slices contains the top-level slices, sorted by start time.
+	Slices []*Slice
+
+	// Instants contains the thread's instant and mark events.
+	Instants []*Event
+}
+
+/*
+Slice is a duration event on a thread. Slices nest according to their start
+and end times.
+*/
+type Slice struct {
+	// Event is the complete or begin event of the slice.
+	Event *Event
+
+	// Name of the slice.
+	Name string
+
+	// Start timestamp in microseconds.
+	Start float64
+
+	// End timestamp in microseconds.
+	End float64
+
+	// Depth is the nesting level, 0 for top-level slices.
+	Depth int
+
+	// Parent is the enclosing slice, nil for top-level slices.
+	Parent *Slice
+
+	// Children contains the nested slices, sorted by start time.
+	Children []*Slice
+
+	// Unfinished is true if no end event was found.
+	Unfinished bool
+}
+
+/*
+Duration returns the wall duration of the slice.
+*/
+func (slice *Slice) Duration() time.Duration {
+	return Micros(slice.End - slice.Start)
+}
+
+/*
+SelfTime returns the wall duration of the slice not spent in child slices.
+*/
+func (slice *Slice) SelfTime() time.Duration {
+	self := slice.End - slice.Start
+	for _, child := range slice.Children {
+		self -= child.End - child.Start
+	}
+	return Micros(math.Max(self, 0))
+}
+
+/*
+AsyncSlice is an async operation that may span threads.
+*/
+type AsyncSlice struct {
+	// Name of the async slice.
+	Name string
+
+	// Category of the async slice.
+	Category string
+
+	// ID is the key matching the begin and end events.
+	ID string
+
+	// Start timestamp in microseconds.
+	Start float64
+
+	// End timestamp in microseconds.
+	End float64
+
+	// Events contains the begin, step, instant and end events.
+	Events []*Event
+
+	// Unfinished is true if no end event was found.
+	Unfinished bool
+}
+
+/*
+Duration returns the wall duration of the async slice.
+*/
+func (slice *AsyncSlice) Duration() time.Duration {
+	return Micros(slice.End - slice.Start)
+}
+
+/*
+Counter is a named series of counter samples.
+*/
+type Counter struct {
+	// PID is the process ID.
+	PID int
+
+	// Name of the counter.
+	Name string
+
+	// Samples contains the counter values, sorted by time.
+	Samples []*CounterSample
+}
+
+/*
+CounterSample holds the values of a counter at a point in time.
+*/
+type CounterSample struct {
+	// Timestamp in microseconds.
+	Timestamp float64
+
+	// Values contains the series values by name.
+	Values map[string]float64
+}
+
+/*
+Timeline builds the per-thread timelines of the trace.
+*/
+func (trace *Trace) Timeline() *Timeline {
+	timeline := &Timeline{
+		Start:     math.Inf(1),
+		End:       math.Inf(-1),
+		Processes: map[int]*Process{},
+	}
+	events := make([]*Event, 0, len(trace.Events))
+	for _, event := range trace.Events {
+		if PhaseMetadata == event.Phase {
+			timeline.metadata(event)
+			continue
+		}
+		events = append(events, event)
+		timeline.Start = math.Min(timeline.Start, event.Timestamp)
+		timeline.End = math.Max(timeline.End, event.End())
+	}
+	if math.IsInf(timeline.Start, 0) {
+		timeline.Start, timeline.End = 0, 0
+	}
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].Timestamp < events[b].Timestamp
+	})
+
+	slices := map[*Thread][]*Slice{}
+	open := map[*Thread][]*Slice{}
+	async := map[string]*AsyncSlice{}
+	for _, event := range events {
+		switch event.Phase {
+		case PhaseComplete:
+			thread := timeline.thread(event.PID, event.TID)
+			slices[thread] = append(slices[thread], &Slice{
+				Event: event,
+				Name:  event.Name,
+				Start: event.Timestamp,
+				End:   event.End(),
+			})
+
+		case PhaseBegin:
+			thread := timeline.thread(event.PID, event.TID)
+			open[thread] = append(open[thread], &Slice{
+				Event:      event,
+				Name:       event.Name,
+				Start:      event.Timestamp,
+				Unfinished: true,
+			})
+
+		case PhaseEnd:
+			thread := timeline.thread(event.PID, event.TID)
+			stack := open[thread]
+			for a := len(stack) - 1; a >= 0; a-- {
+				if "" == event.Name || stack[a].Name == event.Name {
+					slice := stack[a]
+					slice.End = event.Timestamp
+					slice.Unfinished = false
+					slices[thread] = append(slices[thread], slice)
+					open[thread] = append(stack[:a], stack[a+1:]...)
+					break
+				}
+			}
+
+		case PhaseInstant, PhaseInstantLegacy, PhaseMark:
+			thread := timeline.thread(event.PID, event.TID)
+			thread.Instants = append(thread.Instants, event)
+
+		case PhaseCounter:
+			process := timeline.process(event.PID)
+			name := event.Name
+			if "" != event.ID {
+				name += "[" + string(event.ID) + "]"
+			}
+			counter, ok := process.Counters[name]
+			if !ok {
+				counter = &Counter{PID: event.PID, Name: name}
+				process.Counters[name] = counter
+			}
+			sample := &CounterSample{Timestamp: event.Timestamp, Values: map[string]float64{}}
+			for key, value := range event.Args {
+				if number, ok := value.(float64); ok {
+					sample.Values[key] = number
+				}
+			}
+			counter.Samples = append(counter.Samples, sample)
+
+		case PhaseAsyncBegin, PhaseAsyncStart:
+			key := event.AsyncID() + ":" + event.Name
+			slice := &AsyncSlice{
+				Name:       event.Name,
+				Category:   event.Cat,
+				ID:         event.AsyncID(),
+				Start:      event.Timestamp,
+				Events:     []*Event{event},
+				Unfinished: true,
+			}
+			async[key] = slice
+			timeline.Async = append(timeline.Async, slice)
+
+		case PhaseAsyncInstant, PhaseAsyncStep:
+			if slice, ok := async[event.AsyncID()+":"+event.Name]; ok {
+				slice.Events = append(slice.Events, event)
+			} else {
+				timeline.Async = append(timeline.Async, &AsyncSlice{
+					Name:     event.Name,
+					Category: event.Cat,
+					ID:       event.AsyncID(),
+					Start:    event.Timestamp,
+					End:      event.Timestamp,
+					Events:   []*Event{event},
+				})
+			}
+
+		case PhaseAsyncEnd, PhaseAsyncFinish:
+			key := event.AsyncID() + ":" + event.Name
+			if slice, ok := async[key]; ok {
+				slice.End = event.Timestamp
+				slice.Unfinished = false
+				slice.Events = append(slice.Events, event)
+				delete(async, key)
+			}
+		}
+	}
+
+	for thread, stack := range open {
+		for _, slice := range stack {
+			slice.End = timeline.End
+			slices[thread] = append(slices[thread], slice)
+		}
+	}
+	for _, slice := range async {
+		slice.End = timeline.End
+	}
+	for thread, list := range slices {
+		thread.Slices = nest(list)
+	}
+
+	return timeline
+}
+
+/*
+metadata applies process and thread metadata events.
+*/
+func (timeline *Timeline) metadata(event *Event) {
+	switch event.Name {
+	case "process_name":
+		timeline.process(event.PID).Name = event.ArgString("name")
+	case "process_labels":
+		timeline.process(event.PID).Labels = event.ArgString("labels")
+	case "thread_name":
+		timeline.thread(event.PID, event.TID).Name = event.ArgString("name")
+	}
+}
+
+/*
+process returns the process with the specified PID, creating it if needed.
+*/
+func (timeline *Timeline) process(pid int) *Process {
+	process, ok := timeline.Processes[pid]
+	if !ok {
+		process = &Process{
+			PID:      pid,
+			Threads:  map[int]*Thread{},
+			Counters: map[string]*Counter{},
+		}
+		timeline.Processes[pid] = process
+	}
+	return process
+}
+
+/*
+thread returns the thread with the specified PID and TID, creating it if
+needed.
+*/
+func (timeline *Timeline) thread(pid, tid int) *Thread {
+	process := timeline.process(pid)
+	thread, ok := process.Threads[tid]
+	if !ok {
+		thread = &Thread{PID: pid, TID: tid}
+		process.Threads[tid] = thread
+	}
+	return thread
+}
+
+/*
+Threads returns all threads with the specified name.
+*/
+func (timeline *Timeline) Threads(name string) []*Thread {
+	threads := []*Thread{}
+	for _, process := range timeline.Processes {
+		for _, thread := range process.Threads {
+			if name == thread.Name {
+				threads = append(threads, thread)
+			}
+		}
+	}
+	sort.Slice(threads, func(a, b int) bool {
+		if threads[a].PID == threads[b].PID {
+			return threads[a].TID < threads[b].TID
+		}
+		return threads[a].PID < threads[b].PID
+	})
+	return threads
+}
+
+/*
+Walk calls fn for every slice of the thread, parents before children.
+*/
+func (thread *Thread) Walk(fn func(slice *Slice)) {
+	var walk func(slices []*Slice)
+	walk = func(slices []*Slice) {
+		for _, slice := range slices {
+			fn(slice)
+			walk(slice.Children)
+		}
+	}
+	walk(thread.Slices)
+}
+
+/*
+nest builds the slice hierarchy of a thread and returns the top-level slices.
+Slices that start at the same time are ordered longest first so the enclosing
+slice becomes the parent.
+*/
+func nest(slices []*Slice) []*Slice {
+	sort.SliceStable(slices, func(a, b int) bool {
+		if slices[a].Start == slices[b].Start {
+			return slices[a].End > slices[b].End
+		}
+		return slices[a].Start < slices[b].Start
+	})
+
+	top := []*Slice{}
+	stack := []*Slice{}
+	for _, slice := range slices {
+		for len(stack) > 0 && stack[len(stack)-1].End <= slice.Start {
+			stack = stack[:len(stack)-1]
+		}
+		if 0 == len(stack) {
+			top = append(top, slice)
+		} else {
+			parent := stack[len(stack)-1]
+			if slice.End > parent.End {
+				// Improperly nested slices are clipped to their parent.
+				slice.End = parent.End
+			}
+			slice.Parent = parent
+			slice.Depth = parent.Depth + 1
+			parent.Children = append(parent.Children, slice)
+		}
+		stack = append(stack, slice)
+	}
+	return top
+}
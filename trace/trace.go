@@ -0,0 +1,130 @@
+/*
+Package trace parses Chrome trace-event JSON and analyses page timings.
+
+Traces recorded with Tab.Trace() or saved from the DevTools Performance panel
+can be parsed with Parse(). Both the JSON object format
+
+	{"traceEvents": [...], "metadata": {...}}
+
+and the JSON array format, including unterminated arrays, are supported.
+
+See https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+for the trace event format.
+*/
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+	"unicode"
+)
+
+/*
+Trace is a parsed trace-event document.
+*/
+type Trace struct {
+	// Events contains the trace events in the order they were recorded.
+	Events []*Event
+
+	// Metadata contains the optional metadata dictionary of the JSON object
+	// format.
+	Metadata map[string]interface{}
+}
+
+/*
+Parse reads a trace-event JSON document.
+*/
+func Parse(r io.Reader) (*Trace, error) {
+	trace := &Trace{}
+	reader := bufio.NewReader(r)
+
+	// The array format may be unterminated, terminate it before decoding.
+	for {
+		char, err := reader.Peek(1)
+		if nil != err {
+			return nil, fmt.Errorf("trace: could not read trace: %s", err)
+		}
+		if '[' == char[0] {
+			data, err := ioutil.ReadAll(reader)
+			if nil != err {
+				return nil, fmt.Errorf("trace: could not read trace: %s", err)
+			}
+			data = bytes.TrimRight(data, " \t\r\n,")
+			if !bytes.HasSuffix(data, []byte("]")) {
+				data = append(data, ']')
+			}
+			if err = json.Unmarshal(data, &trace.Events); nil != err {
+				return nil, fmt.Errorf("trace: invalid trace array: %s", err)
+			}
+			return trace, nil
+		}
+		if !unicode.IsSpace(rune(char[0])) {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); nil != err || json.Delim('{') != token {
+		return nil, fmt.Errorf("trace: expected a JSON object or array")
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if nil != err {
+			return nil, fmt.Errorf("trace: invalid trace object: %s", err)
+		}
+		switch key {
+		case "traceEvents":
+			if token, err := decoder.Token(); nil != err || json.Delim('[') != token {
+				return nil, fmt.Errorf("trace: traceEvents is not an array")
+			}
+			for decoder.More() {
+				event := &Event{}
+				if err = decoder.Decode(event); nil != err {
+					return nil, fmt.Errorf("trace: invalid event #%d: %s", len(trace.Events), err)
+				}
+				trace.Events = append(trace.Events, event)
+			}
+			if _, err = decoder.Token(); nil != err {
+				return nil, fmt.Errorf("trace: unterminated traceEvents array: %s", err)
+			}
+		case "metadata":
+			if err = decoder.Decode(&trace.Metadata); nil != err {
+				return nil, fmt.Errorf("trace: invalid metadata: %s", err)
+			}
+		default:
+			var skip json.RawMessage
+			if err = decoder.Decode(&skip); nil != err {
+				return nil, fmt.Errorf("trace: invalid value for '%v': %s", key, err)
+			}
+		}
+	}
+
+	return trace, nil
+}
+
+/*
+ParseFile reads a trace-event JSON file.
+*/
+func ParseFile(path string) (*Trace, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+/*
+Micros converts a trace timestamp or duration in microseconds to a
+time.Duration.
+*/
+func Micros(us float64) time.Duration {
+	return time.Duration(us * float64(time.Microsecond))
+}
@@ -0,0 +1,137 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseObjectFormat(t *testing.T) {
+	trace, err := Parse(strings.NewReader(`{
+		"traceEvents": [
+			{"name": "thread_name", "ph": "M", "pid": 1, "tid": 2, "args": {"name": "CrRendererMain"}},
+			{"name": "RunTask", "cat": "toplevel", "ph": "X", "ts": 100, "dur": 50, "pid": 1, "tid": 2}
+		],
+		"metadata": {"cpu-brand": "test"},
+		"otherData": {"ignored": true}
+	}`))
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if 2 != len(trace.Events) {
+		t.Fatalf("Expected 2 events, received %d", len(trace.Events))
+	}
+	if "test" != trace.Metadata["cpu-brand"] {
+		t.Errorf("Expected metadata, received %v", trace.Metadata)
+	}
+	if 150 != trace.Events[1].End() {
+		t.Errorf("Expected end 150, received %f", trace.Events[1].End())
+	}
+}
+
+func TestParseArrayFormat(t *testing.T) {
+	for _, data := range []string{
+		`[{"name": "a", "ph": "I", "ts": 1, "pid": 1, "tid": 1}, {"name": "b", "ph": "I", "ts": 2, "pid": 1, "tid": 1}]`,
+		`[{"name": "a", "ph": "I", "ts": 1, "pid": 1, "tid": 1}, {"name": "b", "ph": "I", "ts": 2, "pid": 1, "tid": 1}`,
+		`[{"name": "a", "ph": "I", "ts": 1, "pid": 1, "tid": 1}, {"name": "b", "ph": "I", "ts": 2, "pid": 1, "tid": 1},`,
+	} {
+		trace, err := Parse(strings.NewReader(data))
+		if nil != err {
+			t.Errorf("Expected nil, received error: %v", err)
+			continue
+		}
+		if 2 != len(trace.Events) {
+			t.Errorf("Expected 2 events, received %d", len(trace.Events))
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(`"invalid"`)); nil == err {
+		t.Errorf("Expected error, received nil")
+	}
+}
+
+func TestEventIDs(t *testing.T) {
+	trace, err := Parse(strings.NewReader(`[
+		{"name": "a", "cat": "c", "ph": "b", "ts": 1, "pid": 1, "tid": 1, "id": 12},
+		{"name": "b", "cat": "c", "ph": "b", "ts": 1, "pid": 1, "tid": 1, "id": "0x1f"},
+		{"name": "c", "cat": "c", "ph": "b", "ts": 1, "pid": 1, "tid": 1, "id2": {"local": "0x2"}}
+	]`))
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+	if "12" != trace.Events[0].ID {
+		t.Errorf("Expected '12', received '%s'", trace.Events[0].ID)
+	}
+	if "0x1f" != trace.Events[1].ID {
+		t.Errorf("Expected '0x1f', received '%s'", trace.Events[1].ID)
+	}
+	if "c::1:0x2" != trace.Events[2].AsyncID() {
+		t.Errorf("Expected 'c::1:0x2', received '%s'", trace.Events[2].AsyncID())
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	trace, err := Parse(strings.NewReader(`[
+		{"name": "process_name", "ph": "M", "pid": 1, "tid": 0, "args": {"name": "Renderer"}},
+		{"name": "thread_name", "ph": "M", "pid": 1, "tid": 2, "args": {"name": "CrRendererMain"}},
+		{"name": "RunTask", "ph": "X", "ts": 100, "dur": 100, "pid": 1, "tid": 2},
+		{"name": "FunctionCall", "ph": "B", "ts": 110, "pid": 1, "tid": 2},
+		{"name": "Layout", "ph": "X", "ts": 120, "dur": 10, "pid": 1, "tid": 2},
+		{"name": "FunctionCall", "ph": "E", "ts": 150, "pid": 1, "tid": 2},
+		{"name": "Unfinished", "ph": "B", "ts": 300, "pid": 1, "tid": 2},
+		{"name": "Mark", "ph": "R", "ts": 105, "pid": 1, "tid": 2},
+		{"name": "JSHeap", "ph": "C", "ts": 100, "pid": 1, "tid": 2, "args": {"used": 10, "total": 20}},
+		{"name": "JSHeap", "ph": "C", "ts": 200, "pid": 1, "tid": 2, "args": {"used": 15, "total": 20}},
+		{"name": "Fetch", "cat": "net", "ph": "b", "ts": 100, "pid": 1, "tid": 2, "id": "1"},
+		{"name": "Fetch", "cat": "net", "ph": "n", "ts": 150, "pid": 1, "tid": 3, "id": "1"},
+		{"name": "Fetch", "cat": "net", "ph": "e", "ts": 400, "pid": 1, "tid": 3, "id": "1"}
+	]`))
+	if nil != err {
+		t.Fatalf("Expected nil, received error: %v", err)
+	}
+
+	timeline := trace.Timeline()
+	if 100 != timeline.Start || 400 != timeline.End {
+		t.Errorf("Expected 100-400, received %f-%f", timeline.Start, timeline.End)
+	}
+	if "Renderer" != timeline.Processes[1].Name {
+		t.Errorf("Expected 'Renderer', received '%s'", timeline.Processes[1].Name)
+	}
+
+	threads := timeline.Threads("CrRendererMain")
+	if 1 != len(threads) {
+		t.Fatalf("Expected 1 thread, received %d", len(threads))
+	}
+	thread := threads[0]
+	if 2 != len(thread.Slices) {
+		t.Fatalf("Expected 2 top-level slices, received %d", len(thread.Slices))
+	}
+	task := thread.Slices[0]
+	if 1 != len(task.Children) || "FunctionCall" != task.Children[0].Name {
+		t.Fatalf("Expected FunctionCall child, received %v", task.Children)
+	}
+	call := task.Children[0]
+	if 1 != call.Depth || 1 != len(call.Children) || 2 != call.Children[0].Depth {
+		t.Errorf("Expected nested Layout slice")
+	}
+	if 60000 != task.SelfTime().Nanoseconds() {
+		t.Errorf("Expected 60µs self time, received %s", task.SelfTime())
+	}
+	if !thread.Slices[1].Unfinished || 400 != thread.Slices[1].End {
+		t.Errorf("Expected unfinished slice ending at trace end")
+	}
+	if 1 != len(thread.Instants) {
+		t.Errorf("Expected 1 instant, received %d", len(thread.Instants))
+	}
+
+	counter := timeline.Processes[1].Counters["JSHeap"]
+	if nil == counter || 2 != len(counter.Samples) || 15 != counter.Samples[1].Values["used"] {
+		t.Errorf("Expected JSHeap counter samples, received %v", counter)
+	}
+
+	if 1 != len(timeline.Async) {
+		t.Fatalf("Expected 1 async slice, received %d", len(timeline.Async))
+	}
+	if 300 != timeline.Async[0].Duration().Nanoseconds()/1000 || 3 != len(timeline.Async[0].Events) {
+		t.Errorf("Expected 300µs async slice with 3 events, received %s", timeline.Async[0].Duration())
+	}
+}